@@ -3,9 +3,12 @@ package builtins_qlik
 import (
 	"encoding/json"
 	"fmt"
+	"path"
+	"regexp"
 
 	"go.uber.org/zap"
 	"sigs.k8s.io/kustomize/api/builtins_qlik/utils"
+	"sigs.k8s.io/kustomize/api/diagnostics"
 	"sigs.k8s.io/kustomize/api/filters/fieldspec"
 	"sigs.k8s.io/kustomize/api/resmap"
 	"sigs.k8s.io/kustomize/api/types"
@@ -22,13 +25,38 @@ type EnvVarType struct {
 	Delete    bool                   `json:"delete,omitempty" yaml:"delete,omitempty"`
 }
 
+// EnvFromType is an envFrom entry of a container, a ref to a ConfigMap or a Secret, the name
+// of which nameReference updates like the one of any envFrom entry. Delete deletes the entry
+// of the same ConfigMap or Secret.
+type EnvFromType struct {
+	Prefix       string                 `json:"prefix,omitempty" yaml:"prefix,omitempty"`
+	ConfigMapRef map[string]interface{} `json:"configMapRef,omitempty" yaml:"configMapRef,omitempty"`
+	SecretRef    map[string]interface{} `json:"secretRef,omitempty" yaml:"secretRef,omitempty"`
+	Delete       bool                   `json:"delete,omitempty" yaml:"delete,omitempty"`
+}
+
+const (
+	// EnvOrderDependencies moves the env vars referenced as $(VAR) in the value of
+	// another one before it, the order of the others is kept.
+	EnvOrderDependencies = "dependencies"
+)
+
 type EnvUpsertPlugin struct {
-	Enabled   bool            `json:"enabled,omitempty" yaml:"enabled,omitempty"`
-	Target    *types.Selector `json:"target,omitempty" yaml:"target,omitempty"`
-	Path      string          `json:"path,omitempty" yaml:"path,omitempty"`
-	EnvVars   []EnvVarType    `json:"env,omitempty" yaml:"env,omitempty"`
-	logger    *zap.SugaredLogger
-	fieldSpec types.FieldSpec
+	Enabled bool            `json:"enabled,omitempty" yaml:"enabled,omitempty"`
+	Target  *types.Selector `json:"target,omitempty" yaml:"target,omitempty"`
+	// Path is the env list to upsert into. Without a path, the containers, initContainers
+	// and ephemeralContainers of the pod templates of the targets are upserted into.
+	Path    string       `json:"path,omitempty" yaml:"path,omitempty"`
+	EnvVars []EnvVarType `json:"env,omitempty" yaml:"env,omitempty"`
+	// EnvFrom is upserted into the envFrom list of the containers, without a path only.
+	EnvFrom []EnvFromType `json:"envFrom,omitempty" yaml:"envFrom,omitempty"`
+	// Containers are shell patterns selecting containers by name, without a path only,
+	// all the containers when empty.
+	Containers  []string `json:"containers,omitempty" yaml:"containers,omitempty"`
+	Order       string   `json:"order,omitempty" yaml:"order,omitempty"`
+	logger      *zap.SugaredLogger
+	diagnostics *diagnostics.Reporter
+	fieldSpec   types.FieldSpec
 }
 
 func (p *EnvUpsertPlugin) Config(h *resmap.PluginHelpers, c []byte) (err error) {
@@ -36,6 +64,9 @@ func (p *EnvUpsertPlugin) Config(h *resmap.PluginHelpers, c []byte) (err error)
 	p.Target = nil
 	p.Path = ""
 	p.EnvVars = make([]EnvVarType, 0)
+	p.EnvFrom = nil
+	p.Containers = nil
+	p.Order = ""
 	err = yaml.Unmarshal(c, p)
 	if err != nil {
 		p.logger.Errorf("error unmarshalling config from yaml, error: %v\n", err)
@@ -56,10 +87,50 @@ func (p *EnvUpsertPlugin) Config(h *resmap.PluginHelpers, c []byte) (err error)
 			return err
 		}
 	}
+	for _, envFrom := range p.EnvFrom {
+		if _, _, err = envFromRef(envFrom); err != nil {
+			p.logger.Errorf("config error: %v\n", err)
+			return err
+		}
+	}
+	for _, pattern := range p.Containers {
+		if _, err = path.Match(pattern, ""); err != nil {
+			err = fmt.Errorf("invalid container name pattern: %v, error: %v", pattern, err)
+			p.logger.Errorf("config error: %v\n", err)
+			return err
+		}
+	}
+	if p.Path != "" && (len(p.EnvFrom) > 0 || len(p.Containers) > 0) {
+		err = fmt.Errorf("envFrom and containers can only be set without a path")
+		p.logger.Errorf("config error: %v\n", err)
+		return err
+	}
+	if p.Order != "" && p.Order != EnvOrderDependencies {
+		err = fmt.Errorf("unknown env order: %v, it can only be: %v", p.Order, EnvOrderDependencies)
+		p.logger.Errorf("config error: %v\n", err)
+		return err
+	}
 	p.fieldSpec = types.FieldSpec{Path: p.Path}
+	p.diagnostics = h.Diagnostics()
 	return nil
 }
 
+// envFromRef returns the kind, configMapRef or secretRef, and the name of the ref of envFrom.
+func envFromRef(envFrom EnvFromType) (string, string, error) {
+	kind, ref := "configMapRef", envFrom.ConfigMapRef
+	if envFrom.SecretRef != nil {
+		if ref != nil {
+			return "", "", fmt.Errorf("envFrom config has both a configMapRef and a secretRef: %v", envFrom)
+		}
+		kind, ref = "secretRef", envFrom.SecretRef
+	}
+	name, _ := ref["name"].(string)
+	if name == "" {
+		return "", "", fmt.Errorf("envFrom config has no configMapRef or secretRef name: %v", envFrom)
+	}
+	return kind, name, nil
+}
+
 func (p *EnvUpsertPlugin) Transform(m resmap.ResMap) error {
 	if p.Enabled {
 		resources, err := m.Select(*p.Target)
@@ -68,15 +139,28 @@ func (p *EnvUpsertPlugin) Transform(m resmap.ResMap) error {
 			p.logger.Errorf("error selecting resources based on the target selector, error: %v\n", err)
 			return err
 		}
+		if len(resources) == 0 {
+			p.diagnostics.Warnf(p.Target.String(), diagnostics.ReasonTargetNotFound, "target selector matched no resources")
+		}
+		selected := 0
 		for _, r := range resources {
-			err := filtersutil.ApplyToJSON(envUpsertFilter{
-				envVars:   p.EnvVars,
-				fieldSpec: p.fieldSpec,
-			}, r)
+			f := &envUpsertFilter{
+				envVars:    p.EnvVars,
+				envFrom:    p.EnvFrom,
+				containers: p.Containers,
+				order:      p.Order,
+				fieldSpec:  p.fieldSpec,
+			}
+			err := filtersutil.ApplyToJSON(f, r)
 			if err != nil {
 				p.logger.Errorf("error upserting env vars: %+v, error: %v\n", p.EnvVars, err)
 				return err
 			}
+			selected += f.selected
+		}
+		if p.Path == "" && len(resources) > 0 && selected == 0 {
+			p.diagnostics.Warnf(p.Target.String(), diagnostics.ReasonTargetNotFound,
+				"containers %v matched no container of the target resources", p.Containers)
 		}
 	}
 	return nil
@@ -87,13 +171,31 @@ func NewEnvUpsertPlugin() resmap.TransformerPlugin {
 }
 
 type envUpsertFilter struct {
-	fieldSpec types.FieldSpec
-	envVars   []EnvVarType
+	fieldSpec  types.FieldSpec
+	envVars    []EnvVarType
+	envFrom    []EnvFromType
+	containers []string
+	order      string
+	// selected counts the containers upserted into, without a path
+	selected int
+}
+
+// podSpecPaths are where the pod specs of workloads are, the pod itself,
+// the template of controllers, and the job template of a CronJob.
+var podSpecPaths = [][]string{
+	{"spec"},
+	{"spec", "template", "spec"},
+	{"spec", "jobTemplate", "spec", "template", "spec"},
 }
 
-func (f envUpsertFilter) Filter(nodes []*kyaml.RNode) ([]*kyaml.RNode, error) {
+var containerListFields = []string{"containers", "initContainers", "ephemeralContainers"}
+
+func (f *envUpsertFilter) Filter(nodes []*kyaml.RNode) ([]*kyaml.RNode, error) {
 	_, err := kio.FilterAll(kyaml.FilterFunc(
 		func(node *kyaml.RNode) (*kyaml.RNode, error) {
+			if f.fieldSpec.Path == "" {
+				return node, f.upsertContainers(node)
+			}
 			if err := node.PipeE(fieldspec.Filter{
 				FieldSpec: f.fieldSpec,
 				SetValue:  f.set,
@@ -105,6 +207,86 @@ func (f envUpsertFilter) Filter(nodes []*kyaml.RNode) ([]*kyaml.RNode, error) {
 	return nodes, err
 }
 
+// upsertContainers upserts into the env and envFrom of the selected containers of the pod specs of node.
+func (f *envUpsertFilter) upsertContainers(node *kyaml.RNode) error {
+	for _, podSpecPath := range podSpecPaths {
+		podSpec, err := node.Pipe(kyaml.Lookup(podSpecPath...))
+		if err != nil {
+			return err
+		}
+		if podSpec == nil {
+			continue
+		}
+		for _, field := range containerListFields {
+			containers, err := podSpec.Pipe(kyaml.Lookup(field))
+			if err != nil {
+				return err
+			}
+			if containers == nil {
+				continue
+			}
+			elements, err := containers.Elements()
+			if err != nil {
+				return err
+			}
+			for _, container := range elements {
+				if !f.selects(container) {
+					continue
+				}
+				f.selected++
+				if err := f.upsertList(container, "env", f.upsertEnvironmentVariables); err != nil {
+					return err
+				}
+				if err := f.upsertList(container, "envFrom", f.upsertEnvFrom); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (f *envUpsertFilter) selects(container *kyaml.RNode) bool {
+	if len(f.containers) == 0 {
+		return true
+	}
+	name, err := container.GetString("name")
+	if err != nil {
+		return false
+	}
+	for _, pattern := range f.containers {
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// upsertList replaces the list field of container with what upsert makes of it,
+// leaving an absent field absent when there is nothing to add.
+func (f *envUpsertFilter) upsertList(container *kyaml.RNode, field string, upsert func([]interface{}) []interface{}) error {
+	var list []interface{}
+	present := container.Field(field)
+	if present != nil {
+		if jsonBytes, err := present.Value.MarshalJSON(); err != nil {
+			return err
+		} else if err := json.Unmarshal(jsonBytes, &list); err != nil {
+			return err
+		}
+	}
+	changed := upsert(list)
+	if present == nil && len(changed) == 0 {
+		return nil
+	}
+	//we need this because rnode.UnmarshalJSON() cannot unmarshal JSON arrays:
+	tempMap := map[string]interface{}{"tmp": changed}
+	if tempMapRNode, err := utils.NewKyamlRNode(tempMap); err != nil {
+		return err
+	} else {
+		return container.PipeE(kyaml.SetField(field, tempMapRNode.Field("tmp").Value))
+	}
+}
+
 func (f *envUpsertFilter) set(node *kyaml.RNode) error {
 	var a []interface{}
 	if jsonBytes, err := node.MarshalJSON(); err != nil {
@@ -124,43 +306,146 @@ func (f *envUpsertFilter) set(node *kyaml.RNode) error {
 	return nil
 }
 
-func (f *envUpsertFilter) upsertEnvironmentVariables(in interface{}) interface{} {
-	presentEnvVars, ok := in.([]interface{})
-	if ok {
-		for _, envVar := range f.envVars {
-			foundMatching := false
-			for i := 0; i < len(presentEnvVars); i++ {
-				presentEnvVar, ok := presentEnvVars[i].(map[string]interface{})
+func (f *envUpsertFilter) upsertEnvironmentVariables(presentEnvVars []interface{}) []interface{} {
+	for _, envVar := range f.envVars {
+		foundMatching := false
+		for i := 0; i < len(presentEnvVars); i++ {
+			presentEnvVar, ok := presentEnvVars[i].(map[string]interface{})
+			if ok {
+				name, ok := presentEnvVar["name"].(string)
 				if ok {
-					name, ok := presentEnvVar["name"].(string)
-					if ok {
-						if name == *envVar.Name {
-							foundMatching = true
-							if envVar.Delete {
-								//delete:
-								presentEnvVars = append(presentEnvVars[:i], presentEnvVars[i+1:]...)
-								i--
-							} else {
-								//update:
-								f.setEnvVar(presentEnvVar, envVar)
-							}
-							break
+					if name == *envVar.Name {
+						foundMatching = true
+						if envVar.Delete {
+							//delete:
+							presentEnvVars = append(presentEnvVars[:i], presentEnvVars[i+1:]...)
+							i--
+						} else {
+							//update:
+							f.setEnvVar(presentEnvVar, envVar)
 						}
+						break
 					}
 				}
 			}
-			if !foundMatching && !envVar.Delete {
-				//insert:
-				newEnvVar := map[string]interface{}{
-					"name": *envVar.Name,
-				}
-				f.setEnvVar(newEnvVar, envVar)
-				presentEnvVars = append(presentEnvVars, newEnvVar)
+		}
+		if !foundMatching && !envVar.Delete {
+			//insert:
+			newEnvVar := map[string]interface{}{
+				"name": *envVar.Name,
+			}
+			f.setEnvVar(newEnvVar, envVar)
+			presentEnvVars = append(presentEnvVars, newEnvVar)
+		}
+	}
+	if f.order == EnvOrderDependencies {
+		return orderEnvVarsByDependencies(presentEnvVars)
+	}
+	return presentEnvVars
+}
+
+func (f *envUpsertFilter) upsertEnvFrom(presentEnvFrom []interface{}) []interface{} {
+	for _, envFrom := range f.envFrom {
+		kind, name, _ := envFromRef(envFrom)
+		foundMatching := false
+		for i := 0; i < len(presentEnvFrom); i++ {
+			present, ok := presentEnvFrom[i].(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if ref, ok := present[kind].(map[string]interface{}); !ok || ref["name"] != name {
+				continue
+			}
+			foundMatching = true
+			if envFrom.Delete {
+				presentEnvFrom = append(presentEnvFrom[:i], presentEnvFrom[i+1:]...)
+			} else {
+				presentEnvFrom[i] = newEnvFrom(envFrom)
 			}
+			break
 		}
-		return presentEnvVars
+		if !foundMatching && !envFrom.Delete {
+			presentEnvFrom = append(presentEnvFrom, newEnvFrom(envFrom))
+		}
+	}
+	return presentEnvFrom
+}
+
+func newEnvFrom(envFrom EnvFromType) map[string]interface{} {
+	entry := make(map[string]interface{})
+	if envFrom.Prefix != "" {
+		entry["prefix"] = envFrom.Prefix
+	}
+	if envFrom.ConfigMapRef != nil {
+		entry["configMapRef"] = envFrom.ConfigMapRef
+	} else {
+		entry["secretRef"] = envFrom.SecretRef
+	}
+	return entry
+}
+
+// envVarRefRegexp matches the $(VAR) references of env var values, and the $$ escapes.
+var envVarRefRegexp = regexp.MustCompile(`\$\$|\$\(([-._a-zA-Z][-._a-zA-Z0-9]*)\)`)
+
+// orderEnvVarsByDependencies moves the env vars referenced by the value of another one
+// before it, for kubernetes only expands references to the env vars defined before.
+// The order of the env vars is otherwise kept, and so is the one of cyclic references.
+func orderEnvVarsByDependencies(envVars []interface{}) []interface{} {
+	names := make(map[string]bool)
+	for _, envVar := range envVars {
+		if name, ok := envVarName(envVar); ok {
+			names[name] = true
+		}
+	}
+	dependencies := make([][]string, len(envVars))
+	for i, envVar := range envVars {
+		m, _ := envVar.(map[string]interface{})
+		value, _ := m["value"].(string)
+		self, _ := envVarName(envVar)
+		for _, match := range envVarRefRegexp.FindAllStringSubmatch(value, -1) {
+			if match[1] != "" && match[1] != self && names[match[1]] {
+				dependencies[i] = append(dependencies[i], match[1])
+			}
+		}
+	}
+	ordered := make([]interface{}, 0, len(envVars))
+	done := make([]bool, len(envVars))
+	defined := make(map[string]bool)
+	for len(ordered) < len(envVars) {
+		next := -1
+		for i := range envVars {
+			if done[i] {
+				continue
+			}
+			if next < 0 {
+				next = i
+			}
+			ready := true
+			for _, dependency := range dependencies[i] {
+				ready = ready && defined[dependency]
+			}
+			if ready {
+				next = i
+				break
+			}
+		}
+		// with a cycle, the first one left goes next
+		done[next] = true
+		ordered = append(ordered, envVars[next])
+		if name, ok := envVarName(envVars[next]); ok {
+			defined[name] = true
+		}
+	}
+	return ordered
+}
+
+func envVarName(envVar interface{}) (string, bool) {
+	m, ok := envVar.(map[string]interface{})
+	if !ok {
+		return "", false
 	}
-	return in
+	name, ok := m["name"].(string)
+	return name, ok
 }
 
 func (f *envUpsertFilter) setEnvVar(setEnvVar map[string]interface{}, fromEnvVar EnvVarType) {
@@ -522,6 +522,138 @@ fooSpec:
 				assert.Equal(t, "cadabra", abraEnvVar["value"].(string))
 			},
 		},
+		{
+			name: "pod template containers selected by name with envFrom",
+			pluginConfig: `
+apiVersion: qlik.com/v1
+kind: EnvUpsert
+metadata:
+  name: notImportantHere
+enabled: true
+target:
+  kind: Deployment|CronJob
+containers:
+- app*
+env:
+- name: FOO
+  value: foo
+envFrom:
+- configMapRef:
+    name: app-config
+- prefix: DB_
+  secretRef:
+    name: db-secret
+    optional: true
+- configMapRef:
+    name: old-config
+  delete: true
+`,
+			pluginInputResources: `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: some-deployment
+spec:
+  template:
+    spec:
+      initContainers:
+      - name: app-init
+      containers:
+      - name: app
+        envFrom:
+        - configMapRef:
+            name: old-config
+        - secretRef:
+            name: db-secret
+      - name: sidecar
+---
+apiVersion: batch/v1beta1
+kind: CronJob
+metadata:
+  name: some-cronjob
+spec:
+  jobTemplate:
+    spec:
+      template:
+        spec:
+          ephemeralContainers:
+          - name: app-debug
+          containers:
+          - name: sidecar
+`,
+			checkAssertions: func(t *testing.T, resMap resmap.ResMap) {
+				envFrom := []interface{}{
+					map[string]interface{}{"configMapRef": map[string]interface{}{"name": "app-config"}},
+					map[string]interface{}{"prefix": "DB_", "secretRef": map[string]interface{}{"name": "db-secret", "optional": true}},
+				}
+				env := []interface{}{map[string]interface{}{"name": "FOO", "value": "foo"}}
+				for container, expectedEnvFrom := range map[string][]interface{}{
+					"spec.template.spec.initContainers[0]": envFrom,
+					// db-secret is updated in place, app-config appended
+					"spec.template.spec.containers[0]": {envFrom[1], envFrom[0]},
+				} {
+					value, err := resMap.GetByIndex(0).GetFieldValue(container + ".env")
+					assert.NoError(t, err)
+					assert.Equal(t, env, value)
+					value, err = resMap.GetByIndex(0).GetFieldValue(container + ".envFrom")
+					assert.NoError(t, err)
+					assert.Equal(t, expectedEnvFrom, value)
+				}
+				value, err := resMap.GetByIndex(1).GetFieldValue("spec.jobTemplate.spec.template.spec.ephemeralContainers[0].envFrom")
+				assert.NoError(t, err)
+				assert.Equal(t, envFrom, value)
+
+				sidecar, err := resMap.GetByIndex(0).GetFieldValue("spec.template.spec.containers[1]")
+				assert.NoError(t, err)
+				assert.Equal(t, map[string]interface{}{"name": "sidecar"}, sidecar)
+				sidecar, err = resMap.GetByIndex(1).GetFieldValue("spec.jobTemplate.spec.template.spec.containers[0]")
+				assert.NoError(t, err)
+				assert.Equal(t, map[string]interface{}{"name": "sidecar"}, sidecar)
+			},
+		},
+		{
+			name: "ordered by dependencies",
+			pluginConfig: `
+apiVersion: qlik.com/v1
+kind: EnvUpsert
+metadata:
+  name: notImportantHere
+enabled: true
+target:
+  kind: Pod
+order: dependencies
+env:
+- name: URL
+  value: http://$(HOST):$(PORT)/$$(NOT_A_REF)
+- name: HOST
+  value: $(DOMAIN)
+- name: DOMAIN
+  value: example.com
+`,
+			pluginInputResources: `
+apiVersion: v1
+kind: Pod
+metadata:
+  name: some-pod
+spec:
+  containers:
+  - name: app
+    env:
+    - name: PORT
+      value: "8080"
+    - name: NOT_A_REF
+      value: $(URL)
+`,
+			checkAssertions: func(t *testing.T, resMap resmap.ResMap) {
+				envVars, err := resMap.GetByIndex(0).GetFieldValue("spec.containers[0].env")
+				assert.NoError(t, err)
+				var names []string
+				for _, envVar := range envVars.([]interface{}) {
+					names = append(names, envVar.(map[string]interface{})["name"].(string))
+				}
+				assert.Equal(t, []string{"PORT", "DOMAIN", "HOST", "URL", "NOT_A_REF"}, names)
+			},
+		},
 	}
 	for _, testCase := range testCases {
 		t.Run(testCase.name, func(t *testing.T) {
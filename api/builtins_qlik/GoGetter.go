@@ -2,6 +2,7 @@ package builtins_qlik
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io/ioutil"
 	"net/url"
@@ -9,40 +10,29 @@ import (
 	"os/exec"
 	"path/filepath"
 	"reflect"
-	"regexp"
 	"runtime"
 	"strconv"
 	"strings"
+	"sync"
 	"syscall"
 
 	"github.com/cnf/structhash"
 	version "github.com/hashicorp/go-version"
 	"github.com/traefik/yaegi/interp"
-	"github.com/traefik/yaegi/stdlib"
 	"go.uber.org/zap"
 	"sigs.k8s.io/kustomize/api/builtins_qlik/utils"
 	"sigs.k8s.io/kustomize/api/builtins_qlik/yaegi/yamlv3"
+	"sigs.k8s.io/kustomize/api/diagnostics"
 	"sigs.k8s.io/kustomize/api/filesys"
 	"sigs.k8s.io/kustomize/api/ifc"
+	"sigs.k8s.io/kustomize/api/internal/git"
 	"sigs.k8s.io/kustomize/api/konfig"
+	"sigs.k8s.io/kustomize/api/provenance"
 	"sigs.k8s.io/kustomize/api/resmap"
 	"sigs.k8s.io/kustomize/api/types"
 	"sigs.k8s.io/yaml"
 )
 
-type iExecutableResolverT interface {
-	Executable() (string, error)
-}
-
-type osExecutableResolverT struct {
-}
-
-func (r *osExecutableResolverT) Executable() (string, error) {
-	return os.Executable()
-}
-
-var defaultBranchRegexp = regexp.MustCompile(`\s->\sorigin/(.*)`)
-
 // GoGetterPlugin ...
 type GoGetterPlugin struct {
 	types.ObjectMeta `json:"metadata,omitempty" yaml:"metadata,omitempty" protobuf:"bytes,1,opt,name=metadata"`
@@ -58,7 +48,11 @@ type GoGetterPlugin struct {
 	PostBuildArgs       []string `json:"postBuildArgs,omitempty" yaml:"postBuildArgs,omitempty" hash:"-"`
 	PostBuildScript     string   `json:"postBuildScript,omitempty" yaml:"postBuildScript,omitempty" hash:"-"`
 	PostBuildScriptFile string   `json:"postBuildScriptFile,omitempty" yaml:"postBuildScriptFile,omitempty" hash:"-"`
-	PartialCloneDir     string   `json:"partialCloneDir,omitempty" yaml:"partialCloneDir,omitempty"`
+	// Capabilities opt the pre and post build scripts into more than read-only access to the repository,
+	// see utils.YaegiSandbox
+	Capabilities    []string `json:"capabilities,omitempty" yaml:"capabilities,omitempty" hash:"-"`
+	ScriptTimeout   string   `json:"scriptTimeout,omitempty" yaml:"scriptTimeout,omitempty" hash:"-"`
+	PartialCloneDir string   `json:"partialCloneDir,omitempty" yaml:"partialCloneDir,omitempty"`
 	/* CloneFilter
 	   The best filter would likely be the unsupported as of 2.30 "combine:blob:none+tree:0"
 	   Therefor a filter must be chosen.
@@ -80,13 +74,27 @@ type GoGetterPlugin struct {
 		 git sparse-checkout set manifests ; \
 		 git checkout"
 	*/
-	CloneFilter        string               `json:"cloneFilter,omitempty" yaml:"cloneFilter,omitempty hash:"-"`
-	Pwd                string               `hash:"-"`
-	ldr                ifc.Loader           `hash:"-"`
-	rf                 *resmap.Factory      `hash:"-"`
-	logger             *zap.SugaredLogger   `hash:"-"`
-	executableResolver iExecutableResolverT `hash:"-"`
-	yamlBytes          []byte               `hash:"-"`
+	CloneFilter string `json:"cloneFilter,omitempty" yaml:"cloneFilter,omitempty" hash:"-"`
+	// Depth, when positive, makes a shallow clone with that many commits of history.
+	// Plugins needing the history of the component, e.g. GitImageTag, need a full clone.
+	Depth         int                         `json:"depth,omitempty" yaml:"depth,omitempty" hash:"-"`
+	Pwd           string                      `hash:"-"`
+	ldr           ifc.Loader                  `hash:"-"`
+	rf            *resmap.Factory             `hash:"-"`
+	logger        *zap.SugaredLogger          `hash:"-"`
+	nestedBuilder resmap.NestedBuilder        `hash:"-"`
+	lookupEnv     func(string) (string, bool) `hash:"-"`
+	yamlBytes     []byte                      `hash:"-"`
+	mirrorConfig  types.MirrorConfig          `hash:"-"`
+	gitBackend    types.GitBackend            `hash:"-"`
+	lockfile      ifc.Lockfile                `hash:"-"`
+	inputs        *provenance.Recorder        `hash:"-"`
+	diagnostics   *diagnostics.Reporter       `hash:"-"`
+	cloneDirOnce  sync.Once                   `hash:"-"`
+	dir           string                      `hash:"-"`
+	nogit         bool                        `hash:"-"`
+	dirErr        error                       `hash:"-"`
+	checkout      int                         `hash:"-"`
 }
 
 // Config ...
@@ -95,19 +103,108 @@ func (p *GoGetterPlugin) Config(h *resmap.PluginHelpers, c []byte) (err error) {
 	p.rf = h.ResmapFactory()
 	p.Pwd = h.Loader().Root()
 	p.yamlBytes = c
+	p.mirrorConfig = utils.GetMirrorConfig(h.GeneralConfig())
+	if h.GeneralConfig() != nil {
+		p.gitBackend = h.GeneralConfig().GitBackend
+	}
+	p.lockfile = h.Lockfile()
+	p.inputs = h.Inputs()
+	p.diagnostics = h.Diagnostics()
+	p.nestedBuilder = h.NestedBuilder()
+	p.lookupEnv = h.LookupEnv
 	return yaml.Unmarshal(c, p)
 }
 
 // Generate ...
 func (p *GoGetterPlugin) Generate() (resmap.ResMap, error) {
-	var nogit bool
-	var dir string
-	var err error
+	if p.nestedBuilder == nil {
+		err := fmt.Errorf("GoGetter %v can only be run as part of a kustomize build", p.ObjectMeta.Name)
+		p.logger.Errorf("Error: %v\n", err)
+		return nil, err
+	}
+	dir, nogit, err := p.cloneDir()
+	if err != nil {
+		return nil, err
+	}
+	cwd := dir
+	if len(p.Cwd) > 0 {
+		cwd = filepath.Join(dir, filepath.FromSlash(p.Cwd))
+	}
+
+	// The same component fetched and built more than once in a run, e.g. by several
+	// overlays, is only fetched and built the first time
+	memoKey := fmt.Sprintf("GoGetter\x00%s\x00%s\x00%x", p.URL, cwd, structhash.Md5(p, 1))
+	p.logger.Debugf("Build memo key: %q", memoKey)
+	built, err := p.nestedBuilder.Memo(memoKey, func(nb resmap.NestedBuilder) (interface{}, error) {
+		if !nogit {
+			if isLastCheckout(dir, p.checkout) {
+				p.logger.Infof("Using %v as prefetched", dir)
+			} else if err := p.lockedFetch(dir); err != nil {
+				return nil, err
+			}
+		} else {
+			p.logger.Infof("Using %v as is, NOT pulling from git", dir)
+		}
+		return p.build(nb, dir, cwd)
+	})
+	if err != nil {
+		return nil, err
+	}
+	kustBytes := built.([]byte)
+
+	if len(p.PostBuildScript) > 0 || len(p.PostBuildScriptFile) > 0 {
+		var (
+			gogetter = interp.Exports{
+				"gogetter": map[string]reflect.Value{
+					"GetKustomizedYaml": reflect.ValueOf(func() []byte {
+						return kustBytes
+					}),
+					"GetGoGetter": reflect.ValueOf(func() []byte {
+						return p.yamlBytes
+					}),
+				},
+			}
+		)
+
+		sandbox, err := p.evalScript(dir, cwd, p.PostBuildScript, p.PostBuildScriptFile, gogetter)
+		if err != nil {
+			return nil, err
+		}
+		ret, err := sandbox.Call("kust.PostBuild", p.PostBuildArgs, 1)
+		if err != nil {
+			p.logger.Errorf("Error from post-Build: %v\n", err)
+			return nil, err
+		}
+		postBuildRet, ok := ret[0].(*string)
+		if !ok && ret[0] != nil {
+			err = fmt.Errorf("kust.PostBuild must return (*string, error)")
+			p.logger.Errorf("Go Script Error: %v\n", err)
+			return nil, err
+		}
+		if postBuildRet != nil {
+			kustBytes = []byte(*postBuildRet)
+		}
+
+	}
+	return p.rf.NewResMapFromBytes(kustBytes)
+}
 
-	if dir, nogit = os.LookupEnv("KUZ_COMMON_" + p.ObjectMeta.Name); nogit {
+// cloneDir returns the directory the component is cloned into, or,
+// with nogit, the directory of a common component to use as is.
+func (p *GoGetterPlugin) cloneDir() (dir string, nogit bool, err error) {
+	p.cloneDirOnce.Do(func() {
+		p.dir, p.nogit, p.dirErr = p.resolveCloneDir()
+	})
+	return p.dir, p.nogit, p.dirErr
+}
+
+func (p *GoGetterPlugin) resolveCloneDir() (dir string, nogit bool, err error) {
+	if dir, nogit = p.lookupEnv("KUZ_COMMON_" + p.ObjectMeta.Name); nogit {
 		_, err := os.Stat(dir)
 		if err != nil {
 			p.logger.Warnf("component %v should of been cloned into %v prior to build, proceeding without", p.ObjectMeta.Name, dir)
+			p.diagnostics.Warnf(dir, diagnostics.ReasonObjRefNotFound,
+				"KUZ_COMMON_%v is not a directory, cloning the component instead", p.ObjectMeta.Name)
 			nogit = false
 		} else {
 			p.logger.Infof("component %v will use %v and not clone/update using git", p.ObjectMeta.Name, dir)
@@ -128,154 +225,126 @@ func (p *GoGetterPlugin) Generate() (resmap.ResMap, error) {
 		}
 
 		repodir := filepath.Join(dir, "qlik", "v1", "repos")
-		// Same repo in the same run, used cached
 		if err := os.MkdirAll(repodir, 0777); err != nil {
 			p.logger.Errorf("error creating directory: %v, error: %v", dir, err)
-			return nil, err
+			return "", false, err
 		}
 		dir = filepath.Join(repodir, p.ObjectMeta.Name)
 	}
-	var kustBytes []byte
-	var cacheFileName string
-
-	runIDVar := strconv.Itoa(os.Getppid()) + "_KUZ_RUN_ID"
-	p.logger.Debugf("Cache Identifier: %v", runIDVar)
-	runID := os.Getenv(runIDVar)
+	return dir, nogit, nil
+}
 
-	// I'm the parent
-	if len(runID) == 0 {
-		runID = strconv.Itoa(os.Getpid())
-		p.logger.Debugf("Cache Identifier not set, using pid: %v", runID)
-	} else {
-		p.logger.Debugf("Cache Identifier set, using: %v", runID)
-	}
-	cacheDirName := filepath.Join(dir, ".kuz_"+runID)
-	// Clean up and old .kuz_*
-	files, _ := filepath.Glob(filepath.Join(dir, ".kuz_*"))
-	for _, f := range files {
-		if f != cacheDirName {
-			os.RemoveAll(f)
-		}
+// PrefetchKey is the directory the component is cloned into,
+// empty for a common component used as is.
+func (p *GoGetterPlugin) PrefetchKey() string {
+	if p.nestedBuilder == nil {
+		return ""
 	}
-
-	cacheFileName = filepath.Join(cacheDirName, fmt.Sprintf("%x", structhash.Md5(p, 1)))
-	p.logger.Debugf("Using Cache file name: %v", cacheFileName)
-	_, err = os.Stat(cacheDirName)
-	if err == nil {
-		p.logger.Infof("Cache Exists, NOT pulling from git")
-		nogit = true
-		_, err = os.Stat(cacheFileName)
-		if err == nil {
-			kustBytes, _ = ioutil.ReadFile(cacheFileName)
-		}
+	if dir, nogit, err := p.cloneDir(); err == nil && !nogit {
+		return dir
 	}
+	return ""
+}
 
-	if !nogit {
-		// We usually only fetch a branch at a time
-		p.logger.Infof("Using git reference: %v", p.URL)
-		url, err := url.Parse(p.URL)
-		if err != nil {
-			p.logger.Errorf("Bad git URL %v\n", err)
-			return nil, err
-		}
-		url.Scheme = "https"
-		if err := p.executeGitGetter(url, dir); err != nil {
-			p.logger.Errorf("Error fetching repository: %v\n", err)
-			return nil, err
-		}
-		os.MkdirAll(cacheDirName, 0777)
+// Prefetch clones, or updates, the component ahead of Generate.
+func (p *GoGetterPlugin) Prefetch() error {
+	dir, nogit, err := p.cloneDir()
+	if err != nil || nogit {
+		return err
 	}
-	if kustBytes == nil {
-		currentExe, err := p.executableResolver.Executable()
-		if err != nil {
-			p.logger.Errorf("Unable to get kustomize executable: %v\n", err)
-			return nil, err
-		}
+	return p.lockedFetch(dir)
+}
 
-		cwd := dir
-		if len(p.Cwd) > 0 {
-			cwd = filepath.Join(dir, filepath.FromSlash(p.Cwd))
-		}
-		// Convert to relative path due to kustomize bug with drive letters
-		// thinks its a remote ref
-		oswd, _ := os.Getwd()
-		err = os.Chdir(cwd)
-		defer os.Chdir(oswd)
-		if err != nil {
-			p.logger.Errorf("Error: Unable to set working dir %v: %v\n", cwd, err)
-			return nil, err
-		}
+// checkouts records the last fetch into each clone directory of the
+// process, for Generate to tell whether the one of Prefetch is current.
+var checkouts = struct {
+	sync.Mutex
+	n    int
+	last map[string]int
+}{last: make(map[string]int)}
+
+func recordCheckout(dir string) int {
+	checkouts.Lock()
+	defer checkouts.Unlock()
+	checkouts.n++
+	checkouts.last[dir] = checkouts.n
+	return checkouts.n
+}
 
-		if len(p.PreBuildScript) > 0 || len(p.PreBuildScriptFile) > 0 {
-			var (
-				gogetter = interp.Exports{
-					"gogetter": map[string]reflect.Value{
-						"GetKustomizedYaml": reflect.ValueOf(func() []byte {
-							return nil
-						}),
-						"GetGoGetter": reflect.ValueOf(func() []byte {
-							return p.yamlBytes
-						}),
-					},
-				}
-			)
-
-			i := interp.New(interp.Options{})
-
-			i.Use(stdlib.Symbols)
-			i.Use(yamlv3.Symbols)
-			i.Use(gogetter)
-
-			if len(p.PreBuildScript) > 0 {
-				_, err = i.Eval(p.PreBuildScript)
-			} else {
-				var gocode []byte
-				gocode, err = ioutil.ReadFile(p.PreBuildScriptFile)
-				if err != nil {
-					p.logger.Errorf("Error loading go file: %v\n", err)
-					return nil, err
-				}
-				_, err = i.Eval(string(gocode))
-			}
-			if err != nil {
-				p.logger.Errorf("Go Script Error: %v\n", err)
-				return nil, err
-			}
-			v, err := i.Eval("kust.PreBuild")
-			if err != nil {
-				p.logger.Errorf("Go Script Error: %v\n", err)
-				return nil, err
-			}
-			preBuild := v.Interface().(func([]string) error)
-			err = preBuild(p.PreBuildArgs)
-			if err != nil {
-				p.logger.Errorf("Error from pre-Build: %v\n", err)
-				return nil, err
-			}
+func isLastCheckout(dir string, checkout int) bool {
+	checkouts.Lock()
+	defer checkouts.Unlock()
+	return checkout != 0 && checkouts.last[dir] == checkout
+}
+
+// lockedFetch fetches into dir holding a lock on it, other
+// processes and generators could be fetching into it too.
+func (p *GoGetterPlugin) lockedFetch(dir string) error {
+	unlockFn, err := utils.LockPath(dir+".flock", utils.DefaultLockTimeoutSeconds,
+		utils.DefaultLockRetryDelayMinMilliSeconds, utils.DefaultLockRetryDelayMaxMilliSeconds, p.logger)
+	if err != nil {
+		p.logger.Errorf("error locking directory: %v, error: %v", dir, err)
+		return err
+	}
+	defer unlockFn()
+	if err := p.fetch(dir); err != nil {
+		return err
+	}
+	p.checkout = recordCheckout(dir)
+	return nil
+}
+
+func (p *GoGetterPlugin) fetch(dir string) error {
+	// We usually only fetch a branch at a time
+	p.logger.Infof("Using git reference: %v", p.URL)
+	url, err := url.Parse(p.URL)
+	if err != nil {
+		p.logger.Errorf("Bad git URL %v\n", err)
+		return err
+	}
+	url.Scheme = "https"
+	repoURL, ref := *url, url.Query().Get("ref")
+	repoURL.RawQuery = ""
+	if p.lockfile != nil {
+		if commit, locked := p.lockfile.LockedGit(repoURL.String(), ref); locked {
+			p.logger.Infof("Using commit %v pinned in %v for ref %v", commit, p.lockfile.Path(), ref)
+			q := url.Query()
+			q.Set("ref", commit)
+			url.RawQuery = q.Encode()
 		}
-		var kustomizedYaml bytes.Buffer
-		cmd := exec.Command(currentExe, "build", ".")
-		cmd.Env = os.Environ()
-		envVar := fmt.Sprintf("%d_KUZ_RUN_ID=%s", os.Getpid(), runID)
-		p.logger.Debugf("Setting Cache Var for kustomize run: %v\n", envVar)
-		cmd.Env = append(cmd.Env, envVar)
-		for _, commonComponent := range p.CommonComponents {
-			cmd.Env = append(cmd.Env, fmt.Sprintf("KUZ_COMMON_%s=%s", commonComponent.Name, commonComponent.Path))
+	}
+	if p.mirrorConfig.Offline {
+		if url, err = utils.ResolveGitFromMirror(p.mirrorConfig.GitMirror, url, url.Query().Get("ref")); err != nil {
+			p.logger.Errorf("Error resolving repository from mirror: %v\n", err)
+			return err
 		}
-		err = p.getRunCommand(cmd, &kustomizedYaml)
-		if err != nil {
-			p.logger.Errorf("Error executing kustomize as a child process: %v\n", err)
-			return nil, err
+		p.logger.Infof("Offline mode, using mirrored repository: %v", url.String())
+	} else if p.mirrorConfig.Vendor && p.mirrorConfig.GitMirror != "" {
+		if err := utils.StoreGitInMirror(p.mirrorConfig.GitMirror, url, p.logger); err != nil {
+			p.logger.Errorf("Error vendoring repository: %v\n", err)
+			return err
 		}
-		kustBytes = kustomizedYaml.Bytes()
-		ioutil.WriteFile(cacheFileName, kustBytes, 0666)
 	}
-	if len(p.PostBuildScript) > 0 || len(p.PostBuildScriptFile) > 0 {
+	if err := p.GoGit(url, dir); err != nil {
+		p.logger.Errorf("Error fetching repository: %v\n", err)
+		return err
+	}
+	if err := p.lockCommit(dir, repoURL.String(), ref); err != nil {
+		p.logger.Errorf("Error recording commit in lock file: %v\n", err)
+		return err
+	}
+	return nil
+}
+
+// build runs the pre-build script, if any, then builds the kustomization in cwd
+// in-process with nb, with the common components handed down to its GoGetters.
+func (p *GoGetterPlugin) build(nb resmap.NestedBuilder, dir, cwd string) ([]byte, error) {
+	if len(p.PreBuildScript) > 0 || len(p.PreBuildScriptFile) > 0 {
 		var (
 			gogetter = interp.Exports{
 				"gogetter": map[string]reflect.Value{
 					"GetKustomizedYaml": reflect.ValueOf(func() []byte {
-						return kustBytes
+						return nil
 					}),
 					"GetGoGetter": reflect.ValueOf(func() []byte {
 						return p.yamlBytes
@@ -284,48 +353,73 @@ func (p *GoGetterPlugin) Generate() (resmap.ResMap, error) {
 			}
 		)
 
-		i := interp.New(interp.Options{})
-
-		i.Use(stdlib.Symbols)
-		i.Use(yamlv3.Symbols)
-		i.Use(gogetter)
-
-		if len(p.PostBuildScript) > 0 {
-			_, err = i.Eval(p.PostBuildScript)
-		} else {
-			var gocode []byte
-			gocode, err = ioutil.ReadFile(p.PostBuildScriptFile)
-			if err != nil {
-				p.logger.Errorf("Error loading go file: %v\n", err)
-				return nil, err
-			}
-			_, err = i.Eval(string(gocode))
-		}
+		sandbox, err := p.evalScript(dir, cwd, p.PreBuildScript, p.PreBuildScriptFile, gogetter)
 		if err != nil {
-			p.logger.Errorf("Go Script Error: %v\n", err)
 			return nil, err
 		}
-		v, err := i.Eval("kust.PostBuild")
-		if err != nil {
-			p.logger.Errorf("Go Script Error: %v\n", err)
+		if _, err := sandbox.Call("kust.PreBuild", p.PreBuildArgs, 0); err != nil {
+			p.logger.Errorf("Error from pre-Build: %v\n", err)
 			return nil, err
 		}
-		postBuild := v.Interface().(func([]string) (*string, error))
-		postBuildRet, err := postBuild(p.PostBuildArgs)
+	}
+	env := make(map[string]string, len(p.CommonComponents))
+	for _, commonComponent := range p.CommonComponents {
+		env["KUZ_COMMON_"+commonComponent.Name] = commonComponent.Path
+	}
+	m, err := nb.Build(cwd, env)
+	if err != nil {
+		p.logger.Errorf("Error building %v: %v\n", cwd, err)
+		return nil, err
+	}
+	kustBytes, err := m.AsYaml()
+	if err != nil {
+		p.logger.Errorf("Error marshalling the build of %v: %v\n", cwd, err)
+		return nil, err
+	}
+	return kustBytes, nil
+}
+
+// evalScript evaluates a pre or post build script in a sandbox confined to the repository in dir,
+// whose working directory is cwd. A relative scriptFile is relative to cwd.
+func (p *GoGetterPlugin) evalScript(dir, cwd, script, scriptFile string, exports interp.Exports) (*utils.YaegiSandbox, error) {
+	sandbox, err := utils.NewYaegiSandbox(dir, p.Capabilities, p.ScriptTimeout, yamlv3.Symbols, exports)
+	if err != nil {
+		p.logger.Errorf("Error configuring go script sandbox: %v\n", err)
+		return nil, err
+	}
+	sandbox.Dir = cwd
+	gocode := []byte(script)
+	if len(script) == 0 {
+		if !filepath.IsAbs(scriptFile) {
+			scriptFile = filepath.Join(cwd, scriptFile)
+		}
+		gocode, err = ioutil.ReadFile(scriptFile)
 		if err != nil {
-			p.logger.Errorf("Error from post-Build: %v\n", err)
+			p.logger.Errorf("Error loading go file: %v\n", err)
 			return nil, err
 		}
-		if postBuildRet != nil {
-			kustBytes = []byte(*postBuildRet)
-		}
-
 	}
-	return p.rf.NewResMapFromBytes(kustBytes)
+	if err := sandbox.Eval(string(gocode)); err != nil {
+		p.logger.Errorf("Go Script Error: %v\n", err)
+		return nil, err
+	}
+	return sandbox, nil
 }
 
-// GoGit ...
+// GoGit clones, or updates, the repository at u into dir, checking out its ref
+// query parameter, with the git backend of the build.
 func (p *GoGetterPlugin) GoGit(u *url.URL, dir string) error {
+	if p.gitBackend == types.GitBackendGoGit {
+		fetchURL := *u
+		fetchURL.RawQuery = ""
+		p.logger.Infof("Fetching %v using ref %v into %v", fetchURL.String(), u.Query().Get("ref"), dir)
+		_, err := git.SparseCheckoutUsingGoGit(context.Background(), dir, fetchURL.String(), u.Query().Get("ref"), p.PartialCloneDir, p.Depth)
+		return err
+	}
+	if _, err := exec.LookPath("git"); err != nil {
+		p.logger.Error("git must be available and on the PATH")
+		return err
+	}
 	var ref string
 	q := u.Query()
 	if len(q) > 0 {
@@ -359,31 +453,39 @@ func (p *GoGetterPlugin) GoGit(u *url.URL, dir string) error {
 	}
 	return nil
 }
-func (p *GoGetterPlugin) findDefaultBranch(dst string) string {
-	var stdoutbuf bytes.Buffer
-	cmd := exec.Command("git", "branch", "-r", "--points-at", "refs/remotes/origin/HEAD")
-	cmd.Dir = dst
-	cmd.Stdout = &stdoutbuf
-	err := cmd.Run()
-	matches := defaultBranchRegexp.FindStringSubmatch(stdoutbuf.String())
-	if err != nil || matches == nil {
-		return "master"
+func (p *GoGetterPlugin) lockCommit(dir string, repoURL string, ref string) error {
+	if p.lockfile == nil && p.inputs == nil {
+		return nil
+	}
+	commit, err := git.HeadCommit(dir)
+	if err != nil {
+		return err
+	}
+	p.recordInput(commit)
+	if p.lockfile == nil {
+		return nil
 	}
-	return matches[len(matches)-1]
+	return p.lockfile.RecordGit(repoURL, ref, commit)
 }
 
-func (p *GoGetterPlugin) executeGitGetter(url *url.URL, dir string) error {
+// recordInput records the git reference fetched, at commit, as an input of the build.
+func (p *GoGetterPlugin) recordInput(commit string) {
+	p.inputs.RecordDigest(provenance.InputGoGetter, p.URL, map[string]string{"sha1": commit})
+}
 
-	if _, err := exec.LookPath("git"); err != nil {
-		p.logger.Error("git must be available and on the PATH")
-		return err
+func (p *GoGetterPlugin) findDefaultBranch(dst string) string {
+	if branch := git.DefaultBranch(dst); branch != "" {
+		return branch
 	}
+	return "master"
+}
 
-	if err := p.GoGit(url, dir); err != nil {
-		p.logger.Errorf("Error executing go-getter: %v\n", err)
-		return err
+// requireGitExec fails the clones and updates that run the git binary
+// when the build uses the go-git backend, e.g. in images without git.
+func (p *GoGetterPlugin) requireGitExec(op string, u *url.URL) error {
+	if p.gitBackend == types.GitBackendGoGit {
+		return fmt.Errorf("%v of %v runs git, which the %v git backend does not", op, u.String(), p.gitBackend)
 	}
-
 	return nil
 }
 
@@ -418,10 +520,13 @@ func (p *GoGetterPlugin) getRunCommand(cmd *exec.Cmd, stdOut *bytes.Buffer) erro
 
 // NewGoGetterPlugin ...
 func NewGoGetterPlugin() resmap.GeneratorPlugin {
-	return &GoGetterPlugin{logger: utils.GetLogger("GoGetterPlugin"), executableResolver: &osExecutableResolverT{}}
+	return &GoGetterPlugin{logger: utils.GetLogger("GoGetterPlugin")}
 }
 
 func (p *GoGetterPlugin) clone(dst string, u *url.URL, ref string) error {
+	if err := p.requireGitExec("clone", u); err != nil {
+		return err
+	}
 
 	// We need the fast clone with history
 	// Cloning the bare repo then doing sparse checkout seems fastest across all versions
@@ -431,6 +536,9 @@ func (p *GoGetterPlugin) clone(dst string, u *url.URL, ref string) error {
 	var args []string
 	if err := p.checkGitVersion("2.25"); err == nil {
 		args = []string{"clone", "--bare", "--filter", p.CloneFilter, "--no-checkout"}
+		if p.Depth > 0 {
+			args = append(args, "--depth", strconv.Itoa(p.Depth))
+		}
 	} else {
 		return err
 	}
@@ -492,6 +600,9 @@ func (p *GoGetterPlugin) clone(dst string, u *url.URL, ref string) error {
 }
 
 func (p *GoGetterPlugin) update(dst string, u *url.URL, ref string) error {
+	if err := p.requireGitExec("update", u); err != nil {
+		return err
+	}
 
 	// Determin current sitution
 	var stdoutbuf bytes.Buffer
@@ -564,6 +675,7 @@ func (p *GoGetterPlugin) update(dst string, u *url.URL, ref string) error {
 		if p.getRunCommand(cmd, nil) != nil {
 			// reclone
 			p.logger.Warnf("Update failed, recloning %v", u.String())
+			p.diagnostics.Warnf(u.String(), diagnostics.ReasonIgnoredError, "update of %v failed, recloning it", dst)
 			os.RemoveAll(dst)
 			return p.clone(dst, u, ref)
 		}
@@ -12,6 +12,7 @@ import (
 	"path/filepath"
 	"runtime"
 	"strings"
+	"sync"
 	"testing"
 
 	"github.com/mholt/archiver/v3"
@@ -34,6 +35,48 @@ func (r *testExecutableResolverT) Executable() (string, error) {
 	return r.path, r.err
 }
 
+// testNestedBuilderT builds the components GoGetter fetches with the kustomize
+// executable resolved by executableResolver, the krusty Kustomizer that runs
+// nested builds in-process being out of reach of the tests of this package.
+type testNestedBuilderT struct {
+	executableResolver *testExecutableResolverT
+	rf                 *resmap.Factory
+	mu                 sync.Mutex
+	memo               map[string]interface{}
+}
+
+func (b *testNestedBuilderT) Build(dir string, env map[string]string) (resmap.ResMap, error) {
+	kustomizeExecutable, err := b.executableResolver.Executable()
+	if err != nil {
+		return nil, err
+	}
+	cmd := exec.Command(kustomizeExecutable, "build", dir)
+	cmd.Env = os.Environ()
+	for k, v := range env {
+		cmd.Env = append(cmd.Env, fmt.Sprintf("%v=%v", k, v))
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, errors.Wrapf(err, "kustomize build %v: %v", dir, stderr.String())
+	}
+	return b.rf.NewResMapFromBytes(out)
+}
+
+func (b *testNestedBuilderT) Memo(key string, f func(nb resmap.NestedBuilder) (interface{}, error)) (interface{}, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if value, ok := b.memo[key]; ok {
+		return value, nil
+	}
+	value, err := f(b)
+	if err == nil {
+		b.memo[key] = value
+	}
+	return value, err
+}
+
 const gitRepoUrl = "https://github.com/qlik-oss/kustomize-gogetter-plugin-tests"
 
 func Test_GoGetter(t *testing.T) {
@@ -176,11 +219,16 @@ metadata:
 	baseLogger, _ := zap.NewDevelopment()
 	logger := baseLogger.Sugar()
 	defer logger.Sync()
-	plugin := GoGetterPlugin{logger: logger, executableResolver: testExecutableResolver}
 	for _, testCase := range testCases {
 		t.Run(testCase.name, func(t *testing.T) {
 			p := provider.NewDefaultDepProvider()
 			resourceFactory := resmap.NewFactory(p.GetResourceFactory())
+			nestedBuilder := &testNestedBuilderT{
+				executableResolver: testExecutableResolver,
+				rf:                 resourceFactory,
+				memo:               make(map[string]interface{}),
+			}
+			plugin := GoGetterPlugin{logger: logger}
 			tmpPluginHomeDir := filepath.Join(testCase.loaderRootDir, "plugin_home")
 			if err := os.Mkdir(tmpPluginHomeDir, os.ModePerm); err != nil {
 				t.Fatalf("Err: %v", err)
@@ -193,7 +241,7 @@ metadata:
 				t.Fatalf("Err: %v", err)
 			}
 
-			h := resmap.NewPluginHelpers(ldr, valtest_test.MakeHappyMapValidator(t), resourceFactory, types.DisabledPluginConfig())
+			h := resmap.NewPluginHelpers(ldr, valtest_test.MakeHappyMapValidator(t), resourceFactory, types.DisabledPluginConfig()).WithNestedBuilder(nestedBuilder, nil)
 			if err := plugin.Config(h, []byte(testCase.pluginConfig)); err != nil {
 				t.Fatalf("Err: %v", err)
 			}
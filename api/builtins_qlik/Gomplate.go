@@ -7,28 +7,42 @@ import (
 	"os"
 
 	"go.uber.org/zap"
+	"sigs.k8s.io/kustomize/api/builtins_qlik/secrets"
 	"sigs.k8s.io/kustomize/api/builtins_qlik/utils"
 	"sigs.k8s.io/kustomize/api/ifc"
+	"sigs.k8s.io/kustomize/api/provenance"
 	"sigs.k8s.io/kustomize/api/resmap"
 	"sigs.k8s.io/yaml"
 )
 
 type GomplatePlugin struct {
 	DataSource map[string]interface{} `json:"dataSource,omitempty" yaml:"dataSource,omitempty"`
-	Pwd        string
-	ldr        ifc.Loader
-	rf         *resmap.Factory
-	logger     *zap.SugaredLogger
+	// Engine is gomplate, the default, or builtin, see utils.BuiltinTemplate,
+	// which only supports a file datasource.
+	Engine string `json:"engine,omitempty" yaml:"engine,omitempty"`
+	Pwd    string
+	ldr    ifc.Loader
+	rf     *resmap.Factory
+	inputs *provenance.Recorder
+	logger *zap.SugaredLogger
 }
 
 func (p *GomplatePlugin) Config(h *resmap.PluginHelpers, c []byte) (err error) {
 	p.ldr = h.Loader()
 	p.rf = h.ResmapFactory()
 	p.Pwd = h.Loader().Root()
-	return yaml.Unmarshal(c, p)
+	p.inputs = h.Inputs()
+	p.Engine = ""
+	if err = yaml.Unmarshal(c, p); err != nil {
+		return err
+	}
+	return utils.ValidateTemplateEngine(p.Engine)
 }
 
 func (p *GomplatePlugin) Transform(m resmap.ResMap) error {
+	if p.Engine == utils.TemplateEngineBuiltin {
+		return p.transformBuiltin(m)
+	}
 	var env []string
 	var vaultAddressPath, vaultTokenPath string
 	var vaultAddress, vaultToken string
@@ -36,29 +50,22 @@ func (p *GomplatePlugin) Transform(m resmap.ResMap) error {
 		vaultAddressPath = fmt.Sprintf("%s", p.DataSource["vault"].(map[string]interface{})["addressPath"])
 		vaultTokenPath = fmt.Sprintf("%s", p.DataSource["vault"].(map[string]interface{})["tokenPath"])
 
-		if _, err := os.Stat(vaultAddressPath); os.IsNotExist(err) {
-			readBytes, err := ioutil.ReadFile(vaultAddressPath)
-			if err != nil {
-				p.logger.Errorf("error reading vault address file: %v, error: %v\n", vaultAddressPath, err)
-				return err
-			}
-			vaultAddress = fmt.Sprintf("VAULT_ADDR=%s", string(readBytes))
-			env = append(env, vaultAddress)
-		} else if err != nil {
-			p.logger.Errorf("error executing stat on vault address file: %v, error: %v\n", vaultAddressPath, err)
+		readBytes, err := ioutil.ReadFile(secrets.KeyFilePath(p.ldr, vaultAddressPath))
+		if err != nil {
+			p.logger.Errorf("error reading vault address file: %v, error: %v\n", vaultAddressPath, err)
+			return err
 		}
+		vaultAddress = fmt.Sprintf("VAULT_ADDR=%s", string(readBytes))
+		env = append(env, vaultAddress)
 
-		if _, err := os.Stat(vaultTokenPath); os.IsNotExist(err) {
-			readBytes, err := ioutil.ReadFile(vaultTokenPath)
-			if err != nil {
-				p.logger.Errorf("error reading vault token file: %v, error: %v\n", vaultTokenPath, err)
-				return err
-			}
-			vaultToken = fmt.Sprintf("VAULT_TOKEN=%s", string(readBytes))
-			env = append(env, vaultToken)
-		} else if err != nil {
-			p.logger.Errorf("error executing stat on vault token file: %v, error: %v\n", vaultTokenPath, err)
+		readBytes, err = ioutil.ReadFile(secrets.KeyFilePath(p.ldr, vaultTokenPath))
+		if err != nil {
+			p.logger.Errorf("error reading vault token file: %v, error: %v\n", vaultTokenPath, err)
+			return err
 		}
+		utils.RegisterSecretValues(string(readBytes))
+		vaultToken = fmt.Sprintf("VAULT_TOKEN=%s", string(readBytes))
+		env = append(env, vaultToken)
 	}
 
 	var ejsonKey string
@@ -68,14 +75,11 @@ func (p *GomplatePlugin) Transform(m resmap.ResMap) error {
 				err := errors.New("privateKeyPath must be a string")
 				p.logger.Errorf("error: %v\n", err)
 				return err
-			} else if _, err := os.Stat(ejsonPrivateKeyPath); err != nil {
-				p.logger.Errorf("error executing stat on ejson private key file: %v, error: %v\n", ejsonPrivateKeyPath, err)
+			} else if readBytes, err := ioutil.ReadFile(secrets.KeyFilePath(p.ldr, ejsonPrivateKeyPath)); err != nil {
+				p.logger.Errorf("error reading ejson private key file: %v, error: %v\n", ejsonPrivateKeyPath, err)
+				return err
 			} else {
-				readBytes, err := ioutil.ReadFile(ejsonPrivateKeyPath)
-				if err != nil {
-					p.logger.Errorf("error reading ejson private key file: %v, error: %v\n", ejsonPrivateKeyPath, err)
-					return err
-				}
+				utils.RegisterSecretValues(string(readBytes))
 				ejsonKey = fmt.Sprintf("EJSON_KEY=%s", string(readBytes))
 				env = append(env, ejsonKey)
 			}
@@ -86,17 +90,65 @@ func (p *GomplatePlugin) Transform(m resmap.ResMap) error {
 		env = append(env, ejsonKey)
 	}
 
-	var dataSource string
+	// the vault secret is the only remote datasource, declared by the vault datasource config
+	var localDataSources, remoteDataSources []string
 	if p.DataSource["ejson"] != nil {
-		dataSource = fmt.Sprintf("%s", p.DataSource["ejson"].(map[string]interface{})["filePath"])
+		localDataSources = []string{fmt.Sprintf("data=%s", p.DataSource["ejson"].(map[string]interface{})["filePath"])}
 	} else if vaultAddress != "" && vaultToken != "" {
-		dataSource = fmt.Sprintf("%s", p.DataSource["vault"].(map[string]interface{})["secretPath"])
-	} else if p.DataSource["file"] != nil {
-		dataSource = fmt.Sprintf("%s", p.DataSource["file"].(map[string]interface{})["path"])
+		remoteDataSources = []string{fmt.Sprintf("data=%s", p.DataSource["vault"].(map[string]interface{})["secretPath"])}
+	} else if file, ok := p.DataSource["file"].(map[string]interface{}); ok {
+		localDataSources = []string{fmt.Sprintf("data=%s", file["path"])}
 	} else {
-		dataSource = fmt.Sprintf("%s", p.DataSource["file"].(map[string]interface{})["path"])
+		err := errors.New("dataSource must have an ejson, vault or file datasource")
+		p.logger.Errorf("error: %v\n", err)
+		return err
 	}
+	dir, err := ioutil.TempDir("", "kustomize-gomplate-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	dataSources, err := utils.GomplateDataSources(p.ldr, dir, localDataSources, remoteDataSources, p.inputs)
+	if err != nil {
+		p.logger.Errorf("error resolving dataSources: %v, in directory: %v, error: %v\n", p.DataSource, p.Pwd, err)
+		return err
+	}
+
+	return p.templateResources(m, func(template string) ([]byte, error) {
+		output, err := utils.RunGomplate(dataSources, env, template, p.logger)
+		if err != nil {
+			p.logger.Errorf("error executing runGomplate() on dataSources: %v, in directory: %v, error: %v\n", dataSources, p.Pwd, err)
+		}
+		return output, err
+	})
+}
+
+// transformBuiltin templates the resources with the builtin template engine.
+func (p *GomplatePlugin) transformBuiltin(m resmap.ResMap) error {
+	file, ok := p.DataSource["file"].(map[string]interface{})
+	if !ok || p.DataSource["ejson"] != nil || p.DataSource["vault"] != nil {
+		err := fmt.Errorf("the %v template engine only supports a file datasource", utils.TemplateEngineBuiltin)
+		p.logger.Errorf("error: %v\n", err)
+		return err
+	}
+	dataSources, err := utils.BuiltinDataSources(p.ldr, []string{fmt.Sprintf("data=%s", file["path"])}, nil, p.inputs)
+	if err != nil {
+		p.logger.Errorf("error resolving dataSources: %v, in directory: %v, error: %v\n", p.DataSource, p.Pwd, err)
+		return err
+	}
+	engine := &utils.BuiltinTemplate{LDelim: "((", RDelim: "))", DataSources: dataSources, Resources: m.DeepCopy()}
+	return p.templateResources(m, func(template string) ([]byte, error) {
+		output, err := engine.Execute("gomplate", template)
+		if err != nil {
+			p.logger.Errorf("error executing the builtin template engine on dataSources: %v, in directory: %v, error: %v\n", p.DataSource, p.Pwd, err)
+		}
+		return output, err
+	})
+}
 
+// templateResources replaces every resource by the output of run with the resource as the template,
+// leaving the resources for which run fails as they are.
+func (p *GomplatePlugin) templateResources(m resmap.ResMap, run func(template string) ([]byte, error)) error {
 	for _, r := range m.Resources() {
 		yamlByte, err := r.AsYAML()
 		if err != nil {
@@ -104,9 +156,7 @@ func (p *GomplatePlugin) Transform(m resmap.ResMap) error {
 			return err
 		}
 
-		if output, err := utils.RunGomplate(dataSource, p.Pwd, env, string(yamlByte), p.logger); err != nil {
-			p.logger.Errorf("error executing runGomplate() on dataSource: %v, in directory: %v, error: %v\n", dataSource, p.Pwd, err)
-		} else {
+		if output, err := run(string(yamlByte)); err == nil {
 			res, err := p.rf.RF().FromBytes(output)
 			if err != nil {
 				p.logger.Errorf("error unmarshalling resource from bytes: %v\n", err)
@@ -0,0 +1,78 @@
+package builtins_qlik
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/kustomize/api/filesys"
+	"sigs.k8s.io/kustomize/api/loader"
+	"sigs.k8s.io/kustomize/api/provider"
+	"sigs.k8s.io/kustomize/api/resmap"
+	valtest_test "sigs.k8s.io/kustomize/api/testutils/valtest"
+	"sigs.k8s.io/kustomize/api/types"
+)
+
+func TestGomplateBuiltinEngine(t *testing.T) {
+	fSys := filesys.MakeFsInMemory()
+	assert.NoError(t, fSys.WriteFile("/app/values.yaml", []byte("replicas: 3\n")))
+	ldr, err := loader.NewLoader(loader.RestrictionRootOnly, "/app", fSys)
+	assert.NoError(t, err)
+
+	p := provider.NewDefaultDepProvider()
+	resourceFactory := resmap.NewFactory(p.GetResourceFactory())
+	resMap, err := resourceFactory.NewResMapFromBytes([]byte(`
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+spec:
+  replicas: (( (ds "data").replicas ))
+  template:
+    spec:
+      containers:
+      - name: app
+        env:
+        - name: DB_PORT
+          value: '(( resource "Service" "db" | field "spec.ports[0].port" | quote ))'
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: db
+spec:
+  ports:
+  - port: 5432
+`))
+	assert.NoError(t, err)
+
+	plugin := NewGomplatePlugin()
+	h := resmap.NewPluginHelpers(ldr, valtest_test.MakeFakeValidator(), resourceFactory, types.DisabledPluginConfig())
+	err = plugin.Config(h, []byte(`
+apiVersion: qlik.com/v1
+kind: Gomplate
+metadata:
+  name: notImportantHere
+engine: nope
+`))
+	assert.EqualError(t, err, "unknown template engine: nope, it can only be: gomplate or builtin")
+
+	err = plugin.Config(h, []byte(`
+apiVersion: qlik.com/v1
+kind: Gomplate
+metadata:
+  name: notImportantHere
+engine: builtin
+dataSource:
+  file:
+    path: values.yaml
+`))
+	assert.NoError(t, err)
+	assert.NoError(t, plugin.Transform(resMap))
+
+	replicas, err := resMap.GetByIndex(0).GetFieldValue("spec.replicas")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, replicas)
+	port, err := resMap.GetByIndex(0).GetFieldValue("spec.template.spec.containers[0].env[0].value")
+	assert.NoError(t, err)
+	assert.Equal(t, "5432", port)
+}
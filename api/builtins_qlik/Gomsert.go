@@ -7,8 +7,11 @@ import (
 
 	"go.uber.org/zap"
 	"sigs.k8s.io/kustomize/api/builtins_qlik/utils"
+	"sigs.k8s.io/kustomize/api/diagnostics"
 	"sigs.k8s.io/kustomize/api/filters/fieldspec"
 	"sigs.k8s.io/kustomize/api/ifc"
+	"sigs.k8s.io/kustomize/api/loader"
+	"sigs.k8s.io/kustomize/api/provenance"
 	"sigs.k8s.io/kustomize/api/resmap"
 	"sigs.k8s.io/kustomize/api/types"
 	"sigs.k8s.io/kustomize/kyaml/filtersutil"
@@ -19,22 +22,30 @@ import (
 
 // inserts a processed gomplate file into a kustomize resource
 type GomsertPlugin struct {
-	LDelim      string   `json:"leftDelimeter" 	yaml:"leftDelimeter"`
-	RDelim      string   `json:"rightDelimeter" 	yaml:"rightDelimeter"`
-	DataSources []string `json:"dataSources,omitempty" 	yaml:"dataSources,omitempty"`
-	InputFile   string   `json:"inputFile,omitempty" 	yaml:"inputFile,omitempty"`
-	EnvVars     []struct {
-		Name          string  `json:"name" 	yaml:"name"`
-		ValueFromEnv  *string `json:"valueFromEnv,omitempty" 	yaml:"valueFromEnv,omitempty"`
-		ValueFromFile *string `json:"valueFromFile,omitempty" 	yaml:"valueFromFile,omitempty"`
-		Value         *string `json:"value,omitempty" 	yaml:"value,omitempty"`
-	} `json:"envVars,omitempty" 	yaml:"envVars,omitempty"`
-	Target      *types.Selector `json:"target,omitempty" yaml:"target,omitempty"`
-	Path        string          `json:"path,omitempty" yaml:"path,omitempty"`
+	LDelim string `json:"leftDelimeter" yaml:"leftDelimeter"`
+	RDelim string `json:"rightDelimeter" yaml:"rightDelimeter"`
+	// DataSources are local, read through the kustomize loader relative to the
+	// kustomization directory, the remote ones must be in RemoteDataSources.
+	DataSources       []string `json:"dataSources,omitempty" yaml:"dataSources,omitempty"`
+	RemoteDataSources []string `json:"remoteDataSources,omitempty" yaml:"remoteDataSources,omitempty"`
+	InputFile         string   `json:"inputFile,omitempty" yaml:"inputFile,omitempty"`
+	EnvVars           []struct {
+		Name          string  `json:"name" yaml:"name"`
+		ValueFromEnv  *string `json:"valueFromEnv,omitempty" yaml:"valueFromEnv,omitempty"`
+		ValueFromFile *string `json:"valueFromFile,omitempty" yaml:"valueFromFile,omitempty"`
+		Value         *string `json:"value,omitempty" yaml:"value,omitempty"`
+	} `json:"envVars,omitempty" yaml:"envVars,omitempty"`
+	Target *types.Selector `json:"target,omitempty" yaml:"target,omitempty"`
+	Path   string          `json:"path,omitempty" yaml:"path,omitempty"`
+	// Engine is gomplate, the default, or builtin, see utils.BuiltinTemplate,
+	// with which datasources are optional and .Env and env read EnvVars.
+	Engine      string `json:"engine,omitempty" yaml:"engine,omitempty"`
 	Pwd         string
 	ldr         ifc.Loader
 	rf          *resmap.Factory
+	inputs      *provenance.Recorder
 	logger      *zap.SugaredLogger
+	diagnostics *diagnostics.Reporter
 	fieldSpec   types.FieldSpec
 	replaceYaml []byte
 }
@@ -43,7 +54,13 @@ func (p *GomsertPlugin) Config(h *resmap.PluginHelpers, c []byte) (err error) {
 	p.ldr = h.Loader()
 	p.rf = h.ResmapFactory()
 	p.Pwd = h.Loader().Root()
-	return yaml.Unmarshal(c, p)
+	p.diagnostics = h.Diagnostics()
+	p.inputs = h.Inputs()
+	p.Engine = ""
+	if err = yaml.Unmarshal(c, p); err != nil {
+		return err
+	}
+	return utils.ValidateTemplateEngine(p.Engine)
 }
 
 func (p *GomsertPlugin) Transform(m resmap.ResMap) error {
@@ -59,21 +76,23 @@ func (p *GomsertPlugin) Transform(m resmap.ResMap) error {
 
 	var env = make(map[string]string)
 
-	valuesFile := p.InputFile
-	if data, err = ioutil.ReadFile(valuesFile); err != nil {
+	if data, err = p.ldr.Load(p.InputFile); err != nil {
+		p.logger.Errorf("error reading input file: %v, error: %v\n", p.InputFile, err)
 		return err
 	}
+	p.inputs.Record(provenance.InputFile, loader.InputURI(p.ldr, p.InputFile), data)
 
-	if p.DataSources != nil {
+	if len(p.DataSources) > 0 || len(p.RemoteDataSources) > 0 || p.Engine == utils.TemplateEngineBuiltin {
 		for _, envVar := range p.EnvVars {
 			if _, keyexists := env[envVar.Name]; !keyexists {
 				if envVar.Value != nil {
 					p.logger.Infof("environmental variable %v set from value", envVar.Name)
 					env[envVar.Name] = *envVar.Value
 				} else if envVar.ValueFromFile != nil {
-					if data, err = ioutil.ReadFile(*envVar.ValueFromFile); err == nil {
+					if fileData, err := p.ldr.Load(*envVar.ValueFromFile); err == nil {
 						p.logger.Infof("environmental variable %v set from File %v", envVar.Name, envVar.ValueFromFile)
-						stringData := string(data)
+						stringData := string(fileData)
+						utils.RegisterSecretValues(stringData)
 						env[envVar.Name] = stringData
 					} else {
 						p.logger.Warnf("environmental variable %v, unable to read file %v, %v", envVar.Name, envVar.ValueFromFile, err)
@@ -82,6 +101,7 @@ func (p *GomsertPlugin) Transform(m resmap.ResMap) error {
 					if envValue, exists := os.LookupEnv(*envVar.ValueFromEnv); exists {
 						p.logger.Infof("environmental variable %v set from env var %v", envVar.Name, envVar.ValueFromEnv)
 						stringData := string(envValue)
+						utils.RegisterSecretValues(stringData)
 						env[envVar.Name] = stringData
 					} else {
 						p.logger.Warnf("environmental variable %v, unable to read env var %v", envVar.Name, envVar.ValueFromEnv)
@@ -90,6 +110,7 @@ func (p *GomsertPlugin) Transform(m resmap.ResMap) error {
 					if envValue, exists := os.LookupEnv(envVar.Name); exists {
 						p.logger.Infof("environmental variable %v set", envVar.Name)
 						stringData := string(envValue)
+						utils.RegisterSecretValues(stringData)
 						env[envVar.Name] = stringData
 					} else {
 						p.logger.Warnf("environmental variable %v does not exist", envVar.Name)
@@ -104,8 +125,32 @@ func (p *GomsertPlugin) Transform(m resmap.ResMap) error {
 			}
 		}
 
-		if p.replaceYaml, err = utils.RunGomplateFromConfig(p.DataSources, p.Pwd, env, string(data), p.logger, p.LDelim, p.RDelim); err != nil {
-			p.logger.Errorf("error executing runGomplate() on dataSources: %v, in directory: %v, error: %v\n", p.DataSources, p.Pwd, err)
+		if p.Engine == utils.TemplateEngineBuiltin {
+			dataSources, err := utils.BuiltinDataSources(p.ldr, p.DataSources, p.RemoteDataSources, p.inputs)
+			if err != nil {
+				p.logger.Errorf("error resolving dataSources: %v, in directory: %v, error: %v\n", p.DataSources, p.Pwd, err)
+				return err
+			}
+			engine := &utils.BuiltinTemplate{LDelim: p.LDelim, RDelim: p.RDelim, DataSources: dataSources, Env: env, Resources: m}
+			if p.replaceYaml, err = engine.Execute(p.InputFile, string(data)); err != nil {
+				p.logger.Errorf("error executing the builtin template engine on dataSources: %v, in directory: %v, error: %v\n", p.DataSources, p.Pwd, err)
+				p.diagnostics.Warnf("", diagnostics.ReasonIgnoredError, "template failed, inserting nothing: %v", utils.RedactError(err))
+			}
+		} else {
+			dir, err := ioutil.TempDir("", "kustomize-gomplate-")
+			if err != nil {
+				return err
+			}
+			defer os.RemoveAll(dir)
+			dataSources, err := utils.GomplateDataSources(p.ldr, dir, p.DataSources, p.RemoteDataSources, p.inputs)
+			if err != nil {
+				p.logger.Errorf("error resolving dataSources: %v, in directory: %v, error: %v\n", p.DataSources, p.Pwd, err)
+				return err
+			}
+			if p.replaceYaml, err = utils.RunGomplateFromConfig(dataSources, env, string(data), p.logger, p.LDelim, p.RDelim); err != nil {
+				p.logger.Errorf("error executing runGomplate() on dataSources: %v, in directory: %v, error: %v\n", dataSources, p.Pwd, err)
+				p.diagnostics.Warnf("", diagnostics.ReasonIgnoredError, "gomplate failed, inserting nothing: %v", utils.RedactError(err))
+			}
 		}
 		// Target
 		resources, err := m.Select(*p.Target)
@@ -113,6 +158,9 @@ func (p *GomsertPlugin) Transform(m resmap.ResMap) error {
 			p.logger.Errorf("error selecting resources based on the target selector, error: %v\n", err)
 			return err
 		}
+		if len(resources) == 0 {
+			p.diagnostics.Warnf(p.Target.String(), diagnostics.ReasonTargetNotFound, "target selector matched no resources")
+		}
 		for _, r := range resources {
 			if err := filtersutil.ApplyToJSON(kio.FilterFunc(func(nodes []*kyaml.RNode) ([]*kyaml.RNode, error) {
 				return kio.FilterAll(kyaml.FilterFunc(func(rn *kyaml.RNode) (*kyaml.RNode, error) {
@@ -0,0 +1,159 @@
+package builtins_qlik
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/kustomize/api/filesys"
+	"sigs.k8s.io/kustomize/api/loader"
+	"sigs.k8s.io/kustomize/api/provenance"
+	"sigs.k8s.io/kustomize/api/provider"
+	"sigs.k8s.io/kustomize/api/resmap"
+	valtest_test "sigs.k8s.io/kustomize/api/testutils/valtest"
+	"sigs.k8s.io/kustomize/api/types"
+)
+
+func TestGomsert(t *testing.T) {
+	resources := `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: some-config
+data:
+  foo: bar
+`
+	testCases := []struct {
+		name           string
+		pluginConfig   string
+		template       string
+		expectedResult string
+		expectedInputs []string
+		transformError string
+	}{
+		{
+			name: "datasources read through the loader",
+			pluginConfig: `
+apiVersion: qlik.com/v1
+kind: Gomsert
+metadata:
+  name: notImportantHere
+dataSources:
+- values=data/values.yaml
+inputFile: template.yaml
+target:
+  kind: ConfigMap
+path: data
+`,
+			expectedResult: `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: some-config
+data:
+  greeting: hello world
+`,
+			expectedInputs: []string{"/app/template.yaml", "/app/data/values.yaml"},
+		},
+		{
+			name: "builtin engine",
+			pluginConfig: `
+apiVersion: qlik.com/v1
+kind: Gomsert
+metadata:
+  name: notImportantHere
+engine: builtin
+dataSources:
+- values=data/values.yaml
+envVars:
+- name: PUNCTUATION
+  value: "!"
+inputFile: template.yaml
+target:
+  kind: ConfigMap
+path: data
+`,
+			template: `greeting: {{ (ds "values").who | title }} {{ resource "ConfigMap" "some-config" | field "data.foo" }}{{ env "PUNCTUATION" }}` + "\n",
+			expectedResult: `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: some-config
+data:
+  greeting: World bar!
+`,
+			expectedInputs: []string{"/app/template.yaml", "/app/data/values.yaml"},
+		},
+		{
+			name: "undeclared remote datasource",
+			pluginConfig: `
+apiVersion: qlik.com/v1
+kind: Gomsert
+metadata:
+  name: notImportantHere
+dataSources:
+- values=https://example.com/values.yaml
+inputFile: template.yaml
+target:
+  kind: ConfigMap
+path: data
+`,
+			transformError: "datasource values=https://example.com/values.yaml is remote, it must be declared as a remote datasource",
+		},
+		{
+			name: "datasource outside of the kustomization",
+			pluginConfig: `
+apiVersion: qlik.com/v1
+kind: Gomsert
+metadata:
+  name: notImportantHere
+dataSources:
+- values=../secrets.yaml
+inputFile: template.yaml
+target:
+  kind: ConfigMap
+path: data
+`,
+			transformError: "error loading datasource values=../secrets.yaml: security; file '/secrets.yaml' is not in or below '/app'",
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			fSys := filesys.MakeFsInMemory()
+			assert.NoError(t, fSys.WriteFile("/app/data/values.yaml", []byte("who: world\n")))
+			template := testCase.template
+			if template == "" {
+				template = "greeting: hello {{ (ds \"values\").who }}\n"
+			}
+			assert.NoError(t, fSys.WriteFile("/app/template.yaml", []byte(template)))
+			assert.NoError(t, fSys.WriteFile("/secrets.yaml", []byte("who: nobody\n")))
+			ldr, err := loader.NewLoader(loader.RestrictionRootOnly, "/app", fSys)
+			assert.NoError(t, err)
+
+			p := provider.NewDefaultDepProvider()
+			resourceFactory := resmap.NewFactory(p.GetResourceFactory())
+			resMap, err := resourceFactory.NewResMapFromBytes([]byte(resources))
+			assert.NoError(t, err)
+
+			inputs := provenance.NewRecorder()
+			plugin := NewGomsertPlugin()
+			err = plugin.Config(resmap.NewPluginHelpers(ldr, valtest_test.MakeFakeValidator(), resourceFactory, types.DisabledPluginConfig()).WithInputs(inputs), []byte(testCase.pluginConfig))
+			assert.NoError(t, err)
+
+			err = plugin.Transform(resMap)
+			if testCase.transformError != "" {
+				assert.EqualError(t, err, testCase.transformError)
+				return
+			}
+			assert.NoError(t, err)
+
+			expected, err := resourceFactory.NewResMapFromBytes([]byte(testCase.expectedResult))
+			assert.NoError(t, err)
+			assert.NoError(t, expected.ErrorIfNotEqualLists(resMap))
+			var uris []string
+			for _, input := range inputs.Inputs() {
+				uris = append(uris, input.URI)
+			}
+			assert.Equal(t, testCase.expectedInputs, uris)
+		})
+	}
+}
@@ -2,43 +2,81 @@ package builtins_qlik
 
 import (
 	"bytes"
-	"crypto/sha256"
-	"encoding/hex"
+	"context"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/Masterminds/semver/v3"
+	dockerauth "github.com/deislabs/oras/pkg/auth/docker"
 	"github.com/google/uuid"
 	"github.com/pkg/errors"
+	"github.com/xeipuuv/gojsonschema"
 	"go.uber.org/zap"
 	"helm.sh/helm/v3/pkg/action"
 	"helm.sh/helm/v3/pkg/chart"
 	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/chartutil"
 	"helm.sh/helm/v3/pkg/cli"
 	"helm.sh/helm/v3/pkg/downloader"
 	"helm.sh/helm/v3/pkg/getter"
 	"helm.sh/helm/v3/pkg/helmpath"
 	"helm.sh/helm/v3/pkg/registry"
+	"helm.sh/helm/v3/pkg/release"
 	"helm.sh/helm/v3/pkg/repo"
 	"k8s.io/client-go/util/homedir"
+	"sigs.k8s.io/kustomize/api/builtins"
 	"sigs.k8s.io/kustomize/api/builtins_qlik/utils"
+	"sigs.k8s.io/kustomize/api/diagnostics"
+	"sigs.k8s.io/kustomize/api/helmvalues"
+	"sigs.k8s.io/kustomize/api/ifc"
+	"sigs.k8s.io/kustomize/api/internal/plugins/builtinconfig"
+	"sigs.k8s.io/kustomize/api/provenance"
 	"sigs.k8s.io/kustomize/api/resmap"
+	"sigs.k8s.io/kustomize/api/types"
+	"sigs.k8s.io/kustomize/kyaml/kio"
 	"sigs.k8s.io/yaml"
 )
 
 const (
 	defaultRepoIndexFileStaleAfterSeconds = 60
+
+	// HelmHooksInclude, HelmHooksDrop and HelmHooksAnnotate are the values of hooks:
+	// include the hook resources as rendered, drop them, or include them as regular
+	// resources with the helm.sh/hook annotations moved under helmHookAnnotationPrefix.
+	HelmHooksInclude  = "include"
+	HelmHooksDrop     = "drop"
+	HelmHooksAnnotate = "annotate"
+
+	helmHookAnnotationPrefix = "helm.qlik.com/"
 )
 
+// helmRunMutex serializes helm install runs, they write the api
+// versions of the capabilities global to all charts in client only mode.
 var helmRunMutex sync.Mutex
 
+// helmSettingsMutex serializes reading helm settings from the env
+// the plugins set. Settings are read once per plugin, the helm code
+// it calls still reading the env is handed the settings instead.
+var helmSettingsMutex sync.Mutex
+
+// HelmPostRenderer is a kustomize-native alternative to helm --post-renderer,
+// applied to the rendered chart before it is returned to kustomize.
+type HelmPostRenderer struct {
+	Patches []types.Patch     `json:"patches,omitempty" yaml:"patches,omitempty"`
+	Images  []types.Image     `json:"images,omitempty" yaml:"images,omitempty"`
+	Labels  map[string]string `json:"labels,omitempty" yaml:"labels,omitempty"`
+}
+
 type HelmChartPlugin struct {
 	ChartName                      string                 `json:"chartName,omitempty" yaml:"chartName,omitempty"`
 	ChartHome                      string                 `json:"chartHome,omitempty" yaml:"chartHome,omitempty"`
@@ -59,23 +97,61 @@ type HelmChartPlugin struct {
 	LockRetryDelayMaxMilliSeconds  int                    `json:"lockRetryDelayMaxMilliSeconds,omitempty" yaml:"lockRetryDelayMaxMilliSeconds,omitempty"`
 	LockTimeoutSeconds             int                    `json:"lockTimeoutSeconds,omitempty" yaml:"lockTimeoutSeconds,omitempty"`
 	IncludeCRDs                    *bool                  `json:"includeCRDs,omitempty" yaml:"includeCRDs,omitempty"`
+	Hooks                          string                 `json:"hooks,omitempty" yaml:"hooks,omitempty"`
+	IncludeTests                   *bool                  `json:"includeTests,omitempty" yaml:"includeTests,omitempty"`
+	IncludeNotes                   bool                   `json:"includeNotes,omitempty" yaml:"includeNotes,omitempty"`
+	PostRenderers                  []HelmPostRenderer     `json:"postRenderers,omitempty" yaml:"postRenderers,omitempty"`
 	rf                             *resmap.Factory
+	postRenderers                  []resmap.Transformer
+	configPath                     string
+	valuesOrigins                  map[string]string
 	logger                         *zap.SugaredLogger
-	hash                           string
-	hashFolder                     string
-	cacheEnabled                   bool
+	chartCache                     *utils.ChartCache
+	mirrorConfig                   types.MirrorConfig
+	lockfile                       ifc.Lockfile
+	inputs                         *provenance.Recorder
+	diagnostics                    *diagnostics.Reporter
+	helmValues                     *helmvalues.Report
+	requestedChartVersion          string
+	ociManifestDigest              string
 }
 
 func (p *HelmChartPlugin) Config(h *resmap.PluginHelpers, c []byte) (err error) {
 	p.rf = h.ResmapFactory()
-
-	p.cacheEnabled, _ = strconv.ParseBool(os.Getenv("KUST_HELM_CACHE"))
+	p.mirrorConfig = utils.GetMirrorConfig(h.GeneralConfig())
 
 	if err = yaml.Unmarshal(c, p); err != nil {
 		p.logger.Errorf("error unmarshalling yaml config: %v, error: %v\n", string(c), err)
 		return err
 	}
 
+	meta, err := configMetadata(c)
+	if err != nil {
+		return err
+	}
+	if p.valuesOrigins, err = helmValuesOrigins(meta.Annotations); err != nil {
+		p.logger.Errorf("error reading the origins of values, error: %v\n", err)
+		return err
+	}
+	p.configPath = configOrigin(h.ConfigPath(), "HelmChart", meta.Name)
+	for path := range utils.FlattenValues(p.Values) {
+		if _, ok := p.valuesOrigins[path]; !ok {
+			p.valuesOrigins[path] = p.configPath
+		}
+	}
+
+	p.lockfile = h.Lockfile()
+	p.inputs = h.Inputs()
+	p.diagnostics = h.Diagnostics()
+	p.helmValues = h.HelmValues()
+	p.requestedChartVersion = p.ChartVersion
+	if p.lockfile != nil {
+		if locked, found := p.lockfile.LockedChart(p.ChartRepo, p.ChartName, p.ChartVersion); found {
+			p.logger.Infof("using chart version: %v pinned in: %v\n", locked.Version, p.lockfile.Path())
+			p.ChartVersion = locked.Version
+		}
+	}
+
 	if p.HelmHome == "" {
 		directory := filepath.Join(os.TempDir(), "dotHelm")
 		p.HelmHome = directory
@@ -102,7 +178,9 @@ func (p *HelmChartPlugin) Config(h *resmap.PluginHelpers, c []byte) (err error)
 		}
 	}
 
-	if p.ChartRepo == "" {
+	if p.mirrorConfig.Offline {
+		p.logger.Infof("Offline mode, chart will only be resolved from the mirror: %v\n", p.mirrorConfig.ChartMirror)
+	} else if p.ChartRepo == "" {
 		p.logger.Info("No chartRepo set in the config. If fetch is needed, it will fail.")
 	}
 
@@ -114,15 +192,11 @@ func (p *HelmChartPlugin) Config(h *resmap.PluginHelpers, c []byte) (err error)
 		p.ReleaseNamespace = "default"
 	}
 
-	if p.cacheEnabled {
-		p.hashFolder = filepath.Join(p.ChartHome, ".plugincache")
-		if err = os.MkdirAll(p.hashFolder, os.ModePerm); err != nil {
-			p.logger.Errorf("error creating hashfolder: %v, error: %v\n", p.hashFolder, err)
+	if cacheEnabled, _ := strconv.ParseBool(os.Getenv("KUST_HELM_CACHE")); cacheEnabled {
+		if p.chartCache, err = utils.NewChartCacheFromEnv(p.logger); err != nil {
+			p.logger.Errorf("error configuring chart cache, error: %v\n", err)
 			return err
 		}
-		chartHash := sha256.New()
-		chartHash.Write(c)
-		p.hash = hex.EncodeToString(chartHash.Sum(nil))
 	}
 	if p.RepoIndexFileStaleAfterSeconds == 0 {
 		p.RepoIndexFileStaleAfterSeconds = defaultRepoIndexFileStaleAfterSeconds
@@ -141,79 +215,216 @@ func (p *HelmChartPlugin) Config(h *resmap.PluginHelpers, c []byte) (err error)
 	if p.IncludeCRDs == nil {
 		p.IncludeCRDs = &defaultIncludeCRDs
 	}
+
+	switch p.Hooks {
+	case "":
+		p.Hooks = HelmHooksInclude
+	case HelmHooksInclude, HelmHooksDrop, HelmHooksAnnotate:
+	default:
+		return fmt.Errorf("invalid hooks: %v, must be one of: %v, %v, %v", p.Hooks, HelmHooksInclude, HelmHooksDrop, HelmHooksAnnotate)
+	}
+	if p.IncludeTests == nil {
+		includeTests := p.Hooks != HelmHooksDrop
+		p.IncludeTests = &includeTests
+	}
+	if p.postRenderers, err = makeHelmPostRenderers(h, p.PostRenderers); err != nil {
+		p.logger.Errorf("error configuring postRenderers, error: %v\n", err)
+		return err
+	}
 	return nil
 }
 
 func (p *HelmChartPlugin) Generate() (resmap.ResMap, error) {
-
 	var templatedYaml []byte
-	var hashFilePath = ""
+	var err error
 
-	if p.cacheEnabled {
-		hashFilePath = filepath.Join(p.hashFolder, p.hash)
-		lockFilePath := filepath.Join(p.hashFolder, fmt.Sprintf("%v.flock", p.hash))
+	if p.helmValues != nil {
+		p.helmValues.Record(helmvalues.ChartValues{
+			ChartName:   p.ChartName,
+			ReleaseName: p.ReleaseName,
+			ConfigPath:  p.configPath,
+			Values:      p.Values,
+			Origins:     p.valuesOrigins,
+		})
+		return resmap.New(), nil
+	}
 
-		if unlockFn, err := utils.LockPath(lockFilePath, p.LockTimeoutSeconds, p.LockRetryDelayMinMilliSeconds, p.LockRetryDelayMaxMilliSeconds, p.logger); err != nil {
-			p.logger.Errorf("error locking %v, error: %v\n", hashFilePath, err)
-			return nil, err
-		} else {
-			defer unlockFn()
-		}
+	if p.chartCache != nil {
+		templatedYaml, err = p.executeCachedHelmTemplate()
+	} else {
+		templatedYaml, err = p.executeHelmTemplate()
 	}
-	if _, err := os.Stat(hashFilePath); err != nil {
-		if os.IsNotExist(err) {
-			if templatedYaml, err = p.executeHelmTemplate(); err != nil {
-				p.logger.Errorf("error executing helm template, error: %v\n", err)
-				return nil, err
-			} else if p.cacheEnabled {
-				if err = ioutil.WriteFile(hashFilePath, templatedYaml, 0644); err != nil {
-					p.logger.Errorf("error writing kustomization yaml to file: %v, error: %v\n", hashFilePath, err)
-				}
-			}
-		} else {
+	if err != nil {
+		p.logger.Errorf("error executing helm template, error: %v\n", err)
+		return nil, err
+	}
+	if p.mirrorConfig.Vendor && p.mirrorConfig.ChartMirror != "" {
+		if err := p.vendorChart(); err != nil {
+			p.logger.Errorf("error vendoring chart: %v, error: %v\n", p.ChartName, err)
 			return nil, err
 		}
-	} else {
-		p.logger.Info("reading previously generated yaml from plugin cache...")
-		templatedYaml, err = ioutil.ReadFile(hashFilePath)
-		if err != nil {
-			p.logger.Errorf("error reading file: %v, error: %v\n", hashFilePath, err)
+	}
+	m, err := p.rf.NewResMapFromBytes(templatedYaml)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range p.postRenderers {
+		if err := t.Transform(m); err != nil {
+			p.logger.Errorf("error applying postRenderers to chart: %v, error: %v\n", p.ChartName, err)
 			return nil, err
 		}
 	}
+	return m, nil
+}
 
-	return p.rf.NewResMapFromBytes(templatedYaml)
+// PrefetchKey is the directory the chart is fetched into.
+func (p *HelmChartPlugin) PrefetchKey() string {
+	if p.helmValues != nil {
+		return ""
+	}
+	return filepath.Join(p.ChartHome, p.ChartName)
 }
 
-func (p *HelmChartPlugin) executeHelmTemplate() ([]byte, error) {
+// Prefetch fetches the chart, and its dependencies unless rendered charts
+// are cached, ahead of Generate.
+func (p *HelmChartPlugin) Prefetch() error {
+	settings := p.helmSettings()
+	if _, err := p.fetchChart(settings); err != nil {
+		return err
+	}
+	if p.chartCache != nil {
+		return nil
+	}
+	chartPath, _ := p.chartPathAndName()
+	if _, err := p.loadChartWithDependencies(settings, chartPath); err != nil {
+		p.logger.Errorf("error building dependencies, err: %v\n", err)
+		return err
+	}
+	return nil
+}
+
+func (p *HelmChartPlugin) helmSettings() *cli.EnvSettings {
+	helmSettingsMutex.Lock()
+	defer helmSettingsMutex.Unlock()
 	os.Setenv("HELM_NAMESPACE", p.ReleaseNamespace)
 	os.Setenv("XDG_CONFIG_HOME", p.HelmHome)
 	os.Setenv("XDG_CACHE_HOME", p.HelmHome)
-	settings := cli.New()
+	return cli.New()
+}
 
-	if err := p.helmFetchIfRequired(settings); err != nil {
-		p.logger.Errorf("error checking/fetching chart, err: %v\n", err)
+func (p *HelmChartPlugin) chartPathAndName() (chartPath string, chartName string) {
+	if p.SubChart != "" {
+		return filepath.Join(p.ChartHome, p.ChartName, "charts", p.SubChart), p.SubChart
+	}
+	return filepath.Join(p.ChartHome, p.ChartName), p.ChartName
+}
+
+func (p *HelmChartPlugin) executeHelmTemplate() ([]byte, error) {
+	settings := p.helmSettings()
+
+	if _, err := p.fetchChart(settings); err != nil {
 		return nil, err
 	}
+	return p.renderChart(settings)
+}
 
-	var chartPath string
-	var chartName string
+func (p *HelmChartPlugin) executeCachedHelmTemplate() ([]byte, error) {
+	settings := p.helmSettings()
 
-	if p.SubChart != "" {
-		chartName = p.SubChart
-		chartPath = filepath.Join(p.ChartHome, p.ChartName, "charts", p.SubChart)
-	} else {
-		chartName = p.ChartName
-		chartPath = filepath.Join(p.ChartHome, p.ChartName)
+	chartDigest, err := p.fetchChart(settings)
+	if err != nil {
+		return nil, err
+	}
+	renderKey, err := utils.RenderKey(chartDigest, p.Values,
+		p.ReleaseName, p.ReleaseNamespace, strconv.FormatBool(*p.IncludeCRDs), p.SubChart, p.NewChartVersion,
+		p.Hooks, strconv.FormatBool(*p.IncludeTests), strconv.FormatBool(p.IncludeNotes))
+	if err != nil {
+		p.logger.Errorf("error computing render cache key for chart: %v, err: %v\n", p.ChartName, err)
+		return nil, err
+	}
+	if templatedYaml, found := p.chartCache.LookupRendered(renderKey); found {
+		p.logger.Info("reading previously generated yaml from chart cache...")
+		return templatedYaml, nil
 	}
 
-	os.RemoveAll(filepath.Join(chartPath, "tmpcharts"))
+	templatedYaml, err := p.renderChart(settings)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.chartCache.StoreRendered(renderKey, utils.ChartCacheEntry{
+		Repo:    p.ChartRepo,
+		Name:    p.ChartName,
+		Version: p.ChartVersion,
+		Digest:  chartDigest,
+	}, templatedYaml); err != nil {
+		p.logger.Errorf("error storing rendered chart: %v in chart cache, error: %v\n", p.ChartName, err)
+		p.diagnostics.Warnf(p.ChartName, diagnostics.ReasonIgnoredError, "rendered chart not stored in the chart cache: %v", err)
+	} else if err := p.chartCache.Evict(); err != nil {
+		p.logger.Errorf("error evicting from chart cache, error: %v\n", err)
+		p.diagnostics.Warnf(p.ChartName, diagnostics.ReasonIgnoredError, "chart cache not evicted: %v", err)
+	}
+	return templatedYaml, nil
+}
+
+// fetchChart makes sure the chart is present in ChartHome, records it in the lock file
+// and the inputs of the build and returns its content digest when any of them, or the
+// chart cache, needs it.
+func (p *HelmChartPlugin) fetchChart(settings *cli.EnvSettings) (chartDigest string, err error) {
+	if err = p.helmFetchIfRequired(settings); err != nil {
+		p.logger.Errorf("error checking/fetching chart, err: %v\n", err)
+		return "", err
+	}
+	if p.chartCache == nil && p.lockfile == nil && p.inputs == nil {
+		return "", nil
+	}
+	chartDir := filepath.Join(p.ChartHome, p.ChartName)
+	if chartDigest, err = utils.ChartDirDigest(chartDir); err != nil {
+		p.logger.Errorf("error computing digest for chart: %v, err: %v\n", p.ChartName, err)
+		return "", err
+	}
+	if p.lockfile != nil || p.inputs != nil {
+		chartVersion, err := readChartVersion(chartDir)
+		if err != nil {
+			p.logger.Errorf("error reading version of chart: %v, err: %v\n", p.ChartName, err)
+			return "", err
+		}
+		chartURI := p.ChartName
+		if p.ChartRepo != "" {
+			chartURI = strings.TrimSuffix(p.ChartRepo, "/") + "/" + p.ChartName
+		}
+		p.inputs.RecordDigest(provenance.InputHelmChart, chartURI+"@"+chartVersion, map[string]string{"sha256": strings.TrimPrefix(chartDigest, "sha256-")})
+		if p.lockfile == nil {
+			return chartDigest, nil
+		}
+		if err := p.lockfile.RecordChart(types.ChartLock{
+			Repo:             p.ChartRepo,
+			Name:             p.ChartName,
+			RequestedVersion: p.requestedChartVersion,
+			Version:          chartVersion,
+			Digest:           chartDigest,
+			ManifestDigest:   p.ociManifestDigest,
+		}); err != nil {
+			p.logger.Errorf("error recording chart: %v in lock file, err: %v\n", p.ChartName, err)
+			return "", err
+		}
+	}
+	return chartDigest, nil
+}
+
+func (p *HelmChartPlugin) renderChart(settings *cli.EnvSettings) ([]byte, error) {
+	chartPath, chartName := p.chartPathAndName()
+
 	c, err := p.loadChartWithDependencies(settings, chartPath)
 	if err != nil {
 		p.logger.Errorf("error building dependencies, err: %v\n", err)
 		return nil, err
 	}
 
+	if err := p.validateValues(c); err != nil {
+		p.logger.Errorf("error validating values for chart: %v, err: %v\n", chartName, err)
+		return nil, err
+	}
+
 	resources, err := p.helmTemplate(settings, c, p.ReleaseName, p.Values)
 	if err != nil {
 		p.logger.Errorf("error executing helm template for chart: %v at path: %v, err: %v\n", chartName, chartPath, err)
@@ -223,6 +434,70 @@ func (p *HelmChartPlugin) executeHelmTemplate() ([]byte, error) {
 	return resources, nil
 }
 
+// validateValues validates the values, merged with the chart's defaults, against the
+// values.schema.json of the chart and of its enabled subcharts, like helm does when
+// templating, but naming the config that set each offending value.
+func (p *HelmChartPlugin) validateValues(c *chart.Chart) error {
+	if err := chartutil.ProcessDependencies(c, p.Values); err != nil {
+		return err
+	}
+	vals, err := chartutil.CoalesceValues(c, p.Values)
+	if err != nil {
+		return err
+	}
+	var schemaErrors []string
+	if err := p.validateValuesAgainstSchema(c, vals, "", &schemaErrors); err != nil {
+		return err
+	}
+	if len(schemaErrors) > 0 {
+		sort.Strings(schemaErrors)
+		return fmt.Errorf("values don't meet the specifications of the schema(s) in chart: %v\n%v",
+			c.Name(), strings.Join(schemaErrors, "\n"))
+	}
+	return nil
+}
+
+func (p *HelmChartPlugin) validateValuesAgainstSchema(c *chart.Chart, vals map[string]interface{}, prefix string, schemaErrors *[]string) error {
+	if c.Schema != nil {
+		schemaJson, err := yaml.YAMLToJSON(c.Schema)
+		if err != nil {
+			return errors.Wrapf(err, "error reading values.schema.json of chart: %v", c.Name())
+		}
+		valuesJson, err := json.Marshal(vals)
+		if err != nil {
+			return err
+		}
+		if bytes.Equal(valuesJson, []byte("null")) {
+			valuesJson = []byte("{}")
+		}
+		result, err := gojsonschema.Validate(gojsonschema.NewBytesLoader(schemaJson), gojsonschema.NewBytesLoader(valuesJson))
+		if err != nil {
+			return errors.Wrapf(err, "error validating values against values.schema.json of chart: %v", c.Name())
+		}
+		for _, resultError := range result.Errors() {
+			path := prefix + resultError.Field()
+			if resultError.Field() == gojsonschema.STRING_ROOT_SCHEMA_PROPERTY {
+				path = strings.TrimSuffix(prefix, ".")
+			}
+			if property, ok := resultError.Details()["property"].(string); ok && resultError.Type() == "additional_property_not_allowed" {
+				path = strings.TrimPrefix(path+"."+property, ".")
+			}
+			schemaError := fmt.Sprintf("- %v: %v", path, resultError.Description())
+			if origin, ok := utils.ValuesOrigin(p.valuesOrigins, path); ok {
+				schemaError = fmt.Sprintf("- %v (set by %v): %v", path, origin, resultError.Description())
+			}
+			*schemaErrors = append(*schemaErrors, schemaError)
+		}
+	}
+	for _, subchart := range c.Dependencies() {
+		subchartVals, _ := vals[subchart.Name()].(map[string]interface{})
+		if err := p.validateValuesAgainstSchema(subchart, subchartVals, prefix+subchart.Name()+".", schemaErrors); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (p *HelmChartPlugin) directoryExists(path string) (exists bool, err error) {
 	if info, err := os.Stat(path); err != nil && os.IsNotExist(err) {
 		exists = false
@@ -278,6 +553,14 @@ func (p *HelmChartPlugin) helmFetchIfRequired(settings *cli.EnvSettings) error {
 	}
 	if !exists {
 		os.RemoveAll(chartDir)
+		if fromCache, err := p.copyChartFromCache(chartDir); err != nil {
+			return err
+		} else if fromCache {
+			return nil
+		}
+		if p.mirrorConfig.Offline {
+			return p.copyChartFromMirror(chartDir)
+		}
 		if !strings.HasPrefix(p.ChartRepo, "oci") {
 			if repoName, err := p.helmConfigForChart(settings, p.ChartRepoName); err != nil {
 				return err
@@ -289,21 +572,112 @@ func (p *HelmChartPlugin) helmFetchIfRequired(settings *cli.EnvSettings) error {
 			p.logger.Errorf("error fetching chart, err: %v\n", err)
 			return err
 		}
+		p.storeChartInCache(chartDir)
 	} else {
 		p.logger.Infof("no need to fetch, chart is already at path: %v\n", chartDir)
 	}
 	return nil
 }
 
+func (p *HelmChartPlugin) copyChartFromCache(chartDir string) (bool, error) {
+	if p.chartCache == nil || p.ChartVersion == "" {
+		return false, nil
+	}
+	cachedChartDir, digest, found := p.chartCache.LookupChart(p.ChartRepo, p.ChartName, p.ChartVersion)
+	if !found {
+		return false, nil
+	}
+	p.logger.Infof("copying chart: %v, version: %v, digest: %v from chart cache\n", p.ChartName, p.ChartVersion, digest)
+	if err := utils.CopyDir(cachedChartDir, chartDir, p.logger); err != nil {
+		p.logger.Errorf("error copying chart from cache: %v to: %v, error: %v\n", cachedChartDir, chartDir, err)
+		os.RemoveAll(chartDir)
+		return false, err
+	}
+	return true, nil
+}
+
+func (p *HelmChartPlugin) copyChartFromMirror(chartDir string) error {
+	mirrorChartDir, resolvedVersion, err := utils.ResolveChartFromMirror(p.mirrorConfig.ChartMirror, p.ChartName, p.ChartVersion)
+	if err != nil {
+		p.logger.Errorf("error resolving chart from mirror: %v\n", err)
+		return err
+	}
+	p.logger.Infof("copying chart: %v, version: %v from mirror: %v\n", p.ChartName, resolvedVersion, p.mirrorConfig.ChartMirror)
+	if err := utils.CopyDir(mirrorChartDir, chartDir, p.logger); err != nil {
+		p.logger.Errorf("error copying chart from mirror: %v to: %v, error: %v\n", mirrorChartDir, chartDir, err)
+		os.RemoveAll(chartDir)
+		return err
+	}
+	return nil
+}
+
+func (p *HelmChartPlugin) vendorChart() error {
+	chartDir := filepath.Join(p.ChartHome, p.ChartName)
+	chartVersion, err := readChartVersion(chartDir)
+	if err != nil {
+		return err
+	}
+	return utils.StoreChartInMirror(p.mirrorConfig.ChartMirror, p.ChartName, chartVersion, chartDir, p.logger)
+}
+
+func readChartVersion(chartDir string) (string, error) {
+	chartBytes, err := ioutil.ReadFile(filepath.Join(chartDir, "Chart.yaml"))
+	if err != nil {
+		return "", err
+	}
+	var chartYaml map[string]interface{}
+	if err := yaml.Unmarshal(chartBytes, &chartYaml); err != nil {
+		return "", err
+	}
+	chartVersion, _ := chartYaml["version"].(string)
+	return chartVersion, nil
+}
+
+func (p *HelmChartPlugin) storeChartInCache(chartDir string) {
+	if p.chartCache == nil {
+		return
+	}
+	fetchedVersion, err := readChartVersion(chartDir)
+	if err != nil {
+		p.logger.Errorf("error reading Chart.yaml of fetched chart: %v, error: %v\n", chartDir, err)
+		p.diagnostics.Warnf(p.ChartName, diagnostics.ReasonIgnoredError, "chart not stored in the chart cache: %v", err)
+		return
+	}
+	if _, err := p.chartCache.StoreChart(p.ChartRepo, p.ChartName, fetchedVersion, chartDir); err != nil {
+		p.logger.Errorf("error storing chart: %v in chart cache, error: %v\n", p.ChartName, err)
+		p.diagnostics.Warnf(p.ChartName, diagnostics.ReasonIgnoredError, "chart not stored in the chart cache: %v", err)
+	}
+}
+
 func (p *HelmChartPlugin) helmConfigForChart(settings *cli.EnvSettings, repoName string) (string, error) {
+	repoName, err := p.helmRepoAddForChart(settings, repoName)
+	if err != nil {
+		return "", err
+	}
+	if err := p.helmReposUpdate(settings); err != nil {
+		p.logger.Errorf("error updating helm repos, err: %v\n", err)
+		return "", err
+	}
+	return repoName, nil
+}
+
+// lockRepoConfig locks the helm repositories file, for the time of reading and updating it.
+func (p *HelmChartPlugin) lockRepoConfig(settings *cli.EnvSettings) (unlockFn func(), err error) {
 	helmConfigHomeAndCacheDir := filepath.Dir(settings.RepositoryConfig)
 	lockFilePath := filepath.Join(helmConfigHomeAndCacheDir, "helm-repo-config.flock")
-	if unlockFn, err := utils.LockPath(lockFilePath, p.LockTimeoutSeconds, p.LockRetryDelayMinMilliSeconds, p.LockRetryDelayMaxMilliSeconds, p.logger); err != nil {
+	if unlockFn, err = utils.LockPath(lockFilePath, p.LockTimeoutSeconds, p.LockRetryDelayMinMilliSeconds, p.LockRetryDelayMaxMilliSeconds, p.logger); err != nil {
 		p.logger.Errorf("error locking helm config home and cache directory: %v, error: %v\n", helmConfigHomeAndCacheDir, err)
+		return nil, err
+	}
+	return unlockFn, nil
+}
+
+func (p *HelmChartPlugin) helmRepoAddForChart(settings *cli.EnvSettings, repoName string) (string, error) {
+	unlockFn, err := p.lockRepoConfig(settings)
+	if err != nil {
 		return "", err
-	} else {
-		defer unlockFn()
 	}
+	defer unlockFn()
 
 	if repoName == "" {
 		repoFileEntries, err := getRepoFileEntries(settings)
@@ -324,10 +698,6 @@ func (p *HelmChartPlugin) helmConfigForChart(settings *cli.EnvSettings, repoName
 		p.logger.Errorf("error adding repo: %v, err: %v\n", p.ChartRepo, err)
 		return "", err
 	}
-	if err := p.helmReposUpdate(settings); err != nil {
-		p.logger.Errorf("error updating helm repos, err: %v\n", err)
-		return "", err
-	}
 	return repoName, nil
 }
 
@@ -361,6 +731,7 @@ func (p *HelmChartPlugin) helmRepoAdd(settings *cli.EnvSettings, repoEntry *repo
 	if err != nil {
 		return err
 	}
+	chartRepository.CachePath = settings.RepositoryCache
 
 	if _, err := chartRepository.DownloadIndexFile(); err != nil {
 		return errors.Wrapf(err, "looks like %q is not a valid chart repository or cannot be reached", repoEntry.URL)
@@ -375,6 +746,8 @@ func (p *HelmChartPlugin) helmRepoAdd(settings *cli.EnvSettings, repoEntry *repo
 	return nil
 }
 
+// helmReposUpdate downloads the stale index files of the repositories in parallel,
+// each holding a lock on its index file only.
 func (p *HelmChartPlugin) helmReposUpdate(settings *cli.EnvSettings) error {
 	var (
 		repoFilePath = settings.RepositoryConfig
@@ -390,22 +763,19 @@ func (p *HelmChartPlugin) helmReposUpdate(settings *cli.EnvSettings) error {
 		return errors.New("no repositories found. You must add one before updating")
 	}
 
-	now := time.Now()
 	for _, cfg = range repoFile.Repositories {
 		r, err := repo.NewChartRepository(cfg, getter.All(settings))
 		if err != nil {
 			return err
 		}
+		r.CachePath = settings.RepositoryCache
 
 		//only re-download index file if it's older than some threshold:
-		indexFilePath := filepath.Join(r.CachePath, helmpath.CacheIndexFile(r.Config.Name))
-		if fileInfo, err := os.Stat(indexFilePath); err != nil {
+		if stale, err := p.helmRepoIndexIsStale(r); err != nil {
 			return err
-		} else if timerSinceUpdate := now.Sub(fileInfo.ModTime()); timerSinceUpdate > time.Duration(p.RepoIndexFileStaleAfterSeconds)*time.Second {
+		} else if stale {
 			p.logger.Infof("Will be updating repository: %v\n", r.Config.Name)
 			repos = append(repos, r)
-		} else {
-			p.logger.Infof("Will NOT be updating repository: %v, because it was updated: %v ago\n", r.Config.Name, timerSinceUpdate)
 		}
 	}
 
@@ -414,8 +784,21 @@ func (p *HelmChartPlugin) helmReposUpdate(settings *cli.EnvSettings) error {
 			wg.Add(1)
 			go func(re *repo.ChartRepository) {
 				defer wg.Done()
+				indexFilePath := filepath.Join(re.CachePath, helmpath.CacheIndexFile(re.Config.Name))
+				unlockFn, err := utils.LockPath(indexFilePath+".flock", p.LockTimeoutSeconds, p.LockRetryDelayMinMilliSeconds, p.LockRetryDelayMaxMilliSeconds, p.logger)
+				if err != nil {
+					p.logger.Errorf("...Unable to lock the index file of the %q chart repository: %v\n", re.Config.Name, err)
+					p.diagnostics.Warnf(re.Config.Name, diagnostics.ReasonIgnoredError, "chart repository index not updated: %v", err)
+					return
+				}
+				defer unlockFn()
+				//another chart may have updated it while waiting for the lock:
+				if stale, err := p.helmRepoIndexIsStale(re); err == nil && !stale {
+					return
+				}
 				if _, err := re.DownloadIndexFile(); err != nil {
 					p.logger.Errorf("...Unable to get an update from the %q chart repository (%s):\n\t%s\n", re.Config.Name, re.Config.URL, err)
+					p.diagnostics.Warnf(re.Config.Name, diagnostics.ReasonIgnoredError, "chart repository index not updated: %v", err)
 				} else {
 					p.logger.Infof("...Successfully got an update from the %q chart repository\n", re.Config.Name)
 				}
@@ -427,6 +810,20 @@ func (p *HelmChartPlugin) helmReposUpdate(settings *cli.EnvSettings) error {
 	return nil
 }
 
+func (p *HelmChartPlugin) helmRepoIndexIsStale(r *repo.ChartRepository) (bool, error) {
+	indexFilePath := filepath.Join(r.CachePath, helmpath.CacheIndexFile(r.Config.Name))
+	fileInfo, err := os.Stat(indexFilePath)
+	if err != nil {
+		return false, err
+	}
+	timerSinceUpdate := time.Now().Sub(fileInfo.ModTime())
+	if timerSinceUpdate > time.Duration(p.RepoIndexFileStaleAfterSeconds)*time.Second {
+		return true, nil
+	}
+	p.logger.Infof("Will NOT be updating repository: %v, because it was updated: %v ago\n", r.Config.Name, timerSinceUpdate)
+	return false, nil
+}
+
 func (p *HelmChartPlugin) helmOciFetch(settings *cli.EnvSettings, chartRef, version, chartUntarDirPath string) error {
 	p.logger.Infof("Fetching chart chartRef: %v, Version: %v\n", chartRef, version)
 
@@ -444,11 +841,7 @@ func (p *HelmChartPlugin) helmOciFetch(settings *cli.EnvSettings, chartRef, vers
 		credentialsFile = filepath.Join(home, ".docker", "config.json")
 	}
 
-	registryClient, err := registry.NewClient(
-		registry.ClientOptDebug(settings.Debug),
-		registry.ClientOptWriter(utils.GetLogWriter(p.logger)),
-		registry.ClientOptCredentialsFile(credentialsFile),
-	)
+	registryClient, err := p.helmRegistryClient(settings, credentialsFile)
 	if err != nil {
 		return err
 	}
@@ -460,8 +853,57 @@ func (p *HelmChartPlugin) helmOciFetch(settings *cli.EnvSettings, chartRef, vers
 	client.Settings = settings
 	client.Version = version
 
-	_, err = client.Run(chartRef)
-	return err
+	if _, err = client.Run(chartRef); err != nil {
+		return err
+	}
+	if p.lockfile != nil {
+		if p.ociManifestDigest, err = ociManifestDigest(credentialsFile, chartRef, version); err != nil {
+			p.logger.Errorf("error resolving the manifest digest of chart: %v, err: %v\n", chartRef, err)
+			return err
+		}
+	}
+	return nil
+}
+
+// ociManifestDigest returns the digest of the manifest chartRef at version
+// resolves to in its OCI registry.
+func ociManifestDigest(credentialsFile, chartRef, version string) (string, error) {
+	ref, err := registry.ParseReference(fmt.Sprintf("%v:%v", strings.TrimPrefix(chartRef, "oci://"), version))
+	if err != nil {
+		return "", err
+	}
+	authClient, err := dockerauth.NewClient(credentialsFile)
+	if err != nil {
+		return "", err
+	}
+	resolver, err := authClient.Resolver(context.Background(), http.DefaultClient, false)
+	if err != nil {
+		return "", err
+	}
+	_, desc, err := resolver.Resolve(context.Background(), ref.FullName())
+	if err != nil {
+		return "", err
+	}
+	return desc.Digest.String(), nil
+}
+
+// helmRegistryClient makes a registry client with its cache next to the repository
+// cache of settings, instead of where the env, shared with other charts, points to.
+func (p *HelmChartPlugin) helmRegistryClient(settings *cli.EnvSettings, credentialsFile string) (*registry.Client, error) {
+	cache, err := registry.NewCache(
+		registry.CacheOptDebug(settings.Debug),
+		registry.CacheOptWriter(utils.GetLogWriter(p.logger)),
+		registry.CacheOptRoot(filepath.Join(filepath.Dir(settings.RepositoryCache), "registry", registry.CacheRootDir)),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return registry.NewClient(
+		registry.ClientOptDebug(settings.Debug),
+		registry.ClientOptWriter(utils.GetLogWriter(p.logger)),
+		registry.ClientOptCredentialsFile(credentialsFile),
+		registry.ClientOptCache(cache),
+	)
 }
 
 func (p *HelmChartPlugin) helmFetch(settings *cli.EnvSettings, chartRef, version, chartUntarDirPath string) error {
@@ -505,15 +947,111 @@ func (p *HelmChartPlugin) helmTemplate(settings *cli.EnvSettings, c *chart.Chart
 
 	var manifests bytes.Buffer
 	fmt.Fprintln(&manifests, strings.TrimSpace(rel.Manifest))
-	if !client.DisableHooks {
-		for _, m := range rel.Hooks {
-			fmt.Fprintf(&manifests, "---\n# Source: %s\n%s\n", m.Path, m.Manifest)
+	for _, hook := range rel.Hooks {
+		manifest := hook.Manifest
+		if isHelmTestHook(hook) {
+			if !*p.IncludeTests {
+				continue
+			}
+		} else if p.Hooks == HelmHooksDrop {
+			continue
 		}
+		if p.Hooks == HelmHooksAnnotate {
+			if manifest, err = helmHookToAnnotations(hook.Manifest); err != nil {
+				return nil, errors.Wrapf(err, "error converting hook: %v", hook.Path)
+			}
+		}
+		fmt.Fprintf(&manifests, "---\n# Source: %s\n%s\n", hook.Path, manifest)
+	}
+	if p.IncludeNotes && rel.Info != nil && strings.TrimSpace(rel.Info.Notes) != "" {
+		notes, err := yaml.Marshal(map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "ConfigMap",
+			"metadata": map[string]interface{}{
+				"name": fmt.Sprintf("%v-notes", releaseName),
+			},
+			"data": map[string]string{
+				"NOTES.txt": rel.Info.Notes,
+			},
+		})
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(&manifests, "---\n# Source: %s/templates/NOTES.txt\n%s", c.Name(), notes)
 	}
 
 	return manifests.Bytes(), nil
 }
 
+func makeHelmPostRenderers(h *resmap.PluginHelpers, postRenderers []HelmPostRenderer) ([]resmap.Transformer, error) {
+	var transformers []resmap.Transformer
+	defaultConfig := builtinconfig.MakeDefaultConfig()
+	for _, postRenderer := range postRenderers {
+		for _, patch := range postRenderer.Patches {
+			c, err := yaml.Marshal(patch)
+			if err != nil {
+				return nil, err
+			}
+			patcher := builtins.NewPatchTransformerPlugin()
+			if err := patcher.Config(h, c); err != nil {
+				return nil, errors.Wrapf(err, "invalid patch: %v", string(c))
+			}
+			transformers = append(transformers, patcher)
+		}
+		for _, image := range postRenderer.Images {
+			transformers = append(transformers, &builtins.ImageTagTransformerPlugin{
+				ImageTag:   image,
+				FieldSpecs: defaultConfig.Images,
+			})
+		}
+		if len(postRenderer.Labels) > 0 {
+			transformers = append(transformers, &builtins.LabelTransformerPlugin{
+				Labels:     postRenderer.Labels,
+				FieldSpecs: defaultConfig.CommonLabels,
+			})
+		}
+	}
+	return transformers, nil
+}
+
+func isHelmTestHook(hook *release.Hook) bool {
+	for _, event := range hook.Events {
+		if event == release.HookTest {
+			return true
+		}
+	}
+	return false
+}
+
+// helmHookToAnnotations turns a hook into a regular resource, moving
+// its helm.sh/hook annotations under helmHookAnnotationPrefix.
+func helmHookToAnnotations(manifest string) (string, error) {
+	nodes, err := kio.FromBytes([]byte(manifest))
+	if err != nil {
+		return "", err
+	}
+	for _, node := range nodes {
+		annotations, err := node.GetAnnotations()
+		if err != nil {
+			return "", err
+		}
+		for k, v := range annotations {
+			if k == release.HookAnnotation || strings.HasPrefix(k, release.HookAnnotation+"-") {
+				delete(annotations, k)
+				annotations[helmHookAnnotationPrefix+strings.TrimPrefix(k, "helm.sh/")] = v
+			}
+		}
+		if err := node.SetAnnotations(annotations); err != nil {
+			return "", err
+		}
+	}
+	var out bytes.Buffer
+	if err := (kio.ByteWriter{Writer: &out}).Write(nodes); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
+
 func isChartInstallable(ch *chart.Chart) (bool, error) {
 	switch ch.Metadata.Type {
 	case "", "application":
@@ -539,6 +1077,7 @@ func (p *HelmChartPlugin) loadChartWithDependencies(settings *cli.EnvSettings, c
 		defer unlockFn()
 	}
 
+	os.RemoveAll(filepath.Join(chartPath, "tmpcharts"))
 	c, err := loader.Load(chartPath)
 	if err != nil {
 		return nil, err
@@ -554,6 +1093,11 @@ func (p *HelmChartPlugin) loadChartWithDependencies(settings *cli.EnvSettings, c
 			p.logger.Errorf("dependency check returned failed: %v\n", err)
 			return nil, err
 		} else if !ok {
+			if p.mirrorConfig.Offline {
+				err := fmt.Errorf("dependencies of chart: %v are not present in mirror: %v, vendor the chart with its dependencies", c.Name(), p.mirrorConfig.ChartMirror)
+				p.logger.Errorf("%v\n", err)
+				return nil, err
+			}
 			buildingDependencies = true
 
 			if err := p.helmConfigForDependencies(settings, c); err != nil {
@@ -565,11 +1109,7 @@ func (p *HelmChartPlugin) loadChartWithDependencies(settings *cli.EnvSettings, c
 			}
 			credentialsFile := filepath.Join(home, ".docker", "config.json")
 
-			registryClient, err := registry.NewClient(
-				registry.ClientOptDebug(settings.Debug),
-				registry.ClientOptWriter(utils.GetLogWriter(p.logger)),
-				registry.ClientOptCredentialsFile(credentialsFile),
-			)
+			registryClient, err := p.helmRegistryClient(settings, credentialsFile)
 			if err != nil {
 				return nil, err
 			}
@@ -625,31 +1165,36 @@ func (p *HelmChartPlugin) checkDependencies(ch *chart.Chart, reqs []*chart.Depen
 }
 
 func (p *HelmChartPlugin) helmConfigForDependencies(settings *cli.EnvSettings, c *chart.Chart) error {
-	helmConfigHomeAndCacheDir := filepath.Dir(settings.RepositoryConfig)
-	lockFilePath := filepath.Join(helmConfigHomeAndCacheDir, "helm-repo-config.flock")
-	if unlockFn, err := utils.LockPath(lockFilePath, p.LockTimeoutSeconds, p.LockRetryDelayMinMilliSeconds, p.LockRetryDelayMaxMilliSeconds, p.logger); err != nil {
-		p.logger.Errorf("error locking helm config home and cache directory: %v, error: %v\n", helmConfigHomeAndCacheDir, err)
+	if added, err := p.helmRepoAddForDependencies(settings, c); err != nil {
 		return err
-	} else {
-		defer unlockFn()
+	} else if added {
+		if err := p.helmReposUpdate(settings); err != nil {
+			p.logger.Errorf("error updating helm repos while processing dependencies, err: %v\n", err)
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *HelmChartPlugin) helmRepoAddForDependencies(settings *cli.EnvSettings, c *chart.Chart) (bool, error) {
+	unlockFn, err := p.lockRepoConfig(settings)
+	if err != nil {
+		return false, err
 	}
+	defer unlockFn()
 
-	if dependencyRepoEntries, err := p.resolveDependencyRepos(settings, c); err != nil {
+	dependencyRepoEntries, err := p.resolveDependencyRepos(settings, c)
+	if err != nil {
 		p.logger.Errorf("error resolving dependency repos: %v\n", err)
-		return err
-	} else {
-		for _, repoEntry := range dependencyRepoEntries {
-			if err := p.helmRepoAdd(settings, repoEntry); err != nil {
-				p.logger.Errorf("error adding dependency repo: %v to the repo file: %v\n", repoEntry.Name, err)
-				return err
-			}
-			if err := p.helmReposUpdate(settings); err != nil {
-				p.logger.Errorf("error updating helm repos while processing dependencies, err: %v\n", err)
-				return err
-			}
+		return false, err
+	}
+	for _, repoEntry := range dependencyRepoEntries {
+		if err := p.helmRepoAdd(settings, repoEntry); err != nil {
+			p.logger.Errorf("error adding dependency repo: %v to the repo file: %v\n", repoEntry.Name, err)
+			return false, err
 		}
 	}
-	return nil
+	return len(dependencyRepoEntries) > 0, nil
 }
 
 func (p *HelmChartPlugin) resolveDependencyRepos(settings *cli.EnvSettings, c *chart.Chart) ([]*repo.Entry, error) {
@@ -1074,3 +1074,276 @@ includeCRDs: false
 	}
 
 }
+
+func writeTestChartWithHooks(t *testing.T, chartHome, chartName string) {
+	for name, content := range map[string]string{
+		"Chart.yaml": fmt.Sprintf(`
+apiVersion: v2
+name: %v
+version: 0.1.0
+`, chartName),
+		"templates/deployment.yaml": `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: {{ .Release.Name }}
+spec:
+  template:
+    spec:
+      containers:
+      - name: app
+        image: nginx:1.19
+`,
+		"templates/migrate.yaml": `
+apiVersion: batch/v1
+kind: Job
+metadata:
+  name: {{ .Release.Name }}-migrate
+  annotations:
+    helm.sh/hook: pre-install
+    helm.sh/hook-weight: "-5"
+spec:
+  template:
+    spec:
+      containers:
+      - name: migrate
+        image: busybox
+`,
+		"templates/tests/test-connection.yaml": `
+apiVersion: v1
+kind: Pod
+metadata:
+  name: {{ .Release.Name }}-test
+  annotations:
+    helm.sh/hook: test
+spec:
+  containers:
+  - name: wget
+    image: busybox
+`,
+		"templates/NOTES.txt": `Thank you for installing {{ .Chart.Name }}.`,
+	} {
+		assert.NoError(t, os.MkdirAll(path.Dir(path.Join(chartHome, chartName, name)), os.ModePerm))
+		assert.NoError(t, ioutil.WriteFile(path.Join(chartHome, chartName, name), []byte(content), os.ModePerm))
+	}
+}
+
+func TestHelmChart_hooksTestsNotesAndPostRenderers(t *testing.T) {
+	testHome, err := ioutil.TempDir("", "")
+	assert.NoError(t, err)
+	defer os.RemoveAll(testHome)
+	writeTestChartWithHooks(t, testHome, "hooks")
+
+	testCases := []struct {
+		name            string
+		config          string
+		checkAssertions func(*testing.T, resmap.ResMap)
+	}{
+		{
+			name: "include by default",
+			checkAssertions: func(t *testing.T, m resmap.ResMap) {
+				assert.Equal(t, 3, m.Size())
+				job, err := m.GetById(resid.NewResId(resid.Gvk{Group: "batch", Version: "v1", Kind: "Job"}, "hooks-migrate"))
+				assert.NoError(t, err)
+				assert.Equal(t, "pre-install", job.GetAnnotations()["helm.sh/hook"])
+			},
+		},
+		{
+			name:   "drop hooks and tests",
+			config: "hooks: drop",
+			checkAssertions: func(t *testing.T, m resmap.ResMap) {
+				assert.Equal(t, 1, m.Size())
+				assert.Equal(t, "Deployment", m.Resources()[0].GetKind())
+			},
+		},
+		{
+			name: "drop hooks, keep tests",
+			config: `hooks: drop
+includeTests: true`,
+			checkAssertions: func(t *testing.T, m resmap.ResMap) {
+				assert.Equal(t, 2, m.Size())
+				_, err := m.GetById(resid.NewResId(resid.Gvk{Version: "v1", Kind: "Pod"}, "hooks-test"))
+				assert.NoError(t, err)
+			},
+		},
+		{
+			name: "annotate hooks, drop tests, keep notes",
+			config: `hooks: annotate
+includeTests: false
+includeNotes: true`,
+			checkAssertions: func(t *testing.T, m resmap.ResMap) {
+				assert.Equal(t, 3, m.Size())
+				job, err := m.GetById(resid.NewResId(resid.Gvk{Group: "batch", Version: "v1", Kind: "Job"}, "hooks-migrate"))
+				assert.NoError(t, err)
+				assert.Equal(t, map[string]string{
+					"helm.qlik.com/hook":        "pre-install",
+					"helm.qlik.com/hook-weight": "-5",
+				}, job.GetAnnotations())
+				notes, err := m.GetById(resid.NewResId(resid.Gvk{Version: "v1", Kind: "ConfigMap"}, "hooks-notes"))
+				assert.NoError(t, err)
+				assert.Equal(t, map[string]string{"NOTES.txt": "Thank you for installing hooks."}, notes.GetDataMap())
+			},
+		},
+		{
+			name: "post-renderers",
+			config: `hooks: drop
+postRenderers:
+- images:
+  - name: nginx
+    newTag: "1.20"
+  labels:
+    app: hooks
+- patches:
+  - target:
+      kind: Deployment
+    patch: |-
+      - op: add
+        path: /spec/replicas
+        value: 2`,
+			checkAssertions: func(t *testing.T, m resmap.ResMap) {
+				assert.Equal(t, 1, m.Size())
+				m.RemoveBuildAnnotations()
+				yamlBytes, err := m.AsYaml()
+				assert.NoError(t, err)
+				assert.Equal(t, `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  labels:
+    app: hooks
+  name: hooks
+spec:
+  replicas: 2
+  selector:
+    matchLabels:
+      app: hooks
+  template:
+    metadata:
+      labels:
+        app: hooks
+    spec:
+      containers:
+      - image: nginx:1.20
+        name: app
+`, string(yamlBytes))
+			},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			p := provider.NewDefaultDepProvider()
+			resourceFactory := resmap.NewFactory(p.GetResourceFactory())
+			pluginHelpers := resmap.NewPluginHelpers(loader.NewFileLoaderAtRoot(filesys.MakeFsInMemory()), valtest_test.MakeFakeValidator(), resourceFactory, types.DisabledPluginConfig())
+			plugin := NewHelmChartPlugin()
+			err := plugin.Config(pluginHelpers, []byte(fmt.Sprintf(`
+apiVersion: apps/v1
+kind: HelmChart
+metadata:
+  name: hooks
+chartHome: %v
+helmHome: %v
+chartName: hooks
+releaseName: hooks
+%v
+`, testHome, testHome, testCase.config)))
+			assert.NoError(t, err)
+
+			m, err := plugin.Generate()
+			assert.NoError(t, err)
+			testCase.checkAssertions(t, m)
+		})
+	}
+}
+
+func Test_Hooks_validation(t *testing.T) {
+	p := provider.NewDefaultDepProvider()
+	resourceFactory := resmap.NewFactory(p.GetResourceFactory())
+	pluginHelpers := resmap.NewPluginHelpers(loader.NewFileLoaderAtRoot(filesys.MakeFsInMemory()), valtest_test.MakeFakeValidator(), resourceFactory, types.DisabledPluginConfig())
+
+	plugin := NewHelmChartPlugin().(*HelmChartPlugin)
+	assert.NoError(t, plugin.Config(pluginHelpers, []byte(`
+apiVersion: apps/v1
+kind: HelmChart
+metadata:
+  name: dontCare
+`)))
+	assert.Equal(t, HelmHooksInclude, plugin.Hooks)
+	assert.True(t, *plugin.IncludeTests)
+
+	err := NewHelmChartPlugin().Config(pluginHelpers, []byte(`
+apiVersion: apps/v1
+kind: HelmChart
+metadata:
+  name: dontCare
+hooks: skip
+`))
+	assert.EqualError(t, err, "invalid hooks: skip, must be one of: include, drop, annotate")
+}
+
+func TestHelmChart_valuesSchema(t *testing.T) {
+	testHome, err := ioutil.TempDir("", "")
+	assert.NoError(t, err)
+	defer os.RemoveAll(testHome)
+	for name, content := range map[string]string{
+		"Chart.yaml": `
+apiVersion: v2
+name: schema
+version: 0.1.0
+`,
+		"values.yaml": `
+image:
+  repository: nginx
+  tag: "1.19"
+`,
+		"values.schema.json": `{
+  "type": "object",
+  "properties": {
+    "image": {
+      "type": "object",
+      "additionalProperties": false,
+      "properties": {
+        "repository": {"type": "string"},
+        "tag": {"type": "string"}
+      }
+    }
+  }
+}`,
+		"templates/configmap.yaml": `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: {{ .Release.Name }}
+data:
+  image: {{ .Values.image.repository }}:{{ .Values.image.tag }}
+`,
+	} {
+		assert.NoError(t, os.MkdirAll(path.Dir(path.Join(testHome, "schema", name)), os.ModePerm))
+		assert.NoError(t, ioutil.WriteFile(path.Join(testHome, "schema", name), []byte(content), os.ModePerm))
+	}
+
+	p := provider.NewDefaultDepProvider()
+	resourceFactory := resmap.NewFactory(p.GetResourceFactory())
+	pluginHelpers := resmap.NewPluginHelpers(loader.NewFileLoaderAtRoot(filesys.MakeFsInMemory()), valtest_test.MakeFakeValidator(), resourceFactory, types.DisabledPluginConfig())
+	plugin := NewHelmChartPlugin()
+	err = plugin.Config(pluginHelpers.WithConfigPath("/base/helmChart.yaml"), []byte(fmt.Sprintf(`
+apiVersion: apps/v1
+kind: HelmChart
+metadata:
+  name: schema
+  annotations:
+    config.kubernetes.io/helmValuesOrigins: '{"image.tagg":"/overlays/prod/values.yaml"}'
+chartHome: %v
+helmHome: %v
+chartName: schema
+releaseName: schema
+values:
+  image:
+    repository: 1234
+    tagg: "1.20"
+`, testHome, testHome)))
+	assert.NoError(t, err)
+
+	_, err = plugin.Generate()
+	assert.EqualError(t, err, `values don't meet the specifications of the schema(s) in chart: schema
+- image.repository (set by /base/helmChart.yaml): Invalid type. Expected: string, given: integer
+- image.tagg (set by /overlays/prod/values.yaml): Additional property tagg is not allowed`)
+}
@@ -2,10 +2,19 @@ package builtins_qlik
 
 import (
 	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
 
 	"github.com/imdario/mergo"
 	"go.uber.org/zap"
 	"sigs.k8s.io/kustomize/api/builtins_qlik/utils"
+	"sigs.k8s.io/kustomize/api/diagnostics"
+	"sigs.k8s.io/kustomize/api/helmvalues"
+	"sigs.k8s.io/kustomize/api/ifc"
+	"sigs.k8s.io/kustomize/api/konfig"
+	"sigs.k8s.io/kustomize/api/loader"
+	"sigs.k8s.io/kustomize/api/provenance"
 	"sigs.k8s.io/kustomize/api/resmap"
 	"sigs.k8s.io/kustomize/api/resource"
 	"sigs.k8s.io/kustomize/api/types"
@@ -15,18 +24,130 @@ import (
 	"sigs.k8s.io/yaml"
 )
 
+// The merge strategies of a HelmValues source.
+const (
+	// HelmValuesDeepMerge merges the maps of the source into the values,
+	// the other values of the source, lists included, replace the ones of the values.
+	HelmValuesDeepMerge = "deepMerge"
+	// HelmValuesReplace replaces the values, or the value at the path of the source.
+	HelmValuesReplace = "replace"
+	// HelmValuesAppend deep merges, appending the lists of the source to the ones of the values.
+	HelmValuesAppend = "append"
+	// HelmValuesMergeByKey deep merges, merging the items of the lists of the source into
+	// the items of the lists of the values with the same mergeKey, appending the other ones.
+	HelmValuesMergeByKey = "mergeByKey"
+)
+
+// HelmValuesSource is a source of values, merged into the values of the charts
+// after the values of the plugin and the sources before it. Exactly one of Values,
+// File and FromResource must be set.
+type HelmValuesSource struct {
+	Values       map[string]interface{}  `json:"values,omitempty" yaml:"values,omitempty"`
+	File         string                  `json:"file,omitempty" yaml:"file,omitempty"`
+	FromResource *HelmValuesFromResource `json:"fromResource,omitempty" yaml:"fromResource,omitempty"`
+	// Path, dot separated, places the values of the source in the values, at the root when empty.
+	Path string `json:"path,omitempty" yaml:"path,omitempty"`
+	// Strategy is deepMerge, the default, replace, append or mergeByKey.
+	Strategy string `json:"strategy,omitempty" yaml:"strategy,omitempty"`
+	// MergeKey identifies the items of lists for mergeByKey, name by default.
+	MergeKey string `json:"mergeKey,omitempty" yaml:"mergeKey,omitempty"`
+}
+
+// HelmValuesFromResource extracts the value at FieldPath of the one resource of the
+// input the selector selects. A string holding a YAML map, e.g. a values file kept
+// in a ConfigMap, is parsed.
+type HelmValuesFromResource struct {
+	types.Selector `json:",inline,omitempty" yaml:",inline,omitempty"`
+	FieldPath      string `json:"fieldPath,omitempty" yaml:"fieldPath,omitempty"`
+}
+
 type HelmValuesPlugin struct {
 	Overwrite        bool                   `json:"overwrite,omitempty" yaml:"overwrite,omitempty"`
 	Chart            string                 `json:"chartName,omitempty" yaml:"chartName,omitempty"`
+	Selector         *types.Selector        `json:"selector,omitempty" yaml:"selector,omitempty"`
 	ReleaseName      string                 `json:"releaseName,omitempty" yaml:"releaseName,omitempty"`
 	ReleaseNamespace string                 `json:"releaseNamespace,omitempty" yaml:"releaseNamespace,omitempty"`
 	FieldSpecs       []types.FieldSpec      `json:"fieldSpecs,omitempty" yaml:"fieldSpecs,omitempty"`
 	Values           map[string]interface{} `json:"values,omitempty" yaml:"values,omitempty"`
+	Sources          []HelmValuesSource     `json:"sources,omitempty" yaml:"sources,omitempty"`
+	origin           string
+	fileValues       []map[string]interface{}
+	ldr              ifc.Loader
+	diagnostics      *diagnostics.Reporter
+	helmValues       *helmvalues.Report
 	logger           *zap.SugaredLogger
 }
 
+// helmValuesSource is a source with its values, placed at its path.
+type helmValuesSource struct {
+	values   map[string]interface{}
+	path     []string
+	origin   string
+	strategy string
+	mergeKey string
+}
+
 func (p *HelmValuesPlugin) Config(h *resmap.PluginHelpers, c []byte) (err error) {
-	return yaml.Unmarshal(c, p)
+	if err = yaml.Unmarshal(c, p); err != nil {
+		return err
+	}
+	meta, err := configMetadata(c)
+	if err != nil {
+		return err
+	}
+	p.origin = configOrigin(h.ConfigPath(), "HelmValues", meta.Name)
+	p.ldr = h.Loader()
+	p.diagnostics = h.Diagnostics()
+	p.helmValues = h.HelmValues()
+	inputs := h.Inputs()
+	p.fileValues = make([]map[string]interface{}, len(p.Sources))
+	for i, source := range p.Sources {
+		if err := validateHelmValuesSource(source); err != nil {
+			err = fmt.Errorf("sources[%v] %v", i, err)
+			p.logger.Errorf("config error: %v\n", err)
+			return err
+		}
+		if source.File == "" {
+			continue
+		}
+		content, err := p.ldr.Load(source.File)
+		if err != nil {
+			p.logger.Errorf("error loading values file: %v, error: %v\n", source.File, err)
+			return err
+		}
+		inputs.Record(provenance.InputFile, loader.InputURI(p.ldr, source.File), content)
+		if err := yaml.Unmarshal(content, &p.fileValues[i]); err != nil {
+			p.logger.Errorf("error unmarshalling values file: %v, error: %v\n", source.File, err)
+			return err
+		}
+	}
+	return nil
+}
+
+func validateHelmValuesSource(source HelmValuesSource) error {
+	set := 0
+	if source.Values != nil {
+		set++
+	}
+	if source.File != "" {
+		set++
+	}
+	if source.FromResource != nil {
+		set++
+		if source.FromResource.FieldPath == "" {
+			return fmt.Errorf("fromResource has no fieldPath")
+		}
+	}
+	if set != 1 {
+		return fmt.Errorf("must set exactly one of values, file and fromResource")
+	}
+	switch source.Strategy {
+	case "", HelmValuesDeepMerge, HelmValuesReplace, HelmValuesAppend, HelmValuesMergeByKey:
+		return nil
+	default:
+		return fmt.Errorf("strategy must be %v, %v, %v or %v, not: %v",
+			HelmValuesDeepMerge, HelmValuesReplace, HelmValuesAppend, HelmValuesMergeByKey, source.Strategy)
+	}
 }
 
 func (p *HelmValuesPlugin) mutateValues(in interface{}) (interface{}, error) {
@@ -51,43 +172,180 @@ func (p *HelmValuesPlugin) mutateValues(in interface{}) (interface{}, error) {
 	return mergedData["root"], nil
 }
 
-func (p *HelmValuesPlugin) Transform(m resmap.ResMap) error {
+// resolveSources returns the sources with their values, the ones of the resources of m
+// included. A resource or field that is not in m is a warning, the source is skipped.
+func (p *HelmValuesPlugin) resolveSources(m resmap.ResMap) ([]helmValuesSource, error) {
+	var sources []helmValuesSource
+	for i, source := range p.Sources {
+		var values interface{}
+		origin := p.origin
+		switch {
+		case source.Values != nil:
+			values = source.Values
+		case source.File != "":
+			values = p.fileValues[i]
+			origin = filepath.Join(p.ldr.Root(), source.File)
+		default:
+			from := source.FromResource
+			selected, err := m.Select(from.Selector)
+			if err != nil {
+				return nil, err
+			}
+			if len(selected) == 0 {
+				p.diagnostics.Warnf(fmt.Sprintf("HelmValues sources[%v]", i), diagnostics.ReasonObjRefNotFound,
+					"no resource matches fromResource, the source was skipped")
+				continue
+			} else if len(selected) > 1 {
+				return nil, fmt.Errorf("sources[%v] fromResource matches %v resources, it must match one", i, len(selected))
+			}
+			value, err := utils.GetFieldValue(selected[0].Node(), from.FieldPath)
+			if _, ok := err.(kyaml.NoFieldError); ok {
+				p.diagnostics.Warnf(fmt.Sprintf("HelmValues sources[%v]", i), diagnostics.ReasonObjRefNotFound,
+					"%v %v has no field %v, the source was skipped", selected[0].GetKind(), selected[0].GetName(), from.FieldPath)
+				continue
+			} else if err != nil {
+				return nil, err
+			}
+			if s, ok := value.(string); ok {
+				var parsed map[string]interface{}
+				if err := yaml.Unmarshal([]byte(s), &parsed); err == nil && parsed != nil {
+					value = parsed
+				}
+			}
+			values = value
+			origin = fmt.Sprintf("%v/%v", selected[0].GetKind(), selected[0].GetName())
+		}
+		var path []string
+		if source.Path != "" {
+			path = strings.Split(source.Path, ".")
+			for j := len(path) - 1; j >= 0; j-- {
+				values = map[string]interface{}{path[j]: values}
+			}
+		}
+		valuesMap, ok := values.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("sources[%v] values are not a map, they must be placed at a path", i)
+		}
+		mergeKey := source.MergeKey
+		if mergeKey == "" {
+			mergeKey = "name"
+		}
+		sources = append(sources, helmValuesSource{values: valuesMap, path: path, origin: origin, strategy: source.Strategy, mergeKey: mergeKey})
+	}
+	return sources, nil
+}
+
+// targets returns the HelmCharts the values are merged into,
+// the ones the selector selects, else the ones of the chart.
+func (p *HelmValuesPlugin) targets(m resmap.ResMap) ([]*resource.Resource, error) {
+	var targets []*resource.Resource
+	if p.Selector != nil {
+		selected, err := m.Select(*p.Selector)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range selected {
+			if isHelmChart(r) {
+				targets = append(targets, r)
+			}
+		}
+		return targets, nil
+	}
 	for _, r := range m.Resources() {
-		if isHelmChart(r) {
-			if applyResources(r, p.Chart) {
-				if err := filtersutil.ApplyToJSON(kio.FilterFunc(func(nodes []*kyaml.RNode) ([]*kyaml.RNode, error) {
-					return kio.FilterAll(kyaml.FilterFunc(func(rn *kyaml.RNode) (*kyaml.RNode, error) {
-						if valuesRn, err := rn.Pipe(kyaml.FieldMatcher{Name: "values"}); err != nil {
+		if isHelmChart(r) && applyResources(r, p.Chart) {
+			targets = append(targets, r)
+		}
+	}
+	return targets, nil
+}
+
+func (p *HelmValuesPlugin) Transform(m resmap.ResMap) error {
+	targets, err := p.targets(m)
+	if err != nil {
+		p.logger.Errorf("error selecting the HelmCharts, error: %v\n", err)
+		return err
+	}
+	if len(targets) == 0 {
+		target := p.Chart
+		if p.Selector != nil {
+			target = p.Selector.String()
+		}
+		p.diagnostics.Warnf(target, diagnostics.ReasonTargetNotFound, "no HelmChart matches, the values are not merged")
+	}
+	sources, err := p.resolveSources(m)
+	if err != nil {
+		p.logger.Errorf("error resolving the sources of values, error: %v\n", err)
+		return err
+	}
+	for _, r := range targets {
+		var before, after map[string]interface{}
+		var sourceSteps [][2]map[string]interface{}
+		if err := filtersutil.ApplyToJSON(kio.FilterFunc(func(nodes []*kyaml.RNode) ([]*kyaml.RNode, error) {
+			return kio.FilterAll(kyaml.FilterFunc(func(rn *kyaml.RNode) (*kyaml.RNode, error) {
+				if valuesRn, err := rn.Pipe(kyaml.FieldMatcher{Name: "values"}); err != nil {
+					return nil, err
+				} else {
+					valuesRnMap := make(map[string]interface{})
+
+					if valuesRn != nil {
+						if jsonBytes, err := valuesRn.MarshalJSON(); err != nil {
 							return nil, err
+						} else if err := json.Unmarshal(jsonBytes, &valuesRnMap); err != nil {
+							return nil, err
+						} else if err := json.Unmarshal(jsonBytes, &before); err != nil {
+							return nil, err
+						}
+					} else {
+						valuesRn = &kyaml.RNode{}
+					}
+
+					if newValuesRnMap, err := p.mutateValues(valuesRnMap); err != nil {
+						return nil, err
+					} else if newJsonBytes, err := json.Marshal(newValuesRnMap); err != nil {
+						return nil, err
+					} else if err := json.Unmarshal(newJsonBytes, &after); err != nil {
+						return nil, err
+					}
+					values := after
+					for _, source := range sources {
+						var merged map[string]interface{}
+						if source.strategy == HelmValuesReplace {
+							merged = replaceHelmValues(values, source.values, source.path)
 						} else {
-							valuesRnMap := make(map[string]interface{})
-
-							if valuesRn != nil {
-								if jsonBytes, err := valuesRn.MarshalJSON(); err != nil {
-									return nil, err
-								} else if err := json.Unmarshal(jsonBytes, &valuesRnMap); err != nil {
-									return nil, err
-								}
-							} else {
-								valuesRn = &kyaml.RNode{}
-							}
-
-							if newValuesRnMap, err := p.mutateValues(valuesRnMap); err != nil {
-								return nil, err
-							} else if newJsonBytes, err := json.Marshal(newValuesRnMap); err != nil {
-								return nil, err
-							} else if err := valuesRn.UnmarshalJSON(newJsonBytes); err != nil {
-								return nil, err
-							} else if err := rn.PipeE(kyaml.FieldSetter{Name: "values", Value: valuesRn}); err != nil {
-								return nil, err
-							}
+							merged, _ = mergeHelmValues(values, source.values, source.strategy, source.mergeKey).(map[string]interface{})
+						}
+						sourceSteps = append(sourceSteps, [2]map[string]interface{}{values, merged})
+						values = merged
+					}
+					if newJsonBytes, err := json.Marshal(values); err != nil {
+						return nil, err
+					} else if err := valuesRn.UnmarshalJSON(newJsonBytes); err != nil {
+						return nil, err
+					} else if err := rn.PipeE(kyaml.FieldSetter{Name: "values", Value: valuesRn}); err != nil {
+						return nil, err
+					}
+				}
+				return rn, nil
+			})).Filter(nodes)
+		}), r); err != nil {
+			return err
+		}
+		if err := p.recordValuesOrigins(r, func(origins map[string]string) {
+			utils.UpdateValuesOrigins(origins, p.origin, p.Values, before, after, p.Overwrite)
+			for i, step := range sourceSteps {
+				if sources[i].strategy == HelmValuesReplace {
+					replaced := strings.Join(sources[i].path, ".")
+					for path := range origins {
+						if replaced == "" || path == replaced || strings.HasPrefix(path, replaced+".") {
+							delete(origins, path)
 						}
-						return rn, nil
-					})).Filter(nodes)
-				}), r); err != nil {
-					return err
+					}
 				}
+				utils.UpdateValuesOrigins(origins, sources[i].origin, sources[i].values, step[0], step[1], true)
 			}
+		}); err != nil {
+			p.logger.Errorf("error recording the origins of values, error: %v\n", err)
+			return err
 		}
 		if len(p.ReleaseNamespace) > 0 && p.ReleaseNamespace != "null" {
 			if err := filtersutil.ApplyToJSON(kio.FilterFunc(func(nodes []*kyaml.RNode) ([]*kyaml.RNode, error) {
@@ -111,6 +369,54 @@ func (p *HelmValuesPlugin) Transform(m resmap.ResMap) error {
 	return nil
 }
 
+// recordValuesOrigins records on the HelmChart r the plugin configs, files and resources
+// update says set the values merged in, for HelmChartPlugin to report schema errors against.
+func (p *HelmValuesPlugin) recordValuesOrigins(r *resource.Resource, update func(origins map[string]string)) error {
+	annotations := r.GetAnnotations()
+	origins, err := helmValuesOrigins(annotations)
+	if err != nil {
+		return err
+	}
+	update(origins)
+	p.helmValues.RecordOrigins(r.GetName(), origins)
+	if len(origins) == 0 {
+		return nil
+	}
+	originsJson, err := json.Marshal(origins)
+	if err != nil {
+		return err
+	}
+	annotations[konfig.HelmValuesOriginsAnnotation] = string(originsJson)
+	r.SetAnnotations(annotations)
+	return nil
+}
+
+func helmValuesOrigins(annotations map[string]string) (map[string]string, error) {
+	origins := make(map[string]string)
+	if originsJson, ok := annotations[konfig.HelmValuesOriginsAnnotation]; ok {
+		if err := json.Unmarshal([]byte(originsJson), &origins); err != nil {
+			return nil, err
+		}
+	}
+	return origins, nil
+}
+
+func configMetadata(c []byte) (types.ObjectMeta, error) {
+	var config struct {
+		Metadata types.ObjectMeta `json:"metadata,omitempty" yaml:"metadata,omitempty"`
+	}
+	err := yaml.Unmarshal(c, &config)
+	return config.Metadata, err
+}
+
+// configOrigin names a plugin config by the file it was read from, else by its kind and name.
+func configOrigin(configPath, kind, name string) string {
+	if configPath != "" {
+		return configPath
+	}
+	return fmt.Sprintf("%v/%v", kind, name)
+}
+
 func isHelmChart(obj *resource.Resource) bool {
 	kind := obj.GetKind()
 	if kind == "HelmChart" {
@@ -134,6 +440,87 @@ func mergeValues(values1 interface{}, values2 interface{}, overwrite bool) error
 	return mergo.Merge(values1, values2)
 }
 
+// mergeHelmValues returns src merged into dst with strategy, without modifying either.
+func mergeHelmValues(dst, src interface{}, strategy, mergeKey string) interface{} {
+	switch srcValue := src.(type) {
+	case map[string]interface{}:
+		dstMap, ok := dst.(map[string]interface{})
+		if !ok {
+			return srcValue
+		}
+		merged := make(map[string]interface{}, len(dstMap)+len(srcValue))
+		for k, v := range dstMap {
+			merged[k] = v
+		}
+		for k, v := range srcValue {
+			if dstValue, ok := dstMap[k]; ok {
+				merged[k] = mergeHelmValues(dstValue, v, strategy, mergeKey)
+			} else {
+				merged[k] = v
+			}
+		}
+		return merged
+	case []interface{}:
+		dstList, ok := dst.([]interface{})
+		if !ok {
+			return srcValue
+		}
+		switch strategy {
+		case HelmValuesAppend:
+			return append(append([]interface{}{}, dstList...), srcValue...)
+		case HelmValuesMergeByKey:
+			merged := append([]interface{}{}, dstList...)
+			for _, item := range srcValue {
+				if i := indexByKey(merged, item, mergeKey); i >= 0 {
+					merged[i] = mergeHelmValues(merged[i], item, strategy, mergeKey)
+				} else {
+					merged = append(merged, item)
+				}
+			}
+			return merged
+		}
+	}
+	return src
+}
+
+// replaceHelmValues returns dst with the value at path replaced by the one of src,
+// src itself when path is empty, without modifying either.
+func replaceHelmValues(dst, src map[string]interface{}, path []string) map[string]interface{} {
+	if len(path) == 0 {
+		return src
+	}
+	merged := make(map[string]interface{}, len(dst)+1)
+	for k, v := range dst {
+		merged[k] = v
+	}
+	if len(path) == 1 {
+		merged[path[0]] = src[path[0]]
+		return merged
+	}
+	dstChild, _ := dst[path[0]].(map[string]interface{})
+	srcChild, _ := src[path[0]].(map[string]interface{})
+	merged[path[0]] = replaceHelmValues(dstChild, srcChild, path[1:])
+	return merged
+}
+
+// indexByKey returns the index of the map of list with the same mergeKey as item, or -1.
+func indexByKey(list []interface{}, item interface{}, mergeKey string) int {
+	itemMap, ok := item.(map[string]interface{})
+	if !ok {
+		return -1
+	}
+	key, ok := itemMap[mergeKey]
+	if !ok {
+		return -1
+	}
+	for i, element := range list {
+		if elementMap, ok := element.(map[string]interface{}); ok && elementMap[mergeKey] == key {
+			return i
+		}
+	}
+	return -1
+}
+
 func NewHelmValuesPlugin() resmap.TransformerPlugin {
 	return &HelmValuesPlugin{logger: utils.GetLogger("HelmValuesPlugin")}
 }
@@ -5,17 +5,21 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/kustomize/api/diagnostics"
 	"sigs.k8s.io/kustomize/api/filesys"
 	"sigs.k8s.io/kustomize/api/loader"
 	"sigs.k8s.io/kustomize/api/provider"
 	"sigs.k8s.io/kustomize/api/resmap"
 	valtest_test "sigs.k8s.io/kustomize/api/testutils/valtest"
 	"sigs.k8s.io/kustomize/api/types"
+	"sigs.k8s.io/kustomize/kyaml/resid"
 	"sigs.k8s.io/yaml"
 )
 
 func TestHelmValues(t *testing.T) {
 	checkAssertions := func(t *testing.T, resMap resmap.ResMap, expectedResult string) {
+		// the origins of values are build annotations, removed from the build output
+		resMap.RemoveBuildAnnotations()
 		result, err := resMap.AsYaml()
 		assert.NoError(t, err)
 
@@ -192,3 +196,238 @@ values:
 		})
 	}
 }
+
+func TestHelmValues_origins(t *testing.T) {
+	p := provider.NewDefaultDepProvider()
+	resourceFactory := resmap.NewFactory(p.GetResourceFactory())
+	resMap, err := resourceFactory.NewResMapFromBytes([]byte(`
+apiVersion: qlik.com/v1
+kind: HelmChart
+metadata:
+  name: qliksense
+chartName: qliksense
+values:
+  image:
+    repository: nginx
+    tag: "1.19"
+`))
+	assert.NoError(t, err)
+
+	for _, config := range []struct {
+		path   string
+		config string
+	}{
+		{
+			path: "/base/values.yaml",
+			config: `
+apiVersion: qlik.com/v1
+kind: HelmValues
+metadata:
+  name: base
+chartName: qliksense
+values:
+  image:
+    repository: busybox
+    pullPolicy: Always
+`,
+		},
+		{
+			config: `
+apiVersion: qlik.com/v1
+kind: HelmValues
+metadata:
+  name: prod
+chartName: qliksense
+overwrite: true
+values:
+  image:
+    tag: "1.20"
+`,
+		},
+	} {
+		plugin := NewHelmValuesPlugin()
+		pluginHelpers := resmap.NewPluginHelpers(loader.NewFileLoaderAtRoot(filesys.MakeFsInMemory()), valtest_test.MakeFakeValidator(), resourceFactory, types.DisabledPluginConfig())
+		assert.NoError(t, plugin.Config(pluginHelpers.WithConfigPath(config.path), []byte(config.config)))
+		assert.NoError(t, plugin.Transform(resMap))
+	}
+
+	origins, err := helmValuesOrigins(resMap.Resources()[0].GetAnnotations())
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"image.pullPolicy": "/base/values.yaml",
+		"image.tag":        "HelmValues/prod",
+	}, origins)
+}
+
+func TestHelmValues_sources(t *testing.T) {
+	fSys := filesys.MakeFsInMemory()
+	assert.NoError(t, fSys.WriteFile("/app/global.yaml", []byte(`
+image:
+  registry: docker.io
+  pullPolicy: IfNotPresent
+ingress:
+  hosts:
+  - name: default
+    host: example.com
+`)))
+	ldr, err := loader.NewLoader(loader.RestrictionRootOnly, "/app", fSys)
+	assert.NoError(t, err)
+
+	p := provider.NewDefaultDepProvider()
+	resourceFactory := resmap.NewFactory(p.GetResourceFactory())
+	resMap, err := resourceFactory.NewResMapFromBytes([]byte(`
+apiVersion: qlik.com/v1
+kind: HelmChart
+metadata:
+  name: web
+  labels:
+    tier: frontend
+chartName: web
+values:
+  image:
+    tag: "1.0"
+  tolerations:
+  - key: a
+---
+apiVersion: qlik.com/v1
+kind: HelmChart
+metadata:
+  name: db
+chartName: db
+values:
+  image:
+    tag: "2.0"
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: region
+data:
+  values.yaml: |
+    ingress:
+      hosts:
+      - name: default
+        host: eu.example.com
+      - name: admin
+        host: admin.eu.example.com
+  cluster: eu-1
+`))
+	assert.NoError(t, err)
+
+	plugin := NewHelmValuesPlugin()
+	pluginHelpers := resmap.NewPluginHelpers(ldr, valtest_test.MakeFakeValidator(), resourceFactory, types.DisabledPluginConfig())
+	assert.NoError(t, plugin.Config(pluginHelpers.WithConfigPath("/app/helmValues.yaml"), []byte(`
+apiVersion: qlik.com/v1
+kind: HelmValues
+metadata:
+  name: layers
+selector:
+  labelSelector: tier=frontend
+sources:
+- file: global.yaml
+- fromResource:
+    kind: ConfigMap
+    name: region
+    fieldPath: data[values.yaml]
+  strategy: mergeByKey
+- fromResource:
+    kind: ConfigMap
+    name: region
+    fieldPath: data.cluster
+  path: global.cluster
+- values:
+    tolerations:
+    - key: b
+  strategy: append
+- values:
+    registry: quay.io
+  path: image
+  strategy: replace
+`)))
+	assert.NoError(t, plugin.Transform(resMap))
+
+	web, err := resMap.GetById(resid.NewResId(resid.NewGvk("qlik.com", "v1", "HelmChart"), "web"))
+	assert.NoError(t, err)
+	values, err := web.GetFieldValue("values")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"global": map[string]interface{}{"cluster": "eu-1"},
+		"image":  map[string]interface{}{"registry": "quay.io"},
+		"ingress": map[string]interface{}{"hosts": []interface{}{
+			map[string]interface{}{"name": "default", "host": "eu.example.com"},
+			map[string]interface{}{"name": "admin", "host": "admin.eu.example.com"},
+		}},
+		"tolerations": []interface{}{
+			map[string]interface{}{"key": "a"},
+			map[string]interface{}{"key": "b"},
+		},
+	}, values)
+
+	origins, err := helmValuesOrigins(web.GetAnnotations())
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"global.cluster": "ConfigMap/region",
+		"image.registry": "/app/helmValues.yaml",
+		"ingress.hosts":  "ConfigMap/region",
+		"tolerations":    "/app/helmValues.yaml",
+	}, origins)
+
+	db, err := resMap.GetById(resid.NewResId(resid.NewGvk("qlik.com", "v1", "HelmChart"), "db"))
+	assert.NoError(t, err)
+	values, err = db.GetFieldValue("values")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"image": map[string]interface{}{"tag": "2.0"}}, values)
+}
+
+func TestHelmValues_invalidSource(t *testing.T) {
+	p := provider.NewDefaultDepProvider()
+	plugin := NewHelmValuesPlugin()
+	err := plugin.Config(resmap.NewPluginHelpers(loader.NewFileLoaderAtRoot(filesys.MakeFsInMemory()), valtest_test.MakeFakeValidator(),
+		resmap.NewFactory(p.GetResourceFactory()), types.DisabledPluginConfig()), []byte(`
+apiVersion: qlik.com/v1
+kind: HelmValues
+metadata:
+  name: invalid
+sources:
+- values:
+    a: b
+  file: values.yaml
+`))
+	assert.EqualError(t, err, "sources[0] must set exactly one of values, file and fromResource")
+}
+
+func TestHelmValues_noTarget(t *testing.T) {
+	p := provider.NewDefaultDepProvider()
+	resourceFactory := resmap.NewFactory(p.GetResourceFactory())
+	resMap, err := resourceFactory.NewResMapFromBytes([]byte(`
+apiVersion: qlik.com/v1
+kind: HelmChart
+metadata:
+  name: db
+chartName: db
+`))
+	assert.NoError(t, err)
+
+	collector := diagnostics.NewCollector()
+	plugin := NewHelmValuesPlugin()
+	pluginHelpers := resmap.NewPluginHelpers(loader.NewFileLoaderAtRoot(filesys.MakeFsInMemory()), valtest_test.MakeFakeValidator(),
+		resourceFactory, types.DisabledPluginConfig())
+	assert.NoError(t, plugin.Config(pluginHelpers.WithDiagnostics(diagnostics.NewReporter(collector, "HelmValues", "misspelled", "")), []byte(`
+apiVersion: qlik.com/v1
+kind: HelmValues
+metadata:
+  name: misspelled
+chartName: wbe
+sources:
+- values:
+    replicas: 2
+`)))
+	assert.NoError(t, plugin.Transform(resMap))
+	assert.Equal(t, []diagnostics.Diagnostic{{
+		Plugin:  "HelmValues",
+		Name:    "misspelled",
+		Target:  "wbe",
+		Reason:  diagnostics.ReasonTargetNotFound,
+		Message: "no HelmChart matches, the values are not merged",
+	}}, collector.Diagnostics())
+}
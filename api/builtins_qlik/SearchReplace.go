@@ -10,9 +10,11 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"text/template"
 
 	"go.uber.org/zap"
 	"sigs.k8s.io/kustomize/api/builtins_qlik/utils"
+	"sigs.k8s.io/kustomize/api/diagnostics"
 	"sigs.k8s.io/kustomize/api/filters/fieldspec"
 	kutils "sigs.k8s.io/kustomize/api/internal/utils"
 	"sigs.k8s.io/kustomize/api/resmap"
@@ -28,7 +30,24 @@ type ReplaceWithGitDescribeTagT struct {
 	Default string `json:"default,omitempty" yaml:"default,omitempty"`
 }
 
-type SearchReplacePlugin struct {
+// SearchReplaceMatcher scopes a rule to the scalar values of the fields whose
+// name matches the regexp Key, and whose whole value matches the regexp Value.
+// The items of a sequence are under the name of the field of the sequence.
+type SearchReplaceMatcher struct {
+	Key   string `json:"key,omitempty" yaml:"key,omitempty"`
+	Value string `json:"value,omitempty" yaml:"value,omitempty"`
+}
+
+// SearchReplaceExpectMatches fails the build when Search of a rule matches less
+// than Min times, 1 by default, or more than Max times when set.
+type SearchReplaceExpectMatches struct {
+	Min *int `json:"min,omitempty" yaml:"min,omitempty"`
+	Max *int `json:"max,omitempty" yaml:"max,omitempty"`
+}
+
+// SearchReplaceRule replaces what the regexp Search matches at Path of the Target resources,
+// or in the whole resources when Path is "/".
+type SearchReplaceRule struct {
 	Target                    *types.Selector             `json:"target,omitempty" yaml:"target,omitempty"`
 	Path                      string                      `json:"path,omitempty" yaml:"path,omitempty"`
 	Search                    string                      `json:"search,omitempty" yaml:"search,omitempty"`
@@ -36,146 +55,374 @@ type SearchReplacePlugin struct {
 	ReplaceWithEnvVar         string                      `json:"replaceWithEnvVar,omitempty" yaml:"replaceWithEnvVar,omitempty"`
 	ReplaceWithObjRef         *types.Var                  `json:"replaceWithObjRef,omitempty" yaml:"replaceWithObjRef,omitempty"`
 	ReplaceWithGitDescribeTag *ReplaceWithGitDescribeTagT `json:"replaceWithGitDescribeTag,omitempty" yaml:"replaceWithGitDescribeTag,omitempty"`
-	ReplaceType               string                      `json:"replaceType,omitempty" yaml:"replaceType,omitempty"`
-	logger                    *zap.SugaredLogger
-	fieldSpec                 types.FieldSpec
-	re                        *regexp.Regexp
-	pwd                       string
-	replaceStr                *string
+	// ReplaceTemplate is a text/template executed for every match of Search, with the
+	// match in .Match and the capture groups of Search by index in .Groups and by name
+	// in .Named. The function self returns a field of the resource, field one of another
+	// resource by kind and name, e.g. {{ field "ConfigMap" "versions" "data.engine" }}.
+	ReplaceTemplate string                      `json:"replaceTemplate,omitempty" yaml:"replaceTemplate,omitempty"`
+	ReplaceType     string                      `json:"replaceType,omitempty" yaml:"replaceType,omitempty"`
+	Match           *SearchReplaceMatcher       `json:"match,omitempty" yaml:"match,omitempty"`
+	ExpectMatches   *SearchReplaceExpectMatches `json:"expectMatches,omitempty" yaml:"expectMatches,omitempty"`
+}
+
+type SearchReplacePlugin struct {
+	SearchReplaceRule `json:",inline" yaml:",inline"`
+	// Rules are applied in order, after the rule of the plugin itself when it has a search.
+	// Rules without a target use the target of the plugin.
+	Rules       []SearchReplaceRule `json:"rules,omitempty" yaml:"rules,omitempty"`
+	logger      *zap.SugaredLogger
+	diagnostics *diagnostics.Reporter
+	pwd         string
+	replacers   []*searchReplacer
+}
+
+// searchReplacer applies a rule.
+type searchReplacer struct {
+	SearchReplaceRule
+	*SearchReplacePlugin
+	name       string
+	fieldSpec  types.FieldSpec
+	re         *regexp.Regexp
+	keyRe      *regexp.Regexp
+	valueRe    *regexp.Regexp
+	template   *template.Template
+	replaceStr *string
+	resources  resmap.ResMap
+	res        *resource.Resource
+	matches    int
+}
+
+// searchReplaceTemplateData is what ReplaceTemplate is executed with.
+type searchReplaceTemplateData struct {
+	Match  string
+	Groups []string
+	Named  map[string]string
 }
 
 func (p *SearchReplacePlugin) Config(h *resmap.PluginHelpers, c []byte) (err error) {
-	p.Target = nil
-	p.Path = ""
-	p.Search = ""
-	p.Replace = ""
-	p.replaceStr = nil
-	p.ReplaceType = ""
-	p.ReplaceWithEnvVar = ""
-	p.ReplaceWithObjRef = nil
-	p.ReplaceWithGitDescribeTag = nil
+	p.SearchReplaceRule = SearchReplaceRule{}
+	p.Rules = nil
+	p.replacers = nil
 	err = yaml.Unmarshal(c, p)
 	if err != nil {
 		p.logger.Errorf("error unmarshalling config from yaml, error: %v\n", err)
 		return err
 	}
-	if p.Target == nil {
-		return fmt.Errorf("must specify a target in the config for the environment variables upsert")
-	}
 
-	p.fieldSpec = types.FieldSpec{Path: p.Path}
+	p.pwd = h.Loader().Root()
+	p.diagnostics = h.Diagnostics()
 
-	p.re, err = regexp.Compile(p.Search)
-	if err != nil {
-		p.logger.Errorf("error compiling regexp from: %v, error: %v\n", p.Search, err)
-		return err
+	rules := p.Rules
+	if len(p.Rules) == 0 || p.Search != "" {
+		rules = append([]SearchReplaceRule{p.SearchReplaceRule}, rules...)
 	}
+	for i, rule := range rules {
+		if rule.Target == nil {
+			rule.Target = p.Target
+		}
+		if rule.Target == nil {
+			return fmt.Errorf("must specify a target in the config for the environment variables upsert")
+		}
+		r := &searchReplacer{
+			SearchReplaceRule:   rule,
+			SearchReplacePlugin: p,
+			name:                fmt.Sprintf("rule %d searching %q", i, rule.Search),
+			fieldSpec:           types.FieldSpec{Path: rule.Path},
+		}
+		if r.re, err = regexp.Compile(rule.Search); err != nil {
+			p.logger.Errorf("error compiling regexp from: %v, error: %v\n", rule.Search, err)
+			return err
+		}
+		if rule.Match != nil {
+			if r.keyRe, err = compileAnchored(rule.Match.Key); err != nil {
+				p.logger.Errorf("error compiling regexp from: %v, error: %v\n", rule.Match.Key, err)
+				return err
+			}
+			if r.valueRe, err = compileAnchored(rule.Match.Value); err != nil {
+				p.logger.Errorf("error compiling regexp from: %v, error: %v\n", rule.Match.Value, err)
+				return err
+			}
+		}
+		if rule.ReplaceTemplate != "" {
+			r.template, err = template.New(r.name).Option("missingkey=error").Funcs(template.FuncMap{
+				"self":  r.selfField,
+				"field": r.resourceField,
+			}).Parse(rule.ReplaceTemplate)
+			if err != nil {
+				p.logger.Errorf("error parsing replaceTemplate: %v, error: %v\n", rule.ReplaceTemplate, err)
+				return err
+			}
+		}
+		p.replacers = append(p.replacers, r)
+	}
+	return nil
+}
 
-	p.pwd = h.Loader().Root()
+// compileAnchored compiles the regexp expr matching whole strings only, nil for an empty expr.
+func compileAnchored(expr string) (*regexp.Regexp, error) {
+	if expr == "" {
+		return nil, nil
+	}
+	return regexp.Compile("^(?:" + expr + ")$")
+}
+
+func (p *SearchReplacePlugin) Transform(m resmap.ResMap) error {
+	for _, r := range p.replacers {
+		r.matches = 0
+		if err := r.transform(m); err != nil {
+			return err
+		}
+		if err := r.checkMatches(); err != nil {
+			p.logger.Errorf("%v\n", err)
+			return err
+		}
+	}
+	return nil
+}
 
+// checkMatches fails when the rule did not match as many times as it expects.
+func (r *searchReplacer) checkMatches() error {
+	if r.ExpectMatches == nil {
+		return nil
+	}
+	min := 1
+	if r.ExpectMatches.Min != nil {
+		min = *r.ExpectMatches.Min
+	}
+	if r.matches < min {
+		return fmt.Errorf("SearchReplace %v at %v matched %d times, expected at least %d", r.name, r.Path, r.matches, min)
+	}
+	if r.ExpectMatches.Max != nil && r.matches > *r.ExpectMatches.Max {
+		return fmt.Errorf("SearchReplace %v at %v matched %d times, expected at most %d", r.name, r.Path, r.matches, *r.ExpectMatches.Max)
+	}
 	return nil
 }
 
-func (p *SearchReplacePlugin) Transform(m resmap.ResMap) error {
-	resources, err := m.Select(*p.Target)
+func (r *searchReplacer) transform(m resmap.ResMap) error {
+	resources, err := m.Select(*r.Target)
 	if err != nil {
-		p.logger.Errorf("error selecting resources based on the target selector, error: %v\n", err)
+		r.logger.Errorf("error selecting resources based on the target selector, error: %v\n", err)
 		return err
 	}
-	if p.Replace != "" {
+	if len(resources) == 0 {
+		r.diagnostics.Warnf(r.Target.String(), diagnostics.ReasonTargetNotFound, "target selector matched no resources")
+	}
+	if r.template == nil {
+		if err := r.resolveReplaceStr(m); err != nil || r.replaceStr == nil {
+			return err
+		}
+	}
+	r.resources = m
+	defer func() {
+		r.resources, r.res = nil, nil
+	}()
+	for _, res := range resources {
+		r.res = res
+		if r.Match != nil {
+			if err := r.searchAndReplaceMatching(res); err != nil {
+				return err
+			}
+		} else if r.fieldSpec.Path == "/" {
+			if rmap, err := res.Map(); err != nil {
+				r.logger.Infof("error reseource.Map(), error: %v\n", err)
+				return err
+			} else if newRoot, err := r.searchAndReplace(rmap, false); err != nil {
+				r.logger.Infof("error executing transformers.MutateField(), error: %v\n", err)
+				return err
+			} else if newRootMap, newRootIsMap := newRoot.(map[string]interface{}); !newRootIsMap {
+				return errors.New("search/replace on root did not return a map[string]interface{}")
+			} else if jsonBytes, err := json.Marshal(newRootMap); err != nil {
+				return err
+			} else if err := res.UnmarshalJSON(jsonBytes); err != nil {
+				return err
+			}
+		} else if err := r.applyToPath(res, func(n *kyaml.RNode) error {
+			return r.searchAndReplaceRNode(n, isSecretDataTarget(res, kutils.PathSplitter(r.fieldSpec.Path)))
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resolveReplaceStr sets the replacement of the rule, unless already resolved
+// by a previous Transform, or leaves it nil when there is nothing to replace with.
+func (r *searchReplacer) resolveReplaceStr(m resmap.ResMap) error {
+	if r.Replace != "" && r.Replace != nil {
 		var replaceStr string
-		switch newValue := p.Replace.(type) {
+		switch newValue := r.Replace.(type) {
 		case int:
 			replaceStr = strconv.FormatInt(int64(newValue), 10)
-			p.replaceStr = &replaceStr
+			r.replaceStr = &replaceStr
 		case bool:
 			replaceStr = strconv.FormatBool(newValue)
-			p.replaceStr = &replaceStr
+			r.replaceStr = &replaceStr
 		case float64:
 			replaceStr = strconv.FormatFloat(newValue, 'f', -1, 64)
-			p.replaceStr = &replaceStr
+			r.replaceStr = &replaceStr
 		case string:
 			replaceStr = newValue
-			p.replaceStr = &replaceStr
+			r.replaceStr = &replaceStr
 		default:
 			return errors.New("replacement input value of unknown type")
 		}
 	}
-	if p.replaceStr == nil {
-		if p.ReplaceWithObjRef != nil {
+	if r.replaceStr == nil {
+		if r.ReplaceWithObjRef != nil {
 			var replaceEmpty bool
 			for _, res := range m.Resources() {
-				if p.matchesObjRef(res) {
-					if replacementValue, replace, err := getReplacementValue(res, p.ReplaceWithObjRef.FieldRef.FieldPath); err != nil {
-						p.logger.Debugf("error getting replacement value: %v\n", err)
+				if r.matchesObjRef(res) {
+					if replacementValue, replace, err := getReplacementValue(res, r.ReplaceWithObjRef.FieldRef.FieldPath); err != nil {
+						r.logger.Debugf("error getting replacement value: %v\n", err)
 					} else {
-						p.replaceStr = &replacementValue
-						p.Replace = replace
+						r.replaceStr = &replacementValue
+						r.Replace = replace
 						replaceEmpty = true
 						break
 					}
 				}
 			}
-			if p.replaceStr == nil {
+			if r.replaceStr == nil {
 				if replaceEmpty {
 					replaceStr := ""
-					p.replaceStr = &replaceStr
+					r.replaceStr = &replaceStr
 				} else {
-					p.logger.Debugf("Object Reference could not be found")
+					r.logger.Debugf("Object Reference could not be found")
+					r.diagnostics.Warnf(fmt.Sprintf("%v %v", r.ReplaceWithObjRef.ObjRef.GVK(), r.ReplaceWithObjRef.ObjRef.Name), diagnostics.ReasonObjRefNotFound,
+						"replaceWithObjRef %v matched no resource with field %v, nothing was replaced", r.ReplaceWithObjRef.Name, r.ReplaceWithObjRef.FieldRef.FieldPath)
 					return nil
 				}
 			}
-		} else if p.ReplaceWithGitDescribeTag != nil {
-			if gitDescribeTag, err := utils.GetGitDescribeForHead(p.pwd, p.ReplaceWithGitDescribeTag.Default, p.logger); err != nil {
+		} else if r.ReplaceWithGitDescribeTag != nil {
+			if gitDescribeTag, err := utils.GetGitDescribeForHead(r.pwd, r.ReplaceWithGitDescribeTag.Default, r.logger); err != nil {
 				return err
 			} else {
 				replaceStr := strings.TrimPrefix(gitDescribeTag, "v")
-				p.replaceStr = &replaceStr
-				p.Replace = p.replaceStr
+				r.replaceStr = &replaceStr
+				r.Replace = r.replaceStr
 			}
-		} else if len(p.ReplaceWithEnvVar) > 0 {
-			if replaceStr, exists := os.LookupEnv(p.ReplaceWithEnvVar); exists {
-				p.Replace = replaceStr
-				p.replaceStr = &replaceStr
+		} else if len(r.ReplaceWithEnvVar) > 0 {
+			if replaceStr, exists := os.LookupEnv(r.ReplaceWithEnvVar); exists {
+				r.Replace = replaceStr
+				r.replaceStr = &replaceStr
 			}
 		}
 	}
-	if p.replaceStr != nil {
-		for _, r := range resources {
-			if p.fieldSpec.Path == "/" {
-				if rmap, err := r.Map(); err != nil {
-					p.logger.Infof("error reseource.Map(), error: %v\n", err)
-					return err
-				} else if newRoot, err := p.searchAndReplace(rmap, false); err != nil {
-					p.logger.Infof("error executing transformers.MutateField(), error: %v\n", err)
-					return err
-				} else if newRootMap, newRootIsMap := newRoot.(map[string]interface{}); !newRootIsMap {
-					return errors.New("search/replace on root did not return a map[string]interface{}")
-				} else if jsonBytes, err := json.Marshal(newRootMap); err != nil {
-					return err
-				} else if err := r.UnmarshalJSON(jsonBytes); err != nil {
-					return err
-				}
-			} else if err := filtersutil.ApplyToJSON(kio.FilterFunc(func(nodes []*kyaml.RNode) ([]*kyaml.RNode, error) {
-				return kio.FilterAll(kyaml.FilterFunc(func(rn *kyaml.RNode) (*kyaml.RNode, error) {
-					if err := rn.PipeE(fieldspec.Filter{
-						FieldSpec: p.fieldSpec,
-						SetValue: func(n *kyaml.RNode) error {
-							return p.searchAndReplaceRNode(n, isSecretDataTarget(r, kutils.PathSplitter(p.fieldSpec.Path)))
-						},
-					}); err != nil {
-						return nil, err
-					}
-					return rn, nil
-				})).Filter(nodes)
-			}), r); err != nil {
+	return nil
+}
+
+// applyToPath calls setValue with the nodes at the path of the rule in res.
+func (r *searchReplacer) applyToPath(res *resource.Resource, setValue func(n *kyaml.RNode) error) error {
+	return filtersutil.ApplyToJSON(kio.FilterFunc(func(nodes []*kyaml.RNode) ([]*kyaml.RNode, error) {
+		return kio.FilterAll(kyaml.FilterFunc(func(rn *kyaml.RNode) (*kyaml.RNode, error) {
+			if err := rn.PipeE(fieldspec.Filter{
+				FieldSpec: r.fieldSpec,
+				SetValue:  setValue,
+			}); err != nil {
+				return nil, err
+			}
+			return rn, nil
+		})).Filter(nodes)
+	}), res)
+}
+
+// searchAndReplaceMatching replaces in the scalar values of res, at the path of
+// the rule or anywhere for "/", that the matcher of the rule selects.
+func (r *searchReplacer) searchAndReplaceMatching(res *resource.Resource) error {
+	if r.fieldSpec.Path == "/" {
+		return r.walkMatching(res.Node().YNode(), "", nil)
+	}
+	path := kutils.PathSplitter(r.fieldSpec.Path)
+	return r.applyToPath(res, func(n *kyaml.RNode) error {
+		return r.walkMatching(n.YNode(), path[len(path)-1], path)
+	})
+}
+
+func (r *searchReplacer) walkMatching(node *kyaml.Node, key string, path []string) error {
+	switch node.Kind {
+	case kyaml.DocumentNode, kyaml.SequenceNode:
+		for _, item := range node.Content {
+			if err := r.walkMatching(item, key, path); err != nil {
+				return err
+			}
+		}
+	case kyaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			name := node.Content[i].Value
+			if err := r.walkMatching(node.Content[i+1], name, append(path[:len(path):len(path)], name)); err != nil {
 				return err
 			}
 		}
+	case kyaml.ScalarNode:
+		if r.keyRe != nil && !r.keyRe.MatchString(key) {
+			return nil
+		}
+		if r.valueRe != nil && !r.valueRe.MatchString(node.Value) {
+			return nil
+		}
+		return r.searchAndReplaceRNode(kyaml.NewRNode(node), isSecretDataTarget(r.res, path))
 	}
 	return nil
 }
 
+// replaceAll replaces the matches of the rule in s, with the replacement
+// or the template of the rule, counting them.
+func (r *searchReplacer) replaceAll(s string) (string, error) {
+	locs := r.re.FindAllStringSubmatchIndex(s, -1)
+	r.matches += len(locs)
+	if r.template == nil {
+		return r.re.ReplaceAllString(s, *r.replaceStr), nil
+	}
+	var b strings.Builder
+	last := 0
+	for _, loc := range locs {
+		data := searchReplaceTemplateData{
+			Groups: make([]string, len(loc)/2),
+			Named:  make(map[string]string),
+		}
+		for i := range data.Groups {
+			if loc[2*i] >= 0 {
+				data.Groups[i] = s[loc[2*i]:loc[2*i+1]]
+			}
+		}
+		data.Match = data.Groups[0]
+		for i, name := range r.re.SubexpNames() {
+			if name != "" {
+				data.Named[name] = data.Groups[i]
+			}
+		}
+		b.WriteString(s[last:loc[0]])
+		if err := r.template.Execute(&b, data); err != nil {
+			return "", err
+		}
+		last = loc[1]
+	}
+	b.WriteString(s[last:])
+	return b.String(), nil
+}
+
+// selfField returns the field at fieldPath of the resource being replaced in.
+func (r *searchReplacer) selfField(fieldPath string) (string, error) {
+	if r.res == nil {
+		return "", fmt.Errorf("self is only available while replacing")
+	}
+	value, _, err := getReplacementValue(r.res, fieldPath)
+	return value, err
+}
+
+// resourceField returns the field at fieldPath of the first resource of kind and name.
+func (r *searchReplacer) resourceField(kind, name, fieldPath string) (string, error) {
+	if r.resources != nil {
+		for _, res := range r.resources.Resources() {
+			if res.GetKind() == kind && res.GetName() == name {
+				value, _, err := getReplacementValue(res, fieldPath)
+				return value, err
+			}
+		}
+	}
+	return "", fmt.Errorf("no %v %v to read field %v from", kind, name, fieldPath)
+}
+
 func getReplacementValue(res *resource.Resource, fieldPath string) (string, interface{}, error) {
 	if val, err := utils.GetFieldValue(res.Node(), fieldPath); err != nil {
 		return "", nil, err
@@ -211,17 +458,17 @@ func isSecretDataTarget(r *resource.Resource, pathSlice []string) bool {
 	return r.GetGvk().Kind == "Secret" && len(pathSlice) > 0 && pathSlice[0] == "data"
 }
 
-func (p *SearchReplacePlugin) matchesObjRef(res *resource.Resource) bool {
-	if res.GetGvk().IsSelected(&p.ReplaceWithObjRef.ObjRef.Gvk) {
-		if len(p.ReplaceWithObjRef.ObjRef.Name) > 0 {
-			return res.GetName() == p.ReplaceWithObjRef.ObjRef.Name
+func (r *searchReplacer) matchesObjRef(res *resource.Resource) bool {
+	if res.GetGvk().IsSelected(&r.ReplaceWithObjRef.ObjRef.Gvk) {
+		if len(r.ReplaceWithObjRef.ObjRef.Name) > 0 {
+			return res.GetName() == r.ReplaceWithObjRef.ObjRef.Name
 		}
 		return true
 	}
 	return false
 }
 
-func (p *SearchReplacePlugin) searchAndReplaceRNode(node *kyaml.RNode, base64Encoded bool) error {
+func (r *searchReplacer) searchAndReplaceRNode(node *kyaml.RNode, base64Encoded bool) error {
 	var in interface{}
 	if node.YNode().Kind == kyaml.ScalarNode {
 		in = node.YNode().Value
@@ -233,19 +480,19 @@ func (p *SearchReplacePlugin) searchAndReplaceRNode(node *kyaml.RNode, base64Enc
 		}
 	}
 
-	changed, err := p.searchAndReplace(in, base64Encoded)
+	changed, err := r.searchAndReplace(in, base64Encoded)
 	if err != nil {
 		return err
 	}
 	if changed != nil {
 		if strChanged, ok := changed.(string); ok {
 			var targetType = "string"
-			if p.ReplaceType == "" {
-				if strChanged == *p.replaceStr {
-					targetType = reflect.TypeOf(p.Replace).String()
+			if r.ReplaceType == "" {
+				if r.replaceStr != nil && strChanged == *r.replaceStr {
+					targetType = reflect.TypeOf(r.Replace).String()
 				}
 			} else {
-				targetType = p.ReplaceType
+				targetType = r.ReplaceType
 			}
 			switch targetType {
 
@@ -275,17 +522,21 @@ func (p *SearchReplacePlugin) searchAndReplaceRNode(node *kyaml.RNode, base64Enc
 	return nil
 }
 
-func (p *SearchReplacePlugin) searchAndReplace(in interface{}, base64Encoded bool) (interface{}, error) {
+func (r *searchReplacer) searchAndReplace(in interface{}, base64Encoded bool) (interface{}, error) {
 	if target, ok := in.(string); ok {
 		if base64Encoded {
 			if decodedValue, err := base64.StdEncoding.DecodeString(target); err != nil {
 				return nil, err
+			} else if replacedDecodedValue, err := r.replaceAll(string(decodedValue)); err != nil {
+				return nil, err
 			} else {
-				replacedDecodedValue := p.re.ReplaceAllString(string(decodedValue), *p.replaceStr)
 				return base64.StdEncoding.EncodeToString([]byte(replacedDecodedValue)), nil
 			}
 		} else {
-			retVal := p.re.ReplaceAllString(target, *p.replaceStr)
+			retVal, err := r.replaceAll(target)
+			if err != nil {
+				return nil, err
+			}
 			// didn't replace anything to retain type
 			if retVal != target {
 				return retVal, nil
@@ -294,21 +545,22 @@ func (p *SearchReplacePlugin) searchAndReplace(in interface{}, base64Encoded boo
 			}
 		}
 	} else if target, ok := in.(map[string]interface{}); ok {
-		return p.marshallToJsonAndReplace(target)
+		return r.marshallToJsonAndReplace(target)
 	} else if target, ok := in.([]interface{}); ok {
-		return p.marshallToJsonAndReplace(target)
+		return r.marshallToJsonAndReplace(target)
 	}
 	return in, nil
 }
 
-func (p *SearchReplacePlugin) marshallToJsonAndReplace(in interface{}) (interface{}, error) {
+func (r *searchReplacer) marshallToJsonAndReplace(in interface{}) (interface{}, error) {
 	if marshalledTarget, err := json.Marshal(in); err != nil {
-		p.logger.Infof("error marshalling interface to JSON, error: %v\n", err)
+		r.logger.Infof("error marshalling interface to JSON, error: %v\n", err)
+		return nil, err
+	} else if replaced, err := r.replaceAll(string(marshalledTarget)); err != nil {
 		return nil, err
 	} else {
-		replaced := p.re.ReplaceAllString(string(marshalledTarget), *p.replaceStr)
 		if err := json.Unmarshal([]byte(replaced), &in); err != nil {
-			p.logger.Infof("error unmarshalling JSON string after replacements back to interface, error: %v\n", err)
+			r.logger.Infof("error unmarshalling JSON string after replacements back to interface, error: %v\n", err)
 			return nil, err
 		} else {
 			return in, err
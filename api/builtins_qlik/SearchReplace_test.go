@@ -26,6 +26,7 @@ func TestSearchReplacePlugin(t *testing.T) {
 		loaderRootDir        string
 		setup                func(*testing.T)
 		teardown             func(*testing.T)
+		transformError       string
 	}
 
 	testCases := []searchReplacePluginTestCaseT{
@@ -754,6 +755,113 @@ fooSpec:
 				},
 			}
 		}(),
+		{
+			name: "rules with capture group templates, key matchers and field lookups",
+			pluginConfig: `
+apiVersion: qlik.com/v1
+kind: SearchReplace
+metadata:
+ name: notImportantHere
+target:
+ kind: Deployment
+rules:
+- path: /
+  match:
+    key: image
+  search: ^(?P<registry>[^/]+)/(?P<name>[^:]+):(.*)$
+  replaceTemplate: '{{ index .Groups 1 }}/mirror/{{ .Named.name }}:{{ field "ConfigMap" "versions" (printf "data.%s" .Named.name) }}'
+  expectMatches:
+    min: 2
+    max: 2
+- path: metadata/labels
+  match:
+    value: old-.*
+  search: old
+  replaceTemplate: '{{ self "metadata.name" }}'
+- target:
+    kind: ConfigMap
+  path: data
+  search: "1"
+  replace: "2"
+`,
+			pluginInputResources: `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: versions
+data:
+  engine: "1.2.3"
+  proxy: "4.5.6"
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+  labels:
+    tier: old-tier
+    other: old
+spec:
+  template:
+    spec:
+      containers:
+      - name: engine
+        image: docker.io/engine:latest
+        env:
+        - name: image
+          value: docker.io/unrelated
+      - name: proxy
+        image: docker.io/proxy:latest
+`,
+			checkAssertions: func(t *testing.T, resMap resmap.ResMap) {
+				deployment := resMap.GetByIndex(1)
+				for field, expected := range map[string]string{
+					"spec.template.spec.containers[0].image":        "docker.io/mirror/engine:1.2.3",
+					"spec.template.spec.containers[0].env[0].value": "docker.io/unrelated",
+					"spec.template.spec.containers[1].image":        "docker.io/mirror/proxy:4.5.6",
+					"metadata.labels.tier":                          "app-tier",
+					"metadata.labels.other":                         "old",
+				} {
+					value, err := deployment.GetFieldValue(field)
+					if err != nil {
+						t.Fatalf("unexpected error: %v", err)
+					}
+					if expected != value {
+						t.Fatalf("unexpected %v: %v\n", field, value)
+					}
+				}
+				value, err := resMap.GetByIndex(0).GetFieldValue("data.engine")
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if "2.2.3" != value {
+					t.Fatalf("unexpected: %v\n", value)
+				}
+			},
+		},
+		{
+			name: "expectMatches fails when nothing matches",
+			pluginConfig: `
+apiVersion: qlik.com/v1
+kind: SearchReplace
+metadata:
+ name: notImportantHere
+target:
+ kind: Foo
+path: spec/image
+search: quay.io/foo
+replace: docker.io/foo
+expectMatches: {}
+`,
+			pluginInputResources: `
+apiVersion: qlik.com/v1
+kind: Foo
+metadata:
+ name: some-foo
+spec:
+ image: ghcr.io/foo:1.0.0
+`,
+			transformError: `SearchReplace rule 0 searching "quay.io/foo" at spec/image matched 0 times, expected at least 1`,
+		},
 	}
 	plugin := SearchReplacePlugin{logger: utils.GetLogger("SearchReplacePlugin")}
 	for _, testCase := range testCases {
@@ -784,7 +892,13 @@ fooSpec:
 				testCase.setup(t)
 			}
 
-			if err := plugin.Transform(resMap); err != nil {
+			err = plugin.Transform(resMap)
+			if testCase.transformError != "" {
+				if err == nil || err.Error() != testCase.transformError {
+					t.Fatalf("expected error: %v, got: %v", testCase.transformError, err)
+				}
+				return
+			} else if err != nil {
 				t.Fatalf("Err: %v", err)
 			}
 
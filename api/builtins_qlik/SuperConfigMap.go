@@ -19,7 +19,7 @@ type SuperConfigMapPlugin struct {
 }
 
 func (p *SuperConfigMapPlugin) Config(h *resmap.PluginHelpers, c []byte) (err error) {
-	p.SuperMapPluginBase = NewBase(h.ResmapFactory(), p)
+	p.SuperMapPluginBase = NewBase(h.ResmapFactory(), h.Diagnostics(), p)
 	p.Data = make(map[string](interface{}))
 	err = yaml.Unmarshal(c, p)
 	if err != nil {
@@ -31,7 +31,16 @@ func (p *SuperConfigMapPlugin) Config(h *resmap.PluginHelpers, c []byte) (err er
 		p.logger.Errorf("error setting up transformer config, error: %v\n", err)
 		return err
 	}
-	return p.ConfigMapGeneratorPlugin.Config(h, c)
+	err = p.ConfigMapGeneratorPlugin.Config(h, c)
+	if err != nil {
+		return err
+	}
+	err = p.SuperMapPluginBase.SetupSources(h, p.ConfigMapGeneratorPlugin.KvPairSources)
+	if err != nil {
+		p.logger.Errorf("error setting up sources, error: %v\n", err)
+		return err
+	}
+	return nil
 }
 
 func (p *SuperConfigMapPlugin) Generate() (resmap.ResMap, error) {
@@ -4,15 +4,26 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"path"
+	"sort"
+	"strings"
 
 	"sigs.k8s.io/kustomize/kyaml/filtersutil"
 	"sigs.k8s.io/kustomize/kyaml/kio"
 	kyaml "sigs.k8s.io/kustomize/kyaml/yaml"
+	"sigs.k8s.io/yaml"
 
+	"github.com/imdario/mergo"
 	"go.uber.org/zap"
+	"sigs.k8s.io/kustomize/api/builtins_qlik/utils"
+	"sigs.k8s.io/kustomize/api/diagnostics"
+	"sigs.k8s.io/kustomize/api/filters/fieldspec"
 	"sigs.k8s.io/kustomize/api/ifc"
 	"sigs.k8s.io/kustomize/api/internal/accumulator"
 	"sigs.k8s.io/kustomize/api/internal/plugins/builtinconfig"
+	"sigs.k8s.io/kustomize/api/konfig"
+	"sigs.k8s.io/kustomize/api/kv"
+	"sigs.k8s.io/kustomize/api/loader"
 	"sigs.k8s.io/kustomize/api/resmap"
 	"sigs.k8s.io/kustomize/api/resource"
 	"sigs.k8s.io/kustomize/api/types"
@@ -30,17 +41,50 @@ type IDecorator interface {
 	Generate() (resmap.ResMap, error)
 }
 
+// FromResource copies the keys of a ConfigMap or Secret of the input
+// matching one of the Keys patterns, all of them when there are none.
+type FromResource struct {
+	Kind      string   `json:"kind,omitempty" yaml:"kind,omitempty"`
+	Name      string   `json:"name,omitempty" yaml:"name,omitempty"`
+	Namespace string   `json:"namespace,omitempty" yaml:"namespace,omitempty"`
+	Keys      []string `json:"keys,omitempty" yaml:"keys,omitempty"`
+}
+
+// KeyBehavior sets how the keys matching one of the Keys patterns, all of them
+// when there are none, are set on the target: create only sets the keys it does
+// not have yet, merge deep merges a YAML or JSON map into the map it has and
+// replace, the default, overwrites them.
+type KeyBehavior struct {
+	Keys     []string `json:"keys,omitempty" yaml:"keys,omitempty"`
+	Behavior string   `json:"behavior,omitempty" yaml:"behavior,omitempty"`
+}
+
+// keyValue is a key to set on the target, in the order the keys are set.
+type keyValue struct {
+	key   string
+	value interface{}
+}
+
 type SuperMapPluginBase struct {
 	AssumeTargetWillExist bool   `json:"assumeTargetWillExist,omitempty" yaml:"assumeTargetWillExist,omitempty"`
 	Prefix                string `json:"prefix,omitempty" yaml:"prefix,omitempty"`
 	Rf                    *resmap.Factory
 	Hasher                ifc.KustHasher
 	Decorator             IDecorator
-	Configurations        []string `json:"configurations,omitempty" yaml:"configurations,omitempty"`
+	Configurations        []string       `json:"configurations,omitempty" yaml:"configurations,omitempty"`
+	FromResources         []FromResource `json:"fromResources,omitempty" yaml:"fromResources,omitempty"`
+	KeyBehaviors          []KeyBehavior  `json:"keyBehaviors,omitempty" yaml:"keyBehaviors,omitempty"`
+	RemoveKeys            []string       `json:"removeKeys,omitempty" yaml:"removeKeys,omitempty"`
+	Delete                bool           `json:"delete,omitempty" yaml:"delete,omitempty"`
 	tConfig               *builtinconfig.TransformerConfig
+	diagnostics           *diagnostics.Reporter
+	kvLoader              ifc.KvLoader
+	kvSources             types.KvPairSources
+	origin                string
+	recordOrigins         bool
 }
 
-func NewBase(rf *resmap.Factory, decorator IDecorator) SuperMapPluginBase {
+func NewBase(rf *resmap.Factory, dr *diagnostics.Reporter, decorator IDecorator) SuperMapPluginBase {
 	return SuperMapPluginBase{
 		AssumeTargetWillExist: true,
 		Prefix:                "",
@@ -49,6 +93,7 @@ func NewBase(rf *resmap.Factory, decorator IDecorator) SuperMapPluginBase {
 		Hasher:                rf.RF().Hasher(),
 		Configurations:        make([]string, 0),
 		tConfig:               nil,
+		diagnostics:           dr,
 	}
 }
 
@@ -67,14 +112,83 @@ func (b *SuperMapPluginBase) SetupTransformerConfig(ldr ifc.Loader) error {
 	return nil
 }
 
+// SetupSources validates fromResources and keyBehaviors and keeps what loading the
+// literals, files and envs of sources takes, for the transformer to set them on the target.
+// It must be called once the name of the decorator is known.
+func (b *SuperMapPluginBase) SetupSources(h *resmap.PluginHelpers, sources types.KvPairSources) error {
+	for i, from := range b.FromResources {
+		if from.Kind != "ConfigMap" && from.Kind != "Secret" {
+			return fmt.Errorf("fromResources[%v] kind must be ConfigMap or Secret, not: %v", i, from.Kind)
+		}
+		if from.Name == "" {
+			return fmt.Errorf("fromResources[%v] has no name", i)
+		}
+		if err := validateKeyPatterns(from.Keys); err != nil {
+			return fmt.Errorf("fromResources[%v] %v", i, err)
+		}
+	}
+	for i, keyBehavior := range b.KeyBehaviors {
+		if types.NewGenerationBehavior(keyBehavior.Behavior) == types.BehaviorUnspecified {
+			return fmt.Errorf("keyBehaviors[%v] behavior must be create, merge or replace, not: %v", i, keyBehavior.Behavior)
+		}
+		if err := validateKeyPatterns(keyBehavior.Keys); err != nil {
+			return fmt.Errorf("keyBehaviors[%v] %v", i, err)
+		}
+	}
+	if err := validateKeyPatterns(b.RemoveKeys); err != nil {
+		return fmt.Errorf("removeKeys %v", err)
+	}
+	b.kvLoader = kv.NewLoader(h.Loader(), h.Validator())
+	loader.RecordKvFiles(h.Inputs(), h.Loader(), sources)
+	b.kvSources = types.KvPairSources{
+		LiteralSources: append([]string(nil), sources.LiteralSources...),
+		FileSources:    append([]string(nil), sources.FileSources...),
+		EnvSources:     append([]string(nil), sources.EnvSources...),
+	}
+	b.recordOrigins = h.GeneralConfig() != nil && h.GeneralConfig().KeyOrigins
+	b.origin = h.Inputs().Relative(configOrigin(h.ConfigPath(), b.Decorator.GetType(), b.Decorator.GetName()))
+	return nil
+}
+
+func validateKeyPatterns(patterns []string) error {
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("has an invalid key pattern: %v, error: %v", pattern, err)
+		}
+	}
+	return nil
+}
+
+func keyMatches(patterns []string, key string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, key); matched {
+			return true
+		}
+	}
+	return false
+}
+
 func (b *SuperMapPluginBase) Transform(m resmap.ResMap) error {
 	resource := b.find(b.Decorator.GetName(), b.Decorator.GetType(), m)
-	if resource != nil {
+	if b.Delete {
+		if resource == nil {
+			b.diagnostics.Warnf(fmt.Sprintf("%v %v", b.Decorator.GetType(), b.Decorator.GetName()), diagnostics.ReasonTargetNotFound,
+				"%v is not in the input, there is nothing to delete", b.Decorator.GetType())
+			return nil
+		}
+		return b.executeDelete(resource, m)
+	} else if resource != nil {
 		return b.executeBasicTransform(resource, m)
 	} else if b.AssumeTargetWillExist && !b.Decorator.GetDisableNameSuffixHash() {
 		return b.executeAssumeWillExistTransform(m)
 	} else {
 		b.Decorator.GetLogger().Info("NOT executing anything because resource: %v is NOT in the input stream and AssumeTargetWillExist: %v, disableNameSuffixHash: %v\n", b.Decorator.GetName(), b.AssumeTargetWillExist, b.Decorator.GetDisableNameSuffixHash())
+		b.diagnostics.Warnf(fmt.Sprintf("%v %v", b.Decorator.GetType(), b.Decorator.GetName()), diagnostics.ReasonTargetNotFound,
+			"%v is not in the input and assumeTargetWillExist: %v, disableNameSuffixHash: %v, nothing was done",
+			b.Decorator.GetType(), b.AssumeTargetWillExist, b.Decorator.GetDisableNameSuffixHash())
 	}
 	return nil
 }
@@ -100,6 +214,18 @@ func (b *SuperMapPluginBase) executeAssumeWillExistTransform(m resmap.ResMap) er
 		return err
 	}
 
+	fromResourcesValues, err := b.fromResourcesValues(m)
+	if err != nil {
+		b.Decorator.GetLogger().Info("error copying keys from resources, error: %v\n", err)
+		return err
+	}
+	if len(fromResourcesValues) > 0 {
+		if err := b.appendData(tempResource, fromResourcesValues); err != nil {
+			b.Decorator.GetLogger().Info("error appending data to temp resource: %v, error: %v\n", b.Decorator.GetName(), err)
+			return err
+		}
+	}
+
 	err = m.Append(tempResource)
 	if err != nil {
 		b.Decorator.GetLogger().Info("error appending temp resource: %v to the resource map, error: %v\n", b.Decorator.GetName(), err)
@@ -137,7 +263,16 @@ func (b *SuperMapPluginBase) executeAssumeWillExistTransform(m resmap.ResMap) er
 func (b *SuperMapPluginBase) executeBasicTransform(resource *resource.Resource, m resmap.ResMap) error {
 	b.Decorator.GetLogger().Info("executeBasicTransform() for resource: %v...\n", resource)
 
-	if err := b.appendData(resource, b.Decorator.GetConfigData(), false); err != nil {
+	if err := b.removeData(resource); err != nil {
+		b.Decorator.GetLogger().Info("error removing keys from resource: %v, error: %v\n", b.Decorator.GetName(), err)
+		return err
+	}
+	values, err := b.values(m)
+	if err != nil {
+		b.Decorator.GetLogger().Info("error loading data for resource: %v, error: %v\n", b.Decorator.GetName(), err)
+		return err
+	}
+	if err := b.appendData(resource, values); err != nil {
 		b.Decorator.GetLogger().Info("error appending data to resource: %v, error: %v\n", b.Decorator.GetName(), err)
 		return err
 	}
@@ -162,6 +297,70 @@ func (b *SuperMapPluginBase) executeBasicTransform(resource *resource.Resource,
 	return nil
 }
 
+// executeDelete removes resource from m. It is an error if anything still refers
+// to it by name, as the nameReference of the transformer config says.
+func (b *SuperMapPluginBase) executeDelete(resource *resource.Resource, m resmap.ResMap) error {
+	referrers, err := b.referrers(resource, m)
+	if err != nil {
+		b.Decorator.GetLogger().Errorf("error looking for references to resource: %v, error: %v\n", b.Decorator.GetName(), err)
+		return err
+	}
+	if len(referrers) > 0 {
+		err := fmt.Errorf("%v %v cannot be deleted, it is still referenced by: %v",
+			b.Decorator.GetType(), resource.GetName(), strings.Join(referrers, ", "))
+		b.Decorator.GetLogger().Errorf("%v\n", err)
+		return err
+	}
+	return m.Remove(resource.CurId())
+}
+
+// referrers returns the resources of m, and their fields, that refer to target by name.
+func (b *SuperMapPluginBase) referrers(target *resource.Resource, m resmap.ResMap) ([]string, error) {
+	var referrers []string
+	for _, backRef := range b.tConfig.NameReference {
+		if !target.GetGvk().IsSelected(&backRef.Gvk) {
+			continue
+		}
+		for _, fs := range backRef.Referrers {
+			for _, res := range m.Resources() {
+				if res == target || (!res.CurId().IsClusterScoped() && !res.CurId().IsNsEquals(target.CurId())) {
+					continue
+				}
+				referenced := false
+				if _, err := (fieldspec.Filter{FieldSpec: fs, SetValue: func(node *kyaml.RNode) error {
+					referenced = referenced || refersTo(node, target.GetName())
+					return nil
+				}}).Filter(res.Node()); err != nil {
+					return nil, err
+				}
+				if referenced {
+					referrers = append(referrers, fmt.Sprintf("%v %v at %v", res.GetKind(), res.GetName(), fs.Path))
+				}
+			}
+		}
+	}
+	return referrers, nil
+}
+
+// refersTo tells if node, a name, a map with a name or a list of them, is name.
+func refersTo(node *kyaml.RNode, name string) bool {
+	switch node.YNode().Kind {
+	case kyaml.ScalarNode:
+		return node.YNode().Value == name
+	case kyaml.MappingNode:
+		nameNode := node.Field("name")
+		return nameNode != nil && refersTo(nameNode.Value, name)
+	case kyaml.SequenceNode:
+		elements, _ := node.Elements()
+		for _, element := range elements {
+			if refersTo(element, name) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 func (b *SuperMapPluginBase) executeNameReferencesTransformer(m resmap.ResMap) error {
 	ac := accumulator.MakeEmptyAccumulator()
 	if err := ac.AppendAll(m); err != nil {
@@ -214,7 +413,212 @@ func (b *SuperMapPluginBase) encodeBase64(s string) string {
 	return string(out[:k])
 }
 
-func (b *SuperMapPluginBase) appendData(res *resource.Resource, data map[string]interface{}, straightCopy bool) error {
+// values returns the keys to set on the target, in order: the keys copied
+// from resources, then the literals, files and envs, then the config data.
+func (b *SuperMapPluginBase) values(m resmap.ResMap) ([]keyValue, error) {
+	values, err := b.fromResourcesValues(m)
+	if err != nil {
+		return nil, err
+	}
+	if b.kvLoader != nil {
+		pairs, err := b.kvLoader.Load(b.kvSources)
+		if err != nil {
+			return nil, err
+		}
+		for _, pair := range pairs {
+			values = append(values, keyValue{key: pair.Key, value: pair.Value})
+		}
+	}
+	configData := b.Decorator.GetConfigData()
+	keys := make([]string, 0, len(configData))
+	for k := range configData {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		values = append(values, keyValue{key: k, value: configData[k]})
+	}
+	return values, nil
+}
+
+// fromResourcesValues returns the keys copied from the fromResources,
+// decoded for Secrets. A resource that is not in m is a warning.
+func (b *SuperMapPluginBase) fromResourcesValues(m resmap.ResMap) ([]keyValue, error) {
+	var values []keyValue
+	for i, from := range b.FromResources {
+		var found []*resource.Resource
+		for _, res := range m.Resources() {
+			if res.GetKind() == from.Kind && (res.GetName() == from.Name || res.OrgId().Name == from.Name) &&
+				(from.Namespace == "" || res.GetNamespace() == from.Namespace) {
+				found = append(found, res)
+			}
+		}
+		if len(found) == 0 {
+			b.diagnostics.Warnf(fmt.Sprintf("%v %v", b.Decorator.GetType(), b.Decorator.GetName()), diagnostics.ReasonObjRefNotFound,
+				"fromResources[%v] %v %v is not in the input, no key was copied", i, from.Kind, from.Name)
+			continue
+		} else if len(found) > 1 {
+			return nil, fmt.Errorf("fromResources[%v] %v %v matches %v resources, its namespace must be set", i, from.Kind, from.Name, len(found))
+		}
+		data, err := resourceData(found[0])
+		if err != nil {
+			return nil, fmt.Errorf("fromResources[%v] %v %v: %v", i, from.Kind, from.Name, err)
+		}
+		keys := make([]string, 0, len(data))
+		for k := range data {
+			if keyMatches(from.Keys, k) {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if from.Kind == "Secret" {
+				utils.RegisterSecretValues(data[k])
+			}
+			values = append(values, keyValue{key: k, value: data[k]})
+		}
+	}
+	return values, nil
+}
+
+// resourceData returns the data of a ConfigMap, or the decoded data and
+// the stringData of a Secret.
+func resourceData(res *resource.Resource) (map[string]string, error) {
+	data := res.GetDataMap()
+	if res.GetKind() != "Secret" {
+		return data, nil
+	}
+	for k, v := range data {
+		decoded, err := base64.StdEncoding.DecodeString(v)
+		if err != nil {
+			return nil, fmt.Errorf("error base64 decoding key: %v, error: %v", k, err)
+		}
+		data[k] = string(decoded)
+	}
+	stringData, err := res.Node().Pipe(kyaml.Lookup("stringData"))
+	if err != nil {
+		return nil, err
+	}
+	if stringData != nil {
+		if err := stringData.VisitFields(func(node *kyaml.MapNode) error {
+			data[kyaml.GetValue(node.Key)] = kyaml.GetValue(node.Value)
+			return nil
+		}); err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
+}
+
+// keyBehavior returns the behavior of the first keyBehaviors entry matching key, replace by default.
+func (b *SuperMapPluginBase) keyBehavior(key string) types.GenerationBehavior {
+	for _, keyBehavior := range b.KeyBehaviors {
+		if keyMatches(keyBehavior.Keys, key) {
+			return types.NewGenerationBehavior(keyBehavior.Behavior)
+		}
+	}
+	return types.BehaviorReplace
+}
+
+// mergeValue deep merges value into existing when both are YAML or JSON maps,
+// the result is written as JSON when existing is. It returns false otherwise.
+func (b *SuperMapPluginBase) mergeValue(existing, value interface{}) (string, bool) {
+	existingString, ok := existing.(string)
+	if !ok {
+		return "", false
+	}
+	valueString, ok := value.(string)
+	if !ok {
+		return "", false
+	}
+	if b.Decorator.ShouldBase64EncodeConfigData() {
+		decoded, err := base64.StdEncoding.DecodeString(existingString)
+		if err != nil {
+			return "", false
+		}
+		existingString = string(decoded)
+	}
+	var existingMap, valueMap map[string]interface{}
+	if err := yaml.Unmarshal([]byte(existingString), &existingMap); err != nil || existingMap == nil {
+		return "", false
+	}
+	if err := yaml.Unmarshal([]byte(valueString), &valueMap); err != nil || valueMap == nil {
+		return "", false
+	}
+	if err := mergo.Merge(&existingMap, valueMap, mergo.WithOverride); err != nil {
+		return "", false
+	}
+	var merged []byte
+	var err error
+	if strings.HasPrefix(strings.TrimSpace(existingString), "{") {
+		merged, err = json.Marshal(existingMap)
+	} else {
+		merged, err = yaml.Marshal(existingMap)
+	}
+	if err != nil {
+		return "", false
+	}
+	return string(merged), true
+}
+
+// removeData removes the keys matching removeKeys from the data of res,
+// and from the binaryData or the stringData, and their origins.
+func (b *SuperMapPluginBase) removeData(res *resource.Resource) error {
+	if len(b.RemoveKeys) == 0 {
+		return nil
+	}
+	annotations := res.GetAnnotations()
+	origins := make(map[string][]string)
+	if originsJson, ok := annotations[konfig.KeyOriginsAnnotation]; ok {
+		if err := json.Unmarshal([]byte(originsJson), &origins); err != nil {
+			return err
+		}
+	}
+	for _, field := range []string{"data", "binaryData", "stringData"} {
+		dataRn, err := res.Node().Pipe(kyaml.Lookup(field))
+		if err != nil {
+			return err
+		} else if dataRn == nil {
+			continue
+		}
+		keys, err := dataRn.Fields()
+		if err != nil {
+			return err
+		}
+		for _, key := range keys {
+			if !keyMatches(b.RemoveKeys, key) {
+				continue
+			}
+			if _, err := dataRn.Pipe(kyaml.Clear(key)); err != nil {
+				return err
+			}
+			delete(origins, key)
+		}
+	}
+	if _, ok := annotations[konfig.KeyOriginsAnnotation]; !ok {
+		return nil
+	} else if len(origins) == 0 {
+		delete(annotations, konfig.KeyOriginsAnnotation)
+	} else if originsJson, err := json.Marshal(origins); err != nil {
+		return err
+	} else {
+		annotations[konfig.KeyOriginsAnnotation] = string(originsJson)
+	}
+	res.SetAnnotations(annotations)
+	return nil
+}
+
+// appendData sets values in the data of res, base64 encoded for Secrets, as their key
+// behavior says, and records the config of the plugin as the origin of the keys it set
+// when the build records key origins.
+func (b *SuperMapPluginBase) appendData(res *resource.Resource, values []keyValue) error {
+	annotations := res.GetAnnotations()
+	origins := make(map[string][]string)
+	if originsJson, ok := annotations[konfig.KeyOriginsAnnotation]; ok {
+		if err := json.Unmarshal([]byte(originsJson), &origins); err != nil {
+			return err
+		}
+	}
 	if err := filtersutil.ApplyToJSON(kio.FilterFunc(func(nodes []*kyaml.RNode) ([]*kyaml.RNode, error) {
 		return kio.FilterAll(kyaml.FilterFunc(func(rn *kyaml.RNode) (*kyaml.RNode, error) {
 			if dataRn, err := rn.Pipe(kyaml.FieldMatcher{Name: "data"}); err != nil {
@@ -232,15 +636,29 @@ func (b *SuperMapPluginBase) appendData(res *resource.Resource, data map[string]
 					dataRn = &kyaml.RNode{}
 				}
 
-				for k, v := range data {
-					var val interface{}
-					val = v
-					if _, ok := v.(string); ok {
-						if !straightCopy && b.Decorator.ShouldBase64EncodeConfigData() {
-							val = b.encodeBase64(v.(string))
+				for _, kv := range values {
+					existing, exists := dataRnMap[kv.key]
+					val := kv.value
+					behavior := b.keyBehavior(kv.key)
+					if exists && behavior == types.BehaviorCreate {
+						continue
+					}
+					if exists && behavior == types.BehaviorMerge {
+						if merged, ok := b.mergeValue(existing, val); ok {
+							val = merged
+							origins[kv.key] = append(origins[kv.key], b.origin)
+						} else {
+							origins[kv.key] = []string{b.origin}
+						}
+					} else {
+						origins[kv.key] = []string{b.origin}
+					}
+					if _, ok := val.(string); ok {
+						if b.Decorator.ShouldBase64EncodeConfigData() {
+							val = b.encodeBase64(val.(string))
 						}
 					}
-					dataRnMap[k] = val
+					dataRnMap[kv.key] = val
 				}
 
 				if newJsonBytes, err := json.Marshal(dataRnMap); err != nil {
@@ -256,5 +674,15 @@ func (b *SuperMapPluginBase) appendData(res *resource.Resource, data map[string]
 	}), res); err != nil {
 		return err
 	}
+	if !b.recordOrigins || len(origins) == 0 {
+		return nil
+	}
+	originsJson, err := json.Marshal(origins)
+	if err != nil {
+		return err
+	}
+	annotations = res.GetAnnotations()
+	annotations[konfig.KeyOriginsAnnotation] = string(originsJson)
+	res.SetAnnotations(annotations)
 	return nil
 }
@@ -4,6 +4,7 @@ import (
 	"encoding/base64"
 	"fmt"
 
+	"sigs.k8s.io/kustomize/api/builtins_qlik/secrets"
 	"sigs.k8s.io/kustomize/api/builtins_qlik/utils"
 
 	"sigs.k8s.io/kustomize/api/builtins"
@@ -15,6 +16,7 @@ import (
 type SuperSecretPlugin struct {
 	StringData          map[string]string `json:"stringData,omitempty" yaml:"stringData,omitempty"`
 	Data                map[string]string `json:"data,omitempty" yaml:"data,omitempty"`
+	SecretRefs          []secrets.Ref     `json:"secretRefs,omitempty" yaml:"secretRefs,omitempty"`
 	aggregateConfigData map[string]interface{}
 	logger              *zap.SugaredLogger
 	builtins.SecretGeneratorPlugin
@@ -22,25 +24,42 @@ type SuperSecretPlugin struct {
 }
 
 func (p *SuperSecretPlugin) Config(h *resmap.PluginHelpers, c []byte) (err error) {
-	p.SuperMapPluginBase = NewBase(h.ResmapFactory(), p)
+	p.SuperMapPluginBase = NewBase(h.ResmapFactory(), h.Diagnostics(), p)
 	p.Data = make(map[string]string)
 	p.StringData = make(map[string]string)
 	err = yaml.Unmarshal(c, p)
 	if err != nil {
 		p.logger.Errorf("error unmarshalling yaml, error: %v\n", err)
-		return err
+		return utils.RedactError(err)
 	}
 	p.aggregateConfigData, err = p.getAggregateConfigData()
 	if err != nil {
 		p.logger.Errorf("error accumulating config data: %v\n", err)
 		return err
 	}
+	err = p.resolveSecretRefs(h)
+	if err != nil {
+		p.logger.Errorf("error resolving secretRefs: %v\n", err)
+		return err
+	}
+	for _, v := range p.aggregateConfigData {
+		utils.RegisterSecretValues(fmt.Sprintf("%v", v))
+	}
 	err = p.SuperMapPluginBase.SetupTransformerConfig(h.Loader())
 	if err != nil {
 		p.logger.Errorf("error setting up transformer config, error: %v\n", err)
 		return err
 	}
-	return p.SecretGeneratorPlugin.Config(h, c)
+	err = p.SecretGeneratorPlugin.Config(h, c)
+	if err != nil {
+		return err
+	}
+	err = p.SuperMapPluginBase.SetupSources(h, p.SecretGeneratorPlugin.KvPairSources)
+	if err != nil {
+		p.logger.Errorf("error setting up sources, error: %v\n", err)
+		return err
+	}
+	return nil
 }
 
 func (p *SuperSecretPlugin) getAggregateConfigData() (map[string]interface{}, error) {
@@ -50,6 +69,7 @@ func (p *SuperSecretPlugin) getAggregateConfigData() (map[string]interface{}, er
 	}
 	for k, v := range p.Data {
 		if decodedValue, err := base64.StdEncoding.DecodeString(v); err != nil {
+			utils.RegisterSecretValues(v)
 			p.logger.Errorf("error base64 decoding value: %v for key: %v, error: %v\n", v, k, err)
 			aggregateConfigData[k] = ""
 		} else {
@@ -59,6 +79,25 @@ func (p *SuperSecretPlugin) getAggregateConfigData() (map[string]interface{}, er
 	return aggregateConfigData, nil
 }
 
+// resolveSecretRefs adds the values fetched from the secret backends to the config data,
+// a key also set by stringData or data is an error.
+func (p *SuperSecretPlugin) resolveSecretRefs(h *resmap.PluginHelpers) error {
+	if len(p.SecretRefs) == 0 {
+		return nil
+	}
+	values, err := secrets.Resolve(h.Loader(), p.SecretRefs)
+	if err != nil {
+		return utils.RedactError(err)
+	}
+	for k, v := range values {
+		if _, exists := p.aggregateConfigData[k]; exists {
+			return fmt.Errorf("secret key %v is set by both secretRefs and stringData or data", k)
+		}
+		p.aggregateConfigData[k] = v
+	}
+	return nil
+}
+
 func (p *SuperSecretPlugin) Generate() (resmap.ResMap, error) {
 	for k, v := range p.aggregateConfigData {
 		p.LiteralSources = append(p.LiteralSources, fmt.Sprintf("%v=%v", k, v))
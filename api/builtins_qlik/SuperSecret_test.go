@@ -8,11 +8,13 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"sigs.k8s.io/kustomize/api/filesys"
+	"sigs.k8s.io/kustomize/api/konfig"
 	"sigs.k8s.io/kustomize/api/loader"
 	"sigs.k8s.io/kustomize/api/provider"
 	"sigs.k8s.io/kustomize/api/resmap"
 	valtest_test "sigs.k8s.io/kustomize/api/testutils/valtest"
 	"sigs.k8s.io/kustomize/api/types"
+	"sigs.k8s.io/kustomize/kyaml/resid"
 )
 
 func TestSuperSecret_simpleTransformer(t *testing.T) {
@@ -735,3 +737,249 @@ behavior: create
 		})
 	}
 }
+
+func TestSuperSecret_secretRefs(t *testing.T) {
+	testCases := []struct {
+		name            string
+		pluginConfig    string
+		expectedError   string
+		checkAssertions func(*testing.T, resmap.ResMap)
+	}{
+		{
+			name: "resolved",
+			pluginConfig: `
+apiVersion: qlik.com/v1
+kind: SuperSecret
+metadata:
+  name: mySecret
+stringData:
+  foo: bar
+secretRefs:
+- backend: file
+  path: secrets.yaml
+  key: db.password
+  as: PASSWORD
+behavior: create
+`,
+			checkAssertions: func(t *testing.T, resMap resmap.ResMap) {
+				assert.Equal(t, 1, resMap.Size())
+				res := resMap.Resources()[0]
+				assert.Equal(t, "mySecret", res.GetName())
+				assert.True(t, res.NeedHashSuffix())
+
+				value, err := res.GetFieldValue("data.PASSWORD")
+				assert.NoError(t, err)
+				assert.Equal(t, base64.StdEncoding.EncodeToString([]byte("s3cr3t")), value)
+
+				value, err = res.GetFieldValue("data.foo")
+				assert.NoError(t, err)
+				assert.Equal(t, base64.StdEncoding.EncodeToString([]byte("bar")), value)
+			},
+		},
+		{
+			name: "conflicting key",
+			pluginConfig: `
+apiVersion: qlik.com/v1
+kind: SuperSecret
+metadata:
+  name: mySecret
+stringData:
+  password: bar
+secretRefs:
+- backend: file
+  path: secrets.yaml
+  key: db.password
+behavior: create
+`,
+			expectedError: "secret key password is set by both secretRefs and stringData or data",
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			fSys := filesys.MakeFsInMemory()
+			err := fSys.WriteFile("/secrets.yaml", []byte("db:\n  password: s3cr3t\n"))
+			assert.NoError(t, err)
+
+			p := provider.NewDefaultDepProvider()
+			resourceFactory := resmap.NewFactory(p.GetResourceFactory())
+
+			plugin := NewSuperSecretGeneratorPlugin()
+			err = plugin.Config(resmap.NewPluginHelpers(loader.NewFileLoaderAtRoot(fSys), valtest_test.MakeFakeValidator(), resourceFactory, types.DisabledPluginConfig()), []byte(testCase.pluginConfig))
+			if testCase.expectedError != "" {
+				assert.EqualError(t, err, testCase.expectedError)
+				return
+			}
+			assert.NoError(t, err)
+
+			resMap, err := plugin.Generate()
+			assert.NoError(t, err)
+			testCase.checkAssertions(t, resMap)
+		})
+	}
+}
+
+func TestSuperSecret_sources(t *testing.T) {
+	pluginInputResources := `
+apiVersion: v1
+kind: Secret
+metadata:
+  name: mySecret
+type: Opaque
+data:
+  config.yaml: ` + base64.StdEncoding.EncodeToString([]byte("db:\n  host: localhost\n  port: 5432\n")) + `
+  token: ` + base64.StdEncoding.EncodeToString([]byte("old")) + `
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: shared
+type: Opaque
+data:
+  api.key: ` + base64.StdEncoding.EncodeToString([]byte("k3y")) + `
+  unrelated: ` + base64.StdEncoding.EncodeToString([]byte("nope")) + `
+stringData:
+  api.url: https://api
+`
+	testCases := []struct {
+		name            string
+		pluginConfig    string
+		expectedError   string
+		checkAssertions func(*testing.T, resmap.ResMap)
+	}{
+		{
+			name: "fromResources, files and merge",
+			pluginConfig: `
+apiVersion: qlik.com/v1
+kind: SuperSecret
+metadata:
+  name: mySecret
+options:
+  disableNameSuffixHash: true
+fromResources:
+- kind: Secret
+  name: shared
+  keys:
+  - api.*
+files:
+- config.yaml
+keyBehaviors:
+- keys:
+  - config.yaml
+  behavior: merge
+- keys:
+  - token
+  behavior: create
+stringData:
+  token: new
+`,
+			checkAssertions: func(t *testing.T, resMap resmap.ResMap) {
+				res, err := resMap.GetById(resid.NewResId(resid.NewGvk("", "v1", "Secret"), "mySecret"))
+				assert.NoError(t, err)
+
+				data := make(map[string]string)
+				for key, value := range res.GetDataMap() {
+					decoded, err := base64.StdEncoding.DecodeString(value)
+					assert.NoError(t, err)
+					data[key] = string(decoded)
+				}
+				assert.Equal(t, map[string]string{
+					"config.yaml": "db:\n  host: db.svc\n  port: 5432\n",
+					"token":       "old",
+					"api.key":     "k3y",
+					"api.url":     "https://api",
+				}, data)
+
+				assert.Equal(t, `{"api.key":["/app/superSecret.yaml"],"api.url":["/app/superSecret.yaml"],"config.yaml":["/app/superSecret.yaml"]}`,
+					res.GetAnnotations()[konfig.KeyOriginsAnnotation])
+			},
+		},
+		{
+			name: "removeKeys and delete",
+			pluginConfig: `
+apiVersion: qlik.com/v1
+kind: SuperSecret
+metadata:
+  name: shared
+options:
+  disableNameSuffixHash: true
+removeKeys:
+- api.*
+`,
+			checkAssertions: func(t *testing.T, resMap resmap.ResMap) {
+				res, err := resMap.GetById(resid.NewResId(resid.NewGvk("", "v1", "Secret"), "shared"))
+				assert.NoError(t, err)
+				assert.Equal(t, map[string]string{"unrelated": base64.StdEncoding.EncodeToString([]byte("nope"))}, res.GetDataMap())
+				stringData, err := res.GetFieldValue("stringData")
+				assert.NoError(t, err)
+				assert.Empty(t, stringData)
+
+				plugin := NewSuperSecretTransformerPlugin()
+				err = plugin.Config(resmap.NewPluginHelpers(loader.NewFileLoaderAtRoot(filesys.MakeFsInMemory()), valtest_test.MakeFakeValidator(),
+					resmap.NewFactory(provider.NewDefaultDepProvider().GetResourceFactory()), types.DisabledPluginConfig()), []byte(`
+apiVersion: qlik.com/v1
+kind: SuperSecret
+metadata:
+  name: shared
+delete: true
+`))
+				assert.NoError(t, err)
+				assert.NoError(t, plugin.Transform(resMap))
+				assert.Equal(t, 1, resMap.Size())
+			},
+		},
+		{
+			name: "invalid fromResources kind",
+			pluginConfig: `
+apiVersion: qlik.com/v1
+kind: SuperSecret
+metadata:
+  name: mySecret
+fromResources:
+- kind: Deployment
+  name: shared
+`,
+			expectedError: "fromResources[0] kind must be ConfigMap or Secret, not: Deployment",
+		},
+		{
+			name: "invalid key behavior",
+			pluginConfig: `
+apiVersion: qlik.com/v1
+kind: SuperSecret
+metadata:
+  name: mySecret
+keyBehaviors:
+- behavior: create-only
+`,
+			expectedError: "keyBehaviors[0] behavior must be create, merge or replace, not: create-only",
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			fSys := filesys.MakeFsInMemory()
+			err := fSys.WriteFile("/app/config.yaml", []byte("db:\n  host: db.svc\n"))
+			assert.NoError(t, err)
+			ldr, err := loader.NewLoader(loader.RestrictionRootOnly, "/app", fSys)
+			assert.NoError(t, err)
+
+			p := provider.NewDefaultDepProvider()
+			resourceFactory := resmap.NewFactory(p.GetResourceFactory())
+			resMap, err := resourceFactory.NewResMapFromBytes([]byte(pluginInputResources))
+			assert.NoError(t, err)
+
+			pc := types.DisabledPluginConfig()
+			pc.KeyOrigins = true
+			plugin := NewSuperSecretTransformerPlugin()
+			h := resmap.NewPluginHelpers(ldr, valtest_test.MakeFakeValidator(), resourceFactory, pc)
+			err = plugin.Config(h.WithConfigPath("/app/superSecret.yaml"), []byte(testCase.pluginConfig))
+			if testCase.expectedError != "" {
+				assert.EqualError(t, err, testCase.expectedError)
+				return
+			}
+			assert.NoError(t, err)
+
+			err = plugin.Transform(resMap)
+			assert.NoError(t, err)
+			testCase.checkAssertions(t, resMap)
+		})
+	}
+}
@@ -9,8 +9,11 @@ import (
 	"path/filepath"
 
 	"github.com/imdario/mergo"
+	"sigs.k8s.io/kustomize/api/builtins_qlik/secrets"
 	"sigs.k8s.io/kustomize/api/builtins_qlik/utils"
 	"sigs.k8s.io/kustomize/api/ifc"
+	"sigs.k8s.io/kustomize/api/loader"
+	"sigs.k8s.io/kustomize/api/provenance"
 	"sigs.k8s.io/kustomize/api/resmap"
 	"sigs.k8s.io/yaml"
 	"go.uber.org/zap"
@@ -22,6 +25,7 @@ type ValuesFilePlugin struct {
 	Root       string
 	ldr        ifc.Loader
 	rf         *resmap.Factory
+	inputs     *provenance.Recorder
 	logger     *zap.SugaredLogger
 }
 
@@ -29,6 +33,7 @@ func (p *ValuesFilePlugin) Config(h *resmap.PluginHelpers, c []byte) (err error)
 	p.ldr = h.Loader()
 	p.rf = h.ResmapFactory()
 	p.Root = h.Loader().Root()
+	p.inputs = h.Inputs()
 	return yaml.Unmarshal(c, p)
 }
 
@@ -52,29 +57,22 @@ func (p *ValuesFilePlugin) Transform(m resmap.ResMap) error {
 		vaultAddressPath = fmt.Sprintf("%v", p.DataSource["vault"].(map[string]interface{})["addressPath"])
 		vaultTokenPath = fmt.Sprintf("%v", p.DataSource["vault"].(map[string]interface{})["tokenPath"])
 
-		if _, err := os.Stat(vaultAddressPath); os.IsNotExist(err) {
-			readBytes, err := ioutil.ReadFile(vaultAddressPath)
-			if err != nil {
-				p.logger.Errorf("error reading file : %v, error: %v\n", vaultAddressPath, err)
-				return err
-			}
-			vaultAddress = fmt.Sprintf("VAULT_ADDR=%s", string(readBytes))
-			env = append(env, vaultAddress)
-		} else if err != nil {
-			p.logger.Errorf("error executing stat on file: %v, error: %v\n", vaultAddressPath, err)
+		readBytes, err := ioutil.ReadFile(secrets.KeyFilePath(p.ldr, vaultAddressPath))
+		if err != nil {
+			p.logger.Errorf("error reading file : %v, error: %v\n", vaultAddressPath, err)
+			return err
 		}
+		vaultAddress = fmt.Sprintf("VAULT_ADDR=%s", string(readBytes))
+		env = append(env, vaultAddress)
 
-		if _, err := os.Stat(vaultTokenPath); os.IsNotExist(err) {
-			readBytes, err := ioutil.ReadFile(fmt.Sprintf("%v", vaultTokenPath))
-			if err != nil {
-				p.logger.Errorf("error reading file: %v, error: %v\n", vaultTokenPath, err)
-				return err
-			}
-			vaultToken = fmt.Sprintf("VAULT_TOKEN=%s", string(readBytes))
-			env = append(env, vaultToken)
-		} else if err != nil {
-			p.logger.Errorf("error executing stat on file: %v, error: %v\n", vaultTokenPath, err)
+		readBytes, err = ioutil.ReadFile(secrets.KeyFilePath(p.ldr, vaultTokenPath))
+		if err != nil {
+			p.logger.Errorf("error reading file: %v, error: %v\n", vaultTokenPath, err)
+			return err
 		}
+		utils.RegisterSecretValues(string(readBytes))
+		vaultToken = fmt.Sprintf("VAULT_TOKEN=%s", string(readBytes))
+		env = append(env, vaultToken)
 	}
 
 	var ejsonKey string
@@ -84,29 +82,38 @@ func (p *ValuesFilePlugin) Transform(m resmap.ResMap) error {
 				err := errors.New("privateKeyPath must be a string")
 				p.logger.Errorf("error: %v\n", err)
 				return err
-			} else if _, err := os.Stat(ejsonPrivateKeyPath); err != nil {
-				p.logger.Errorf("error executing stat on ejson private key file: %v, error: %v\n", ejsonPrivateKeyPath, err)
+			} else if readBytes, err := ioutil.ReadFile(secrets.KeyFilePath(p.ldr, ejsonPrivateKeyPath)); err != nil {
+				p.logger.Errorf("error reading ejson private key file: %v, error: %v\n", ejsonPrivateKeyPath, err)
+				return err
 			} else {
-				readBytes, err := ioutil.ReadFile(ejsonPrivateKeyPath)
-				if err != nil {
-					p.logger.Errorf("error reading ejson private key file: %v, error: %v\n", ejsonPrivateKeyPath, err)
-					return err
-				}
+				utils.RegisterSecretValues(string(readBytes))
 				ejsonKey = fmt.Sprintf("EJSON_KEY=%s", string(readBytes))
 				env = append(env, ejsonKey)
 			}
 		}
 	}
 	if os.Getenv("EJSON_KEY") != "" && ejsonKey == "" {
+		utils.RegisterSecretValues(os.Getenv("EJSON_KEY"))
 		ejsonKey = fmt.Sprintf("EJSON_KEY=%s", os.Getenv("EJSON_KEY"))
 		env = append(env, ejsonKey)
 	}
 
-	var dataSource string
+	// the vault secret is the only remote datasource, declared by the vault datasource config
+	var localDataSources, remoteDataSources []string
 	if ejsonKey != "" {
-		dataSource = fmt.Sprintf("%v", p.DataSource["ejson"].(map[string]interface{})["filePath"])
+		localDataSources = []string{fmt.Sprintf("data=%v", p.DataSource["ejson"].(map[string]interface{})["filePath"])}
 	} else if vaultAddress != "" && vaultToken != "" {
-		dataSource = fmt.Sprintf("%v", p.DataSource["vault"].(map[string]interface{})["secretPath"])
+		remoteDataSources = []string{fmt.Sprintf("data=%v", p.DataSource["vault"].(map[string]interface{})["secretPath"])}
+	}
+	dir, err := ioutil.TempDir("", "kustomize-gomplate-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	dataSources, err := utils.GomplateDataSources(p.ldr, dir, localDataSources, remoteDataSources, p.inputs)
+	if err != nil {
+		p.logger.Errorf("error resolving dataSources: %v, error: %v\n", p.DataSource, err)
+		return utils.RedactError(err)
 	}
 
 	filePath := filepath.Join(p.Root, p.ValuesFile)
@@ -115,6 +122,7 @@ func (p *ValuesFilePlugin) Transform(m resmap.ResMap) error {
 		p.logger.Errorf("error reading values.tml.yaml file: %v, error: %v\n", filePath, err)
 		return errors.New("Error: values.tml.yaml is not found")
 	}
+	p.inputs.Record(provenance.InputFile, loader.InputURI(p.ldr, filePath), fileData)
 
 	for _, r := range m.Resources() {
 		// gomplate the initial values file first
@@ -123,10 +131,10 @@ func (p *ValuesFilePlugin) Transform(m resmap.ResMap) error {
 			p.logger.Errorf("error getting resource as yaml: %v, error: %v\n", r.GetName(), err)
 			return errors.New("Error: Not a valid yaml file")
 		}
-		output, err := utils.RunGomplate(dataSource, p.Root, env, string(fileData), p.logger)
+		output, err := utils.RunGomplate(dataSources, env, string(fileData), p.logger)
 		if err != nil {
 			p.logger.Errorf("error executing runGomplate(), error: %v\n", err)
-			return err
+			return utils.RedactError(err)
 		}
 		var Values map[string]interface{}
 		err = yaml.Unmarshal(output, &Values)
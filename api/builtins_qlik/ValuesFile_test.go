@@ -31,6 +31,7 @@ func TestValuesFile(t *testing.T) {
 		pluginInputResources string
 		valuesFileContent    string
 		expectedResult       string
+		expectedError        string
 		checkAssertions      func(*testing.T, resmap.ResMap, string)
 	}{
 		{
@@ -80,6 +81,30 @@ values:
 				assert.Equal(t, expected, result)
 			},
 		},
+		{
+			name: "ValuesFile unreadable ejson private key",
+			pluginConfig: `
+apiVersion: qlik.com/v1
+kind: ValuesFile
+metadata:
+  name: qliksense
+enabled: true
+valuesFile: values.tml.yaml
+dataSource:
+  ejson:
+    filePath: test.json
+    privateKeyPath: /does/not/exist/ejson.key
+`,
+			valuesFileContent: valuesFileContent,
+			pluginInputResources: `
+apiVersion: apps/v1
+kind: HelmValues
+metadata:
+  name: collections
+values: {}
+`,
+			expectedError: "/does/not/exist/ejson.key",
+		},
 	}
 	for _, testCase := range testCases {
 		t.Run(testCase.name, func(t *testing.T) {
@@ -106,6 +131,11 @@ values:
 			}
 
 			err = plugin.Transform(resMap)
+			if testCase.expectedError != "" {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), testCase.expectedError)
+				return
+			}
 			if err != nil {
 				t.Fatalf("Err: %v", err)
 			}
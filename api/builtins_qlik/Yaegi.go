@@ -1,15 +1,16 @@
 package builtins_qlik
 
 import (
+	"fmt"
 	"io/ioutil"
 	"reflect"
 
 	"github.com/traefik/yaegi/interp"
-	"github.com/traefik/yaegi/stdlib"
 	"sigs.k8s.io/kustomize/api/builtins_qlik/utils"
+	"sigs.k8s.io/kustomize/api/builtins_qlik/yaegi/resources"
 	"sigs.k8s.io/kustomize/api/builtins_qlik/yaegi/yamlv3"
+	"sigs.k8s.io/kustomize/api/diagnostics"
 	"sigs.k8s.io/kustomize/api/resmap"
-	"sigs.k8s.io/kustomize/api/resource"
 	"sigs.k8s.io/kustomize/api/types"
 	"sigs.k8s.io/yaml"
 	"go.uber.org/zap"
@@ -21,76 +22,69 @@ type YaegiPlugin struct {
 	BuildArgs        []string `json:"buildArgs,omitempty" yaml:"buildArgs,omitempty"`
 	BuildScript      string   `json:"buildScript,omitempty" yaml:"buildScript,omitempty"`
 	BuildScriptFile  string   `json:"buildScriptFile,omitempty" yaml:"buildScriptFile,omitempty"`
+	Capabilities     []string `json:"capabilities,omitempty" yaml:"capabilities,omitempty"`
+	ScriptTimeout    string   `json:"scriptTimeout,omitempty" yaml:"scriptTimeout,omitempty"`
+	root             string
 	rf               *resmap.Factory
 	logger           *zap.SugaredLogger
+	diagnostics      *diagnostics.Reporter
 	yamlBytes        []byte
 }
 
 // Config ...
 func (p *YaegiPlugin) Config(h *resmap.PluginHelpers, c []byte) (err error) {
 	p.rf = h.ResmapFactory()
+	p.root = h.Loader().Root()
+	p.diagnostics = h.Diagnostics()
 	p.yamlBytes = c
 	return yaml.Unmarshal(c, p)
 }
 
 func (p *YaegiPlugin) Transform(m resmap.ResMap) error {
-	var byteArray [][]byte
-	var err error
-	var resources = m.Resources()
-	for _, r := range resources {
-		yamlByte, err := r.AsYAML()
-		if err != nil {
-			p.logger.Errorf("Go Yaml Error: %v\n", err)
-			return err
+	// the script sees no error from GetResources, so a marshalling
+	// error is kept to fail the transformation once the script returns.
+	var getResourcesErr error
+	getResources := func() [][]byte {
+		var byteArray [][]byte
+		for _, r := range m.Resources() {
+			yamlByte, err := r.AsYAML()
+			if err != nil {
+				p.logger.Errorf("Go Yaml Error: %v\n", err)
+				getResourcesErr = err
+				return nil
+			}
+			byteArray = append(byteArray, yamlByte)
 		}
-		byteArray = append(byteArray, yamlByte)
+		return byteArray
 	}
-	var (
-		Yaegi = interp.Exports{
-			"yaegi": map[string]reflect.Value{
-				"GetResources": reflect.ValueOf(func() [][]byte {
-					return byteArray
-				}),
-				"GetPlugin": reflect.ValueOf(func() []byte {
-					return p.yamlBytes
-				}),
-			},
-		}
-	)
-
-	i := interp.New(interp.Options{})
-	i.Use(stdlib.Symbols)
-	i.Use(yamlv3.Symbols)
-	i.Use(Yaegi)
+	rs := resources.New(m, p.rf.RF())
+	Yaegi := p.exports(getResources, rs)
 
-	if len(p.BuildScript) > 0 {
-		_, err = i.Eval(p.BuildScript)
-	} else {
-		var gocode []byte
-		gocode, err = ioutil.ReadFile(p.BuildScriptFile)
-		if err != nil {
-			p.logger.Errorf("Error loading go file: %v\n", err)
-			return err
-		}
-		_, err = i.Eval(string(gocode))
-	}
+	sandbox, err := p.evalBuildScript(Yaegi)
 	if err != nil {
-		p.logger.Errorf("Go Script Error: %v\n", err)
 		return err
 	}
-	v, err := i.Eval("kust.Transform")
-
+	ret, err := sandbox.Call("kust.Transform", p.BuildArgs, 1)
 	if err != nil {
-		p.logger.Errorf("Go Script Error: %v\n", err)
+		p.logger.Errorf("Error from kust.Transform: %v\n", err)
 		return err
 	}
-	transform := v.Interface().(func([]string) (*[][]byte, error))
-	transformRet, err := transform(p.BuildArgs)
-	if err != nil {
-		p.logger.Errorf("Error from post-Build: %v\n", err)
+	if getResourcesErr != nil {
+		return getResourcesErr
+	}
+	transformRet, ok := ret[0].(*[][]byte)
+	if !ok && ret[0] != nil {
+		err = fmt.Errorf("kust.Transform must return (*[][]byte, error)")
+		p.logger.Errorf("Go Script Error: %v\n", err)
 		return err
 	}
 	if transformRet != nil {
+		// resources removed through the Resources API stay removed,
+		// even if the script returns them from an earlier GetResources.
+		removed := make(map[string]bool)
+		for _, id := range rs.Removed() {
+			removed[id.String()] = true
+		}
 		kustBytes := [][]byte(*transformRet)
 		for _, r := range kustBytes {
 			res, err := p.rf.RF().FromBytes(r)
@@ -98,6 +92,11 @@ func (p *YaegiPlugin) Transform(m resmap.ResMap) error {
 				p.logger.Errorf("error unmarshalling resource from bytes: %v\n", err)
 				return err
 			}
+			if removed[res.CurId().String()] {
+				p.diagnostics.Warnf(res.CurId().String(), diagnostics.ReasonIgnoredError,
+					"kust.Transform returned a resource it removed, keeping it removed")
+				continue
+			}
 			origres, _ := m.GetById(res.CurId())
 			if origres == nil {
 				m.Append(res)
@@ -115,54 +114,24 @@ func (p *YaegiPlugin) Transform(m resmap.ResMap) error {
 
 }
 func (p *YaegiPlugin) Generate() (resmap.ResMap, error) {
+	m := p.rf.FromResourceSlice(nil)
+	Yaegi := p.exports(func() [][]byte { return nil }, resources.New(m, p.rf.RF()))
 
-	var err error
-	var (
-		Yaegi = interp.Exports{
-			"yaegi": map[string]reflect.Value{
-				"GetResources": reflect.ValueOf(func() [][]byte {
-					return nil
-				}),
-				"GetPlugin": reflect.ValueOf(func() []byte {
-					return p.yamlBytes
-				}),
-			},
-		}
-	)
-
-	i := interp.New(interp.Options{})
-
-	i.Use(stdlib.Symbols)
-	i.Use(yamlv3.Symbols)
-	i.Use(Yaegi)
-
-	if len(p.BuildScript) > 0 {
-		_, err = i.Eval(p.BuildScript)
-	} else {
-		var gocode []byte
-		gocode, err = ioutil.ReadFile(p.BuildScriptFile)
-		if err != nil {
-			p.logger.Errorf("Error loading go file: %v\n", err)
-			return nil, err
-		}
-		_, err = i.Eval(string(gocode))
-	}
+	sandbox, err := p.evalBuildScript(Yaegi)
 	if err != nil {
-		p.logger.Errorf("Go Script Error: %v\n", err)
 		return nil, err
 	}
-	v, err := i.Eval("kust.Generate")
+	ret, err := sandbox.Call("kust.Generate", p.BuildArgs, 1)
 	if err != nil {
-		p.logger.Errorf("Go Script Error: %v\n", err)
+		p.logger.Errorf("Error from kust.Generate: %v\n", err)
 		return nil, err
 	}
-	generate := v.Interface().(func([]string) (*[][]byte, error))
-	generateRet, err := generate(p.BuildArgs)
-	if err != nil {
-		p.logger.Errorf("Error from post-Build: %v\n", err)
+	generateRet, ok := ret[0].(*[][]byte)
+	if !ok && ret[0] != nil {
+		err = fmt.Errorf("kust.Generate must return (*[][]byte, error)")
+		p.logger.Errorf("Go Script Error: %v\n", err)
 		return nil, err
 	}
-	var retVal []*resource.Resource
 	if generateRet != nil {
 		kustBytes := [][]byte(*generateRet)
 		for _, r := range kustBytes {
@@ -171,10 +140,55 @@ func (p *YaegiPlugin) Generate() (resmap.ResMap, error) {
 				p.logger.Errorf("error unmarshalling resource from bytes: %v\n", err)
 				return nil, err
 			}
-			retVal = append(retVal, res)
+			if err := m.Append(res); err != nil {
+				p.logger.Errorf("error appending resource: %v\n", err)
+				return nil, err
+			}
+		}
+	}
+	return m, nil
+}
+
+// exports returns the yaegi package of the build script: the legacy GetResources,
+// which marshals every resource to YAML, and the typed Resources API working on the resources in place.
+func (p *YaegiPlugin) exports(getResources func() [][]byte, rs *resources.Resources) interp.Exports {
+	return interp.Exports{
+		"yaegi": map[string]reflect.Value{
+			"GetResources": reflect.ValueOf(getResources),
+			"GetPlugin": reflect.ValueOf(func() []byte {
+				return p.yamlBytes
+			}),
+			"Resources": reflect.ValueOf(func() *resources.Resources {
+				return rs
+			}),
+			"ResourceSet": reflect.ValueOf((*resources.Resources)(nil)),
+			"Resource":    reflect.ValueOf((*resources.Resource)(nil)),
+			"ID":          reflect.ValueOf((*resources.ID)(nil)),
+			"Selector":    reflect.ValueOf((*resources.Selector)(nil)),
+		},
+	}
+}
+
+// evalBuildScript evaluates the build script in a sandbox confined to the kustomization root.
+func (p *YaegiPlugin) evalBuildScript(exports interp.Exports) (*utils.YaegiSandbox, error) {
+	sandbox, err := utils.NewYaegiSandbox(p.root, p.Capabilities, p.ScriptTimeout, yamlv3.Symbols, exports)
+	if err != nil {
+		p.logger.Errorf("Error configuring go script sandbox: %v\n", err)
+		return nil, err
+	}
+	gocode := []byte(p.BuildScript)
+	if len(p.BuildScript) == 0 {
+		gocode, err = ioutil.ReadFile(utils.ResolvePath(p.root, p.BuildScriptFile))
+		if err != nil {
+			p.logger.Errorf("Error loading go file: %v\n", err)
+			return nil, err
 		}
 	}
-	return p.rf.FromResourceSlice(retVal), nil
+	if err := sandbox.Eval(string(gocode)); err != nil {
+		p.logger.Errorf("Go Script Error: %v\n", err)
+		return nil, err
+	}
+	return sandbox, nil
 }
 
 func NewYaegiTransformerPlugin() resmap.TransformerPlugin {
@@ -0,0 +1,305 @@
+package builtins_qlik
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/kustomize/api/diagnostics"
+	"sigs.k8s.io/kustomize/api/filesys"
+	"sigs.k8s.io/kustomize/api/loader"
+	"sigs.k8s.io/kustomize/api/provider"
+	"sigs.k8s.io/kustomize/api/resmap"
+	valtest_test "sigs.k8s.io/kustomize/api/testutils/valtest"
+	"sigs.k8s.io/kustomize/api/types"
+)
+
+func TestYaegiTransformer(t *testing.T) {
+	testCases := []struct {
+		name                 string
+		pluginConfig         string
+		pluginInputResources string
+		expectedYaml         string
+		expectedDiagnostics  []string
+	}{
+		{
+			name: "typed resource api",
+			pluginConfig: `
+apiVersion: qlik.com/v1
+kind: Yaegi
+metadata:
+  name: notImportantHere
+buildArgs:
+- registry.example.com/app:2.0
+buildScript: |
+  package kust
+
+  import "yaegi"
+
+  func Transform(args []string) (*[][]byte, error) {
+  	rs := yaegi.Resources()
+  	deployments, err := rs.Select(yaegi.Selector{Kind: "Deployment", LabelSelector: "tier=web"})
+  	if err != nil {
+  		return nil, err
+  	}
+  	for _, d := range deployments {
+  		if err := d.Set("spec.replicas", 3); err != nil {
+  			return nil, err
+  		}
+  		if err := d.SetString("spec.template.spec.containers.[name=app].image", args[0]); err != nil {
+  			return nil, err
+  		}
+  		if err := d.Delete("spec.template.spec.containers.[name=sidecar]"); err != nil {
+  			return nil, err
+  		}
+  	}
+  	for _, id := range rs.IDs() {
+  		if id.Kind == "ConfigMap" {
+  			if err := rs.Remove(id); err != nil {
+  				return nil, err
+  			}
+  		}
+  	}
+  	_, err = rs.Add("apiVersion: v1\nkind: Service\nmetadata:\n  name: web\n")
+  	return nil, err
+  }
+`,
+			pluginInputResources: `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  labels:
+    tier: web
+spec:
+  # keep one replica until the transform scales it
+  replicas: 1
+  template:
+    spec:
+      containers:
+      - name: app
+        image: app:1.0 # the image to replace
+      - name: sidecar
+        image: sidecar:1.0
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: obsolete
+`,
+			expectedYaml: `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  labels:
+    tier: web
+spec:
+  # keep one replica until the transform scales it
+  replicas: 3
+  template:
+    spec:
+      containers:
+      - name: app
+        image: registry.example.com/app:2.0 # the image to replace
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+`,
+		},
+		{
+			name: "get resources",
+			pluginConfig: `
+apiVersion: qlik.com/v1
+kind: Yaegi
+metadata:
+  name: notImportantHere
+buildScript: |
+  package kust
+
+  import (
+  	"strings"
+
+  	"yaegi"
+  )
+
+  func Transform(args []string) (*[][]byte, error) {
+  	var ret [][]byte
+  	for _, r := range yaegi.GetResources() {
+  		ret = append(ret, []byte(strings.Replace(string(r), "app:1.0", "app:1.1", 1)))
+  	}
+  	return &ret, nil
+  }
+`,
+			pluginInputResources: `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  template:
+    spec:
+      containers:
+      - name: app
+        image: app:1.0
+`,
+			expectedYaml: `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  template:
+    spec:
+      containers:
+      - image: app:1.1
+        name: app
+`,
+		},
+		{
+			name: "removed resources stay removed",
+			pluginConfig: `
+apiVersion: qlik.com/v1
+kind: Yaegi
+metadata:
+  name: notImportantHere
+buildScript: |
+  package kust
+
+  import "yaegi"
+
+  func Transform(args []string) (*[][]byte, error) {
+  	ret := yaegi.GetResources()
+  	rs := yaegi.Resources()
+  	for _, id := range rs.IDs() {
+  		if id.Kind == "ConfigMap" {
+  			if err := rs.Remove(id); err != nil {
+  				return nil, err
+  			}
+  		}
+  	}
+  	return &ret, nil
+  }
+`,
+			pluginInputResources: `
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: obsolete
+`,
+			expectedYaml: `apiVersion: v1
+kind: Service
+metadata:
+  name: web
+`,
+			expectedDiagnostics: []string{
+				"Yaegi notImportantHere: IgnoredError [~G_v1_ConfigMap|~X|obsolete]: kust.Transform returned a resource it removed, keeping it removed",
+			},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			p := provider.NewDefaultDepProvider()
+			resourceFactory := resmap.NewFactory(p.GetResourceFactory())
+			resMap, err := resourceFactory.NewResMapFromBytes([]byte(testCase.pluginInputResources))
+			if err != nil {
+				t.Fatalf("Err: %v", err)
+			}
+
+			collector := diagnostics.NewCollector()
+			pluginHelpers := resmap.NewPluginHelpers(loader.NewFileLoaderAtRoot(filesys.MakeFsInMemory()), valtest_test.MakeFakeValidator(), resourceFactory, types.DisabledPluginConfig()).
+				WithDiagnostics(diagnostics.NewReporter(collector, "Yaegi", "notImportantHere", ""))
+			plugin := NewYaegiTransformerPlugin()
+			err = plugin.Config(pluginHelpers, []byte(testCase.pluginConfig))
+			if err != nil {
+				t.Fatalf("Err: %v", err)
+			}
+
+			err = plugin.Transform(resMap)
+			if err != nil {
+				t.Fatalf("Err: %v", err)
+			}
+
+			assert.Equal(t, testCase.expectedYaml, nodesAsYaml(t, resMap))
+			var messages []string
+			for _, d := range collector.Diagnostics() {
+				messages = append(messages, d.String())
+			}
+			assert.Equal(t, testCase.expectedDiagnostics, messages)
+		})
+	}
+}
+
+func TestYaegiGenerator(t *testing.T) {
+	pluginConfig := `
+apiVersion: qlik.com/v1
+kind: Yaegi
+metadata:
+  name: notImportantHere
+buildArgs:
+- one
+- two
+buildScript: |
+  package kust
+
+  import "yaegi"
+
+  func Generate(args []string) (*[][]byte, error) {
+  	rs := yaegi.Resources()
+  	for _, name := range args {
+  		added, err := rs.Add("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: " + name + "\n")
+  		if err != nil {
+  			return nil, err
+  		}
+  		if err := added[0].Set("data", map[string]string{"name": name}); err != nil {
+  			return nil, err
+  		}
+  	}
+  	return nil, nil
+  }
+`
+	p := provider.NewDefaultDepProvider()
+	resourceFactory := resmap.NewFactory(p.GetResourceFactory())
+
+	plugin := NewYaegiGeneratorPlugin()
+	err := plugin.Config(resmap.NewPluginHelpers(loader.NewFileLoaderAtRoot(filesys.MakeFsInMemory()), valtest_test.MakeFakeValidator(), resourceFactory, types.DisabledPluginConfig()), []byte(pluginConfig))
+	if err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+
+	resMap, err := plugin.Generate()
+	if err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+
+	assert.Equal(t, `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: one
+data:
+  name: one
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: two
+data:
+  name: two
+`, nodesAsYaml(t, resMap))
+}
+
+// nodesAsYaml returns the resources with field order and comments, unlike resMap.AsYaml.
+func nodesAsYaml(t *testing.T, resMap resmap.ResMap) string {
+	var docs []string
+	for _, r := range resMap.Resources() {
+		doc, err := r.Node().String()
+		assert.NoError(t, err)
+		docs = append(docs, doc)
+	}
+	return strings.Join(docs, "---\n")
+}
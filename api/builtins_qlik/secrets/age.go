@@ -0,0 +1,96 @@
+package secrets
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+	"sigs.k8s.io/kustomize/api/ifc"
+)
+
+const (
+	// AgeKeyFileEnvVar and AgeKeyEnvVar provide age identities,
+	// using the same variables as sops does.
+	AgeKeyFileEnvVar = "SOPS_AGE_KEY_FILE"
+	AgeKeyEnvVar     = "SOPS_AGE_KEY"
+)
+
+// AgeBackend decrypts files encrypted with age to X25519 recipients,
+// holding YAML or JSON documents or, with as and without key, any content.
+type AgeBackend struct{}
+
+// Fetch implements Backend.
+func (AgeBackend) Fetch(ldr ifc.Loader, ref Ref) (*Document, error) {
+	content, err := ldr.Load(ref.Path)
+	if err != nil {
+		return nil, err
+	}
+	identities, err := loadAgeIdentities(KeyFilePath(ldr, ref.IdentityFile))
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := ageDecrypt(content, identities)
+	if err != nil {
+		return nil, err
+	}
+	doc := &Document{Raw: plaintext}
+	if ref.As == "" || ref.Key != "" {
+		if doc.Data, err = parseDocument(plaintext); err != nil {
+			return nil, err
+		}
+	}
+	return doc, nil
+}
+
+// loadAgeIdentities reads the age identities of identityFile, or of the
+// SOPS_AGE_KEY and SOPS_AGE_KEY_FILE env variables and the default sops key file.
+func loadAgeIdentities(identityFile string) ([]age.Identity, error) {
+	var keys string
+	if identityFile != "" {
+		b, err := ioutil.ReadFile(identityFile)
+		if err != nil {
+			return nil, err
+		}
+		keys = string(b)
+	} else if value := os.Getenv(AgeKeyEnvVar); value != "" {
+		keys = value
+	} else {
+		keyFile := os.Getenv(AgeKeyFileEnvVar)
+		if keyFile == "" {
+			configDir, err := os.UserConfigDir()
+			if err != nil {
+				return nil, err
+			}
+			keyFile = filepath.Join(configDir, "sops", "age", "keys.txt")
+		}
+		b, err := ioutil.ReadFile(keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("no age identity configured: %w", err)
+		}
+		keys = string(b)
+	}
+	identities, err := age.ParseIdentities(strings.NewReader(keys))
+	if err != nil {
+		return nil, fmt.Errorf("malformed age identities: %w", err)
+	}
+	return identities, nil
+}
+
+// ageDecrypt decrypts an age file, armored or binary, with the first of identities that matches.
+func ageDecrypt(content []byte, identities []age.Identity) ([]byte, error) {
+	var r io.Reader = bytes.NewReader(content)
+	if trimmed := bytes.TrimSpace(content); bytes.HasPrefix(trimmed, []byte(armor.Header)) {
+		r = armor.NewReader(bytes.NewReader(trimmed))
+	}
+	plaintext, err := age.Decrypt(r, identities...)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.ReadAll(plaintext)
+}
@@ -0,0 +1,55 @@
+package secrets
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/Shopify/ejson"
+	"sigs.k8s.io/kustomize/api/ifc"
+)
+
+const (
+	// EjsonKeyEnvVar provides the ejson private key, as for the ValuesFile plugin.
+	EjsonKeyEnvVar = "EJSON_KEY"
+	// EjsonKeyDirEnvVar overrides the ejson key directory.
+	EjsonKeyDirEnvVar = "EJSON_KEYDIR"
+
+	defaultEjsonKeyDir = "/opt/ejson/keys"
+	ejsonPublicKeyKey  = "_public_key"
+)
+
+// EjsonBackend decrypts ejson files. The private key comes from privateKeyFile,
+// the EJSON_KEY env variable or the ejson key directory.
+type EjsonBackend struct{}
+
+// Fetch implements Backend.
+func (EjsonBackend) Fetch(ldr ifc.Loader, ref Ref) (*Document, error) {
+	content, err := ldr.Load(ref.Path)
+	if err != nil {
+		return nil, err
+	}
+	privateKey := os.Getenv(EjsonKeyEnvVar)
+	if ref.PrivateKeyFile != "" {
+		b, err := ioutil.ReadFile(KeyFilePath(ldr, ref.PrivateKeyFile))
+		if err != nil {
+			return nil, err
+		}
+		privateKey = string(b)
+	}
+	keyDir := os.Getenv(EjsonKeyDirEnvVar)
+	if keyDir == "" {
+		keyDir = defaultEjsonKeyDir
+	}
+	var decrypted bytes.Buffer
+	if err := ejson.Decrypt(bytes.NewReader(content), &decrypted, keyDir, strings.TrimSpace(privateKey)); err != nil {
+		return nil, err
+	}
+	data, err := parseDocument(decrypted.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	delete(data, ejsonPublicKeyKey)
+	return &Document{Data: data, Raw: decrypted.Bytes()}, nil
+}
@@ -0,0 +1,204 @@
+// Package secrets resolves the secretRefs of the SuperSecret plugin
+// through pluggable secret backends.
+package secrets
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+	"sigs.k8s.io/kustomize/api/ifc"
+)
+
+// Ref references secret values held by a backend.
+type Ref struct {
+	// Backend is the name of the backend, e.g. sops, age, ejson, vault or file.
+	Backend string `json:"backend" yaml:"backend"`
+	// Path is the file holding the secrets, relative to the kustomization,
+	// or for vault the API path of the secret, e.g. secret/data/app.
+	Path string `json:"path" yaml:"path"`
+	// Key selects a single period delimited key of the secret document.
+	// Without Key all top level keys of the document are used.
+	Key string `json:"key,omitempty" yaml:"key,omitempty"`
+	// As names the resulting Secret key. With Key it defaults to the last element of Key,
+	// without Key it stores the whole decrypted file under this name.
+	As string `json:"as,omitempty" yaml:"as,omitempty"`
+
+	// The key files below are read from disk rather than through the loader, so they may be
+	// kept outside of the kustomization. Relative paths are relative to the kustomization.
+
+	// IdentityFile is the age identity file for the age and sops backends,
+	// the sops backend only decrypts files with age recipients.
+	IdentityFile string `json:"identityFile,omitempty" yaml:"identityFile,omitempty"`
+	// PrivateKeyFile is the ejson private key file for the ejson backend.
+	PrivateKeyFile string `json:"privateKeyFile,omitempty" yaml:"privateKeyFile,omitempty"`
+	// Address and TokenFile locate and authenticate to the vault server for the vault backend.
+	Address   string `json:"address,omitempty" yaml:"address,omitempty"`
+	TokenFile string `json:"tokenFile,omitempty" yaml:"tokenFile,omitempty"`
+}
+
+// Document is the content of a secret reference as returned by a backend.
+type Document struct {
+	// Data holds the structured content of the secret.
+	Data map[string]interface{}
+	// Raw holds the decrypted bytes of file based secrets, nil otherwise.
+	Raw []byte
+}
+
+// Backend fetches and decrypts the secret referenced by a Ref.
+type Backend interface {
+	Fetch(ldr ifc.Loader, ref Ref) (*Document, error)
+}
+
+var (
+	backendsMutex sync.RWMutex
+	backends      = map[string]Backend{
+		"file":  FileBackend{},
+		"age":   AgeBackend{},
+		"sops":  SopsBackend{},
+		"ejson": EjsonBackend{},
+		"vault": &VaultBackend{},
+	}
+)
+
+// Register makes backend available under name, replacing any backend registered under the same name.
+func Register(name string, backend Backend) {
+	backendsMutex.Lock()
+	defer backendsMutex.Unlock()
+	backends[name] = backend
+}
+
+func getBackend(name string) (Backend, error) {
+	backendsMutex.RLock()
+	defer backendsMutex.RUnlock()
+	if backend, ok := backends[name]; ok {
+		return backend, nil
+	}
+	var names []string
+	for name := range backends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return nil, fmt.Errorf("unknown secret backend: %q, must be one of: %v", name, strings.Join(names, ", "))
+}
+
+// Resolve fetches the secrets of refs and returns them by Secret key.
+// A key resolved by more than one ref is an error.
+func Resolve(ldr ifc.Loader, refs []Ref) (map[string]string, error) {
+	values := make(map[string]string)
+	for _, ref := range refs {
+		backend, err := getBackend(ref.Backend)
+		if err != nil {
+			return nil, err
+		}
+		doc, err := backend.Fetch(ldr, ref)
+		if err != nil {
+			return nil, fmt.Errorf("error fetching secret %v from backend %v: %w", ref.Path, ref.Backend, err)
+		}
+		refValues, err := selectValues(doc, ref)
+		if err != nil {
+			return nil, fmt.Errorf("error selecting secret %v from backend %v: %w", ref.Path, ref.Backend, err)
+		}
+		for k, v := range refValues {
+			if _, exists := values[k]; exists {
+				return nil, fmt.Errorf("secret key %v is resolved by more than one secretRef", k)
+			}
+			values[k] = v
+		}
+	}
+	return values, nil
+}
+
+// KeyFilePath returns the location on disk of the key file name,
+// resolving a relative name against the root of ldr.
+func KeyFilePath(ldr ifc.Loader, name string) string {
+	if name == "" || filepath.IsAbs(name) {
+		return name
+	}
+	return filepath.Join(ldr.Root(), name)
+}
+
+func selectValues(doc *Document, ref Ref) (map[string]string, error) {
+	if ref.Key != "" {
+		fields := strings.Split(ref.Key, ".")
+		var value interface{} = doc.Data
+		for _, field := range fields {
+			m, ok := value.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("key %v not found", ref.Key)
+			}
+			if value, ok = m[field]; !ok {
+				return nil, fmt.Errorf("key %v not found", ref.Key)
+			}
+		}
+		as := ref.As
+		if as == "" {
+			as = fields[len(fields)-1]
+		}
+		s, err := stringify(value)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]string{as: s}, nil
+	}
+	if ref.As != "" {
+		if doc.Raw == nil {
+			return nil, fmt.Errorf("as without key requires a file based backend")
+		}
+		return map[string]string{ref.As: string(doc.Raw)}, nil
+	}
+	values := make(map[string]string, len(doc.Data))
+	for k, v := range doc.Data {
+		s, err := stringify(v)
+		if err != nil {
+			return nil, err
+		}
+		values[k] = s
+	}
+	return values, nil
+}
+
+// stringify returns scalars as they are written in YAML and maps and lists as YAML documents.
+func stringify(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case nil:
+		return "", nil
+	case map[string]interface{}, []interface{}:
+		b, err := yaml.Marshal(v)
+		return string(b), err
+	default:
+		return fmt.Sprintf("%v", v), nil
+	}
+}
+
+// parseDocument parses YAML or JSON content into a map.
+func parseDocument(content []byte) (map[string]interface{}, error) {
+	data := make(map[string]interface{})
+	if err := yaml.Unmarshal(content, &data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// FileBackend reads plaintext YAML or JSON files, e.g. for local development.
+type FileBackend struct{}
+
+// Fetch implements Backend.
+func (FileBackend) Fetch(ldr ifc.Loader, ref Ref) (*Document, error) {
+	content, err := ldr.Load(ref.Path)
+	if err != nil {
+		return nil, err
+	}
+	doc := &Document{Raw: content}
+	if ref.As == "" || ref.Key != "" {
+		if doc.Data, err = parseDocument(content); err != nil {
+			return nil, err
+		}
+	}
+	return doc, nil
+}
@@ -0,0 +1,416 @@
+package secrets
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+	"github.com/Shopify/ejson"
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/kustomize/api/filesys"
+	"sigs.k8s.io/kustomize/api/loader"
+)
+
+func TestResolve(t *testing.T) {
+	identity, err := age.GenerateX25519Identity()
+	assert.NoError(t, err)
+
+	dataKey := make([]byte, 32)
+	_, err = rand.Read(dataKey)
+	assert.NoError(t, err)
+
+	ejsonPublicKey, ejsonPrivateKey, err := ejson.GenerateKeypair()
+	assert.NoError(t, err)
+	var ejsonFile bytes.Buffer
+	_, err = ejson.Encrypt(strings.NewReader(fmt.Sprintf(`{"_public_key": %q, "password": "ejson-secret"}`, ejsonPublicKey)), &ejsonFile)
+	assert.NoError(t, err)
+
+	vault := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "s.token" {
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprint(w, `{"errors": ["permission denied"]}`)
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/secret/data/app":
+			fmt.Fprint(w, `{"data": {"data": {"password": "vault-secret", "user": "admin"}, "metadata": {"version": 3}}}`)
+		case "/v1/kv/app":
+			fmt.Fprint(w, `{"data": {"password": "vault-v1-secret"}}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"errors": []}`)
+		}
+	}))
+	defer vault.Close()
+
+	dir, err := ioutil.TempDir("", "secrets-test-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	identityFile := filepath.Join(dir, "keys.txt")
+	assert.NoError(t, ioutil.WriteFile(identityFile, []byte("# test key\n"+identity.String()+"\n"), 0600))
+	ejsonKeyFile := filepath.Join(dir, "ejson.key")
+	assert.NoError(t, ioutil.WriteFile(ejsonKeyFile, []byte(ejsonPrivateKey), 0600))
+	tokenFile := filepath.Join(dir, "token")
+	assert.NoError(t, ioutil.WriteFile(tokenFile, []byte("s.token\n"), 0600))
+	wrongTokenFile := filepath.Join(dir, "wrong-token")
+	assert.NoError(t, ioutil.WriteFile(wrongTokenFile, []byte("s.wrong"), 0600))
+
+	fSys := filesys.MakeFsInMemory()
+	assert.NoError(t, fSys.WriteFile("/app/plain.yaml", []byte("user: admin\nnested:\n  password: plain-secret\n")))
+	assert.NoError(t, fSys.WriteFile("/app/secret.age", ageEncrypt(t, identity, []byte("password: age-secret\n"), true)))
+	assert.NoError(t, fSys.WriteFile("/app/cert.age", ageEncrypt(t, identity, bytes.Repeat([]byte("x"), 64*1024+10), false)))
+	assert.NoError(t, fSys.WriteFile("/app/secret.sops.yaml", []byte(sopsFile(t, identity, dataKey))))
+	assert.NoError(t, fSys.WriteFile("/app/secret.ejson", ejsonFile.Bytes()))
+	ldr := loader.NewFileLoaderAtRoot(fSys)
+
+	Register("vault", &VaultBackend{Client: vault.Client()})
+	defer Register("vault", &VaultBackend{})
+
+	testCases := []struct {
+		name           string
+		refs           []Ref
+		expectedValues map[string]string
+		expectedError  string
+	}{
+		{
+			name: "file",
+			refs: []Ref{
+				{Backend: "file", Path: "/app/plain.yaml"},
+			},
+			expectedValues: map[string]string{"user": "admin", "nested": "password: plain-secret\n"},
+		},
+		{
+			name: "file key",
+			refs: []Ref{
+				{Backend: "file", Path: "/app/plain.yaml", Key: "nested.password", As: "PASSWORD"},
+			},
+			expectedValues: map[string]string{"PASSWORD": "plain-secret"},
+		},
+		{
+			name: "age",
+			refs: []Ref{
+				{Backend: "age", Path: "/app/secret.age", IdentityFile: identityFile},
+				{Backend: "age", Path: "/app/cert.age", IdentityFile: identityFile, As: "cert"},
+			},
+			expectedValues: map[string]string{"password": "age-secret", "cert": strings.Repeat("x", 64*1024+10)},
+		},
+		{
+			name: "sops",
+			refs: []Ref{
+				{Backend: "sops", Path: "/app/secret.sops.yaml", IdentityFile: identityFile, Key: "db.password"},
+				{Backend: "sops", Path: "/app/secret.sops.yaml", IdentityFile: identityFile, Key: "port", As: "PORT"},
+				{Backend: "sops", Path: "/app/secret.sops.yaml", IdentityFile: identityFile, Key: "enabled"},
+				{Backend: "sops", Path: "/app/secret.sops.yaml", IdentityFile: identityFile, Key: "ratio"},
+				{Backend: "sops", Path: "/app/secret.sops.yaml", IdentityFile: identityFile, Key: "db.user_unencrypted", As: "user"},
+			},
+			expectedValues: map[string]string{"password": "sops-secret", "PORT": "5432", "enabled": "true", "ratio": "0.5", "user": "admin"},
+		},
+		{
+			name: "sops nested",
+			refs: []Ref{
+				{Backend: "sops", Path: "/app/secret.sops.yaml", IdentityFile: identityFile, Key: "db.hosts", As: "hosts"},
+			},
+			expectedValues: map[string]string{"hosts": "- db.local\n"},
+		},
+		{
+			name: "ejson",
+			refs: []Ref{
+				{Backend: "ejson", Path: "/app/secret.ejson", PrivateKeyFile: ejsonKeyFile},
+			},
+			expectedValues: map[string]string{"password": "ejson-secret"},
+		},
+		{
+			name: "vault",
+			refs: []Ref{
+				{Backend: "vault", Path: "secret/data/app", Address: vault.URL, TokenFile: tokenFile},
+				{Backend: "vault", Path: "kv/app", Address: vault.URL, TokenFile: tokenFile, Key: "password", As: "v1"},
+			},
+			expectedValues: map[string]string{"password": "vault-secret", "user": "admin", "v1": "vault-v1-secret"},
+		},
+		{
+			name: "vault denied",
+			refs: []Ref{
+				{Backend: "vault", Path: "secret/data/app", Address: vault.URL, TokenFile: wrongTokenFile},
+			},
+			expectedError: "permission denied",
+		},
+		{
+			name: "duplicate key",
+			refs: []Ref{
+				{Backend: "age", Path: "/app/secret.age", IdentityFile: identityFile},
+				{Backend: "ejson", Path: "/app/secret.ejson", PrivateKeyFile: ejsonKeyFile},
+			},
+			expectedError: "secret key password is resolved by more than one secretRef",
+		},
+		{
+			name: "missing key",
+			refs: []Ref{
+				{Backend: "file", Path: "/app/plain.yaml", Key: "nested.user"},
+			},
+			expectedError: "key nested.user not found",
+		},
+		{
+			name: "unknown backend",
+			refs: []Ref{
+				{Backend: "kms", Path: "/app/plain.yaml"},
+			},
+			expectedError: `unknown secret backend: "kms"`,
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			values, err := Resolve(ldr, testCase.refs)
+			if testCase.expectedError != "" {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), testCase.expectedError)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, testCase.expectedValues, values)
+		})
+	}
+}
+
+func TestKeyFileOutsideRoot(t *testing.T) {
+	identity, err := age.GenerateX25519Identity()
+	assert.NoError(t, err)
+	dir, err := ioutil.TempDir("", "secrets-test-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "keys"), 0700))
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "app"), 0700))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "keys", "keys.txt"), []byte(identity.String()), 0600))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "app", "secret.age"), ageEncrypt(t, identity, []byte("password: age-secret\n"), true), 0600))
+
+	ldr, err := loader.NewLoader(loader.RestrictionRootOnly, filepath.Join(dir, "app"), filesys.MakeFsOnDisk())
+	assert.NoError(t, err)
+	values, err := Resolve(ldr, []Ref{{Backend: "age", Path: "secret.age", IdentityFile: "../keys/keys.txt"}})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"password": "age-secret"}, values)
+}
+
+func TestSopsTampered(t *testing.T) {
+	identity, err := age.GenerateX25519Identity()
+	assert.NoError(t, err)
+	dataKey := make([]byte, 32)
+	_, err = rand.Read(dataKey)
+	assert.NoError(t, err)
+	dir, err := ioutil.TempDir("", "secrets-test-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	identityFile := filepath.Join(dir, "keys.txt")
+	assert.NoError(t, ioutil.WriteFile(identityFile, []byte(identity.String()), 0600))
+	content := sopsFile(t, identity, dataKey)
+	port := regexp.MustCompile(`port: (ENC\[.*\])`).FindStringSubmatch(content)[1]
+	ratio := regexp.MustCompile(`ratio: (ENC\[.*\])`).FindStringSubmatch(content)[1]
+
+	testCases := []struct {
+		name          string
+		tamper        func(string) string
+		expectedError string
+	}{
+		{
+			name: "unencrypted value changed",
+			tamper: func(s string) string {
+				return strings.Replace(s, "user_unencrypted: admin", "user_unencrypted: root", 1)
+			},
+			expectedError: "sops MAC mismatch",
+		},
+		{
+			name: "value removed",
+			tamper: func(s string) string {
+				return regexp.MustCompile(`(?m)^enabled: .*\n`).ReplaceAllString(s, "")
+			},
+			expectedError: "sops MAC mismatch",
+		},
+		{
+			name: "comment removed",
+			tamper: func(s string) string {
+				return regexp.MustCompile(`(?m)^#ENC.*\n`).ReplaceAllString(s, "")
+			},
+			expectedError: "sops MAC mismatch",
+		},
+		{
+			name: "values swapped",
+			tamper: func(s string) string {
+				s = strings.Replace(s, port, "PORT", 1)
+				s = strings.Replace(s, ratio, port, 1)
+				return strings.Replace(s, "PORT", ratio, 1)
+			},
+			expectedError: "error decrypting port: authentication failed",
+		},
+		{
+			name: "mac removed",
+			tamper: func(s string) string {
+				return regexp.MustCompile(`(?m)^  mac: .*\n`).ReplaceAllString(s, "")
+			},
+			expectedError: "sops file has no MAC",
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			fSys := filesys.MakeFsInMemory()
+			assert.NoError(t, fSys.WriteFile("/app/secret.sops.yaml", []byte(testCase.tamper(content))))
+			_, err := SopsBackend{}.Fetch(loader.NewFileLoaderAtRoot(fSys), Ref{Path: "/app/secret.sops.yaml", IdentityFile: identityFile})
+			assert.Error(t, err)
+			assert.Contains(t, err.Error(), testCase.expectedError)
+		})
+	}
+}
+
+func TestSopsAgeOnly(t *testing.T) {
+	testCases := []struct {
+		name          string
+		metadata      string
+		expectedError string
+	}{
+		{
+			name: "pgp and kms",
+			metadata: `  kms:
+  - arn: arn:aws:kms:us-east-1:123456789012:key/example
+    enc: AQICAHg=
+  age: []
+  pgp:
+  - fp: 85D77543B3D624B63CEA9E6DBC17301B491B3F21
+    enc: |
+      -----BEGIN PGP MESSAGE-----
+      -----END PGP MESSAGE-----
+`,
+			expectedError: "sops file has no age recipients, only kms, pgp, and the sops backend is age-only",
+		},
+		{
+			name: "key groups",
+			metadata: `  key_groups:
+  - age:
+    - recipient: age1ql3z7hjy54pw3hyww5ayyfg7zqgvc7w3j2elw8zmrj2kg5sfn9aqmcac8p
+      enc: x
+  shamir_threshold: 1
+`,
+			expectedError: "sops files with key groups are not supported",
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			fSys := filesys.MakeFsInMemory()
+			assert.NoError(t, fSys.WriteFile("/app/secret.sops.yaml", []byte("password: ENC[AES256_GCM,data:AA==,iv:AA==,tag:AA==,type:str]\nsops:\n"+
+				testCase.metadata+"  mac: ENC[AES256_GCM,data:AA==,iv:AA==,tag:AA==,type:str]\n  version: 3.7.1\n")))
+			_, err := SopsBackend{}.Fetch(loader.NewFileLoaderAtRoot(fSys), Ref{Path: "/app/secret.sops.yaml"})
+			assert.Error(t, err)
+			assert.Contains(t, err.Error(), testCase.expectedError)
+		})
+	}
+}
+
+func TestAgeDecryptWrongIdentity(t *testing.T) {
+	identity, err := age.GenerateX25519Identity()
+	assert.NoError(t, err)
+	other, err := age.GenerateX25519Identity()
+	assert.NoError(t, err)
+
+	_, err = ageDecrypt(ageEncrypt(t, identity, []byte("secret"), false), []age.Identity{other})
+	var noMatch *age.NoIdentityMatchError
+	assert.True(t, errors.As(err, &noMatch))
+}
+
+func indent(s, prefix string) string {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	for i := range lines {
+		lines[i] = prefix + lines[i]
+	}
+	return strings.Join(lines, "\n")
+}
+
+func ageEncrypt(t *testing.T, identity *age.X25519Identity, plaintext []byte, armored bool) []byte {
+	var out bytes.Buffer
+	var dst io.Writer = &out
+	var armorWriter io.WriteCloser
+	if armored {
+		armorWriter = armor.NewWriter(&out)
+		dst = armorWriter
+	}
+	w, err := age.Encrypt(dst, identity.Recipient())
+	assert.NoError(t, err)
+	_, err = w.Write(plaintext)
+	assert.NoError(t, err)
+	assert.NoError(t, w.Close())
+	if armorWriter != nil {
+		assert.NoError(t, armorWriter.Close())
+	}
+	return out.Bytes()
+}
+
+// sopsFile returns a file encrypted like sops does with the age recipient of identity,
+// holding values of every type, an unencrypted value and an encrypted comment.
+func sopsFile(t *testing.T, identity *age.X25519Identity, dataKey []byte) string {
+	const lastModified = "2021-06-01T10:00:00Z"
+	// the plaintexts, as sops hashes them, in document order
+	mac := sha512.New()
+	for _, plaintext := range []string{"sops-secret", "db.local", "admin", " database port", "5432", "True", "0.5"} {
+		mac.Write([]byte(plaintext))
+	}
+	return fmt.Sprintf(`db:
+  password: %v
+  hosts:
+  - %v
+  user_unencrypted: admin
+
+#%v
+port: %v
+enabled: %v
+ratio: %v
+sops:
+  kms: []
+  gcp_kms: []
+  azure_kv: []
+  hc_vault: []
+  age:
+  - recipient: %v
+    enc: |
+%v
+  lastmodified: "%v"
+  mac: %v
+  pgp: []
+  unencrypted_suffix: _unencrypted
+  version: 3.7.1
+`,
+		sopsEncrypt(t, dataKey, "sops-secret", "str", "db:password:"),
+		sopsEncrypt(t, dataKey, "db.local", "str", "db:hosts:"),
+		sopsEncrypt(t, dataKey, " database port", "comment", ":"),
+		sopsEncrypt(t, dataKey, "5432", "int", "port:"),
+		sopsEncrypt(t, dataKey, "True", "bool", "enabled:"),
+		sopsEncrypt(t, dataKey, "0.5", "float", "ratio:"),
+		identity.Recipient(),
+		indent(string(ageEncrypt(t, identity, dataKey, true)), "      "),
+		lastModified,
+		sopsEncrypt(t, dataKey, fmt.Sprintf("%X", mac.Sum(nil)), "str", lastModified))
+}
+
+func sopsEncrypt(t *testing.T, dataKey []byte, value, valueType, additionalData string) string {
+	block, err := aes.NewCipher(dataKey)
+	assert.NoError(t, err)
+	gcm, err := cipher.NewGCMWithNonceSize(block, 32)
+	assert.NoError(t, err)
+	iv := make([]byte, 32)
+	_, err = rand.Read(iv)
+	assert.NoError(t, err)
+	sealed := gcm.Seal(nil, iv, []byte(value), []byte(additionalData))
+	data, tag := sealed[:len(sealed)-gcm.Overhead()], sealed[len(sealed)-gcm.Overhead():]
+	return fmt.Sprintf("ENC[AES256_GCM,data:%v,iv:%v,tag:%v,type:%v]", base64.StdEncoding.EncodeToString(data),
+		base64.StdEncoding.EncodeToString(iv), base64.StdEncoding.EncodeToString(tag), valueType)
+}
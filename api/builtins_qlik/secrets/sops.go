@@ -0,0 +1,436 @@
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"filippo.io/age"
+	"gopkg.in/yaml.v3"
+	"sigs.k8s.io/kustomize/api/ifc"
+)
+
+const (
+	sopsMetadataKey              = "sops"
+	sopsDefaultUnencryptedSuffix = "_unencrypted"
+)
+
+var sopsValueRegexp = regexp.MustCompile(`^ENC\[AES256_GCM,data:(.*),iv:(.*),tag:(.*),type:(.*)\]$`)
+
+// SopsBackend decrypts YAML or JSON files encrypted by sops and verifies their MAC.
+// It is age-only: the data key is recovered from the age recipients of the file with
+// the age identities, files encrypted solely for pgp or a key management service
+// (kms, gcp_kms, azure_kv, hc_vault) and files split across several key groups are
+// rejected. Decrypt those with the sops binary and use the file backend instead.
+type SopsBackend struct{}
+
+// Fetch implements Backend.
+func (SopsBackend) Fetch(ldr ifc.Loader, ref Ref) (*Document, error) {
+	content, err := ldr.Load(ref.Path)
+	if err != nil {
+		return nil, err
+	}
+	// the MAC covers the values in document order, so the
+	// document is walked as nodes rather than as a map.
+	var doc yaml.Node
+	if err := yaml.Unmarshal(content, &doc); err != nil {
+		return nil, err
+	}
+	metadataNode := sopsMetadataNode(&doc)
+	if metadataNode == nil {
+		return nil, errors.New("not a sops encrypted file: sops metadata not found")
+	}
+	var metadata sopsMetadata
+	if err := metadataNode.Decode(&metadata); err != nil {
+		return nil, fmt.Errorf("malformed sops metadata: %w", err)
+	}
+	if err := metadata.checkAgeOnly(); err != nil {
+		return nil, err
+	}
+	identities, err := loadAgeIdentities(KeyFilePath(ldr, ref.IdentityFile))
+	if err != nil {
+		return nil, err
+	}
+	dataKey, err := metadata.dataKey(identities)
+	if err != nil {
+		return nil, err
+	}
+	t := &sopsTree{metadata: metadata, dataKey: dataKey, hash: sha512.New()}
+	data, err := t.document(&doc)
+	if err != nil {
+		return nil, err
+	}
+	if err := t.verifyMAC(); err != nil {
+		return nil, err
+	}
+	return &Document{Data: data}, nil
+}
+
+// sopsMetadataNode returns the value of the top level sops key of doc, or nil.
+func sopsMetadataNode(doc *yaml.Node) *yaml.Node {
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil
+	}
+	m := doc.Content[0]
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == sopsMetadataKey {
+			return m.Content[i+1]
+		}
+	}
+	return nil
+}
+
+type sopsMetadata struct {
+	Age []struct {
+		Enc string `yaml:"enc"`
+	} `yaml:"age"`
+	// the other key services, only to name them when the file has no age recipients
+	KMS               []interface{} `yaml:"kms"`
+	GCPKMS            []interface{} `yaml:"gcp_kms"`
+	AzureKV           []interface{} `yaml:"azure_kv"`
+	HCVault           []interface{} `yaml:"hc_vault"`
+	PGP               []interface{} `yaml:"pgp"`
+	KeyGroups         []interface{} `yaml:"key_groups"`
+	LastModified      string        `yaml:"lastmodified"`
+	MAC               string        `yaml:"mac"`
+	UnencryptedSuffix string        `yaml:"unencrypted_suffix"`
+	EncryptedSuffix   string        `yaml:"encrypted_suffix"`
+	UnencryptedRegex  string        `yaml:"unencrypted_regex"`
+	EncryptedRegex    string        `yaml:"encrypted_regex"`
+	MACOnlyEncrypted  bool          `yaml:"mac_only_encrypted"`
+}
+
+// checkAgeOnly returns an error naming the key services of files the age-only backend cannot decrypt.
+func (m *sopsMetadata) checkAgeOnly() error {
+	if len(m.KeyGroups) > 0 {
+		return errors.New("sops files with key groups are not supported, the sops backend is age-only")
+	}
+	if len(m.Age) == 0 {
+		var services []string
+		for _, service := range []struct {
+			name string
+			keys []interface{}
+		}{{"kms", m.KMS}, {"gcp_kms", m.GCPKMS}, {"azure_kv", m.AzureKV}, {"hc_vault", m.HCVault}, {"pgp", m.PGP}} {
+			if len(service.keys) > 0 {
+				services = append(services, service.name)
+			}
+		}
+		if len(services) == 0 {
+			return errors.New("sops file has no age recipients")
+		}
+		return fmt.Errorf("sops file has no age recipients, only %v, and the sops backend is age-only",
+			strings.Join(services, ", "))
+	}
+	return nil
+}
+
+func (m *sopsMetadata) dataKey(identities []age.Identity) ([]byte, error) {
+	for _, recipient := range m.Age {
+		if dataKey, err := ageDecrypt([]byte(recipient.Enc), identities); err == nil {
+			return dataKey, nil
+		}
+	}
+	return nil, errors.New("no age identity matched any of the sops age recipients")
+}
+
+// encrypted tells if sops encrypted the values at path, after the suffix and regex rules of the file.
+func (m *sopsMetadata) encrypted(path []string) bool {
+	unencryptedSuffix := m.UnencryptedSuffix
+	if unencryptedSuffix == "" && m.EncryptedSuffix == "" && m.UnencryptedRegex == "" && m.EncryptedRegex == "" {
+		unencryptedSuffix = sopsDefaultUnencryptedSuffix
+	}
+	encrypted := true
+	if unencryptedSuffix != "" {
+		for _, key := range path {
+			if strings.HasSuffix(key, unencryptedSuffix) {
+				encrypted = false
+				break
+			}
+		}
+	}
+	if m.EncryptedSuffix != "" {
+		encrypted = false
+		for _, key := range path {
+			if strings.HasSuffix(key, m.EncryptedSuffix) {
+				encrypted = true
+				break
+			}
+		}
+	}
+	if m.UnencryptedRegex != "" {
+		for _, key := range path {
+			if matched, _ := regexp.MatchString(m.UnencryptedRegex, key); matched {
+				encrypted = false
+				break
+			}
+		}
+	}
+	if m.EncryptedRegex != "" {
+		encrypted = false
+		for _, key := range path {
+			if matched, _ := regexp.MatchString(m.EncryptedRegex, key); matched {
+				encrypted = true
+				break
+			}
+		}
+	}
+	return encrypted
+}
+
+// sopsComment is a comment of a sops file. Like sops, comments are
+// decrypted and authenticated as values, but they are left out of the result.
+type sopsComment string
+
+// sopsTree decrypts the values of a sops file and hashes them for the MAC.
+// It follows the sops YAML store: values and comments are visited in document
+// order and each value is authenticated with the keys leading to it, list indices excluded.
+type sopsTree struct {
+	metadata sopsMetadata
+	dataKey  []byte
+	hash     hash.Hash
+}
+
+func (t *sopsTree) document(doc *yaml.Node) (map[string]interface{}, error) {
+	if err := t.comments(nil, doc.HeadComment, doc.LineComment); err != nil {
+		return nil, err
+	}
+	data, err := t.mapping(doc.Content[0], nil, false, true)
+	if err != nil {
+		return nil, err
+	}
+	return data, t.comments(nil, doc.FootComment)
+}
+
+func (t *sopsTree) mapping(node *yaml.Node, path []string, commentsHandled, root bool) (map[string]interface{}, error) {
+	if !commentsHandled {
+		if err := t.comments(path, node.HeadComment, node.LineComment); err != nil {
+			return nil, err
+		}
+	}
+	result := make(map[string]interface{}, len(node.Content)/2)
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		if err := t.comments(path, key.HeadComment, key.LineComment); err != nil {
+			return nil, err
+		}
+		if root && key.Value == sopsMetadataKey {
+			continue
+		}
+		if key.Kind != yaml.ScalarNode || key.ShortTag() != "!!str" {
+			return nil, fmt.Errorf("sops files only support string keys, found %v", key.Value)
+		}
+		scalar := value.Kind == yaml.ScalarNode || value.Kind == yaml.AliasNode
+		if scalar {
+			if err := t.comments(path, value.HeadComment, value.LineComment); err != nil {
+				return nil, err
+			}
+		}
+		v, err := t.value(value, append(append([]string{}, path...), key.Value), scalar)
+		if err != nil {
+			return nil, err
+		}
+		result[key.Value] = v
+		if scalar {
+			if err := t.comments(path, value.FootComment); err != nil {
+				return nil, err
+			}
+		}
+		if err := t.comments(path, key.FootComment); err != nil {
+			return nil, err
+		}
+	}
+	if !commentsHandled {
+		if err := t.comments(path, node.FootComment); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+func (t *sopsTree) value(node *yaml.Node, path []string, commentsHandled bool) (interface{}, error) {
+	switch node.Kind {
+	case yaml.MappingNode:
+		return t.mapping(node, path, commentsHandled, false)
+	case yaml.SequenceNode:
+		result := make([]interface{}, 0, len(node.Content))
+		for _, item := range node.Content {
+			if err := t.comments(path, item.HeadComment, item.LineComment); err != nil {
+				return nil, err
+			}
+			v, err := t.value(item, path, true)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, v)
+			if err := t.comments(path, item.FootComment); err != nil {
+				return nil, err
+			}
+		}
+		return result, nil
+	case yaml.AliasNode:
+		return t.value(node.Alias, path, false)
+	case yaml.ScalarNode:
+		var v interface{}
+		if err := node.Decode(&v); err != nil {
+			return nil, err
+		}
+		if v == nil {
+			return nil, nil
+		}
+		v, err := t.leaf(v, path)
+		if b, ok := v.([]byte); ok {
+			return string(b), err
+		}
+		return v, err
+	default:
+		return nil, fmt.Errorf("unsupported YAML node at %v", strings.Join(path, "."))
+	}
+}
+
+// comments decrypts and hashes the lines of the comments, as sops does.
+func (t *sopsTree) comments(path []string, comments ...string) error {
+	for _, comment := range comments {
+		for _, line := range strings.Split(comment, "\n") {
+			if line == "" {
+				continue
+			}
+			if _, err := t.leaf(sopsComment(line[1:]), path); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (t *sopsTree) leaf(in interface{}, path []string) (interface{}, error) {
+	encrypted := t.metadata.encrypted(path)
+	v := in
+	if encrypted {
+		additionalData := strings.Join(path, ":") + ":"
+		switch in := in.(type) {
+		case sopsComment:
+			// comments written by older versions of sops are not encrypted
+			if decrypted, err := sopsDecryptValue(string(in), t.dataKey, additionalData); err == nil {
+				v = decrypted
+			}
+		case string:
+			decrypted, err := sopsDecryptValue(in, t.dataKey, additionalData)
+			if err != nil {
+				return nil, fmt.Errorf("error decrypting %v: %w", strings.Join(path, "."), err)
+			}
+			v = decrypted
+		default:
+			return nil, fmt.Errorf("error decrypting %v: not an encrypted value", strings.Join(path, "."))
+		}
+	}
+	if !t.metadata.MACOnlyEncrypted || encrypted {
+		b, err := sopsBytes(v)
+		if err != nil {
+			return nil, fmt.Errorf("error hashing %v: %w", strings.Join(path, "."), err)
+		}
+		t.hash.Write(b)
+	}
+	return v, nil
+}
+
+// verifyMAC compares the hash of the values to the MAC of the file,
+// encrypted with its last modification time as additional data.
+func (t *sopsTree) verifyMAC() error {
+	if t.metadata.MAC == "" {
+		return errors.New("sops file has no MAC")
+	}
+	lastModified, err := time.Parse(time.RFC3339, t.metadata.LastModified)
+	if err != nil {
+		return fmt.Errorf("malformed sops lastmodified: %w", err)
+	}
+	mac, err := sopsDecryptValue(t.metadata.MAC, t.dataKey, lastModified.Format(time.RFC3339))
+	if err != nil {
+		return fmt.Errorf("error decrypting sops MAC: %w", err)
+	}
+	if computed := fmt.Sprintf("%X", t.hash.Sum(nil)); mac != computed {
+		return fmt.Errorf("sops MAC mismatch: file has %v, computed %v", mac, computed)
+	}
+	return nil
+}
+
+// sopsBytes returns the bytes sops hashes for a value.
+func sopsBytes(v interface{}) ([]byte, error) {
+	switch v := v.(type) {
+	case string:
+		return []byte(v), nil
+	case []byte:
+		return v, nil
+	case int:
+		return []byte(strconv.Itoa(v)), nil
+	case float64:
+		return []byte(strconv.FormatFloat(v, 'f', -1, 64)), nil
+	case bool:
+		if v {
+			return []byte("True"), nil
+		}
+		return []byte("False"), nil
+	case sopsComment:
+		return []byte(v), nil
+	default:
+		return nil, fmt.Errorf("unsupported value type %T", v)
+	}
+}
+
+// sopsDecryptValue decrypts a sops value and returns it with its original type.
+func sopsDecryptValue(value string, dataKey []byte, additionalData string) (interface{}, error) {
+	if value == "" {
+		return "", nil
+	}
+	matches := sopsValueRegexp.FindStringSubmatch(value)
+	if matches == nil {
+		return nil, errors.New("value is not in the sops format")
+	}
+	data, err := base64.StdEncoding.DecodeString(matches[1])
+	if err != nil {
+		return nil, err
+	}
+	iv, err := base64.StdEncoding.DecodeString(matches[2])
+	if err != nil {
+		return nil, err
+	}
+	tag, err := base64.StdEncoding.DecodeString(matches[3])
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCMWithNonceSize(block, len(iv))
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := gcm.Open(nil, iv, append(data, tag...), []byte(additionalData))
+	if err != nil {
+		return nil, errors.New("authentication failed")
+	}
+	switch matches[4] {
+	case "str":
+		return string(plaintext), nil
+	case "int":
+		return strconv.Atoi(string(plaintext))
+	case "float":
+		return strconv.ParseFloat(string(plaintext), 64)
+	case "bool":
+		return strconv.ParseBool(string(plaintext))
+	case "bytes":
+		return plaintext, nil
+	case "comment":
+		return sopsComment(plaintext), nil
+	default:
+		return nil, fmt.Errorf("unknown sops value type %q", matches[4])
+	}
+}
@@ -0,0 +1,100 @@
+package secrets
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"sigs.k8s.io/kustomize/api/ifc"
+)
+
+const (
+	// VaultAddrEnvVar, VaultTokenEnvVar and VaultNamespaceEnvVar configure the vault backend
+	// like they configure the vault CLI.
+	VaultAddrEnvVar      = "VAULT_ADDR"
+	VaultTokenEnvVar     = "VAULT_TOKEN"
+	VaultNamespaceEnvVar = "VAULT_NAMESPACE"
+)
+
+// VaultBackend reads secrets from the KV secrets engine of a vault server over its HTTP API.
+// Path is the API path below /v1, e.g. secret/data/app for KV version 2 or secret/app for version 1.
+type VaultBackend struct {
+	// Client is the HTTP client used, with a 30 seconds timeout if nil.
+	Client *http.Client
+}
+
+type vaultResponse struct {
+	Data   map[string]interface{} `json:"data"`
+	Errors []string               `json:"errors"`
+}
+
+// Fetch implements Backend.
+func (b *VaultBackend) Fetch(ldr ifc.Loader, ref Ref) (*Document, error) {
+	address := ref.Address
+	if address == "" {
+		address = os.Getenv(VaultAddrEnvVar)
+	}
+	if address == "" {
+		return nil, fmt.Errorf("no vault address configured, set address or %v", VaultAddrEnvVar)
+	}
+	token, err := vaultToken(KeyFilePath(ldr, ref.TokenFile))
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(http.MethodGet,
+		fmt.Sprintf("%v/v1/%v", strings.TrimSuffix(address, "/"), strings.TrimPrefix(ref.Path, "/")), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Vault-Token", token)
+	if namespace := os.Getenv(VaultNamespaceEnvVar); namespace != "" {
+		req.Header.Set("X-Vault-Namespace", namespace)
+	}
+	client := b.Client
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var vr vaultResponse
+	if err := json.NewDecoder(resp.Body).Decode(&vr); err != nil && resp.StatusCode == http.StatusOK {
+		return nil, fmt.Errorf("invalid vault response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("vault returned %v: %v", resp.Status, strings.Join(vr.Errors, ", "))
+	}
+	data := vr.Data
+	// KV version 2 nests the secret with its metadata
+	if nested, ok := data["data"].(map[string]interface{}); ok {
+		if _, hasMetadata := data["metadata"]; hasMetadata {
+			data = nested
+		}
+	}
+	return &Document{Data: data}, nil
+}
+
+func vaultToken(tokenFile string) (string, error) {
+	if tokenFile == "" {
+		if token := os.Getenv(VaultTokenEnvVar); token != "" {
+			return token, nil
+		}
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		tokenFile = filepath.Join(home, ".vault-token")
+	}
+	b, err := ioutil.ReadFile(tokenFile)
+	if err != nil {
+		return "", fmt.Errorf("no vault token configured: %w", err)
+	}
+	return strings.TrimSpace(string(b)), nil
+}
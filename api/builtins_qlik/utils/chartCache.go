@@ -0,0 +1,439 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	ChartCacheDirEnvVar       = "KUST_HELM_CACHE_DIR"
+	ChartCacheMaxSizeMBEnvVar = "KUST_HELM_CACHE_MAX_SIZE_MB"
+	ChartCacheMaxAgeEnvVar    = "KUST_HELM_CACHE_MAX_AGE"
+
+	ChartCacheKindChart    = "chart"
+	ChartCacheKindRendered = "rendered"
+
+	chartCacheEntryFile    = "entry.json"
+	chartCacheManifestFile = "manifest.yaml"
+	chartCacheChartDir     = "chart"
+	chartCacheRefsDir      = "refs"
+	chartCacheTmpDir       = "tmp"
+)
+
+// ChartCacheEntry describes one item stored in the chart cache.
+type ChartCacheEntry struct {
+	Kind     string    `json:"kind"`
+	Key      string    `json:"key"`
+	Repo     string    `json:"repo,omitempty"`
+	Name     string    `json:"name,omitempty"`
+	Version  string    `json:"version,omitempty"`
+	Digest   string    `json:"digest,omitempty"`
+	Size     int64     `json:"size"`
+	Created  time.Time `json:"created"`
+	LastUsed time.Time `json:"-"`
+	Path     string    `json:"-"`
+}
+
+// ChartCache is a content-addressed cache for fetched helm charts and their rendered output,
+// shared by every HelmChart generator that points at the same root.
+//
+// Layout under Root:
+//
+//	chart/<digest>/chart/<name>/...   untarred chart, stored once per content digest
+//	chart/<digest>/entry.json
+//	refs/<sha256(repo, name, version)> the digest of the chart fetched for that reference
+//	rendered/<key>/manifest.yaml       output of helm template, keyed by RenderKey()
+//	rendered/<key>/entry.json
+//
+// Every write goes to a private temp directory first and is renamed into place,
+// so concurrent builds never observe a partially written entry and need no locks.
+type ChartCache struct {
+	Root         string
+	MaxSizeBytes int64
+	MaxAge       time.Duration
+	logger       *zap.SugaredLogger
+}
+
+// DefaultChartCacheRoot returns the cache root used when KUST_HELM_CACHE_DIR is not set.
+func DefaultChartCacheRoot() string {
+	if dir, err := os.UserCacheDir(); err == nil {
+		return filepath.Join(dir, "kustomize", "helm")
+	}
+	return filepath.Join(os.TempDir(), "kustomize", "helm")
+}
+
+// NewChartCacheFromEnv creates a ChartCache configured from the KUST_HELM_CACHE_* env variables.
+func NewChartCacheFromEnv(logger *zap.SugaredLogger) (*ChartCache, error) {
+	root := os.Getenv(ChartCacheDirEnvVar)
+	if root == "" {
+		root = DefaultChartCacheRoot()
+	}
+	cache := NewChartCache(root, logger)
+	if value := os.Getenv(ChartCacheMaxSizeMBEnvVar); value != "" {
+		maxSizeMB, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value: %v for %v, error: %w", value, ChartCacheMaxSizeMBEnvVar, err)
+		}
+		cache.MaxSizeBytes = maxSizeMB * 1024 * 1024
+	}
+	if value := os.Getenv(ChartCacheMaxAgeEnvVar); value != "" {
+		maxAge, err := time.ParseDuration(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value: %v for %v, error: %w", value, ChartCacheMaxAgeEnvVar, err)
+		}
+		cache.MaxAge = maxAge
+	}
+	return cache, nil
+}
+
+// NewChartCache creates a ChartCache rooted at root with no eviction limits.
+func NewChartCache(root string, logger *zap.SugaredLogger) *ChartCache {
+	if logger == nil {
+		logger = GetNopLogger()
+	}
+	return &ChartCache{Root: root, logger: logger}
+}
+
+// ChartRefKey returns the key under which the digest of a fetched chart reference is recorded.
+func ChartRefKey(repo, name, version string) string {
+	return hashStrings(repo, name, version)
+}
+
+// RenderKey returns the key for rendered output of a chart with the given digest and render inputs.
+func RenderKey(chartDigest string, values map[string]interface{}, inputs ...string) (string, error) {
+	valuesBytes, err := json.Marshal(values)
+	if err != nil {
+		return "", err
+	}
+	return hashStrings(append([]string{chartDigest, string(valuesBytes)}, inputs...)...), nil
+}
+
+// LookupChart returns the directory of the cached, untarred chart for repo/name/version, if present.
+func (c *ChartCache) LookupChart(repo, name, version string) (chartDir string, digest string, found bool) {
+	refBytes, err := ioutil.ReadFile(filepath.Join(c.Root, chartCacheRefsDir, ChartRefKey(repo, name, version)))
+	if err != nil {
+		return "", "", false
+	}
+	digest = strings.TrimSpace(string(refBytes))
+	entryDir := filepath.Join(c.Root, ChartCacheKindChart, digest)
+	chartDir = filepath.Join(entryDir, chartCacheChartDir, name)
+	if _, err := os.Stat(chartDir); err != nil {
+		return "", "", false
+	}
+	c.touch(entryDir)
+	c.logger.Debugf("chart cache hit for repo: %v, chart: %v, version: %v, digest: %v\n", repo, name, version, digest)
+	return chartDir, digest, true
+}
+
+// StoreChart copies the untarred chart at chartDir into the cache and records it for repo/name/version.
+func (c *ChartCache) StoreChart(repo, name, version, chartDir string) (digest string, err error) {
+	if digest, err = ChartDirDigest(chartDir); err != nil {
+		return "", err
+	}
+	entryDir := filepath.Join(c.Root, ChartCacheKindChart, digest)
+	if _, err := os.Stat(entryDir); os.IsNotExist(err) {
+		if err := c.storeEntry(entryDir, &ChartCacheEntry{
+			Kind: ChartCacheKindChart, Key: digest, Repo: repo, Name: name, Version: version, Digest: digest,
+		}, func(tmpDir string) error {
+			return CopyDir(chartDir, filepath.Join(tmpDir, chartCacheChartDir, name), c.logger)
+		}); err != nil {
+			return "", err
+		}
+	}
+	if err := c.writeFileAtomic(filepath.Join(c.Root, chartCacheRefsDir, ChartRefKey(repo, name, version)), []byte(digest)); err != nil {
+		return "", err
+	}
+	return digest, nil
+}
+
+// LookupRendered returns previously rendered output for key, if present.
+func (c *ChartCache) LookupRendered(key string) ([]byte, bool) {
+	entryDir := filepath.Join(c.Root, ChartCacheKindRendered, key)
+	manifest, err := ioutil.ReadFile(filepath.Join(entryDir, chartCacheManifestFile))
+	if err != nil {
+		return nil, false
+	}
+	c.touch(entryDir)
+	c.logger.Debugf("rendered chart cache hit for key: %v\n", key)
+	return manifest, true
+}
+
+// StoreRendered records rendered output for key.
+func (c *ChartCache) StoreRendered(key string, entry ChartCacheEntry, manifest []byte) error {
+	entryDir := filepath.Join(c.Root, ChartCacheKindRendered, key)
+	if _, err := os.Stat(entryDir); err == nil {
+		return nil
+	}
+	entry.Kind = ChartCacheKindRendered
+	entry.Key = key
+	return c.storeEntry(entryDir, &entry, func(tmpDir string) error {
+		return ioutil.WriteFile(filepath.Join(tmpDir, chartCacheManifestFile), manifest, 0644)
+	})
+}
+
+// List returns all cache entries, least recently used first.
+func (c *ChartCache) List() ([]*ChartCacheEntry, error) {
+	var entries []*ChartCacheEntry
+	for _, kind := range []string{ChartCacheKindChart, ChartCacheKindRendered} {
+		kindDir := filepath.Join(c.Root, kind)
+		infos, err := ioutil.ReadDir(kindDir)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		for _, info := range infos {
+			if !info.IsDir() {
+				continue
+			}
+			entryDir := filepath.Join(kindDir, info.Name())
+			entry, err := c.readEntry(entryDir)
+			if err != nil {
+				c.logger.Warnf("skipping unreadable chart cache entry: %v, error: %v\n", entryDir, err)
+				continue
+			}
+			entries = append(entries, entry)
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].LastUsed.Before(entries[j].LastUsed)
+	})
+	return entries, nil
+}
+
+// Prune evicts entries not used for longer than maxAge, then the least recently used entries
+// until the cache is no larger than maxSizeBytes. Zero values disable the respective limit.
+func (c *ChartCache) Prune(maxSizeBytes int64, maxAge time.Duration) (removed []*ChartCacheEntry, err error) {
+	entries, err := c.List()
+	if err != nil {
+		return nil, err
+	}
+	var totalSize int64
+	for _, entry := range entries {
+		totalSize += entry.Size
+	}
+	now := time.Now()
+	for _, entry := range entries {
+		tooOld := maxAge > 0 && now.Sub(entry.LastUsed) > maxAge
+		tooBig := maxSizeBytes > 0 && totalSize > maxSizeBytes
+		if !tooOld && !tooBig {
+			continue
+		}
+		if err := os.RemoveAll(entry.Path); err != nil {
+			return removed, err
+		}
+		totalSize -= entry.Size
+		removed = append(removed, entry)
+	}
+	if len(removed) > 0 {
+		c.removeDanglingRefs()
+	}
+	return removed, nil
+}
+
+// Clear removes every entry of the cache, and only those: files under Root
+// the cache did not write, e.g. when Root is not a cache, are left alone.
+func (c *ChartCache) Clear() (removed []*ChartCacheEntry, err error) {
+	entries, err := c.List()
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if err := os.RemoveAll(entry.Path); err != nil {
+			return removed, err
+		}
+		removed = append(removed, entry)
+	}
+	c.removeDanglingRefs()
+	return removed, nil
+}
+
+// Evict applies the cache's own MaxSizeBytes/MaxAge policy.
+func (c *ChartCache) Evict() error {
+	if c.MaxSizeBytes == 0 && c.MaxAge == 0 {
+		return nil
+	}
+	removed, err := c.Prune(c.MaxSizeBytes, c.MaxAge)
+	for _, entry := range removed {
+		c.logger.Debugf("evicted %v cache entry: %v\n", entry.Kind, entry.Key)
+	}
+	return err
+}
+
+func (c *ChartCache) removeDanglingRefs() {
+	refsDir := filepath.Join(c.Root, chartCacheRefsDir)
+	infos, err := ioutil.ReadDir(refsDir)
+	if err != nil {
+		return
+	}
+	for _, info := range infos {
+		refPath := filepath.Join(refsDir, info.Name())
+		if digest, err := ioutil.ReadFile(refPath); err != nil {
+			continue
+		} else if _, err := os.Stat(filepath.Join(c.Root, ChartCacheKindChart, strings.TrimSpace(string(digest)))); os.IsNotExist(err) {
+			_ = os.Remove(refPath)
+		}
+	}
+}
+
+func (c *ChartCache) storeEntry(entryDir string, entry *ChartCacheEntry, fill func(tmpDir string) error) error {
+	tmpRoot := filepath.Join(c.Root, chartCacheTmpDir)
+	if err := os.MkdirAll(tmpRoot, os.ModePerm); err != nil {
+		return err
+	}
+	tmpDir, err := ioutil.TempDir(tmpRoot, "")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	if err := fill(tmpDir); err != nil {
+		return err
+	}
+	entry.Created = time.Now()
+	if entry.Size, err = dirSize(tmpDir); err != nil {
+		return err
+	}
+	entryBytes, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(filepath.Join(tmpDir, chartCacheEntryFile), entryBytes, 0644); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(entryDir), os.ModePerm); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpDir, entryDir); err != nil {
+		if _, statErr := os.Stat(entryDir); statErr == nil {
+			// another build stored the same content first
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+func (c *ChartCache) readEntry(entryDir string) (*ChartCacheEntry, error) {
+	entryFile := filepath.Join(entryDir, chartCacheEntryFile)
+	info, err := os.Stat(entryFile)
+	if err != nil {
+		return nil, err
+	}
+	entryBytes, err := ioutil.ReadFile(entryFile)
+	if err != nil {
+		return nil, err
+	}
+	var entry ChartCacheEntry
+	if err := json.Unmarshal(entryBytes, &entry); err != nil {
+		return nil, err
+	}
+	entry.LastUsed = info.ModTime()
+	entry.Path = entryDir
+	return &entry, nil
+}
+
+func (c *ChartCache) touch(entryDir string) {
+	now := time.Now()
+	_ = os.Chtimes(filepath.Join(entryDir, chartCacheEntryFile), now, now)
+}
+
+func (c *ChartCache) writeFileAtomic(path string, content []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return err
+	}
+	tmpFile, err := ioutil.TempFile(filepath.Dir(path), ".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpFile.Name())
+	if _, err := tmpFile.Write(content); err != nil {
+		tmpFile.Close()
+		return err
+	}
+	if err := tmpFile.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmpFile.Name(), path)
+}
+
+// ChartDirDigest returns a sha256 digest over the relative paths and contents of all files in chartDir.
+// Dependency archives under charts/ and helm's tmpcharts directory are ignored,
+// since they are derived from Chart.yaml/Chart.lock which are part of the digest.
+func ChartDirDigest(chartDir string) (string, error) {
+	var paths []string
+	if err := filepath.Walk(chartDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(chartDir, path)
+		if err != nil {
+			return err
+		}
+		relPath = filepath.ToSlash(relPath)
+		if info.IsDir() {
+			if relPath == "tmpcharts" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if strings.HasPrefix(relPath, "charts/") && strings.HasSuffix(relPath, ".tgz") {
+			return nil
+		}
+		paths = append(paths, relPath)
+		return nil
+	}); err != nil {
+		return "", err
+	}
+	sort.Strings(paths)
+
+	digest := sha256.New()
+	for _, relPath := range paths {
+		f, err := os.Open(filepath.Join(chartDir, filepath.FromSlash(relPath)))
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(digest, "%s\x00", relPath)
+		_, err = io.Copy(digest, f)
+		f.Close()
+		if err != nil {
+			return "", err
+		}
+		digest.Write([]byte{0})
+	}
+	return "sha256-" + hex.EncodeToString(digest.Sum(nil)), nil
+}
+
+func dirSize(dir string) (size int64, err error) {
+	err = filepath.Walk(dir, func(_ string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size, err
+}
+
+func hashStrings(values ...string) string {
+	h := sha256.New()
+	for _, value := range values {
+		h.Write([]byte(value))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,83 @@
+package utils
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeTestChart(t *testing.T, dir string, version string) {
+	t.Helper()
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "templates"), os.ModePerm))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "Chart.yaml"), []byte("name: foo\nversion: "+version+"\n"), 0644))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "templates", "cm.yaml"), []byte("kind: ConfigMap\n"), 0644))
+}
+
+func TestChartCache(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "")
+	assert.NoError(t, err)
+	defer os.RemoveAll(tmpDir)
+
+	chartDir := filepath.Join(tmpDir, "fetched", "foo")
+	writeTestChart(t, chartDir, "1.0.0")
+
+	cache := NewChartCache(filepath.Join(tmpDir, "cache"), nil)
+
+	_, _, found := cache.LookupChart("https://charts.example.com", "foo", "1.0.0")
+	assert.False(t, found)
+
+	digest, err := cache.StoreChart("https://charts.example.com", "foo", "1.0.0", chartDir)
+	assert.NoError(t, err)
+
+	cachedChartDir, cachedDigest, found := cache.LookupChart("https://charts.example.com", "foo", "1.0.0")
+	assert.True(t, found)
+	assert.Equal(t, digest, cachedDigest)
+	cachedDigest, err = ChartDirDigest(cachedChartDir)
+	assert.NoError(t, err)
+	assert.Equal(t, digest, cachedDigest)
+
+	// dependency archives do not change the digest:
+	assert.NoError(t, os.MkdirAll(filepath.Join(chartDir, "charts"), os.ModePerm))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(chartDir, "charts", "dep-1.0.0.tgz"), []byte("tgz"), 0644))
+	depsDigest, err := ChartDirDigest(chartDir)
+	assert.NoError(t, err)
+	assert.Equal(t, digest, depsDigest)
+
+	key1, err := RenderKey(digest, map[string]interface{}{"a": "b"}, "release-name", "default", "true")
+	assert.NoError(t, err)
+	key2, err := RenderKey(digest, map[string]interface{}{"a": "c"}, "release-name", "default", "true")
+	assert.NoError(t, err)
+	assert.NotEqual(t, key1, key2)
+
+	_, found = cache.LookupRendered(key1)
+	assert.False(t, found)
+	assert.NoError(t, cache.StoreRendered(key1, ChartCacheEntry{Name: "foo", Version: "1.0.0", Digest: digest}, []byte("kind: ConfigMap\n")))
+	manifest, found := cache.LookupRendered(key1)
+	assert.True(t, found)
+	assert.Equal(t, "kind: ConfigMap\n", string(manifest))
+
+	entries, err := cache.List()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(entries))
+
+	// age the chart entry, the rendered entry stays fresh:
+	old := time.Now().Add(-48 * time.Hour)
+	assert.NoError(t, os.Chtimes(filepath.Join(cache.Root, ChartCacheKindChart, digest, chartCacheEntryFile), old, old))
+	removed, err := cache.Prune(0, 24*time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(removed))
+	assert.Equal(t, ChartCacheKindChart, removed[0].Kind)
+	_, _, found = cache.LookupChart("https://charts.example.com", "foo", "1.0.0")
+	assert.False(t, found)
+
+	removed, err = cache.Prune(1, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(removed))
+	entries, err = cache.List()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(entries))
+}
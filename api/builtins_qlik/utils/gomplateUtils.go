@@ -3,6 +3,7 @@ package utils
 import (
 	"fmt"
 	"io/ioutil"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strings"
@@ -10,14 +11,105 @@ import (
 
 	"github.com/hairyhenderson/gomplate/v3"
 	"go.uber.org/zap"
+	"sigs.k8s.io/kustomize/api/ifc"
+	"sigs.k8s.io/kustomize/api/loader"
+	"sigs.k8s.io/kustomize/api/provenance"
 )
 
 var gomplateMutex sync.Mutex
 
-func RunGomplate(dataSource string, pwd string, env []string, template string, logger *zap.SugaredLogger) ([]byte, error) {
+// GomplateDataSources resolves datasources, alias=URL with an optional alias, so
+// that gomplate reads them from copies loaded through ldr into dir, the load
+// restrictions of ldr applying to them. The local datasources, plain paths or file
+// URLs, relative to the root of ldr, are given in local, the remote ones in remote.
+// The http(s) remote datasources are loaded through ldr as well, the others, e.g.
+// vault secrets, are left to gomplate. The content, or the URL of the remote
+// datasources gomplate reads, of every datasource is recorded into inputs.
+func GomplateDataSources(ldr ifc.Loader, dir string, local []string, remote []string, inputs *provenance.Recorder) ([]string, error) {
+	loaded, err := loadDataSources(ldr, local, remote, inputs)
+	if err != nil {
+		return nil, err
+	}
+	var result []string
+	for i, dataSource := range loaded {
+		if dataSource.content == nil {
+			result = append(result, dataSource.dataSource)
+			continue
+		}
+		// the extension is kept for gomplate to infer the type of the content
+		copyPath := filepath.Join(dir, fmt.Sprintf("%d-%s%s", i, dataSource.alias, filepath.Ext(dataSource.url.Path)))
+		if err := ioutil.WriteFile(copyPath, dataSource.content, 0600); err != nil {
+			return nil, err
+		}
+		copyURL := url.URL{Scheme: "file", Path: filepath.ToSlash(copyPath), RawQuery: dataSource.url.RawQuery}
+		result = append(result, fmt.Sprintf("%s=%s", dataSource.alias, copyURL.String()))
+	}
+	return result, nil
+}
+
+// loadedDataSource is a datasource with its content loaded through a loader,
+// or a remote one with a nil content.
+type loadedDataSource struct {
+	dataSource string
+	alias      string
+	url        *url.URL
+	content    []byte
+}
+
+// loadDataSources loads the datasources as GomplateDataSources does.
+func loadDataSources(ldr ifc.Loader, local []string, remote []string, inputs *provenance.Recorder) ([]loadedDataSource, error) {
+	var result []loadedDataSource
+	for i, dataSource := range append(append([]string{}, local...), remote...) {
+		isLocal := i < len(local)
+		alias, source := splitGomplateDataSource(dataSource)
+		u, err := url.Parse(source)
+		if err != nil {
+			u = &url.URL{Path: source}
+		}
+		if u.Scheme == "file" {
+			u = &url.URL{Path: u.Host + u.Path, RawQuery: u.RawQuery}
+		}
+		if isLocal && u.Scheme != "" {
+			return nil, fmt.Errorf("datasource %v is remote, it must be declared as a remote datasource", dataSource)
+		}
+		if !isLocal && u.Scheme == "" {
+			return nil, fmt.Errorf("remote datasource %v is local, it must be declared as a datasource", dataSource)
+		}
+		if alias == "" {
+			alias = strings.TrimSuffix(filepath.Base(u.Path), filepath.Ext(u.Path))
+		}
+		loaded := loadedDataSource{dataSource: dataSource, alias: alias, url: u}
+		if u.Scheme != "" && u.Scheme != "http" && u.Scheme != "https" {
+			inputs.Record(provenance.InputGomplateDataSource, source, nil)
+			result = append(result, loaded)
+			continue
+		}
+		location, uri := u.Path, loader.InputURI(ldr, u.Path)
+		if u.Scheme != "" {
+			location = (&url.URL{Scheme: u.Scheme, User: u.User, Host: u.Host, Path: u.Path}).String()
+			uri = location
+		}
+		if loaded.content, err = ldr.Load(location); err != nil {
+			return nil, fmt.Errorf("error loading datasource %v: %v", dataSource, err)
+		}
+		inputs.Record(provenance.InputGomplateDataSource, uri, loaded.content)
+		result = append(result, loaded)
+	}
+	return result, nil
+}
+
+// splitGomplateDataSource splits a datasource into its alias, empty if it has none, and its URL.
+func splitGomplateDataSource(dataSource string) (string, string) {
+	if parts := strings.SplitN(dataSource, "=", 2); len(parts) == 2 && !strings.ContainsAny(parts[0], "/:?.") {
+		return parts[0], parts[1]
+	}
+	return "", dataSource
+}
+
+func RunGomplate(dataSources []string, env []string, template string, logger *zap.SugaredLogger) ([]byte, error) {
 
 	var opts gomplate.Config
-	opts.DataSources = []string{fmt.Sprintf("data=%s", filepath.Join(pwd, dataSource))}
+	opts.DataSources = dataSources
 	opts.Input = template
 	opts.LDelim = "(("
 	opts.RDelim = "))"
@@ -48,7 +140,7 @@ func RunGomplate(dataSource string, pwd string, env []string, template string, l
 	return ioutil.ReadFile(tmpFile.Name())
 }
 
-func RunGomplateFromConfig(dataSources []string, pwd string, env map[string]string, template string, logger *zap.SugaredLogger, ldelim string, rdelim string) ([]byte, error) {
+func RunGomplateFromConfig(dataSources []string, env map[string]string, template string, logger *zap.SugaredLogger, ldelim string, rdelim string) ([]byte, error) {
 
 	var opts gomplate.Config
 	opts.DataSources = dataSources
@@ -0,0 +1,58 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/kustomize/api/filesys"
+	"sigs.k8s.io/kustomize/api/loader"
+	"sigs.k8s.io/kustomize/api/provenance"
+)
+
+func TestGomplateDataSources(t *testing.T) {
+	fSys := filesys.MakeFsInMemory()
+	assert.NoError(t, fSys.WriteFile("/app/values.yaml", []byte("foo: bar\n")))
+	assert.NoError(t, fSys.WriteFile("/app/data/config.json", []byte(`{"baz": 1}`)))
+	assert.NoError(t, fSys.WriteFile("/secret.yaml", []byte("password: nope\n")))
+	ldr, err := loader.NewLoader(loader.RestrictionRootOnly, "/app", fSys)
+	assert.NoError(t, err)
+	dir, err := ioutil.TempDir("", "")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	inputs := provenance.NewRecorder()
+	dataSources, err := GomplateDataSources(ldr, dir,
+		[]string{"values.yaml", "config=file://data/config.json?type=application/json"},
+		[]string{"secrets=vault:///secret/app"}, inputs)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"values=file://" + filepath.ToSlash(filepath.Join(dir, "0-values.yaml")),
+		"config=file://" + filepath.ToSlash(filepath.Join(dir, "1-config.json")) + "?type=application/json",
+		"secrets=vault:///secret/app",
+	}, dataSources)
+	content, err := ioutil.ReadFile(filepath.Join(dir, "1-config.json"))
+	assert.NoError(t, err)
+	assert.Equal(t, `{"baz": 1}`, string(content))
+
+	sha := func(content string) map[string]string {
+		sum := sha256.Sum256([]byte(content))
+		return map[string]string{"sha256": hex.EncodeToString(sum[:])}
+	}
+	assert.Equal(t, []provenance.Input{
+		{Type: provenance.InputGomplateDataSource, URI: "/app/data/config.json", Digest: sha(`{"baz": 1}`)},
+		{Type: provenance.InputGomplateDataSource, URI: "/app/values.yaml", Digest: sha("foo: bar\n")},
+		{Type: provenance.InputGomplateDataSource, URI: "vault:///secret/app"},
+	}, inputs.Inputs())
+
+	_, err = GomplateDataSources(ldr, dir, []string{"secret=../secret.yaml"}, nil, nil)
+	assert.Error(t, err)
+	_, err = GomplateDataSources(ldr, dir, []string{"secrets=vault:///secret/app"}, nil, nil)
+	assert.EqualError(t, err, "datasource secrets=vault:///secret/app is remote, it must be declared as a remote datasource")
+	_, err = GomplateDataSources(ldr, dir, nil, []string{"values=values.yaml"}, nil)
+	assert.EqualError(t, err, "remote datasource values=values.yaml is local, it must be declared as a datasource")
+}
@@ -0,0 +1,68 @@
+package utils
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// FlattenValues returns the leaves of helm values keyed by their dot separated path,
+// e.g. image.tag. Lists and empty maps are leaves.
+func FlattenValues(values map[string]interface{}) map[string]interface{} {
+	flat := make(map[string]interface{})
+	flattenValues("", values, flat)
+	return flat
+}
+
+func flattenValues(prefix string, values map[string]interface{}, flat map[string]interface{}) {
+	for k, v := range values {
+		path := k
+		if prefix != "" {
+			path = prefix + "." + k
+		}
+		if m, ok := v.(map[string]interface{}); ok && len(m) > 0 {
+			flattenValues(path, m, flat)
+		} else {
+			flat[path] = v
+		}
+	}
+}
+
+// UpdateValuesOrigins records origin in origins for every leaf of values that
+// changed the merged values, or for every leaf when overwrite is set.
+func UpdateValuesOrigins(origins map[string]string, origin string, values, before, after map[string]interface{}, overwrite bool) {
+	flatBefore := FlattenValues(before)
+	flatAfter := FlattenValues(after)
+	for path := range FlattenValues(values) {
+		previous, existed := flatBefore[path]
+		if overwrite || !existed || !reflect.DeepEqual(previous, flatAfter[path]) {
+			origins[path] = origin
+		}
+	}
+}
+
+// ValuesOrigin returns where the value at path was set: the origin recorded for path,
+// else for its closest parent, else for its first child, e.g. when the schema error
+// is about a map whose keys were set by an overlay.
+func ValuesOrigin(origins map[string]string, path string) (string, bool) {
+	if origin, ok := origins[path]; ok {
+		return origin, true
+	}
+	for parent := path; strings.Contains(parent, "."); {
+		parent = parent[:strings.LastIndex(parent, ".")]
+		if origin, ok := origins[parent]; ok {
+			return origin, true
+		}
+	}
+	var children []string
+	for p := range origins {
+		if path == "" || strings.HasPrefix(p, path+".") {
+			children = append(children, p)
+		}
+	}
+	if len(children) == 0 {
+		return "", false
+	}
+	sort.Strings(children)
+	return origins[children[0]], true
+}
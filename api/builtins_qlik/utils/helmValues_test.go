@@ -0,0 +1,50 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUpdateValuesOrigins(t *testing.T) {
+	origins := map[string]string{"image.tag": "/base/values.yaml"}
+	values := map[string]interface{}{
+		"image": map[string]interface{}{"tag": "1.19", "pullPolicy": "Always"},
+	}
+	before := map[string]interface{}{
+		"image": map[string]interface{}{"tag": "1.19"},
+	}
+	after := map[string]interface{}{
+		"image": map[string]interface{}{"tag": "1.19", "pullPolicy": "Always"},
+	}
+
+	UpdateValuesOrigins(origins, "/prod/values.yaml", values, before, after, false)
+	assert.Equal(t, map[string]string{
+		"image.tag":        "/base/values.yaml",
+		"image.pullPolicy": "/prod/values.yaml",
+	}, origins)
+
+	UpdateValuesOrigins(origins, "/prod/values.yaml", values, before, after, true)
+	assert.Equal(t, "/prod/values.yaml", origins["image.tag"])
+}
+
+func TestValuesOrigin(t *testing.T) {
+	origins := map[string]string{
+		"image":               "/base/values.yaml",
+		"ingress.hosts":       "/prod/values.yaml",
+		"ingress.tls.enabled": "/prod/tls.yaml",
+	}
+	for path, expected := range map[string]string{
+		"image":         "/base/values.yaml",
+		"image.tag":     "/base/values.yaml",
+		"ingress":       "/prod/values.yaml",
+		"ingress.tls":   "/prod/tls.yaml",
+		"ingress.hosts": "/prod/values.yaml",
+	} {
+		origin, ok := ValuesOrigin(origins, path)
+		assert.True(t, ok, path)
+		assert.Equal(t, expected, origin, path)
+	}
+	_, ok := ValuesOrigin(origins, "replicas")
+	assert.False(t, ok)
+}
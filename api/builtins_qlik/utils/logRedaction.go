@@ -0,0 +1,173 @@
+package utils
+
+import (
+	"encoding/base64"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// RedactedValue replaces secret material in log lines and redacted errors.
+const RedactedValue = "***"
+
+// minRedactedLength is the shortest value registered for redaction,
+// masking shorter values would garble the logs without protecting anything.
+const minRedactedLength = 4
+
+var (
+	secretValuesMutex sync.RWMutex
+	secretValues      = make(map[string]bool)
+
+	jsonSecretKindRegexp = regexp.MustCompile(`"kind":\s*"Secret"`)
+	jsonSecretDataRegexp = regexp.MustCompile(`"(data|stringData)":\s*\{[^{}]*\}`)
+	jsonValueRegexp      = regexp.MustCompile(`("[^"\\]*(?:\\.[^"\\]*)*":\s*)"[^"\\]*(?:\\.[^"\\]*)*"`)
+	yamlSecretKindRegexp = regexp.MustCompile(`(?m)^\s*kind:\s*Secret\s*$`)
+	yamlSecretDataRegexp = regexp.MustCompile(`^(\s*)(data|stringData):\s*$`)
+	yamlValueRegexp      = regexp.MustCompile(`^(\s*[^:\s][^:]*:)\s*[^|>\s].*$`)
+)
+
+// RegisterSecretValues marks values, e.g. Secret data or values read from env variables and files,
+// as secret material to mask in every log line and redacted error, along with their base64 encoding.
+func RegisterSecretValues(values ...string) {
+	secretValuesMutex.Lock()
+	defer secretValuesMutex.Unlock()
+	for _, value := range values {
+		trimmed := strings.TrimSpace(value)
+		if len(trimmed) < minRedactedLength {
+			continue
+		}
+		secretValues[trimmed] = true
+		secretValues[base64.StdEncoding.EncodeToString([]byte(trimmed))] = true
+		secretValues[base64.StdEncoding.EncodeToString([]byte(value))] = true
+	}
+}
+
+// Redact masks the registered secret values and the data of Secret resources, in JSON or YAML form, of s.
+func Redact(s string) string {
+	s = redactSecretResources(s)
+
+	secretValuesMutex.RLock()
+	values := make([]string, 0, len(secretValues))
+	for v := range secretValues {
+		if strings.Contains(s, v) {
+			values = append(values, v)
+		}
+	}
+	secretValuesMutex.RUnlock()
+	// longest first, so a value containing another one is masked whole
+	sort.Slice(values, func(i, j int) bool {
+		return len(values[i]) > len(values[j])
+	})
+	for _, v := range values {
+		s = strings.ReplaceAll(s, v, RedactedValue)
+	}
+	return s
+}
+
+func redactSecretResources(s string) string {
+	if jsonSecretKindRegexp.MatchString(s) {
+		s = jsonSecretDataRegexp.ReplaceAllStringFunc(s, func(data string) string {
+			return jsonValueRegexp.ReplaceAllString(data, `${1}"`+RedactedValue+`"`)
+		})
+	}
+	if yamlSecretKindRegexp.MatchString(s) {
+		lines := strings.Split(s, "\n")
+		dataIndent, keyIndent := -1, -1
+		for i, line := range lines {
+			indent := len(line) - len(strings.TrimLeft(line, " "))
+			if dataIndent >= 0 && strings.TrimSpace(line) != "" {
+				if indent > dataIndent {
+					if keyIndent < 0 {
+						keyIndent = indent
+					}
+					if indent == keyIndent {
+						lines[i] = yamlValueRegexp.ReplaceAllString(line, "${1} "+RedactedValue)
+					} else {
+						// content of a block scalar
+						lines[i] = line[:indent] + RedactedValue
+					}
+					continue
+				}
+				dataIndent, keyIndent = -1, -1
+			}
+			if m := yamlSecretDataRegexp.FindStringSubmatch(line); m != nil {
+				dataIndent = len(m[1])
+			}
+		}
+		s = strings.Join(lines, "\n")
+	}
+	return s
+}
+
+type redactedError struct {
+	err error
+}
+
+func (e *redactedError) Error() string {
+	return Redact(e.err.Error())
+}
+
+func (e *redactedError) Unwrap() error {
+	return e.err
+}
+
+// RedactError wraps err so that its message is redacted, errors.Is and errors.As still see err.
+func RedactError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := err.(*redactedError); ok {
+		return err
+	}
+	return &redactedError{err: err}
+}
+
+// redactingCore redacts the message and string and error fields of every entry
+// before handing it to the wrapped core.
+type redactingCore struct {
+	zapcore.Core
+}
+
+func (c *redactingCore) With(fields []zapcore.Field) zapcore.Core {
+	return &redactingCore{c.Core.With(redactFields(fields))}
+}
+
+func (c *redactingCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(entry.Level) {
+		return checked.AddCore(entry, c)
+	}
+	return checked
+}
+
+func (c *redactingCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	entry.Message = Redact(entry.Message)
+	return c.Core.Write(entry, redactFields(fields))
+}
+
+func redactFields(fields []zapcore.Field) []zapcore.Field {
+	redacted := make([]zapcore.Field, len(fields))
+	for i, field := range fields {
+		switch field.Type {
+		case zapcore.StringType:
+			field.String = Redact(field.String)
+		case zapcore.ErrorType:
+			if err, ok := field.Interface.(error); ok {
+				field.Interface = RedactError(err)
+			}
+		case zapcore.StringerType, zapcore.ReflectType:
+			field = zapcore.Field{Key: field.Key, Type: zapcore.StringType, String: Redact(redactedFieldString(field))}
+		}
+		redacted[i] = field
+	}
+	return redacted
+}
+
+func redactedFieldString(field zapcore.Field) string {
+	enc := zapcore.NewMapObjectEncoder()
+	field.AddTo(enc)
+	return fmt.Sprint(enc.Fields[field.Key])
+}
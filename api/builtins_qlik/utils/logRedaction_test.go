@@ -0,0 +1,104 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestRedact(t *testing.T) {
+	RegisterSecretValues("s3cr3t-token\n", "abc")
+
+	testCases := []struct {
+		name     string
+		in       string
+		expected string
+	}{
+		{
+			name:     "registered value",
+			in:       "token: s3cr3t-token, file: s3cr3t-token\n",
+			expected: "token: ***, file: ***\n",
+		},
+		{
+			name:     "base64 encoded value",
+			in:       "PASSWORD: czNjcjN0LXRva2Vu",
+			expected: "PASSWORD: ***",
+		},
+		{
+			name:     "short value",
+			in:       "abc",
+			expected: "abc",
+		},
+		{
+			name:     "json secret",
+			in:       `resource: {"apiVersion":"v1","data":{"PASSWORD":"d2hhdGV2ZXI=","foo":"YmFy"},"kind":"Secret","metadata":{"name":"mySecret"}}`,
+			expected: `resource: {"apiVersion":"v1","data":{"PASSWORD":"***","foo":"***"},"kind":"Secret","metadata":{"name":"mySecret"}}`,
+		},
+		{
+			name:     "json config map",
+			in:       `resource: {"apiVersion":"v1","data":{"foo":"bar"},"kind":"ConfigMap"}`,
+			expected: `resource: {"apiVersion":"v1","data":{"foo":"bar"},"kind":"ConfigMap"}`,
+		},
+		{
+			name: "yaml secret",
+			in: `apiVersion: v1
+kind: Secret
+metadata:
+  name: mySecret
+stringData:
+  foo: bar
+  cert: |
+    -----BEGIN CERTIFICATE-----
+    MIIB
+type: Opaque
+`,
+			expected: `apiVersion: v1
+kind: Secret
+metadata:
+  name: mySecret
+stringData:
+  foo: ***
+  cert: |
+    ***
+    ***
+type: Opaque
+`,
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			assert.Equal(t, testCase.expected, Redact(testCase.in))
+		})
+	}
+}
+
+func TestRedactError(t *testing.T) {
+	RegisterSecretValues("hunter2-password")
+
+	err := RedactError(fmt.Errorf("error reading %v: %w", "hunter2-password", os.ErrNotExist))
+	assert.EqualError(t, err, "error reading ***: file does not exist")
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+	assert.Nil(t, RedactError(nil))
+}
+
+func TestRedactingCore(t *testing.T) {
+	RegisterSecretValues("vault-token-value")
+
+	core, logs := observer.New(zapcore.DebugLevel)
+	logger := zap.New(&redactingCore{core}).Sugar()
+	logger.Infof("VAULT_TOKEN=%v", "vault-token-value")
+	logger.Infow("fetched", "token", "vault-token-value", "error", errors.New("bad token vault-token-value"))
+	logger.With("token", "vault-token-value").Info("with")
+
+	entries := logs.AllUntimed()
+	assert.Equal(t, 3, len(entries))
+	assert.Equal(t, "VAULT_TOKEN=***", entries[0].Message)
+	assert.Equal(t, map[string]interface{}{"token": "***", "error": "bad token ***"}, entries[1].ContextMap())
+	assert.Equal(t, map[string]interface{}{"token": "***"}, entries[2].ContextMap())
+}
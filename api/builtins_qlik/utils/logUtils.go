@@ -57,7 +57,9 @@ func GetLogger(pluginName string) *zap.SugaredLogger {
 			EncodeCaller:   zapcore.ShortCallerEncoder,
 		},
 	}
-	logger, _ = cfg.Build()
+	logger, _ = cfg.Build(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		return &redactingCore{core}
+	}))
 	sugar = logger.Sugar()
 	defer sugar.Sync()
 	return sugar
@@ -0,0 +1,172 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/semver/v3"
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"go.uber.org/zap"
+	"sigs.k8s.io/kustomize/api/internal/git"
+	"sigs.k8s.io/kustomize/api/types"
+)
+
+const (
+	OfflineEnvVar     = "KUST_OFFLINE"
+	VendorEnvVar      = "KUST_VENDOR"
+	ChartMirrorEnvVar = "KUST_CHART_MIRROR"
+	GitMirrorEnvVar   = "KUST_GIT_MIRROR"
+
+	gitMirrorTimeout = 10 * time.Minute
+)
+
+// ArtifactNotInMirrorError is returned in offline mode when a remote artifact cannot be resolved locally.
+type ArtifactNotInMirrorError struct {
+	Artifact string
+	Version  string
+	Mirror   string
+}
+
+func (e *ArtifactNotInMirrorError) Error() string {
+	version := e.Version
+	if version == "" {
+		version = "latest"
+	}
+	if e.Mirror == "" {
+		return fmt.Sprintf("artifact %v@%v not present in mirror: offline mode is enabled but no mirror directory is configured", e.Artifact, version)
+	}
+	return fmt.Sprintf("artifact %v@%v not present in mirror: %v", e.Artifact, version, e.Mirror)
+}
+
+// GetMirrorConfig returns the mirror configuration of the build.
+func GetMirrorConfig(pc *types.PluginConfig) types.MirrorConfig {
+	if pc == nil {
+		return types.MirrorConfig{}
+	}
+	return pc.MirrorConfig
+}
+
+// MirrorConfigFromEnv returns the mirror configuration set by the KUST_OFFLINE,
+// KUST_VENDOR, KUST_CHART_MIRROR and KUST_GIT_MIRROR env variables, the defaults
+// of the command line flags, which take precedence.
+func MirrorConfigFromEnv() types.MirrorConfig {
+	var mc types.MirrorConfig
+	mc.Offline, _ = strconv.ParseBool(os.Getenv(OfflineEnvVar))
+	mc.Vendor, _ = strconv.ParseBool(os.Getenv(VendorEnvVar))
+	mc.ChartMirror = os.Getenv(ChartMirrorEnvVar)
+	mc.GitMirror = os.Getenv(GitMirrorEnvVar)
+	return mc
+}
+
+// ResolveChartFromMirror returns the directory of chart name@version in the chart mirror.
+// An empty version resolves to the highest semantic version present.
+func ResolveChartFromMirror(mirror, name, version string) (chartDir string, resolvedVersion string, err error) {
+	notFound := &ArtifactNotInMirrorError{Artifact: name, Version: version, Mirror: mirror}
+	if mirror == "" {
+		return "", "", notFound
+	}
+	resolvedVersion = version
+	if resolvedVersion == "" {
+		infos, err := ioutil.ReadDir(filepath.Join(mirror, name))
+		if err != nil {
+			return "", "", notFound
+		}
+		var highest *semver.Version
+		for _, info := range infos {
+			if v, err := semver.NewVersion(info.Name()); err == nil && info.IsDir() && (highest == nil || v.GreaterThan(highest)) {
+				highest = v
+				resolvedVersion = info.Name()
+			}
+		}
+		if highest == nil {
+			return "", "", notFound
+		}
+	}
+	chartDir = filepath.Join(mirror, name, resolvedVersion)
+	if _, err := os.Stat(filepath.Join(chartDir, "Chart.yaml")); err != nil {
+		return "", "", notFound
+	}
+	return chartDir, resolvedVersion, nil
+}
+
+// StoreChartInMirror copies the untarred chart at chartDir into the chart mirror as name@version.
+func StoreChartInMirror(mirror, name, version, chartDir string, logger *zap.SugaredLogger) error {
+	mirrorChartDir := filepath.Join(mirror, name, version)
+	if err := os.RemoveAll(mirrorChartDir); err != nil {
+		return err
+	}
+	if err := CopyDir(chartDir, mirrorChartDir, logger); err != nil {
+		return err
+	}
+	if err := os.RemoveAll(filepath.Join(mirrorChartDir, "tmpcharts")); err != nil {
+		return err
+	}
+	logger.Infof("vendored chart: %v@%v to: %v\n", name, version, mirrorChartDir)
+	return nil
+}
+
+// GitMirrorRepoDir returns the bare repository directory for repository URL u in the git mirror.
+func GitMirrorRepoDir(mirror string, u *url.URL) string {
+	repoPath := strings.TrimSuffix(strings.Trim(u.Path, "/"), ".git") + ".git"
+	return filepath.Join(mirror, u.Host, filepath.FromSlash(repoPath))
+}
+
+// ResolveGitFromMirror returns a file URL for the mirrored repository of u, making sure ref is present in it.
+func ResolveGitFromMirror(mirror string, u *url.URL, ref string) (*url.URL, error) {
+	repoURL := *u
+	repoURL.RawQuery = ""
+	notFound := &ArtifactNotInMirrorError{Artifact: repoURL.String(), Version: ref, Mirror: mirror}
+	if mirror == "" {
+		return nil, notFound
+	}
+	repoDir := GitMirrorRepoDir(mirror, u)
+	if _, err := os.Stat(repoDir); err != nil {
+		return nil, notFound
+	}
+	if ref != "" {
+		r, err := gogit.PlainOpen(repoDir)
+		if err != nil {
+			return nil, notFound
+		}
+		if _, err := r.ResolveRevision(plumbing.Revision(ref)); err != nil {
+			return nil, notFound
+		}
+	}
+	absRepoDir, err := filepath.Abs(repoDir)
+	if err != nil {
+		return nil, err
+	}
+	return &url.URL{Scheme: "file", Path: filepath.ToSlash(absRepoDir), RawQuery: u.RawQuery}, nil
+}
+
+// StoreGitInMirror creates or refreshes a bare mirror clone of repository u in the git mirror.
+func StoreGitInMirror(mirror string, u *url.URL, logger *zap.SugaredLogger) error {
+	repoURL := *u
+	repoURL.RawQuery = ""
+	repoDir := GitMirrorRepoDir(mirror, u)
+	unlockFn, err := LockPath(repoDir+".flock", DefaultLockTimeoutSeconds,
+		DefaultLockRetryDelayMinMilliSeconds, DefaultLockRetryDelayMaxMilliSeconds, logger)
+	if err != nil {
+		return err
+	}
+	defer unlockFn()
+
+	if err := os.MkdirAll(filepath.Dir(repoDir), os.ModePerm); err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), gitMirrorTimeout)
+	defer cancel()
+	if err := git.MirrorUsingGoGit(ctx, repoDir, repoURL.String()); err != nil {
+		return fmt.Errorf("error vendoring git repository: %v to: %v, error: %v", repoURL.String(), repoDir, err)
+	}
+	logger.Infof("vendored git repository: %v to: %v\n", repoURL.String(), repoDir)
+	return nil
+}
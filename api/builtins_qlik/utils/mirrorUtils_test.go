@@ -0,0 +1,110 @@
+package utils
+
+import (
+	"io/ioutil"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/kustomize/api/types"
+)
+
+func TestGetMirrorConfig(t *testing.T) {
+	pc := types.DisabledPluginConfig()
+	pc.MirrorConfig.ChartMirror = "/from/flags"
+	assert.Equal(t, types.MirrorConfig{ChartMirror: "/from/flags"}, GetMirrorConfig(pc))
+	assert.Equal(t, types.MirrorConfig{}, GetMirrorConfig(nil))
+
+	// the env only provides the defaults of the flags:
+	os.Setenv(OfflineEnvVar, "true")
+	os.Setenv(GitMirrorEnvVar, "/from/env")
+	defer os.Unsetenv(OfflineEnvVar)
+	defer os.Unsetenv(GitMirrorEnvVar)
+	assert.Equal(t, types.MirrorConfig{ChartMirror: "/from/flags"}, GetMirrorConfig(pc))
+	assert.Equal(t, types.MirrorConfig{Offline: true, GitMirror: "/from/env"}, MirrorConfigFromEnv())
+}
+
+func TestResolveChartFromMirror(t *testing.T) {
+	mirror, err := ioutil.TempDir("", "")
+	assert.NoError(t, err)
+	defer os.RemoveAll(mirror)
+
+	_, _, err = ResolveChartFromMirror(mirror, "foo", "1.0.0")
+	assert.EqualError(t, err, "artifact foo@1.0.0 not present in mirror: "+mirror)
+
+	chartDir, err := ioutil.TempDir("", "")
+	assert.NoError(t, err)
+	defer os.RemoveAll(chartDir)
+	for _, version := range []string{"1.0.0", "1.10.0", "1.9.0"} {
+		writeTestChart(t, chartDir, version)
+		assert.NoError(t, StoreChartInMirror(mirror, "foo", version, chartDir, GetNopLogger()))
+	}
+
+	dir, version, err := ResolveChartFromMirror(mirror, "foo", "1.9.0")
+	assert.NoError(t, err)
+	assert.Equal(t, "1.9.0", version)
+	assert.Equal(t, filepath.Join(mirror, "foo", "1.9.0"), dir)
+
+	_, version, err = ResolveChartFromMirror(mirror, "foo", "")
+	assert.NoError(t, err)
+	assert.Equal(t, "1.10.0", version)
+
+	_, _, err = ResolveChartFromMirror(mirror, "bar", "")
+	assert.EqualError(t, err, "artifact bar@latest not present in mirror: "+mirror)
+}
+
+func TestGitMirror(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not available")
+	}
+	tmpDir, err := ioutil.TempDir("", "")
+	assert.NoError(t, err)
+	defer os.RemoveAll(tmpDir)
+
+	srcRepo := filepath.Join(tmpDir, "src")
+	for _, args := range [][]string{
+		{"init", "-q", srcRepo},
+		{"-C", srcRepo, "-c", "user.email=test@example.com", "-c", "user.name=test", "commit", "-q", "--allow-empty", "-m", "init"},
+		{"-C", srcRepo, "tag", "v1.0.0"},
+	} {
+		out, err := exec.Command("git", args...).CombinedOutput()
+		assert.NoError(t, err, string(out))
+	}
+
+	mirror := filepath.Join(tmpDir, "mirror")
+	u, err := url.Parse("https://github.com/example/repo.git?ref=v1.0.0")
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(mirror, "github.com", "example", "repo.git"), GitMirrorRepoDir(mirror, u))
+
+	_, err = ResolveGitFromMirror(mirror, u, "v1.0.0")
+	assert.EqualError(t, err, "artifact https://github.com/example/repo.git@v1.0.0 not present in mirror: "+mirror)
+
+	src, err := url.Parse("file://" + filepath.ToSlash(srcRepo) + "?ref=v1.0.0")
+	assert.NoError(t, err)
+	assert.NoError(t, StoreGitInMirror(mirror, src, GetNopLogger()))
+
+	mirrored, err := ResolveGitFromMirror(mirror, src, "v1.0.0")
+	assert.NoError(t, err)
+	assert.Equal(t, "file", mirrored.Scheme)
+	assert.Equal(t, "v1.0.0", mirrored.Query().Get("ref"))
+
+	_, err = ResolveGitFromMirror(mirror, src, "v2.0.0")
+	assert.Error(t, err)
+
+	// refreshing the mirror fetches new tags and prunes deleted ones:
+	for _, args := range [][]string{
+		{"-C", srcRepo, "tag", "v2.0.0"},
+		{"-C", srcRepo, "tag", "-d", "v1.0.0"},
+	} {
+		out, err := exec.Command("git", args...).CombinedOutput()
+		assert.NoError(t, err, string(out))
+	}
+	assert.NoError(t, StoreGitInMirror(mirror, src, GetNopLogger()))
+	_, err = ResolveGitFromMirror(mirror, src, "v2.0.0")
+	assert.NoError(t, err)
+	_, err = ResolveGitFromMirror(mirror, src, "v1.0.0")
+	assert.Error(t, err)
+}
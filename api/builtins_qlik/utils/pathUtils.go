@@ -0,0 +1,20 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// ResolvePath returns name relative to the kustomization root when it exists there,
+// else name as given. Builds no longer change the working directory of the process
+// to the kustomization they build, e.g. for components fetched by GoGetter.
+func ResolvePath(root, name string) string {
+	if name == "" || filepath.IsAbs(name) || root == "" {
+		return name
+	}
+	path := filepath.Join(root, name)
+	if _, err := os.Stat(path); err != nil {
+		return name
+	}
+	return path
+}
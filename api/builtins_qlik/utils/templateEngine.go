@@ -0,0 +1,254 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"mime"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/Masterminds/sprig/v3"
+	"sigs.k8s.io/kustomize/api/ifc"
+	"sigs.k8s.io/kustomize/api/provenance"
+	"sigs.k8s.io/kustomize/api/resmap"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// TemplateEngineGomplate runs templates with gomplate, the default.
+	TemplateEngineGomplate = "gomplate"
+	// TemplateEngineBuiltin runs templates with text/template and the functions of BuiltinTemplate.
+	TemplateEngineBuiltin = "builtin"
+)
+
+// ValidateTemplateEngine returns an error if engine is neither empty nor a known engine.
+func ValidateTemplateEngine(engine string) error {
+	if engine != "" && engine != TemplateEngineGomplate && engine != TemplateEngineBuiltin {
+		return fmt.Errorf("unknown template engine: %v, it can only be: %v or %v",
+			engine, TemplateEngineGomplate, TemplateEngineBuiltin)
+	}
+	return nil
+}
+
+// BuiltinTemplate runs templates with text/template, without gomplate. The templates
+// are executed with .Env, the Env map, and the functions below, the ones of sprig
+// except for ds, env, resource and field, and for toYaml and required, like helm's.
+//
+// Datasources, the environment and the resources of the build:
+//
+//	ds ALIAS                the content of a datasource, parsed when it is JSON or YAML
+//	env NAME                the value of NAME in Env, the env of the plugin only
+//	resource KIND NAME [NS] the resource of the build with the given kind and name,
+//	                        and namespace if several have the name, as a map
+//	field PATH VALUE        the field at PATH, e.g. spec.containers[0].image or
+//	                        spec.containers.0.image, of VALUE, e.g. a resource,
+//	                        failing if there is none
+//
+// Strings:
+//
+//	upper lower title trim trimAll trimPrefix trimSuffix replace contains hasPrefix
+//	hasSuffix repeat trunc indent nindent quote squote splitList join regexMatch
+//	regexReplaceAll b64enc b64dec toString toJson toYaml
+//
+// Defaults and conditions:
+//
+//	default empty coalesce ternary required
+//
+// Lists and dicts:
+//
+//	list first last rest initial append prepend concat has without uniq sortAlpha
+//	dict get set hasKey keys, the keys being sorted unlike sprig
+type BuiltinTemplate struct {
+	LDelim      string
+	RDelim      string
+	DataSources map[string]interface{}
+	Env         map[string]string
+	Resources   resmap.ResMap
+}
+
+// BuiltinDataSources loads datasources like GomplateDataSources, for a BuiltinTemplate.
+// The content of JSON and YAML datasources, by their type query parameter, e.g.
+// ?type=application/json, or their extension, is parsed, the others are strings.
+// Remote datasources other than http(s) ones, e.g. vault secrets, are not supported.
+func BuiltinDataSources(ldr ifc.Loader, local []string, remote []string, inputs *provenance.Recorder) (map[string]interface{}, error) {
+	loaded, err := loadDataSources(ldr, local, remote, inputs)
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string]interface{}, len(loaded))
+	for _, dataSource := range loaded {
+		if dataSource.content == nil {
+			return nil, fmt.Errorf("datasource %v is not supported by the %v template engine",
+				dataSource.dataSource, TemplateEngineBuiltin)
+		}
+		mediaType := dataSource.url.Query().Get("type")
+		if mediaType == "" {
+			mediaType = mime.TypeByExtension(filepath.Ext(dataSource.url.Path))
+			switch filepath.Ext(dataSource.url.Path) {
+			case ".yaml", ".yml":
+				mediaType = "application/yaml"
+			}
+		}
+		if mediaType, _, err = mime.ParseMediaType(mediaType); err != nil || !isStructuredMediaType(mediaType) {
+			result[dataSource.alias] = string(dataSource.content)
+			continue
+		}
+		var value interface{}
+		if err := yaml.Unmarshal(dataSource.content, &value); err != nil {
+			return nil, fmt.Errorf("error parsing datasource %v: %v", dataSource.dataSource, err)
+		}
+		result[dataSource.alias] = value
+	}
+	return result, nil
+}
+
+func isStructuredMediaType(mediaType string) bool {
+	switch mediaType {
+	case "application/json", "application/yaml", "application/x-yaml", "text/yaml", "text/x-yaml":
+		return true
+	}
+	return false
+}
+
+// Execute executes the template text.
+func (t *BuiltinTemplate) Execute(name string, text string) ([]byte, error) {
+	tmpl, err := template.New(name).Delims(t.LDelim, t.RDelim).Funcs(t.funcs()).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	var b bytes.Buffer
+	if err := tmpl.Execute(&b, map[string]interface{}{"Env": t.Env}); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// builtinTemplateFuncs are the functions of sprig a BuiltinTemplate provides, none of
+// which reads the system, e.g. env or now, nor returns random values, e.g. uuidv4,
+// so that builds are reproducible.
+var builtinTemplateFuncs = []string{
+	"upper", "lower", "title", "trim", "trimAll", "trimPrefix", "trimSuffix", "replace", "contains",
+	"hasPrefix", "hasSuffix", "repeat", "trunc", "indent", "nindent", "quote", "squote", "splitList",
+	"join", "regexMatch", "regexReplaceAll", "b64enc", "b64dec", "toString", "toJson",
+
+	"default", "empty", "coalesce", "ternary",
+
+	"list", "first", "last", "rest", "initial", "append", "prepend", "concat", "has", "without",
+	"uniq", "sortAlpha", "dict", "get", "set", "hasKey",
+}
+
+func (t *BuiltinTemplate) funcs() template.FuncMap {
+	sprigFuncs := sprig.TxtFuncMap()
+	funcs := template.FuncMap{
+		"ds":       t.ds,
+		"env":      t.env,
+		"resource": t.resource,
+		"field":    templateField,
+		"toYaml":   templateToYaml,
+		"required": templateRequired,
+		"keys":     templateKeys,
+	}
+	for _, name := range builtinTemplateFuncs {
+		funcs[name] = sprigFuncs[name]
+	}
+	return funcs
+}
+
+func (t *BuiltinTemplate) ds(alias string) (interface{}, error) {
+	value, ok := t.DataSources[alias]
+	if !ok {
+		return nil, fmt.Errorf("undefined datasource %q", alias)
+	}
+	return value, nil
+}
+
+func (t *BuiltinTemplate) env(name string) string {
+	return t.Env[name]
+}
+
+func (t *BuiltinTemplate) resource(kind string, name string, namespace ...string) (map[string]interface{}, error) {
+	if t.Resources == nil {
+		return nil, fmt.Errorf("resource %v %v not found, there are no resources", kind, name)
+	}
+	var found []map[string]interface{}
+	for _, r := range t.Resources.Resources() {
+		id := r.CurId()
+		if id.Kind != kind || id.Name != name || (len(namespace) > 0 && r.GetNamespace() != namespace[0]) {
+			continue
+		}
+		m, err := r.Map()
+		if err != nil {
+			return nil, err
+		}
+		found = append(found, m)
+	}
+	switch len(found) {
+	case 0:
+		return nil, fmt.Errorf("resource %v %v %v not found", kind, name, strings.Join(namespace, ""))
+	case 1:
+		return found[0], nil
+	}
+	return nil, fmt.Errorf("resource %v %v is ambiguous, %d resources match, give its namespace", kind, name, len(found))
+}
+
+var fieldIndexRegexp = regexp.MustCompile(`\[(\d+)\]`)
+
+func templateField(path string, value interface{}) (interface{}, error) {
+	segments := strings.Split(fieldIndexRegexp.ReplaceAllString(path, ".$1"), ".")
+	for _, segment := range segments {
+		if segment == "" {
+			continue
+		}
+		switch v := value.(type) {
+		case map[string]interface{}:
+			next, ok := v[segment]
+			if !ok {
+				return nil, fmt.Errorf("field %v not found", path)
+			}
+			value = next
+		case []interface{}:
+			i, err := strconv.Atoi(segment)
+			if err != nil || i < 0 || i >= len(v) {
+				return nil, fmt.Errorf("field %v not found", path)
+			}
+			value = v[i]
+		default:
+			return nil, fmt.Errorf("field %v not found", path)
+		}
+	}
+	return value, nil
+}
+
+// templateToYaml marshals value to YAML, like the toYaml of helm.
+func templateToYaml(value interface{}) (string, error) {
+	data, err := yaml.Marshal(value)
+	return strings.TrimSuffix(string(data), "\n"), err
+}
+
+// templateRequired fails with message when value is nil or empty, like the required of helm.
+func templateRequired(message string, value interface{}) (interface{}, error) {
+	if value == nil {
+		return nil, errors.New(message)
+	}
+	if s, ok := value.(string); ok && s == "" {
+		return nil, errors.New(message)
+	}
+	return value, nil
+}
+
+// templateKeys returns the keys of dicts, sorted unlike the keys of sprig.
+func templateKeys(dicts ...map[string]interface{}) []string {
+	var result []string
+	for _, d := range dicts {
+		for key := range d {
+			result = append(result, key)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
@@ -0,0 +1,111 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/kustomize/api/filesys"
+	"sigs.k8s.io/kustomize/api/loader"
+	"sigs.k8s.io/kustomize/api/provider"
+	"sigs.k8s.io/kustomize/api/resmap"
+)
+
+func TestBuiltinTemplate(t *testing.T) {
+	resourceFactory := resmap.NewFactory(provider.NewDefaultDepProvider().GetResourceFactory())
+	resources, err := resourceFactory.NewResMapFromBytes([]byte(`
+apiVersion: v1
+kind: Service
+metadata:
+  name: db
+spec:
+  ports:
+  - port: 5432
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: settings
+  namespace: a
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: settings
+  namespace: b
+data:
+  mode: fast
+`))
+	assert.NoError(t, err)
+	engine := &BuiltinTemplate{
+		LDelim:      "{{",
+		RDelim:      "}}",
+		DataSources: map[string]interface{}{"values": map[string]interface{}{"names": []interface{}{"b", "a", "b"}}},
+		Env:         map[string]string{"REGION": "eu"},
+		Resources:   resources,
+	}
+	for _, testCase := range []struct {
+		template string
+		expected string
+		err      string
+	}{
+		{template: `{{ resource "Service" "db" | field "spec.ports[0].port" }}`, expected: "5432"},
+		{template: `{{ resource "ConfigMap" "settings" "b" | field "data.mode" | upper }}`, expected: "FAST"},
+		{template: `{{ env "REGION" }}-{{ .Env.REGION }}`, expected: "eu-eu"},
+		{template: `{{ (ds "values").names | uniq | sortAlpha | join "," }}`, expected: "a,b"},
+		{template: `{{ "foo-bar" | replace "-" "_" | trimPrefix "foo" | quote }}`, expected: `"_bar"`},
+		{template: `{{ "" | default "none" }} {{ list 1 2 3 | last }} {{ dict "a" 1 "b" 2 | keys | toJson }}`, expected: `none 3 ["a","b"]`},
+		{template: `{{ "a\nb" | nindent 2 }}`, expected: "\n  a\n  b"},
+		{template: `{{ "hello" | b64enc | b64dec | trunc 4 }}`, expected: "hell"},
+		{template: `{{ regexReplaceAll "[0-9]+" "v1.20" "N" }} {{ has "a" (list "a") }}`, expected: "vN.N true"},
+		{
+			template: `{{ resource "ConfigMap" "settings" }}`,
+			err:      `template: test:1:3: executing "test" at <resource "ConfigMap" "settings">: error calling resource: resource ConfigMap settings is ambiguous, 2 resources match, give its namespace`,
+		},
+		{
+			template: `{{ resource "Service" "db" | field "spec.ports[1].port" }}`,
+			err:      `template: test:1:29: executing "test" at <field "spec.ports[1].port">: error calling field: field spec.ports[1].port not found`,
+		},
+		{
+			template: `{{ ds "nope" }}`,
+			err:      `template: test:1:3: executing "test" at <ds "nope">: error calling ds: undefined datasource "nope"`,
+		},
+		{
+			template: `{{ now }}`,
+			err:      `template: test:1: function "now" not defined`,
+		},
+		{
+			template: `{{ required "region is required" (env "ZONE") }}`,
+			err:      `template: test:1:3: executing "test" at <required "region is required" (env "ZONE")>: error calling required: region is required`,
+		},
+	} {
+		output, err := engine.Execute("test", testCase.template)
+		if testCase.err != "" {
+			assert.EqualError(t, err, testCase.err, testCase.template)
+			continue
+		}
+		assert.NoError(t, err, testCase.template)
+		assert.Equal(t, testCase.expected, string(output), testCase.template)
+	}
+}
+
+func TestBuiltinDataSources(t *testing.T) {
+	fSys := filesys.MakeFsInMemory()
+	assert.NoError(t, fSys.WriteFile("/app/values.yaml", []byte("foo: bar\n")))
+	assert.NoError(t, fSys.WriteFile("/app/config.json", []byte(`{"baz": [1]}`)))
+	assert.NoError(t, fSys.WriteFile("/app/notes.txt", []byte("a: b")))
+	assert.NoError(t, fSys.WriteFile("/app/data", []byte("c: d")))
+	ldr, err := loader.NewLoader(loader.RestrictionRootOnly, "/app", fSys)
+	assert.NoError(t, err)
+
+	dataSources, err := BuiltinDataSources(ldr, []string{"values.yaml", "config.json", "notes=notes.txt", "data?type=application/yaml"}, nil, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"values": map[string]interface{}{"foo": "bar"},
+		"config": map[string]interface{}{"baz": []interface{}{float64(1)}},
+		"notes":  "a: b",
+		"data":   map[string]interface{}{"c": "d"},
+	}, dataSources)
+
+	_, err = BuiltinDataSources(ldr, nil, []string{"secrets=vault:///secret/app"}, nil)
+	assert.EqualError(t, err, "datasource secrets=vault:///secret/app is not supported by the builtin template engine")
+}
@@ -0,0 +1,911 @@
+package utils
+
+import (
+	"archive/zip"
+	"context"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	htmltemplate "html/template"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	"github.com/traefik/yaegi/interp"
+	"github.com/traefik/yaegi/stdlib"
+	"github.com/traefik/yaegi/stdlib/unrestricted"
+)
+
+// Capabilities that opt a Yaegi script back into stdlib functionality
+// which the sandbox withholds by default.
+const (
+	// YaegiCapabilityFsWrite allows creating, modifying and removing files under the sandbox root.
+	YaegiCapabilityFsWrite = "fs-write"
+	// YaegiCapabilityFsAny lifts the confinement of file access to the sandbox root.
+	YaegiCapabilityFsAny = "fs-any"
+	// YaegiCapabilityEnv allows reading and modifying environment variables.
+	YaegiCapabilityEnv = "env"
+	// YaegiCapabilityExec allows starting processes, e.g. through os/exec.
+	YaegiCapabilityExec = "exec"
+	// YaegiCapabilityNetwork allows network access, e.g. through net/http.
+	YaegiCapabilityNetwork = "network"
+	// YaegiCapabilityUnrestricted gives the script the full Go stdlib.
+	YaegiCapabilityUnrestricted = "unrestricted"
+
+	// DefaultYaegiTimeout bounds each evaluation of a script unless a plugin configures its own timeout.
+	DefaultYaegiTimeout = time.Minute
+)
+
+var yaegiCapabilities = map[string]bool{
+	YaegiCapabilityFsWrite:      true,
+	YaegiCapabilityFsAny:        true,
+	YaegiCapabilityEnv:          true,
+	YaegiCapabilityExec:         true,
+	YaegiCapabilityNetwork:      true,
+	YaegiCapabilityUnrestricted: true,
+}
+
+// yaegiPackages lists the stdlib packages available to scripts by default: those that
+// access neither files, the environment, processes nor the network, and those whose file
+// access the sandbox wraps, see fileSymbols. The other packages require a capability, see
+// yaegiPackageCapabilities, or are only available to unrestricted scripts.
+var yaegiPackages = map[string]bool{
+	"archive/tar":          true,
+	"archive/zip":          true,
+	"bufio":                true,
+	"bytes":                true,
+	"compress/bzip2":       true,
+	"compress/flate":       true,
+	"compress/gzip":        true,
+	"compress/lzw":         true,
+	"compress/zlib":        true,
+	"container/heap":       true,
+	"container/list":       true,
+	"container/ring":       true,
+	"context":              true,
+	"crypto":               true,
+	"crypto/aes":           true,
+	"crypto/cipher":        true,
+	"crypto/des":           true,
+	"crypto/dsa":           true,
+	"crypto/ecdsa":         true,
+	"crypto/ed25519":       true,
+	"crypto/elliptic":      true,
+	"crypto/hmac":          true,
+	"crypto/md5":           true,
+	"crypto/rand":          true,
+	"crypto/rc4":           true,
+	"crypto/rsa":           true,
+	"crypto/sha1":          true,
+	"crypto/sha256":        true,
+	"crypto/sha512":        true,
+	"crypto/subtle":        true,
+	"crypto/x509/pkix":     true,
+	"encoding":             true,
+	"encoding/ascii85":     true,
+	"encoding/asn1":        true,
+	"encoding/base32":      true,
+	"encoding/base64":      true,
+	"encoding/binary":      true,
+	"encoding/csv":         true,
+	"encoding/gob":         true,
+	"encoding/hex":         true,
+	"encoding/json":        true,
+	"encoding/pem":         true,
+	"encoding/xml":         true,
+	"errors":               true,
+	"fmt":                  true,
+	"go/ast":               true,
+	"go/constant":          true,
+	"go/doc":               true,
+	"go/format":            true,
+	"go/parser":            true,
+	"go/printer":           true,
+	"go/scanner":           true,
+	"go/token":             true,
+	"go/types":             true,
+	"hash":                 true,
+	"hash/adler32":         true,
+	"hash/crc32":           true,
+	"hash/crc64":           true,
+	"hash/fnv":             true,
+	"hash/maphash":         true,
+	"html":                 true,
+	"image":                true,
+	"image/color":          true,
+	"image/color/palette":  true,
+	"image/draw":           true,
+	"image/gif":            true,
+	"image/jpeg":           true,
+	"image/png":            true,
+	"index/suffixarray":    true,
+	"io":                   true,
+	"io/ioutil":            true,
+	"log":                  true,
+	"math":                 true,
+	"math/big":             true,
+	"math/bits":            true,
+	"math/cmplx":           true,
+	"math/rand":            true,
+	"mime/quotedprintable": true,
+	"net/mail":             true,
+	"net/url":              true,
+	"os":                   true,
+	"path":                 true,
+	"path/filepath":        true,
+	"reflect":              true,
+	"regexp":               true,
+	"regexp/syntax":        true,
+	"sort":                 true,
+	"strconv":              true,
+	"strings":              true,
+	"sync":                 true,
+	"sync/atomic":          true,
+	"testing/iotest":       true,
+	"testing/quick":        true,
+	"text/scanner":         true,
+	"text/tabwriter":       true,
+	"text/template/parse":  true,
+	"time":                 true,
+	"unicode":              true,
+	"unicode/utf16":        true,
+	"unicode/utf8":         true,
+}
+
+// yaegiPackageCapabilities lists the stdlib packages that are available with a capability.
+// The text/template and html/template packages are only available once file access is not
+// confined to the root, for the ParseFiles and ParseGlob methods of their templates read
+// any file.
+var yaegiPackageCapabilities = map[string][]string{
+	"os/exec":            {YaegiCapabilityExec},
+	"net/http/cgi":       {YaegiCapabilityExec, YaegiCapabilityNetwork},
+	"net":                {YaegiCapabilityNetwork},
+	"net/http":           {YaegiCapabilityNetwork},
+	"net/http/cookiejar": {YaegiCapabilityNetwork},
+	"net/http/fcgi":      {YaegiCapabilityNetwork},
+	"net/http/httptest":  {YaegiCapabilityNetwork},
+	"net/http/httptrace": {YaegiCapabilityNetwork},
+	"net/http/httputil":  {YaegiCapabilityNetwork},
+	"net/http/pprof":     {YaegiCapabilityNetwork},
+	"net/rpc":            {YaegiCapabilityNetwork},
+	"net/rpc/jsonrpc":    {YaegiCapabilityNetwork},
+	"net/smtp":           {YaegiCapabilityNetwork},
+	"net/textproto":      {YaegiCapabilityNetwork},
+	"crypto/tls":         {YaegiCapabilityNetwork},
+	"crypto/x509":        {YaegiCapabilityNetwork},
+	"log/syslog":         {YaegiCapabilityNetwork},
+	"expvar":             {YaegiCapabilityNetwork},
+	"text/template":      {YaegiCapabilityFsAny},
+	"html/template":      {YaegiCapabilityFsAny},
+}
+
+// yaegiSymbols lists, for the packages available by default that also hold symbols
+// accessing the environment, processes or files, the symbols available by default.
+// The file symbols are replaced by the ones of fileSymbols.
+var yaegiSymbols = map[string]map[string]bool{
+	"os": {
+		"DevNull": true, "DirEntry": true, "ErrClosed": true, "ErrDeadlineExceeded": true,
+		"ErrExist": true, "ErrInvalid": true, "ErrNoDeadline": true, "ErrNotExist": true,
+		"ErrPermission": true, "ErrProcessDone": true, "File": true, "FileInfo": true,
+		"FileMode": true, "LinkError": true, "PathError": true, "ProcAttr": true, "Process": true,
+		"ProcessState": true, "Signal": true, "SyscallError": true, "Interrupt": true, "Kill": true,
+		"ModeAppend": true, "ModeCharDevice": true, "ModeDevice": true, "ModeDir": true,
+		"ModeExclusive": true, "ModeIrregular": true, "ModeNamedPipe": true, "ModePerm": true,
+		"ModeSetgid": true, "ModeSetuid": true, "ModeSocket": true, "ModeSticky": true,
+		"ModeSymlink": true, "ModeTemporary": true, "ModeType": true, "O_APPEND": true,
+		"O_CREATE": true, "O_EXCL": true, "O_RDONLY": true, "O_RDWR": true, "O_SYNC": true,
+		"O_TRUNC": true, "O_WRONLY": true, "PathListSeparator": true, "PathSeparator": true,
+		"SEEK_CUR": true, "SEEK_END": true, "SEEK_SET": true, "Stdin": true, "Stdout": true,
+		"Stderr": true, "_DirEntry": true, "_FileInfo": true, "_Signal": true,
+		"Expand": true, "Getegid": true, "Geteuid": true, "Getgid": true, "Getgroups": true,
+		"Getpagesize": true, "Getpid": true, "Getppid": true, "Getuid": true, "IsExist": true,
+		"IsNotExist": true, "IsPathSeparator": true, "IsPermission": true, "IsTimeout": true,
+		"NewSyscallError": true, "Pipe": true, "SameFile": true,
+	},
+}
+
+// yaegiSymbolCapabilities lists the stdlib symbols of the packages of yaegiSymbols
+// that are available with a capability.
+var yaegiSymbolCapabilities = map[string]map[string]string{
+	"os": {
+		"Getenv":        YaegiCapabilityEnv,
+		"LookupEnv":     YaegiCapabilityEnv,
+		"Environ":       YaegiCapabilityEnv,
+		"ExpandEnv":     YaegiCapabilityEnv,
+		"Setenv":        YaegiCapabilityEnv,
+		"Unsetenv":      YaegiCapabilityEnv,
+		"Clearenv":      YaegiCapabilityEnv,
+		"TempDir":       YaegiCapabilityEnv,
+		"UserCacheDir":  YaegiCapabilityEnv,
+		"UserConfigDir": YaegiCapabilityEnv,
+		"UserHomeDir":   YaegiCapabilityEnv,
+		"StartProcess":  YaegiCapabilityExec,
+		"FindProcess":   YaegiCapabilityExec,
+	},
+}
+
+// packageCapabilities returns the capabilities a script needs to import the stdlib
+// package pkg, none for the packages available by default.
+func packageCapabilities(pkg string) []string {
+	if yaegiPackages[pkg] {
+		return nil
+	}
+	if capabilities, ok := yaegiPackageCapabilities[pkg]; ok {
+		return capabilities
+	}
+	return []string{YaegiCapabilityUnrestricted}
+}
+
+// YaegiSandboxError is returned to a script for an operation its capabilities do not allow.
+type YaegiSandboxError struct {
+	Op         string
+	Path       string
+	Root       string
+	Capability string
+}
+
+func (e *YaegiSandboxError) Error() string {
+	if e.Path == "" {
+		return fmt.Sprintf("yaegi sandbox: %v requires capability: %v", e.Op, e.Capability)
+	}
+	return fmt.Sprintf("yaegi sandbox: %v %v outside of root %v requires capability: %v", e.Op, e.Path, e.Root, e.Capability)
+}
+
+// Is makes errors.Is(err, os.ErrPermission) hold for sandbox errors.
+func (e *YaegiSandboxError) Is(target error) bool {
+	return target == os.ErrPermission
+}
+
+// YaegiSandbox evaluates Yaegi scripts with a restricted set of stdlib symbols.
+// By default scripts may only read files under Root and cannot write files,
+// read the environment, start processes or access the network.
+// Capabilities opt back into each of these.
+type YaegiSandbox struct {
+	Root string
+	// Dir is the working directory of scripts, which relative file names and
+	// the commands they run resolve against. It defaults to Root.
+	Dir          string
+	Capabilities map[string]bool
+	Timeout      time.Duration
+	interp       *interp.Interpreter
+	calls        int
+}
+
+// NewYaegiSandbox returns a sandbox confined to root, with the given capabilities and timeout.
+// An empty timeout means DefaultYaegiTimeout and "0" disables the timeout.
+// The exports are made available to scripts in addition to the sandboxed stdlib.
+func NewYaegiSandbox(root string, capabilities []string, timeout string, exports ...interp.Exports) (*YaegiSandbox, error) {
+	s := &YaegiSandbox{Capabilities: make(map[string]bool), Timeout: DefaultYaegiTimeout}
+	for _, capability := range capabilities {
+		if !yaegiCapabilities[capability] {
+			return nil, fmt.Errorf("unknown yaegi capability: %v", capability)
+		}
+		s.Capabilities[capability] = true
+	}
+	if timeout != "" {
+		d, err := time.ParseDuration(timeout)
+		if err != nil {
+			if seconds, err := strconv.Atoi(timeout); err == nil {
+				d = time.Duration(seconds) * time.Second
+			} else {
+				return nil, fmt.Errorf("invalid yaegi timeout: %v", timeout)
+			}
+		}
+		s.Timeout = d
+	}
+	if root == "" {
+		root = "."
+	}
+	root, err := resolvePath(root)
+	if err != nil {
+		return nil, err
+	}
+	s.Root = root
+	s.Dir = root
+
+	s.interp = interp.New(interp.Options{})
+	s.interp.Use(s.symbols())
+	for _, e := range exports {
+		s.interp.Use(e)
+	}
+	return s, nil
+}
+
+func (s *YaegiSandbox) has(capability string) bool {
+	return s.Capabilities[capability] || s.Capabilities[YaegiCapabilityUnrestricted]
+}
+
+// hasSymbol tells whether the symbol name of the stdlib package pkg is available to s.
+func (s *YaegiSandbox) hasSymbol(pkg, name string) bool {
+	if s.has(YaegiCapabilityUnrestricted) {
+		return true
+	}
+	if capability, ok := yaegiSymbolCapabilities[pkg][name]; ok {
+		return s.has(capability)
+	}
+	if allowed, ok := yaegiSymbols[pkg]; ok {
+		return allowed[name]
+	}
+	return true
+}
+
+func (s *YaegiSandbox) hasAll(capabilities []string) bool {
+	for _, capability := range capabilities {
+		if !s.has(capability) {
+			return false
+		}
+	}
+	return true
+}
+
+// Eval evaluates script src. Imports of stdlib packages withheld by the sandbox fail with the capability they require.
+func (s *YaegiSandbox) Eval(src string) error {
+	f, err := parser.ParseFile(token.NewFileSet(), "", src, parser.ImportsOnly)
+	if err == nil {
+		for _, spec := range f.Imports {
+			pkg, _ := strconv.Unquote(spec.Path.Value)
+			if _, ok := stdlib.Symbols[pkg]; !ok && unrestricted.Symbols[pkg] == nil {
+				continue
+			}
+			if capabilities := packageCapabilities(pkg); !s.hasAll(capabilities) {
+				return &YaegiSandboxError{Op: fmt.Sprintf("import %q", pkg), Capability: strings.Join(capabilities, ", ")}
+			}
+		}
+	}
+	_, err = s.evalWithTimeout(src)
+	return err
+}
+
+// Call calls the script function name, e.g. "kust.Transform", which takes a []string
+// and returns numOut values followed by an error. The returned error is the one of the script function.
+func (s *YaegiSandbox) Call(name string, args []string, numOut int) ([]interface{}, error) {
+	s.calls++
+	vars := make([]string, numOut+1)
+	for i := range vars {
+		vars[i] = fmt.Sprintf("yaegiSandboxRet%d_%d", s.calls, i)
+	}
+	if _, err := s.evalWithTimeout(fmt.Sprintf("%v := %v(%#v)", strings.Join(vars, ", "), name, args)); err != nil {
+		return nil, err
+	}
+	values := make([]interface{}, numOut)
+	for i := range vars {
+		v, err := s.interp.Eval(vars[i])
+		if err != nil {
+			return nil, err
+		}
+		var value interface{}
+		if v.IsValid() {
+			value = v.Interface()
+		}
+		if i < numOut {
+			values[i] = value
+		} else if value != nil {
+			if err, ok := value.(error); ok {
+				return values, err
+			}
+			return values, fmt.Errorf("%v", value)
+		}
+	}
+	return values, nil
+}
+
+func (s *YaegiSandbox) evalWithTimeout(src string) (reflect.Value, error) {
+	ctx := context.Background()
+	if s.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.Timeout)
+		defer cancel()
+	}
+	v, err := s.interp.EvalWithContext(ctx, src)
+	if errors.Is(err, context.DeadlineExceeded) {
+		return v, fmt.Errorf("yaegi script did not complete within timeout: %v", s.Timeout)
+	}
+	return v, err
+}
+
+// symbols returns the stdlib symbols allowed by the capabilities of s,
+// with file access wrapped by the sandbox rules.
+func (s *YaegiSandbox) symbols() interp.Exports {
+	symbols := make(interp.Exports)
+	for pkg, pkgSymbols := range stdlib.Symbols {
+		if !s.hasAll(packageCapabilities(pkg)) {
+			continue
+		}
+		symbols[pkg] = make(map[string]reflect.Value, len(pkgSymbols))
+		for name, value := range pkgSymbols {
+			if s.hasSymbol(pkg, name) {
+				symbols[pkg][name] = value
+			}
+		}
+	}
+	if s.has(YaegiCapabilityExec) {
+		symbols["os/exec"] = make(map[string]reflect.Value, len(unrestricted.Symbols["os/exec"]))
+		for name, value := range unrestricted.Symbols["os/exec"] {
+			symbols["os/exec"][name] = value
+		}
+		symbols["os/exec"]["Command"] = reflect.ValueOf(func(name string, arg ...string) *exec.Cmd {
+			cmd := exec.Command(name, arg...)
+			cmd.Dir = s.Dir
+			return cmd
+		})
+		symbols["os/exec"]["CommandContext"] = reflect.ValueOf(func(ctx context.Context, name string, arg ...string) *exec.Cmd {
+			cmd := exec.CommandContext(ctx, name, arg...)
+			cmd.Dir = s.Dir
+			return cmd
+		})
+		symbols["os"]["FindProcess"] = unrestricted.Symbols["os"]["FindProcess"]
+	}
+	if s.has(YaegiCapabilityUnrestricted) {
+		symbols["os"]["Chdir"] = reflect.ValueOf(func(dir string) error {
+			dir = s.path(dir)
+			if info, err := os.Stat(dir); err != nil {
+				return err
+			} else if !info.IsDir() {
+				return &os.PathError{Op: "chdir", Path: dir, Err: errors.New("not a directory")}
+			}
+			s.Dir = dir
+			return nil
+		})
+	}
+	// the file symbols resolve relative names against Dir, and only check
+	// access when the capabilities of s do not already allow everything
+	for pkg, pkgSymbols := range s.fileSymbols() {
+		if symbols[pkg] == nil {
+			continue
+		}
+		for name, value := range pkgSymbols {
+			symbols[pkg][name] = value
+		}
+	}
+	return symbols
+}
+
+func (s *YaegiSandbox) fileSymbols() interp.Exports {
+	return interp.Exports{
+		"os": {
+			"Getwd": reflect.ValueOf(func() (string, error) {
+				return s.Dir, nil
+			}),
+			"Open": reflect.ValueOf(func(name string) (*os.File, error) {
+				name = s.path(name)
+				if err := s.checkRead("open", name); err != nil {
+					return nil, err
+				}
+				return os.Open(name)
+			}),
+			"OpenFile": reflect.ValueOf(func(name string, flag int, perm os.FileMode) (*os.File, error) {
+				name = s.path(name)
+				check := s.checkRead
+				if flag&(os.O_WRONLY|os.O_RDWR|os.O_CREATE|os.O_TRUNC|os.O_APPEND) != 0 {
+					check = s.checkWrite
+				}
+				if err := check("open", name); err != nil {
+					return nil, err
+				}
+				return os.OpenFile(name, flag, perm)
+			}),
+			"Stat": reflect.ValueOf(func(name string) (os.FileInfo, error) {
+				name = s.path(name)
+				if err := s.checkRead("stat", name); err != nil {
+					return nil, err
+				}
+				return os.Stat(name)
+			}),
+			"Lstat": reflect.ValueOf(func(name string) (os.FileInfo, error) {
+				name = s.path(name)
+				if err := s.checkRead("lstat", name); err != nil {
+					return nil, err
+				}
+				return os.Lstat(name)
+			}),
+			"ReadFile": reflect.ValueOf(func(name string) ([]byte, error) {
+				name = s.path(name)
+				if err := s.checkRead("read", name); err != nil {
+					return nil, err
+				}
+				return os.ReadFile(name)
+			}),
+			"ReadDir": reflect.ValueOf(func(name string) ([]os.DirEntry, error) {
+				name = s.path(name)
+				if err := s.checkRead("readdir", name); err != nil {
+					return nil, err
+				}
+				return os.ReadDir(name)
+			}),
+			"Readlink": reflect.ValueOf(func(name string) (string, error) {
+				name = s.path(name)
+				if err := s.checkRead("readlink", name); err != nil {
+					return "", err
+				}
+				return os.Readlink(name)
+			}),
+			"Create": reflect.ValueOf(func(name string) (*os.File, error) {
+				name = s.path(name)
+				if err := s.checkWrite("create", name); err != nil {
+					return nil, err
+				}
+				return os.Create(name)
+			}),
+			"CreateTemp": reflect.ValueOf(func(dir, pattern string) (*os.File, error) {
+				dir = s.path(dir)
+				if err := s.checkWrite("createtemp", tempDir(dir)); err != nil {
+					return nil, err
+				}
+				return os.CreateTemp(dir, pattern)
+			}),
+			"MkdirTemp": reflect.ValueOf(func(dir, pattern string) (string, error) {
+				dir = s.path(dir)
+				if err := s.checkWrite("mkdirtemp", tempDir(dir)); err != nil {
+					return "", err
+				}
+				return os.MkdirTemp(dir, pattern)
+			}),
+			"WriteFile": reflect.ValueOf(func(name string, data []byte, perm os.FileMode) error {
+				name = s.path(name)
+				if err := s.checkWrite("write", name); err != nil {
+					return err
+				}
+				return os.WriteFile(name, data, perm)
+			}),
+			"Mkdir": reflect.ValueOf(func(name string, perm os.FileMode) error {
+				name = s.path(name)
+				if err := s.checkWrite("mkdir", name); err != nil {
+					return err
+				}
+				return os.Mkdir(name, perm)
+			}),
+			"MkdirAll": reflect.ValueOf(func(name string, perm os.FileMode) error {
+				name = s.path(name)
+				if err := s.checkWrite("mkdir", name); err != nil {
+					return err
+				}
+				return os.MkdirAll(name, perm)
+			}),
+			"Remove": reflect.ValueOf(func(name string) error {
+				name = s.path(name)
+				if err := s.checkWrite("remove", name); err != nil {
+					return err
+				}
+				return os.Remove(name)
+			}),
+			"RemoveAll": reflect.ValueOf(func(name string) error {
+				name = s.path(name)
+				if err := s.checkWrite("remove", name); err != nil {
+					return err
+				}
+				return os.RemoveAll(name)
+			}),
+			"Rename": reflect.ValueOf(func(oldpath, newpath string) error {
+				oldpath, newpath = s.path(oldpath), s.path(newpath)
+				if err := s.checkWrite("rename", oldpath); err != nil {
+					return err
+				}
+				if err := s.checkWrite("rename", newpath); err != nil {
+					return err
+				}
+				return os.Rename(oldpath, newpath)
+			}),
+			"Symlink": reflect.ValueOf(func(oldname, newname string) error {
+				newname = s.path(newname)
+				target := oldname
+				if !filepath.IsAbs(target) {
+					target = filepath.Join(filepath.Dir(newname), target)
+				}
+				if err := s.checkRead("symlink", target); err != nil {
+					return err
+				}
+				if err := s.checkWrite("symlink", newname); err != nil {
+					return err
+				}
+				return os.Symlink(oldname, newname)
+			}),
+			"Link": reflect.ValueOf(func(oldname, newname string) error {
+				oldname, newname = s.path(oldname), s.path(newname)
+				if err := s.checkRead("link", oldname); err != nil {
+					return err
+				}
+				if err := s.checkWrite("link", newname); err != nil {
+					return err
+				}
+				return os.Link(oldname, newname)
+			}),
+			"Chmod": reflect.ValueOf(func(name string, mode os.FileMode) error {
+				name = s.path(name)
+				if err := s.checkWrite("chmod", name); err != nil {
+					return err
+				}
+				return os.Chmod(name, mode)
+			}),
+			"Chtimes": reflect.ValueOf(func(name string, atime time.Time, mtime time.Time) error {
+				name = s.path(name)
+				if err := s.checkWrite("chtimes", name); err != nil {
+					return err
+				}
+				return os.Chtimes(name, atime, mtime)
+			}),
+			"Truncate": reflect.ValueOf(func(name string, size int64) error {
+				name = s.path(name)
+				if err := s.checkWrite("truncate", name); err != nil {
+					return err
+				}
+				return os.Truncate(name, size)
+			}),
+		},
+		"io/ioutil": {
+			"ReadFile": reflect.ValueOf(func(name string) ([]byte, error) {
+				name = s.path(name)
+				if err := s.checkRead("read", name); err != nil {
+					return nil, err
+				}
+				return ioutil.ReadFile(name)
+			}),
+			"ReadDir": reflect.ValueOf(func(name string) ([]os.FileInfo, error) {
+				name = s.path(name)
+				if err := s.checkRead("readdir", name); err != nil {
+					return nil, err
+				}
+				return ioutil.ReadDir(name)
+			}),
+			"WriteFile": reflect.ValueOf(func(name string, data []byte, perm os.FileMode) error {
+				name = s.path(name)
+				if err := s.checkWrite("write", name); err != nil {
+					return err
+				}
+				return ioutil.WriteFile(name, data, perm)
+			}),
+			"TempDir": reflect.ValueOf(func(dir, pattern string) (string, error) {
+				dir = s.path(dir)
+				if err := s.checkWrite("mkdirtemp", tempDir(dir)); err != nil {
+					return "", err
+				}
+				return ioutil.TempDir(dir, pattern)
+			}),
+			"TempFile": reflect.ValueOf(func(dir, pattern string) (*os.File, error) {
+				dir = s.path(dir)
+				if err := s.checkWrite("createtemp", tempDir(dir)); err != nil {
+					return nil, err
+				}
+				return ioutil.TempFile(dir, pattern)
+			}),
+		},
+		"path/filepath": {
+			"Walk": reflect.ValueOf(func(root string, fn filepath.WalkFunc) error {
+				if err := s.checkRead("walk", s.path(root)); err != nil {
+					return err
+				}
+				return filepath.Walk(s.path(root), func(path string, info os.FileInfo, err error) error {
+					return fn(s.walkedPath(root, path), info, err)
+				})
+			}),
+			"WalkDir": reflect.ValueOf(func(root string, fn func(string, os.DirEntry, error) error) error {
+				if err := s.checkRead("walk", s.path(root)); err != nil {
+					return err
+				}
+				return filepath.WalkDir(s.path(root), func(path string, d os.DirEntry, err error) error {
+					return fn(s.walkedPath(root, path), d, err)
+				})
+			}),
+			"Abs": reflect.ValueOf(func(path string) (string, error) {
+				if path == "" {
+					return s.Dir, nil
+				}
+				return filepath.Clean(s.path(path)), nil
+			}),
+			"Glob": reflect.ValueOf(s.glob),
+			"EvalSymlinks": reflect.ValueOf(func(path string) (string, error) {
+				path = s.path(path)
+				if err := s.checkRead("evalsymlinks", path); err != nil {
+					return "", err
+				}
+				return filepath.EvalSymlinks(path)
+			}),
+		},
+		// only available with fs-any, these resolve relative names against Dir
+		"text/template": {
+			"ParseFiles": reflect.ValueOf(func(filenames ...string) (*texttemplate.Template, error) {
+				filenames = s.paths(filenames)
+				if err := s.checkReadAll("parse", filenames); err != nil {
+					return nil, err
+				}
+				return texttemplate.ParseFiles(filenames...)
+			}),
+			"ParseGlob": reflect.ValueOf(func(pattern string) (*texttemplate.Template, error) {
+				filenames, err := s.globFiles(pattern)
+				if err != nil {
+					return nil, err
+				}
+				return texttemplate.ParseFiles(filenames...)
+			}),
+		},
+		"html/template": {
+			"ParseFiles": reflect.ValueOf(func(filenames ...string) (*htmltemplate.Template, error) {
+				filenames = s.paths(filenames)
+				if err := s.checkReadAll("parse", filenames); err != nil {
+					return nil, err
+				}
+				return htmltemplate.ParseFiles(filenames...)
+			}),
+			"ParseGlob": reflect.ValueOf(func(pattern string) (*htmltemplate.Template, error) {
+				filenames, err := s.globFiles(pattern)
+				if err != nil {
+					return nil, err
+				}
+				return htmltemplate.ParseFiles(filenames...)
+			}),
+		},
+		"go/parser": {
+			"ParseFile": reflect.ValueOf(func(fset *token.FileSet, filename string, src interface{}, mode parser.Mode) (*ast.File, error) {
+				if src == nil {
+					// the file is read only without source
+					filename = s.path(filename)
+					if err := s.checkRead("parse", filename); err != nil {
+						return nil, err
+					}
+				}
+				return parser.ParseFile(fset, filename, src, mode)
+			}),
+			"ParseDir": reflect.ValueOf(func(fset *token.FileSet, path string, filter func(fs.FileInfo) bool, mode parser.Mode) (map[string]*ast.Package, error) {
+				path = s.path(path)
+				if err := s.checkRead("parse", path); err != nil {
+					return nil, err
+				}
+				// the files of the directory may be links out of the root
+				entries, err := os.ReadDir(path)
+				if err != nil {
+					return nil, err
+				}
+				for _, entry := range entries {
+					if strings.HasSuffix(entry.Name(), ".go") {
+						if err := s.checkRead("parse", filepath.Join(path, entry.Name())); err != nil {
+							return nil, err
+						}
+					}
+				}
+				return parser.ParseDir(fset, path, filter, mode)
+			}),
+		},
+		"archive/zip": {
+			"OpenReader": reflect.ValueOf(func(name string) (*zip.ReadCloser, error) {
+				name = s.path(name)
+				if err := s.checkRead("open", name); err != nil {
+					return nil, err
+				}
+				return zip.OpenReader(name)
+			}),
+		},
+	}
+}
+
+// glob returns the matches of pattern the sandbox allows reading,
+// relative to the working directory if pattern is.
+func (s *YaegiSandbox) glob(pattern string) ([]string, error) {
+	matches, err := filepath.Glob(s.path(pattern))
+	if err != nil {
+		return nil, err
+	}
+	var allowed []string
+	for _, match := range matches {
+		if s.checkRead("glob", match) == nil {
+			if !filepath.IsAbs(pattern) {
+				match, _ = filepath.Rel(s.Dir, match)
+			}
+			allowed = append(allowed, match)
+		}
+	}
+	return allowed, nil
+}
+
+// path resolves a name a script passed relative to the working directory of the sandbox,
+// without depending on the working directory of the process.
+func (s *YaegiSandbox) path(name string) string {
+	if name == "" || filepath.IsAbs(name) {
+		return name
+	}
+	return filepath.Join(s.Dir, name)
+}
+
+func (s *YaegiSandbox) paths(names []string) []string {
+	result := make([]string, len(names))
+	for i, name := range names {
+		result[i] = s.path(name)
+	}
+	return result
+}
+
+// walkedPath returns path, found by walking the resolved root, as the script
+// would see it walking root from the working directory of the sandbox.
+func (s *YaegiSandbox) walkedPath(root, path string) string {
+	if filepath.IsAbs(root) {
+		return path
+	}
+	rel, err := filepath.Rel(s.path(root), path)
+	if err != nil {
+		return path
+	}
+	return filepath.Join(root, rel)
+}
+
+func (s *YaegiSandbox) globFiles(pattern string) ([]string, error) {
+	filenames, err := s.glob(pattern)
+	if err != nil {
+		return nil, err
+	}
+	if len(filenames) == 0 {
+		return nil, fmt.Errorf("template: pattern matches no files: %#q", pattern)
+	}
+	return s.paths(filenames), nil
+}
+
+func (s *YaegiSandbox) checkReadAll(op string, names []string) error {
+	for _, name := range names {
+		if err := s.checkRead(op, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *YaegiSandbox) checkRead(op, name string) error {
+	if s.has(YaegiCapabilityFsAny) {
+		return nil
+	}
+	return s.checkUnderRoot(op, name, YaegiCapabilityFsAny)
+}
+
+func (s *YaegiSandbox) checkWrite(op, name string) error {
+	if !s.has(YaegiCapabilityFsWrite) {
+		return &YaegiSandboxError{Op: op, Capability: YaegiCapabilityFsWrite}
+	}
+	if s.has(YaegiCapabilityFsAny) {
+		return nil
+	}
+	return s.checkUnderRoot(op, name, YaegiCapabilityFsAny)
+}
+
+func (s *YaegiSandbox) checkUnderRoot(op, name, capability string) error {
+	path, err := resolvePath(name)
+	if err != nil {
+		return err
+	}
+	if rel, err := filepath.Rel(s.Root, path); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return &YaegiSandboxError{Op: op, Path: name, Root: s.Root, Capability: capability}
+	}
+	return nil
+}
+
+// resolvePath returns the absolute path of name with the symbolic links of its existing part resolved,
+// so that a link under the sandbox root cannot point a script outside of it.
+func resolvePath(name string) (string, error) {
+	path, err := filepath.Abs(name)
+	if err != nil {
+		return "", err
+	}
+	var rest []string
+	for {
+		if resolved, err := filepath.EvalSymlinks(path); err == nil {
+			return filepath.Join(append([]string{resolved}, rest...)...), nil
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return filepath.Join(append([]string{path}, rest...)...), nil
+		}
+		rest = append([]string{filepath.Base(path)}, rest...)
+		path = parent
+	}
+}
+
+func tempDir(dir string) string {
+	if dir == "" {
+		return os.TempDir()
+	}
+	return dir
+}
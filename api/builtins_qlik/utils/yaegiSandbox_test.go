@@ -0,0 +1,414 @@
+package utils
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestYaegiSandbox_FileAccess(t *testing.T) {
+	root, err := ioutil.TempDir("", "")
+	assert.NoError(t, err)
+	defer os.RemoveAll(root)
+	outside, err := ioutil.TempDir("", "")
+	assert.NoError(t, err)
+	defer os.RemoveAll(outside)
+
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(root, "in.txt"), []byte("in"), 0644))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(outside, "out.txt"), []byte("out"), 0644))
+	assert.NoError(t, os.Symlink(outside, filepath.Join(root, "escape")))
+
+	script := `package kust
+
+import (
+	"io/ioutil"
+	"os"
+)
+
+func Read(args []string) (*string, error) {
+	b, err := ioutil.ReadFile(args[0])
+	if err != nil {
+		return nil, err
+	}
+	s := string(b)
+	return &s, nil
+}
+
+func Write(args []string) error {
+	return os.WriteFile(args[0], []byte("written"), 0644)
+}
+`
+	testCases := []struct {
+		name         string
+		capabilities []string
+		call         string
+		path         string
+		expectError  string
+	}{
+		{
+			name: "read under root",
+			call: "kust.Read",
+			path: filepath.Join(root, "in.txt"),
+		},
+		{
+			name:        "read outside root",
+			call:        "kust.Read",
+			path:        filepath.Join(outside, "out.txt"),
+			expectError: "outside of root",
+		},
+		{
+			name:        "read through symlink out of root",
+			call:        "kust.Read",
+			path:        filepath.Join(root, "escape", "out.txt"),
+			expectError: "outside of root",
+		},
+		{
+			name:         "read outside root with fs-any",
+			capabilities: []string{YaegiCapabilityFsAny},
+			call:         "kust.Read",
+			path:         filepath.Join(outside, "out.txt"),
+		},
+		{
+			name:        "write without fs-write",
+			call:        "kust.Write",
+			path:        filepath.Join(root, "new.txt"),
+			expectError: "requires capability: fs-write",
+		},
+		{
+			name:         "write under root with fs-write",
+			capabilities: []string{YaegiCapabilityFsWrite},
+			call:         "kust.Write",
+			path:         filepath.Join(root, "new.txt"),
+		},
+		{
+			name:         "write outside root with fs-write",
+			capabilities: []string{YaegiCapabilityFsWrite},
+			call:         "kust.Write",
+			path:         filepath.Join(outside, "new.txt"),
+			expectError:  "requires capability: fs-any",
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			s, err := NewYaegiSandbox(root, testCase.capabilities, "")
+			assert.NoError(t, err)
+			assert.NoError(t, s.Eval(script))
+			numOut := 0
+			if testCase.call == "kust.Read" {
+				numOut = 1
+			}
+			_, err = s.Call(testCase.call, []string{testCase.path}, numOut)
+			if testCase.expectError != "" {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), testCase.expectError)
+				assert.True(t, errors.Is(err, os.ErrPermission))
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestYaegiSandbox_ParserEscape(t *testing.T) {
+	root, err := ioutil.TempDir("", "")
+	assert.NoError(t, err)
+	defer os.RemoveAll(root)
+	outside, err := ioutil.TempDir("", "")
+	assert.NoError(t, err)
+	defer os.RemoveAll(outside)
+
+	assert.NoError(t, os.Mkdir(filepath.Join(root, "pkg"), 0755))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(root, "pkg", "in.go"), []byte("package in\n"), 0644))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(outside, "secret.go"), []byte("package secret\n"), 0644))
+	assert.NoError(t, os.Mkdir(filepath.Join(root, "linked"), 0755))
+	assert.NoError(t, os.Symlink(filepath.Join(outside, "secret.go"), filepath.Join(root, "linked", "secret.go")))
+
+	script := `package kust
+
+import (
+	"go/parser"
+	"go/token"
+)
+
+func ParseFile(args []string) (*string, error) {
+	f, err := parser.ParseFile(token.NewFileSet(), args[0], nil, 0)
+	if err != nil {
+		return nil, err
+	}
+	name := f.Name.Name
+	return &name, nil
+}
+
+func ParseDir(args []string) (*int, error) {
+	pkgs, err := parser.ParseDir(token.NewFileSet(), args[0], nil, 0)
+	if err != nil {
+		return nil, err
+	}
+	n := len(pkgs)
+	return &n, nil
+}
+`
+	testCases := []struct {
+		name        string
+		call        string
+		path        string
+		expectError string
+	}{
+		{name: "parse file under root", call: "kust.ParseFile", path: "pkg/in.go"},
+		{name: "parse file outside root", call: "kust.ParseFile", path: filepath.Join(outside, "secret.go"), expectError: "outside of root"},
+		{name: "parse dir under root", call: "kust.ParseDir", path: "pkg"},
+		{name: "parse dir outside root", call: "kust.ParseDir", path: outside, expectError: "outside of root"},
+		{name: "parse dir linking out of root", call: "kust.ParseDir", path: "linked", expectError: "outside of root"},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			s, err := NewYaegiSandbox(root, nil, "")
+			assert.NoError(t, err)
+			assert.NoError(t, s.Eval(script))
+			_, err = s.Call(testCase.call, []string{testCase.path}, 1)
+			if testCase.expectError != "" {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), testCase.expectError)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestYaegiSandbox_TemplateEscape(t *testing.T) {
+	root, err := ioutil.TempDir("", "")
+	assert.NoError(t, err)
+	defer os.RemoveAll(root)
+	outside, err := ioutil.TempDir("", "")
+	assert.NoError(t, err)
+	defer os.RemoveAll(outside)
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(outside, "secret.txt"), []byte("secret"), 0644))
+
+	testCases := []struct {
+		name   string
+		pkg    string
+		method string
+		arg    string
+	}{
+		{name: "text/template ParseGlob", pkg: "text/template", method: "ParseGlob", arg: filepath.Join(outside, "secr*")},
+		{name: "text/template ParseFiles", pkg: "text/template", method: "ParseFiles", arg: filepath.Join(outside, "secret.txt")},
+		{name: "html/template ParseGlob", pkg: "html/template", method: "ParseGlob", arg: filepath.Join(outside, "secr*")},
+		{name: "html/template ParseFiles", pkg: "html/template", method: "ParseFiles", arg: filepath.Join(outside, "secret.txt")},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			script := `package kust
+
+import (
+	"bytes"
+	"` + testCase.pkg + `"
+)
+
+func Read(args []string) (*string, error) {
+	tmpl, err := template.New("x").` + testCase.method + `(args[0])
+	if err != nil {
+		return nil, err
+	}
+	var b bytes.Buffer
+	if err := tmpl.ExecuteTemplate(&b, "secret.txt", nil); err != nil {
+		return nil, err
+	}
+	s := b.String()
+	return &s, nil
+}
+`
+			s, err := NewYaegiSandbox(root, nil, "")
+			assert.NoError(t, err)
+			err = s.Eval(script)
+			assert.EqualError(t, err, `yaegi sandbox: import "`+testCase.pkg+`" requires capability: fs-any`)
+			assert.True(t, errors.Is(err, os.ErrPermission))
+
+			s, err = NewYaegiSandbox(root, []string{YaegiCapabilityFsAny}, "")
+			assert.NoError(t, err)
+			assert.NoError(t, s.Eval(script))
+			values, err := s.Call("kust.Read", []string{testCase.arg}, 1)
+			assert.NoError(t, err)
+			assert.Equal(t, "secret", *values[0].(*string))
+		})
+	}
+}
+
+func TestYaegiSandbox_Capabilities(t *testing.T) {
+	testCases := []struct {
+		name         string
+		capabilities []string
+		script       string
+		expectError  string
+	}{
+		{
+			name:        "exec withheld",
+			script:      "package kust\nimport \"os/exec\"\nvar _ = exec.Command",
+			expectError: `yaegi sandbox: import "os/exec" requires capability: exec`,
+		},
+		{
+			name:         "exec allowed",
+			capabilities: []string{YaegiCapabilityExec},
+			script:       "package kust\nimport \"os/exec\"\nvar _ = exec.Command",
+		},
+		{
+			name:        "network withheld",
+			script:      "package kust\nimport \"net/http\"\nvar _ = http.Get",
+			expectError: `yaegi sandbox: import "net/http" requires capability: network`,
+		},
+		{
+			name:         "network allowed",
+			capabilities: []string{YaegiCapabilityNetwork},
+			script:       "package kust\nimport \"net/http\"\nvar _ = http.Get",
+		},
+		{
+			name:        "env withheld",
+			script:      "package kust\nimport \"os\"\nvar _ = os.Getenv",
+			expectError: "undefined selector os.Getenv",
+		},
+		{
+			name:         "env allowed",
+			capabilities: []string{YaegiCapabilityEnv},
+			script:       "package kust\nimport \"os\"\nvar _ = os.Getenv",
+		},
+		{
+			name:        "home directory withheld",
+			script:      "package kust\nimport \"os\"\nvar _ = os.UserHomeDir",
+			expectError: "undefined selector os.UserHomeDir",
+		},
+		{
+			name:        "package outside the allowed ones withheld",
+			script:      "package kust\nimport \"os/user\"\nvar _ = user.Current",
+			expectError: `yaegi sandbox: import "os/user" requires capability: unrestricted`,
+		},
+		{
+			name:         "unrestricted",
+			capabilities: []string{YaegiCapabilityUnrestricted},
+			script:       "package kust\nimport (\n\"os\"\n\"os/exec\"\n)\nvar _ = exec.Command\nvar _ = os.Chdir",
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			s, err := NewYaegiSandbox("", testCase.capabilities, "")
+			assert.NoError(t, err)
+			err = s.Eval(testCase.script)
+			if testCase.expectError != "" {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), testCase.expectError)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+
+	_, err := NewYaegiSandbox("", []string{"root"}, "")
+	assert.EqualError(t, err, "unknown yaegi capability: root")
+}
+
+func TestYaegiSandbox_Timeout(t *testing.T) {
+	s, err := NewYaegiSandbox("", nil, "100ms")
+	assert.NoError(t, err)
+	assert.NoError(t, s.Eval(`package kust
+
+func Loop(args []string) error {
+	for {
+	}
+}
+`))
+	_, err = s.Call("kust.Loop", nil, 0)
+	assert.EqualError(t, err, "yaegi script did not complete within timeout: 100ms")
+}
+
+func TestYaegiSandbox_Call(t *testing.T) {
+	s, err := NewYaegiSandbox("", nil, "")
+	assert.NoError(t, err)
+	assert.NoError(t, s.Eval(`package kust
+
+import "errors"
+
+func Echo(args []string) (*[][]byte, error) {
+	var ret [][]byte
+	for _, arg := range args {
+		ret = append(ret, []byte(arg))
+	}
+	return &ret, nil
+}
+
+func Fail(args []string) error {
+	return errors.New("failed: " + args[0])
+}
+`))
+	values, err := s.Call("kust.Echo", []string{"a", `"b"`}, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, &[][]byte{[]byte("a"), []byte(`"b"`)}, values[0])
+
+	_, err = s.Call("kust.Fail", []string{"x"}, 0)
+	assert.EqualError(t, err, "failed: x")
+}
+
+func TestYaegiSandbox_Dir(t *testing.T) {
+	root, err := ioutil.TempDir("", "")
+	assert.NoError(t, err)
+	defer os.RemoveAll(root)
+	assert.NoError(t, os.MkdirAll(filepath.Join(root, "manifests", "base"), 0777))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(root, "manifests", "base", "in.txt"), []byte("in"), 0644))
+
+	s, err := NewYaegiSandbox(root, nil, "")
+	assert.NoError(t, err)
+	s.Dir = filepath.Join(root, "manifests")
+	assert.NoError(t, s.Eval(`package kust
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+func Read(args []string) (*string, error) {
+	b, err := ioutil.ReadFile(args[0])
+	if err != nil {
+		return nil, err
+	}
+	s := string(b)
+	return &s, nil
+}
+
+func Getwd(args []string) (*string, error) {
+	wd, err := os.Getwd()
+	return &wd, err
+}
+
+func Walk(args []string) (*[]string, error) {
+	var paths []string
+	err := filepath.Walk(args[0], func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			paths = append(paths, path)
+		}
+		return err
+	})
+	return &paths, err
+}
+`))
+	wd, err := os.Getwd()
+	assert.NoError(t, err)
+
+	values, err := s.Call("kust.Read", []string{"base/in.txt"}, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, "in", *values[0].(*string))
+
+	values, err = s.Call("kust.Getwd", nil, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(root, "manifests"), *values[0].(*string))
+
+	values, err = s.Call("kust.Walk", []string{"base"}, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join("base", "in.txt")}, *values[0].(*[]string))
+
+	cwd, err := os.Getwd()
+	assert.NoError(t, err)
+	assert.Equal(t, wd, cwd)
+}
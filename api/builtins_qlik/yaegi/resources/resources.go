@@ -0,0 +1,261 @@
+// Package resources is the typed resource API of Yaegi scripts.
+// Scripts work on the kyaml RNodes of the resources directly,
+// so comments and field ordering survive a transformation.
+package resources
+
+import (
+	"fmt"
+	"strings"
+
+	"sigs.k8s.io/kustomize/api/resmap"
+	"sigs.k8s.io/kustomize/api/resource"
+	"sigs.k8s.io/kustomize/api/types"
+	"sigs.k8s.io/kustomize/kyaml/resid"
+	kyaml "sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// ID identifies a resource by its current group, version, kind, name and namespace.
+type ID struct {
+	Group     string
+	Version   string
+	Kind      string
+	Name      string
+	Namespace string
+}
+
+func (id ID) String() string {
+	return id.resId().String()
+}
+
+func (id ID) resId() resid.ResId {
+	return resid.NewResIdWithNamespace(
+		resid.Gvk{Group: id.Group, Version: id.Version, Kind: id.Kind}, id.Name, id.Namespace)
+}
+
+func idOf(r *resource.Resource) ID {
+	curId := r.CurId()
+	return ID{
+		Group:     curId.Group,
+		Version:   curId.Version,
+		Kind:      curId.Kind,
+		Name:      curId.Name,
+		Namespace: curId.Namespace,
+	}
+}
+
+// Selector selects resources. Empty fields match anything,
+// Name and Namespace are regular expressions and
+// LabelSelector and AnnotationSelector follow the kubernetes label selector syntax.
+type Selector struct {
+	Group              string
+	Version            string
+	Kind               string
+	Name               string
+	Namespace          string
+	LabelSelector      string
+	AnnotationSelector string
+}
+
+// Resource is a resource of the build. The embedded RNode is the
+// resource itself, not a copy, so changes to it are changes to the build.
+type Resource struct {
+	*kyaml.RNode
+	res *resource.Resource
+}
+
+// ID returns the current ID of the resource.
+func (r *Resource) ID() ID {
+	return idOf(r.res)
+}
+
+// Get returns the node at the period delimited path, e.g. "spec.template.spec.containers.[name=app].image",
+// or nil if there is none.
+func (r *Resource) Get(path string) (*kyaml.RNode, error) {
+	return r.RNode.Pipe(kyaml.Lookup(splitPath(path)...))
+}
+
+// GetString returns the scalar value at path, or an error if there is none.
+func (r *Resource) GetString(path string) (string, error) {
+	node, err := r.Get(path)
+	if err != nil {
+		return "", err
+	}
+	if node == nil {
+		return "", kyaml.NoFieldError{Field: path}
+	}
+	if err := kyaml.ErrorIfInvalid(node, kyaml.ScalarNode); err != nil {
+		return "", fmt.Errorf("%v: %w", path, err)
+	}
+	return node.YNode().Value, nil
+}
+
+// Set sets the node at path to value, creating the fields leading to it.
+// Value is any Go value that marshals to YAML, e.g. a string, a number,
+// a map or a slice.
+func (r *Resource) Set(path string, value interface{}) error {
+	node, err := kyaml.FromMap(map[string]interface{}{"value": value})
+	if err != nil {
+		return err
+	}
+	node = node.Field("value").Value
+	target, err := r.RNode.Pipe(kyaml.LookupCreate(node.YNode().Kind, splitPath(path)...))
+	if err != nil {
+		return err
+	}
+	if target == nil {
+		return fmt.Errorf("unable to create path: %v", path)
+	}
+	replaceNode(target, node)
+	return nil
+}
+
+// SetString sets the scalar at path to the string value.
+func (r *Resource) SetString(path, value string) error {
+	target, err := r.RNode.Pipe(kyaml.LookupCreate(kyaml.ScalarNode, splitPath(path)...))
+	if err != nil {
+		return err
+	}
+	if target == nil {
+		return fmt.Errorf("unable to create path: %v", path)
+	}
+	replaceNode(target, kyaml.NewStringRNode(value))
+	return nil
+}
+
+// replaceNode replaces the content of target with node, keeping the comments of target.
+func replaceNode(target *kyaml.RNode, node *kyaml.RNode) {
+	n, old := node.YNode(), target.YNode()
+	n.HeadComment, n.LineComment, n.FootComment = old.HeadComment, old.LineComment, old.FootComment
+	target.SetYNode(n)
+}
+
+// Delete removes the field or list element at path. It is not an error if there is none.
+func (r *Resource) Delete(path string) error {
+	fields := splitPath(path)
+	parent, err := r.RNode.Pipe(kyaml.Lookup(fields[:len(fields)-1]...))
+	if err != nil || parent == nil {
+		return err
+	}
+	last := fields[len(fields)-1]
+	if kyaml.IsListIndex(last) {
+		key, value, err := kyaml.SplitIndexNameValue(last)
+		if err != nil {
+			return err
+		}
+		_, err = parent.Pipe(kyaml.ElementSetter{Keys: []string{key}, Values: []string{value}})
+		return err
+	}
+	_, err = parent.Pipe(kyaml.Clear(last))
+	return err
+}
+
+// YAML returns the resource as a YAML document.
+func (r *Resource) YAML() (string, error) {
+	return r.RNode.String()
+}
+
+// Resources is the set of resources a script transforms or generates.
+type Resources struct {
+	m       resmap.ResMap
+	rf      *resource.Factory
+	removed []ID
+}
+
+// New returns the scripting API over m. Changes made by scripts are made to m.
+func New(m resmap.ResMap, rf *resource.Factory) *Resources {
+	return &Resources{m: m, rf: rf}
+}
+
+// All returns all resources in order.
+func (rs *Resources) All() []*Resource {
+	return rs.wrap(rs.m.Resources())
+}
+
+// IDs returns the current IDs of all resources in order.
+func (rs *Resources) IDs() []ID {
+	var ids []ID
+	for _, r := range rs.m.Resources() {
+		ids = append(ids, idOf(r))
+	}
+	return ids
+}
+
+// Select returns the resources matching s in order.
+func (rs *Resources) Select(s Selector) ([]*Resource, error) {
+	selected, err := rs.m.Select(types.Selector{
+		ResId: resid.NewResIdWithNamespace(
+			resid.Gvk{Group: s.Group, Version: s.Version, Kind: s.Kind}, s.Name, s.Namespace),
+		LabelSelector:      s.LabelSelector,
+		AnnotationSelector: s.AnnotationSelector,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rs.wrap(selected), nil
+}
+
+// Get returns the resource with the current ID id, or nil if there is none.
+func (rs *Resources) Get(id ID) *Resource {
+	r, err := rs.m.GetByCurrentId(id.resId())
+	if err != nil {
+		return nil
+	}
+	return &Resource{RNode: r.Node(), res: r}
+}
+
+// Add adds the resources in the YAML documents of y and returns them.
+func (rs *Resources) Add(y string) ([]*Resource, error) {
+	added, err := rs.rf.SliceFromBytes([]byte(y))
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range added {
+		if err := rs.m.Append(r); err != nil {
+			return nil, err
+		}
+	}
+	return rs.wrap(added), nil
+}
+
+// Remove removes the resource with the current ID id and declares it removed.
+func (rs *Resources) Remove(id ID) error {
+	if err := rs.m.Remove(id.resId()); err != nil {
+		return err
+	}
+	rs.removed = append(rs.removed, id)
+	return nil
+}
+
+// Removed returns the IDs of the resources removed so far.
+func (rs *Resources) Removed() []ID {
+	return rs.removed
+}
+
+func (rs *Resources) wrap(list []*resource.Resource) []*Resource {
+	result := make([]*Resource, len(list))
+	for i, r := range list {
+		result[i] = &Resource{RNode: r.Node(), res: r}
+	}
+	return result
+}
+
+// splitPath splits a period delimited path, keeping periods inside list element selectors.
+func splitPath(path string) []string {
+	var fields []string
+	var current strings.Builder
+	depth := 0
+	for _, c := range path {
+		switch {
+		case c == '[':
+			depth++
+		case c == ']' && depth > 0:
+			depth--
+		case c == '.' && depth == 0:
+			fields = append(fields, current.String())
+			current.Reset()
+			continue
+		}
+		current.WriteRune(c)
+	}
+	return append(fields, current.String())
+}
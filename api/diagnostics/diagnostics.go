@@ -0,0 +1,149 @@
+// Copyright 2021 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package diagnostics collects the warnings plugins emit during a build,
+// e.g. a target selector matching nothing, so that they can be reported
+// together instead of being lost in the logs.
+package diagnostics
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Reason is a machine readable code for the cause of a diagnostic.
+type Reason string
+
+const (
+	// ReasonTargetNotFound means that a target selector or name matched no resource.
+	ReasonTargetNotFound Reason = "TargetNotFound"
+	// ReasonObjRefNotFound means that a referenced object or field could not be found.
+	ReasonObjRefNotFound Reason = "ObjRefNotFound"
+	// ReasonIgnoredError means that the plugin carried on after an error.
+	ReasonIgnoredError Reason = "IgnoredError"
+)
+
+// Diagnostic is a warning emitted by a plugin.
+type Diagnostic struct {
+	// Plugin is the kind of the plugin, e.g. SearchReplace.
+	Plugin string `json:"plugin"`
+	// Name is the metadata.name of the plugin config.
+	Name string `json:"name,omitempty"`
+	// ConfigPath is the file holding the plugin config,
+	// or the kustomization directory for inline configs.
+	ConfigPath string `json:"configPath,omitempty"`
+	// Target identifies the resource, or the selector, the diagnostic is about.
+	Target  string `json:"target,omitempty"`
+	Reason  Reason `json:"reason"`
+	Message string `json:"message"`
+}
+
+func (d Diagnostic) String() string {
+	var b strings.Builder
+	b.WriteString(d.Plugin)
+	if d.Name != "" {
+		fmt.Fprintf(&b, " %s", d.Name)
+	}
+	if d.ConfigPath != "" {
+		fmt.Fprintf(&b, " (%s)", d.ConfigPath)
+	}
+	fmt.Fprintf(&b, ": %s", d.Reason)
+	if d.Target != "" {
+		fmt.Fprintf(&b, " [%s]", d.Target)
+	}
+	fmt.Fprintf(&b, ": %s", d.Message)
+	return b.String()
+}
+
+// Collector accumulates the diagnostics of a build.
+// All methods are safe on a nil receiver, which discards diagnostics.
+type Collector struct {
+	mu          sync.Mutex
+	diagnostics []Diagnostic
+}
+
+// NewCollector returns an empty Collector.
+func NewCollector() *Collector {
+	return &Collector{}
+}
+
+// Add records d, dropping exact duplicates, e.g. from a plugin
+// that is run once per base.
+func (c *Collector) Add(d Diagnostic) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, existing := range c.diagnostics {
+		if existing == d {
+			return
+		}
+	}
+	c.diagnostics = append(c.diagnostics, d)
+}
+
+// Diagnostics returns the recorded diagnostics ordered by config path,
+// plugin and name, keeping the emission order within a plugin.
+func (c *Collector) Diagnostics() []Diagnostic {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	result := append([]Diagnostic{}, c.diagnostics...)
+	sort.SliceStable(result, func(i, j int) bool {
+		if result[i].ConfigPath != result[j].ConfigPath {
+			return result[i].ConfigPath < result[j].ConfigPath
+		}
+		if result[i].Plugin != result[j].Plugin {
+			return result[i].Plugin < result[j].Plugin
+		}
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
+// Reporter emits the diagnostics of one plugin into a Collector.
+// All methods are safe on a nil receiver, which discards diagnostics.
+type Reporter struct {
+	collector  *Collector
+	plugin     string
+	name       string
+	configPath string
+}
+
+// NewReporter returns a Reporter for the plugin of the given kind and name,
+// configured in configPath. It returns nil if c is nil.
+func NewReporter(c *Collector, plugin, name, configPath string) *Reporter {
+	if c == nil {
+		return nil
+	}
+	return &Reporter{collector: c, plugin: plugin, name: name, configPath: configPath}
+}
+
+// Warnf emits a diagnostic about target.
+func (r *Reporter) Warnf(target string, reason Reason, format string, args ...interface{}) {
+	if r == nil {
+		return
+	}
+	r.collector.Add(Diagnostic{
+		Plugin:     r.plugin,
+		Name:       r.name,
+		ConfigPath: r.configPath,
+		Target:     target,
+		Reason:     reason,
+		Message:    fmt.Sprintf(format, args...),
+	})
+}
+
+// ErrStrict is returned by strict builds that emitted diagnostics.
+type ErrStrict struct {
+	Diagnostics []Diagnostic
+}
+
+func (e *ErrStrict) Error() string {
+	return fmt.Sprintf("strict build failed: plugins emitted %d warning(s)", len(e.Diagnostics))
+}
@@ -0,0 +1,182 @@
+// Copyright 2021 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package replacement
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"sigs.k8s.io/kustomize/api/internal/utils"
+	"sigs.k8s.io/kustomize/api/types"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// The encodings and formats of FieldOptions.
+const (
+	encodingNone   = "none"
+	encodingBase64 = "base64"
+	encodingURL    = "url"
+	encodingHex    = "hex"
+	encodingJSON   = "json"
+
+	formatJSON = "json"
+	formatYAML = "yaml"
+)
+
+// isEncoded tells if the field is read and written through its encoding or format.
+func isEncoded(options *types.FieldOptions) bool {
+	return options != nil && (options.Format != "" ||
+		(options.Encoding != "" && !strings.EqualFold(options.Encoding, encodingNone)))
+}
+
+func decode(encoding, value string) (string, error) {
+	switch strings.ToLower(encoding) {
+	case "", encodingNone:
+		return value, nil
+	case encodingBase64:
+		decoded, err := base64.StdEncoding.DecodeString(value)
+		return string(decoded), err
+	case encodingURL:
+		return url.QueryUnescape(value)
+	case encodingHex:
+		decoded, err := hex.DecodeString(value)
+		return string(decoded), err
+	case encodingJSON:
+		var decoded string
+		err := json.Unmarshal([]byte(value), &decoded)
+		return decoded, err
+	default:
+		return "", fmt.Errorf("unsupported encoding %s", encoding)
+	}
+}
+
+func encode(encoding, value string) (string, error) {
+	switch strings.ToLower(encoding) {
+	case "", encodingNone:
+		return value, nil
+	case encodingBase64:
+		return base64.StdEncoding.EncodeToString([]byte(value)), nil
+	case encodingURL:
+		return url.QueryEscape(value), nil
+	case encodingHex:
+		return hex.EncodeToString([]byte(value)), nil
+	case encodingJSON:
+		encoded, err := json.Marshal(value)
+		return string(encoded), err
+	default:
+		return "", fmt.Errorf("unsupported encoding %s", encoding)
+	}
+}
+
+// parseEmbedded parses the document of a field in format.
+func parseEmbedded(format, value string) (*yaml.RNode, error) {
+	switch strings.ToLower(format) {
+	case formatJSON, formatYAML:
+		return yaml.Parse(value)
+	default:
+		return nil, fmt.Errorf("unsupported format %s", format)
+	}
+}
+
+// formatEmbedded writes the document of a field in format.
+func formatEmbedded(format string, doc *yaml.RNode) (string, error) {
+	if strings.EqualFold(format, formatJSON) {
+		value, err := doc.MarshalJSON()
+		return string(value), err
+	}
+	return doc.String()
+}
+
+// getDecodedValue returns the value of rn, decoded, or the value at the
+// embedded path of the document it holds.
+func getDecodedValue(options *types.FieldOptions, rn *yaml.RNode) (*yaml.RNode, error) {
+	if rn.YNode().Kind != yaml.ScalarNode {
+		return nil, fmt.Errorf("encoding and format options can only be used with scalar nodes")
+	}
+	value, err := decode(options.Encoding, yaml.GetValue(rn))
+	if err != nil {
+		return nil, fmt.Errorf("decoding %s value: %w", options.Encoding, err)
+	}
+	if options.Format == "" {
+		n := rn.Copy()
+		n.YNode().Value = value
+		n.YNode().Tag = yaml.NodeTagString
+		return n, nil
+	}
+	doc, err := parseEmbedded(options.Format, value)
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s document: %w", options.Format, err)
+	}
+	if options.EmbeddedPath == "" {
+		return doc, nil
+	}
+	n, err := doc.Pipe(yaml.Lookup(utils.FieldPathSplitter(options.EmbeddedPath)...))
+	if err != nil {
+		return nil, err
+	}
+	if n == nil {
+		return nil, fmt.Errorf("embeddedPath %s not found in %s document", options.EmbeddedPath, options.Format)
+	}
+	return n, nil
+}
+
+// setEncodedTargetValue sets value in t, encoded, or at the embedded path
+// of the document t holds.
+func setEncodedTargetValue(options *types.FieldOptions, t *yaml.RNode, value *yaml.RNode) error {
+	if t.YNode().Kind != yaml.ScalarNode {
+		return fmt.Errorf("encoding and format options can only be used with scalar nodes")
+	}
+	decoded, err := decode(options.Encoding, t.YNode().Value)
+	if err != nil {
+		return fmt.Errorf("decoding %s value: %w", options.Encoding, err)
+	}
+	delimiterOptions := &types.FieldOptions{Delimiter: options.Delimiter, Index: options.Index}
+	if options.Format == "" {
+		if value.YNode().Kind != yaml.ScalarNode {
+			return fmt.Errorf("encoding option can only be used to set scalar values")
+		}
+		n := yaml.NewScalarRNode(decoded)
+		if err := setTargetValue(delimiterOptions, n, value.Copy()); err != nil {
+			return err
+		}
+		decoded = n.YNode().Value
+	} else {
+		doc, err := parseEmbedded(options.Format, decoded)
+		if err != nil {
+			return fmt.Errorf("parsing %s document: %w", options.Format, err)
+		}
+		embedded := doc
+		if options.EmbeddedPath != "" {
+			path := utils.FieldPathSplitter(options.EmbeddedPath)
+			if options.Create {
+				embedded, err = doc.Pipe(yaml.LookupCreate(value.YNode().Kind, path...))
+			} else {
+				embedded, err = doc.Pipe(yaml.Lookup(path...))
+			}
+			if err != nil {
+				return err
+			}
+			if embedded == nil {
+				return nil
+			}
+		}
+		if err := setTargetValue(delimiterOptions, embedded, value.Copy()); err != nil {
+			return err
+		}
+		if decoded, err = formatEmbedded(options.Format, doc); err != nil {
+			return err
+		}
+	}
+	encoded, err := encode(options.Encoding, decoded)
+	if err != nil {
+		return err
+	}
+	t.YNode().Value = encoded
+	t.YNode().Tag = yaml.NodeTagString
+	return nil
+}
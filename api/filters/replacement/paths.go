@@ -0,0 +1,162 @@
+// Copyright 2021 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package replacement
+
+import (
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// The segments of a field path that match several fields.
+const (
+	// wildcard matches every element of a sequence or every value of a map.
+	wildcard        = "*"
+	wildcardElement = "[*]"
+	// recursiveDescent matches the node and all of the nodes nested in it.
+	recursiveDescent = "**"
+)
+
+// isPattern tells if the segment of a field path may match several fields,
+// i.e. it is a wildcard, a recursive descent or a [name=value] matcher
+// whose value is a glob, e.g. [name=sidecar-*].
+func isPattern(segment string) bool {
+	switch segment {
+	case wildcard, wildcardElement, recursiveDescent:
+		return true
+	}
+	if !yaml.IsListIndex(segment) {
+		return false
+	}
+	_, value, err := yaml.SplitIndexNameValue(segment)
+	return err == nil && strings.Contains(value, "*")
+}
+
+func hasPattern(path []string) bool {
+	for _, segment := range path {
+		if isPattern(segment) {
+			return true
+		}
+	}
+	return false
+}
+
+// lookupAll returns the fields at path in node. The fields after the last
+// pattern segment of path are created when create is set, as a kind node
+// at the end of the path.
+func lookupAll(node *yaml.RNode, path []string, create bool, kind yaml.Kind) ([]*yaml.RNode, error) {
+	var result []*yaml.RNode
+	seen := map[*yaml.Node]bool{}
+	err := walkPath(node, path, create, kind, func(rn *yaml.RNode) {
+		if !seen[rn.YNode()] {
+			seen[rn.YNode()] = true
+			result = append(result, rn)
+		}
+	})
+	return result, err
+}
+
+func walkPath(node *yaml.RNode, path []string, create bool, kind yaml.Kind, visit func(*yaml.RNode)) error {
+	if !hasPattern(path) {
+		var rn *yaml.RNode
+		var err error
+		if create {
+			rn, err = node.Pipe(yaml.LookupCreate(kind, path...))
+		} else {
+			rn, err = node.Pipe(yaml.Lookup(path...))
+		}
+		if err != nil || rn == nil {
+			return err
+		}
+		visit(rn)
+		return nil
+	}
+	segment, rest := path[0], path[1:]
+	switch {
+	case segment == recursiveDescent:
+		if canLookup(node, rest) {
+			if err := walkPath(node, rest, false, kind, visit); err != nil {
+				return err
+			}
+		}
+		for _, child := range children(node) {
+			if err := walkPath(child, path, false, kind, visit); err != nil {
+				return err
+			}
+		}
+	case segment == wildcard || segment == wildcardElement:
+		for _, child := range children(node) {
+			if !canLookup(child, rest) {
+				continue
+			}
+			if err := walkPath(child, rest, create, kind, visit); err != nil {
+				return err
+			}
+		}
+	case isPattern(segment):
+		name, value, err := yaml.SplitIndexNameValue(segment)
+		if err != nil {
+			return err
+		}
+		if node.YNode().Kind != yaml.SequenceNode {
+			return nil
+		}
+		for _, element := range children(node) {
+			field := element
+			if name != "" {
+				mapNode := element.Field(name)
+				if mapNode == nil {
+					continue
+				}
+				field = mapNode.Value
+			}
+			if ok, err := filepath.Match(value, yaml.GetValue(field)); err != nil {
+				return err
+			} else if ok {
+				if err := walkPath(element, rest, create, kind, visit); err != nil {
+					return err
+				}
+			}
+		}
+	default:
+		child, err := node.Pipe(yaml.Lookup(segment))
+		if err != nil || child == nil {
+			return err
+		}
+		return walkPath(child, rest, create, kind, visit)
+	}
+	return nil
+}
+
+// canLookup tells if path can be looked up in node, i.e. node is a map
+// when path starts with a field and a sequence when it starts with an element.
+// The fields matched by patterns may be of any kind.
+func canLookup(node *yaml.RNode, path []string) bool {
+	if len(path) == 0 || isPattern(path[0]) {
+		return true
+	}
+	if _, err := strconv.Atoi(path[0]); err == nil || path[0] == "-" || yaml.IsListIndex(path[0]) {
+		return node.YNode().Kind == yaml.SequenceNode
+	}
+	return node.YNode().Kind == yaml.MappingNode
+}
+
+// children returns the elements of a sequence node or the values of a map node.
+func children(node *yaml.RNode) []*yaml.RNode {
+	var result []*yaml.RNode
+	switch node.YNode().Kind {
+	case yaml.SequenceNode:
+		for _, n := range node.Content() {
+			result = append(result, yaml.NewRNode(n))
+		}
+	case yaml.MappingNode:
+		content := node.Content()
+		for i := 1; i < len(content); i += 2 {
+			result = append(result, yaml.NewRNode(content[i]))
+		}
+	}
+	return result
+}
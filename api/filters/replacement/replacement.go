@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"strings"
 
+	"sigs.k8s.io/kustomize/api/internal/utils"
 	"sigs.k8s.io/kustomize/api/types"
 	"sigs.k8s.io/kustomize/kyaml/resid"
 	"sigs.k8s.io/kustomize/kyaml/yaml"
@@ -65,20 +66,25 @@ func rejectId(rejects []*types.Selector, id *resid.ResId) bool {
 }
 
 func applyToNode(node *yaml.RNode, value *yaml.RNode, target *types.TargetSelector) error {
-	for _, fp := range target.FieldPaths {
-		fieldPath := strings.Split(fp, ".")
-		var t *yaml.RNode
+	if target.Options != nil {
 		var err error
-		if target.Options != nil && target.Options.Create {
-			t, err = node.Pipe(yaml.LookupCreate(value.YNode().Kind, fieldPath...))
-		} else {
-			t, err = node.Pipe(yaml.Lookup(fieldPath...))
+		if value, err = transformValue(target.Options.Transforms, value); err != nil {
+			return err
+		}
+	}
+	for _, fp := range target.FieldPaths {
+		fieldPath := utils.FieldPathSplitter(fp)
+		var kind yaml.Kind
+		create := target.Options != nil && target.Options.Create
+		if create {
+			kind = value.YNode().Kind
 		}
+		ts, err := lookupAll(node, fieldPath, create, kind)
 		if err != nil {
 			return err
 		}
-		if t != nil {
-			if err = setTargetValue(target.Options, t, value); err != nil {
+		for _, t := range ts {
+			if err = setTargetValue(target.Options, t, value.Copy()); err != nil {
 				return err
 			}
 		}
@@ -87,6 +93,9 @@ func applyToNode(node *yaml.RNode, value *yaml.RNode, target *types.TargetSelect
 }
 
 func setTargetValue(options *types.FieldOptions, t *yaml.RNode, value *yaml.RNode) error {
+	if isEncoded(options) {
+		return setEncodedTargetValue(options, t, value)
+	}
 	if options != nil && options.Delimiter != "" {
 
 		if t.YNode().Kind != yaml.ScalarNode {
@@ -119,12 +128,19 @@ func getReplacement(nodes []*yaml.RNode, r *types.Replacement) (*yaml.RNode, err
 	if r.Source.FieldPath == "" {
 		r.Source.FieldPath = types.DefaultReplacementFieldPath
 	}
-	fieldPath := strings.Split(r.Source.FieldPath, ".")
+	fieldPath := utils.FieldPathSplitter(r.Source.FieldPath)
 
-	rn, err := source.Pipe(yaml.Lookup(fieldPath...))
+	matches, err := lookupAll(source, fieldPath, false, 0)
 	if err != nil {
 		return nil, err
 	}
+	if len(matches) > 1 {
+		return nil, fmt.Errorf("multiple matches for fieldPath %s of source %s", r.Source.FieldPath, r.Source.ResId)
+	}
+	var rn *yaml.RNode
+	if len(matches) == 1 {
+		rn = matches[0]
+	}
 	if !rn.IsNilOrEmpty() {
 		return getRefinedValue(r.Source.Options, rn)
 	}
@@ -132,19 +148,28 @@ func getReplacement(nodes []*yaml.RNode, r *types.Replacement) (*yaml.RNode, err
 }
 
 func getRefinedValue(options *types.FieldOptions, rn *yaml.RNode) (*yaml.RNode, error) {
-	if options == nil || options.Delimiter == "" {
-		return rn, nil
+	if isEncoded(options) {
+		var err error
+		if rn, err = getDecodedValue(options, rn); err != nil {
+			return nil, err
+		}
 	}
-	if rn.YNode().Kind != yaml.ScalarNode {
-		return nil, fmt.Errorf("delimiter option can only be used with scalar nodes")
+	if options == nil {
+		return rn, nil
 	}
-	value := strings.Split(yaml.GetValue(rn), options.Delimiter)
-	if options.Index >= len(value) || options.Index < 0 {
-		return nil, fmt.Errorf("options.index %d is out of bounds for value %s", options.Index, yaml.GetValue(rn))
+	if options.Delimiter != "" {
+		if rn.YNode().Kind != yaml.ScalarNode {
+			return nil, fmt.Errorf("delimiter option can only be used with scalar nodes")
+		}
+		value := strings.Split(yaml.GetValue(rn), options.Delimiter)
+		if options.Index >= len(value) || options.Index < 0 {
+			return nil, fmt.Errorf("options.index %d is out of bounds for value %s", options.Index, yaml.GetValue(rn))
+		}
+		n := rn.Copy()
+		n.YNode().Value = value[options.Index]
+		rn = n
 	}
-	n := rn.Copy()
-	n.YNode().Value = value[options.Index]
-	return n, nil
+	return transformValue(options.Transforms, rn)
 }
 
 // selectSourceNode finds the node that matches the selector, returning
@@ -1252,6 +1252,477 @@ metadata:
   name: deploy2
 `,
 		},
+		"base64 encoding": {
+			input: `apiVersion: v1
+kind: Secret
+metadata:
+  name: db
+data:
+  user: YWRtaW4=
+  url: ""
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: cfg
+data:
+  url: postgres://db:5432
+`,
+			replacements: `replacements:
+- source:
+    kind: Secret
+    name: db
+    fieldPath: data.user
+    options:
+      encoding: base64
+  targets:
+  - select:
+      kind: ConfigMap
+    fieldPaths:
+    - data.url
+    options:
+      delimiter: "//"
+      index: 1
+      encoding: url
+- source:
+    kind: ConfigMap
+    name: cfg
+    fieldPath: data.url
+    options:
+      encoding: url
+  targets:
+  - select:
+      kind: Secret
+    fieldPaths:
+    - data.url
+    options:
+      encoding: base64
+`,
+			expected: `apiVersion: v1
+kind: Secret
+metadata:
+  name: db
+data:
+  user: YWRtaW4=
+  url: "cG9zdGdyZXM6Ly9hZG1pbg=="
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: cfg
+data:
+  url: postgres%3A%2F%2Fadmin
+`,
+		},
+		"hex and json encodings": {
+			input: `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: source
+data:
+  value: 68656c6c6f
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: target
+data:
+  value: '"old"'
+`,
+			replacements: `replacements:
+- source:
+    kind: ConfigMap
+    name: source
+    fieldPath: data.value
+    options:
+      encoding: hex
+  targets:
+  - select:
+      name: target
+    fieldPaths:
+    - data.value
+    options:
+      encoding: json
+`,
+			expected: `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: source
+data:
+  value: 68656c6c6f
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: target
+data:
+  value: '"hello"'
+`,
+		},
+		"embedded documents": {
+			input: `apiVersion: v1
+kind: Service
+metadata:
+  name: db
+  namespace: data
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: app
+data:
+  application.yaml: |
+    server:
+      port: 8080
+  settings.json: '{"db":{"host":"localhost","port":5432}}'
+`,
+			replacements: `replacements:
+- source:
+    kind: Service
+    name: db
+  targets:
+  - select:
+      kind: ConfigMap
+    fieldPaths:
+    - data.application\.yaml
+    options:
+      format: yaml
+      embeddedPath: db.host
+      create: true
+  - select:
+      kind: ConfigMap
+    fieldPaths:
+    - data.settings\.json
+    options:
+      format: json
+      embeddedPath: db.host
+- source:
+    kind: ConfigMap
+    name: app
+    fieldPath: data.settings\.json
+    options:
+      format: json
+      embeddedPath: db.port
+  targets:
+  - select:
+      kind: ConfigMap
+    fieldPaths:
+    - data.application\.yaml
+    options:
+      format: yaml
+      embeddedPath: server.port
+`,
+			expected: `apiVersion: v1
+kind: Service
+metadata:
+  name: db
+  namespace: data
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: app
+data:
+  application.yaml: |
+    server:
+      port: 5432
+    db:
+      host: db
+  settings.json: '{"db":{"host":"db","port":5432}}'
+`,
+		},
+		"unsupported encoding": {
+			input: `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: cfg
+data:
+  value: foo
+`,
+			replacements: `replacements:
+- source:
+    kind: ConfigMap
+    name: cfg
+    fieldPath: data.value
+    options:
+      encoding: rot13
+  targets:
+  - select:
+      kind: ConfigMap
+    fieldPaths:
+    - data.value
+`,
+			expectedErr: "decoding rot13 value: unsupported encoding rot13",
+		},
+		"wildcards and matchers fan out to every matching field": {
+			input: `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: registry
+data:
+  host: registry.example.com
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+spec:
+  template:
+    spec:
+      initContainers:
+      - image: busybox:1.0
+        name: init
+      containers:
+      - image: nginx:1.7.9
+        name: nginx
+      - image: sidecar:2.0
+        name: sidecar-log
+      - image: sidecar:2.0
+        name: sidecar-metrics
+---
+apiVersion: batch/v1
+kind: Job
+metadata:
+  name: migrate
+spec:
+  template:
+    spec:
+      containers:
+      - image: migrate:3.1
+        name: migrate
+`,
+			replacements: `replacements:
+- source:
+    kind: ConfigMap
+    name: registry
+    fieldPath: data.host
+  targets:
+  - select:
+      kind: Deployment
+    fieldPaths:
+    - spec.template.spec.containers.[*].image
+    - spec.template.spec.initContainers.*.image
+    options:
+      delimiter: /
+      index: -1
+  - select:
+      kind: Job
+    fieldPaths:
+    - spec.template.spec.containers.[name=migr*].image
+    options:
+      delimiter: /
+      index: -1
+`,
+			expected: `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: registry
+data:
+  host: registry.example.com
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+spec:
+  template:
+    spec:
+      initContainers:
+      - image: registry.example.com/busybox:1.0
+        name: init
+      containers:
+      - image: registry.example.com/nginx:1.7.9
+        name: nginx
+      - image: registry.example.com/sidecar:2.0
+        name: sidecar-log
+      - image: registry.example.com/sidecar:2.0
+        name: sidecar-metrics
+---
+apiVersion: batch/v1
+kind: Job
+metadata:
+  name: migrate
+spec:
+  template:
+    spec:
+      containers:
+      - image: registry.example.com/migrate:3.1
+        name: migrate
+`,
+		},
+		"recursive descent": {
+			input: `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: source
+data:
+  tag: "2.0"
+---
+apiVersion: v1
+kind: Custom
+metadata:
+  name: target
+spec:
+  frontend:
+    tag: "1.0"
+  backend:
+    workers:
+    - tag: "1.0"
+    - name: worker
+      tag: "1.0"
+  tagged: false
+`,
+			replacements: `replacements:
+- source:
+    kind: ConfigMap
+    name: source
+    fieldPath: "**.tag"
+  targets:
+  - select:
+      kind: Custom
+    fieldPaths:
+    - spec.**.tag
+`,
+			expected: `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: source
+data:
+  tag: "2.0"
+---
+apiVersion: v1
+kind: Custom
+metadata:
+  name: target
+spec:
+  frontend:
+    tag: "2.0"
+  backend:
+    workers:
+    - tag: "2.0"
+    - name: worker
+      tag: "2.0"
+  tagged: false
+`,
+		},
+		"multiple matches for source field path": {
+			input: `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: source
+data:
+  a: "1"
+  b: "2"
+`,
+			replacements: `replacements:
+- source:
+    kind: ConfigMap
+    fieldPath: data.*
+  targets:
+  - select:
+      kind: ConfigMap
+`,
+			expectedErr: "multiple matches for fieldPath data.* of source ~G_~V_ConfigMap|~X|~N",
+		},
+		"value transforms": {
+			input: `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: source
+data:
+  version: v1.2.3
+  port: "8080"
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: app
+  annotations:
+    release: none
+    url: none
+spec:
+  ports:
+  - port: 80
+`,
+			replacements: `replacements:
+- source:
+    kind: ConfigMap
+    fieldPath: data.version
+    options:
+      transforms:
+      - regex: ^v(\d+)\.(\d+)\..*$
+        replace: ${1}.${2}
+  targets:
+  - select:
+      kind: Service
+    fieldPaths:
+    - metadata.annotations.release
+    options:
+      transforms:
+      - prefix: release-
+        suffix: -stable
+  - select:
+      kind: Service
+    fieldPaths:
+    - metadata.annotations.url
+    options:
+      transforms:
+      - format: https://docs.example.com/%s/index.html
+- source:
+    kind: ConfigMap
+    fieldPath: data.port
+  targets:
+  - select:
+      kind: Service
+    fieldPaths:
+    - spec.ports.[*].port
+    options:
+      transforms:
+      - regex: "0$"
+        replace: "1"
+`,
+			expected: `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: source
+data:
+  version: v1.2.3
+  port: "8080"
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: app
+  annotations:
+    release: release-1.2-stable
+    url: https://docs.example.com/1.2/index.html
+spec:
+  ports:
+  - port: "8081"
+`,
+		},
+		"invalid value transform": {
+			input: `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: source
+data:
+  a: "1"
+`,
+			replacements: `replacements:
+- source:
+    kind: ConfigMap
+    fieldPath: data.a
+    options:
+      transforms:
+      - prefix: x
+        format: "%s"
+  targets:
+  - select:
+      kind: ConfigMap
+`,
+			expectedErr: "transforms[0]: exactly one of prefix and suffix, regex or format must be set",
+		},
 		"complex type with delimiter in source": {
 			input: `apiVersion: v1
 kind: Pod
@@ -0,0 +1,59 @@
+// Copyright 2021 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package replacement
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"sigs.k8s.io/kustomize/api/types"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// transformValue returns a copy of rn with its value transformed by the
+// steps of transforms, in order.
+func transformValue(transforms []types.ValueTransform, rn *yaml.RNode) (*yaml.RNode, error) {
+	if len(transforms) == 0 || rn == nil {
+		return rn, nil
+	}
+	if rn.YNode().Kind != yaml.ScalarNode {
+		return nil, fmt.Errorf("transforms can only be used with scalar values")
+	}
+	value := yaml.GetValue(rn)
+	for i, t := range transforms {
+		var err error
+		if value, err = transformString(t, value); err != nil {
+			return nil, fmt.Errorf("transforms[%d]: %w", i, err)
+		}
+	}
+	n := rn.Copy()
+	n.YNode().Value = value
+	if n.YNode().Tag != yaml.NodeTagString {
+		// The type of the value, e.g. an int, may not hold once transformed.
+		n.YNode().Tag = ""
+	}
+	return n, nil
+}
+
+func transformString(t types.ValueTransform, value string) (string, error) {
+	affix := t.Prefix != "" || t.Suffix != ""
+	switch {
+	case affix && t.Regex == "" && t.Format == "":
+		return t.Prefix + value + t.Suffix, nil
+	case t.Regex != "" && !affix && t.Format == "":
+		re, err := regexp.Compile(t.Regex)
+		if err != nil {
+			return "", err
+		}
+		return re.ReplaceAllString(value, t.Replace), nil
+	case t.Format != "" && !affix && t.Regex == "":
+		if !strings.Contains(t.Format, "%s") {
+			return "", fmt.Errorf("format %q must contain %%s", t.Format)
+		}
+		return strings.ReplaceAll(t.Format, "%s", value), nil
+	default:
+		return "", fmt.Errorf("exactly one of prefix and suffix, regex or format must be set")
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright 2021 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package helmvalues collects the effective values of the HelmCharts of a
+// build, once every HelmValues transformer merged its values in, with the
+// config that set each of them, instead of the charts being rendered.
+package helmvalues
+
+import (
+	"sort"
+	"sync"
+)
+
+// ChartValues are the effective values of a HelmChart, once every HelmValues
+// transformer ran, with the config file that set each of them.
+type ChartValues struct {
+	ChartName   string                 `json:"chartName" yaml:"chartName"`
+	ReleaseName string                 `json:"releaseName,omitempty" yaml:"releaseName,omitempty"`
+	ConfigPath  string                 `json:"configPath,omitempty" yaml:"configPath,omitempty"`
+	Values      map[string]interface{} `json:"values,omitempty" yaml:"values,omitempty"`
+	Origins     map[string]string      `json:"origins,omitempty" yaml:"origins,omitempty"`
+}
+
+// Report collects the values of the HelmCharts of a build.
+// All methods are safe on a nil receiver, which records nothing.
+type Report struct {
+	mu      sync.Mutex
+	charts  []ChartValues
+	origins map[string]map[string]string
+}
+
+// NewReport returns an empty Report.
+func NewReport() *Report {
+	return &Report{origins: make(map[string]map[string]string)}
+}
+
+// Record adds the values of a HelmChart to the report.
+func (r *Report) Record(v ChartValues) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.charts = append(r.charts, v)
+}
+
+// RecordOrigins records the origins of the values of the HelmChart resource
+// named name, for HelmCharts that are output by the build instead of being generated.
+func (r *Report) RecordOrigins(name string, origins map[string]string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.origins[name] = origins
+}
+
+// Origins returns the origins last recorded for the HelmChart resource named name.
+func (r *Report) Origins(name string) map[string]string {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.origins[name]
+}
+
+// Charts returns the recorded values, ordered by chart and release name.
+func (r *Report) Charts() []ChartValues {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	result := append([]ChartValues{}, r.charts...)
+	sort.SliceStable(result, func(i, j int) bool {
+		if result[i].ChartName != result[j].ChartName {
+			return result[i].ChartName < result[j].ChartName
+		}
+		return result[i].ReleaseName < result[j].ReleaseName
+	})
+	return result
+}
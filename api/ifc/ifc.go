@@ -38,6 +38,22 @@ type Loader interface {
 	Cleanup() error
 }
 
+// Lockfile pins the remote inputs of a build, e.g. helm charts and git
+// refs, in kustomization.lock and records what they resolved to.
+// A nil Lockfile stands for a build without a lock file.
+type Lockfile interface {
+	// Path returns the location of the lock file.
+	Path() string
+	// LockedChart returns the pin for a chart, if there is one.
+	LockedChart(repo, name, requestedVersion string) (types.ChartLock, bool)
+	// RecordChart records the chart a build resolved.
+	RecordChart(c types.ChartLock) error
+	// LockedGit returns the commit pinned for a git ref, if there is one.
+	LockedGit(url, ref string) (string, bool)
+	// RecordGit records the commit a git ref resolved to.
+	RecordGit(url, ref, commit string) error
+}
+
 // KustHasher returns a hash of the argument
 // or an error.
 type KustHasher interface {
@@ -5,6 +5,7 @@ package git
 
 import (
 	"sigs.k8s.io/kustomize/api/filesys"
+	"sigs.k8s.io/kustomize/api/ifc"
 )
 
 // Cloner is a function that can clone a git repo.
@@ -13,7 +14,14 @@ type Cloner func(repoSpec *RepoSpec) error
 // ClonerUsingGitExec uses a local git install, as opposed
 // to say, some remote API, to obtain a local clone of
 // a remote repo.
+// The commit that was checked out is set in the repoSpec.
 func ClonerUsingGitExec(repoSpec *RepoSpec) error {
+	return cloneUsingGitExec(repoSpec, nil)
+}
+
+// cloneUsingGitExec is ClonerUsingGitExec taking the commit from, when already
+// pinned, and recording it in the lock file lf.
+func cloneUsingGitExec(repoSpec *RepoSpec, lf ifc.Lockfile) error {
 	r, err := newCmdRunner()
 	if err != nil {
 		return err
@@ -30,13 +38,27 @@ func ClonerUsingGitExec(repoSpec *RepoSpec) error {
 	if repoSpec.Ref != "" {
 		ref = repoSpec.Ref
 	}
+	if lf != nil {
+		if commit, locked := lf.LockedGit(repoSpec.CloneSpec(), repoSpec.Ref); locked {
+			ref = commit
+		}
+	}
 	if err = r.run("fetch", "--depth=1", "origin", ref); err != nil {
 		return err
 	}
 	if err = r.run("checkout", "FETCH_HEAD"); err != nil {
 		return err
 	}
-	return r.run("submodule", "update", "--init", "--recursive")
+	if err = r.run("submodule", "update", "--init", "--recursive"); err != nil {
+		return err
+	}
+	if repoSpec.Commit, err = r.output("rev-parse", "HEAD"); err != nil {
+		return err
+	}
+	if lf == nil {
+		return nil
+	}
+	return lf.RecordGit(repoSpec.CloneSpec(), repoSpec.Ref, repoSpec.Commit)
 }
 
 // DoNothingCloner returns a cloner that only sets
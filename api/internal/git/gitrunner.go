@@ -5,6 +5,7 @@ package git
 
 import (
 	"os/exec"
+	"strings"
 	"time"
 
 	"github.com/pkg/errors"
@@ -56,3 +57,23 @@ func (r gitRunner) run(args ...string) error {
 			return err
 		})
 }
+
+// output runs a command with a timeout and returns its trimmed stdout.
+func (r gitRunner) output(args ...string) (string, error) {
+	var out []byte
+	//nolint: gosec
+	cmd := exec.Command(r.gitProgram, args...)
+	cmd.Dir = r.dir.String()
+	err := utils.TimedCall(
+		cmd.String(),
+		r.duration,
+		func() error {
+			var err error
+			out, err = cmd.Output()
+			if err != nil {
+				return errors.Wrapf(err, "git cmd = '%s'", cmd.String())
+			}
+			return nil
+		})
+	return strings.TrimSpace(string(out)), err
+}
@@ -0,0 +1,430 @@
+// Copyright 2021 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package git
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/go-git/go-billy/v5/osfs"
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/cache"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/plumbing/transport/client"
+	"github.com/go-git/go-git/v5/plumbing/transport/server"
+	"github.com/go-git/go-git/v5/storage/filesystem"
+	"github.com/pkg/errors"
+	"sigs.k8s.io/kustomize/api/filesys"
+	"sigs.k8s.io/kustomize/api/ifc"
+	"sigs.k8s.io/kustomize/api/types"
+)
+
+// goGitRemote is the name of the remote repositories are fetched from.
+const goGitRemote = "origin"
+
+var commitRegexp = regexp.MustCompile(`^[0-9a-f]{4,40}$`)
+
+func init() {
+	// The default file transport runs git-upload-pack,
+	// serve local repositories, e.g. git mirrors, in-process instead.
+	client.InstallProtocol("file", server.NewClient(localLoader{}))
+}
+
+// localLoader loads the local repository at the path of an endpoint,
+// bare, e.g. a git mirror, or with a worktree.
+type localLoader struct{}
+
+func (localLoader) Load(ep *transport.Endpoint) (storer.Storer, error) {
+	dir := filepath.FromSlash(ep.Path)
+	if info, err := os.Stat(filepath.Join(dir, gogit.GitDirName)); err == nil && info.IsDir() {
+		dir = filepath.Join(dir, gogit.GitDirName)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "HEAD")); err != nil {
+		return nil, transport.ErrRepositoryNotFound
+	}
+	return filesystem.NewStorage(osfs.New(dir), cache.NewObjectLRUDefault()), nil
+}
+
+// ClonerFor returns the Cloner of the given git backend, which takes the
+// commit from, when already pinned, and records it in the lock file lf.
+func ClonerFor(backend types.GitBackend, lf ifc.Lockfile) Cloner {
+	if backend == types.GitBackendGoGit {
+		return func(repoSpec *RepoSpec) error {
+			return cloneUsingGoGit(repoSpec, lf)
+		}
+	}
+	return func(repoSpec *RepoSpec) error {
+		return cloneUsingGitExec(repoSpec, lf)
+	}
+}
+
+// ClonerUsingGoGit obtains a local clone of a remote repo, like
+// ClonerUsingGitExec, with a git implementation in pure Go
+// instead of a local git install.
+func ClonerUsingGoGit(repoSpec *RepoSpec) error {
+	return cloneUsingGoGit(repoSpec, nil)
+}
+
+func cloneUsingGoGit(repoSpec *RepoSpec, lf ifc.Lockfile) error {
+	dir, err := filesys.NewTmpConfirmedDir()
+	if err != nil {
+		return err
+	}
+	repoSpec.Dir = dir
+	ref := repoSpec.Ref
+	if lf != nil {
+		if commit, locked := lf.LockedGit(repoSpec.CloneSpec(), repoSpec.Ref); locked {
+			ref = commit
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), defaultDuration)
+	defer cancel()
+	r, err := gogit.PlainInit(dir.String(), false)
+	if err != nil {
+		return errors.Wrapf(err, "initializing repository in %s", dir)
+	}
+	commit, err := Fetch(ctx, r, repoSpec.CloneSpec(), ref, 1)
+	if err != nil {
+		return err
+	}
+	wt, err := r.Worktree()
+	if err != nil {
+		return err
+	}
+	if err = wt.Checkout(&gogit.CheckoutOptions{Hash: commit, Force: true}); err != nil {
+		return errors.Wrapf(err, "checking out %s of %s", commit, repoSpec.CloneSpec())
+	}
+	submodules, err := wt.Submodules()
+	if err != nil {
+		return err
+	}
+	auth, err := Auth(repoSpec.CloneSpec())
+	if err != nil {
+		return err
+	}
+	if err = submodules.UpdateContext(ctx, &gogit.SubmoduleUpdateOptions{
+		Init:              true,
+		RecurseSubmodules: gogit.DefaultSubmoduleRecursionDepth,
+		Auth:              auth,
+	}); err != nil {
+		return errors.Wrapf(err, "updating submodules of %s", repoSpec.CloneSpec())
+	}
+	repoSpec.Commit = commit.String()
+	if lf == nil {
+		return nil
+	}
+	return lf.RecordGit(repoSpec.CloneSpec(), repoSpec.Ref, repoSpec.Commit)
+}
+
+// SparseCheckoutUsingGoGit clones, or updates, the repository at url into dir
+// and checks out ref, a branch, tag or commit, or the default branch when empty.
+// When subdir is neither empty nor ".", only the files at the root of the repository
+// and the ones under subdir are checked out, like a git sparse checkout in cone mode.
+// A positive depth makes a shallow clone. It returns the commit checked out.
+func SparseCheckoutUsingGoGit(ctx context.Context, dir, url, ref, subdir string, depth int) (string, error) {
+	r, err := gogit.PlainOpen(dir)
+	if err == gogit.ErrRepositoryNotExists {
+		r, err = gogit.PlainInit(dir, false)
+	}
+	if err != nil {
+		return "", errors.Wrapf(err, "opening repository in %s", dir)
+	}
+	commit, err := Fetch(ctx, r, url, ref, depth)
+	if err != nil {
+		return "", err
+	}
+	if err := export(r, commit, dir, subdir); err != nil {
+		return "", errors.Wrapf(err, "checking out %s of %s", commit, url)
+	}
+	return commit.String(), nil
+}
+
+// MirrorUsingGoGit creates, or updates, dir as a bare mirror of the branches
+// and tags of the repository at url, like git clone --mirror, or git remote
+// update --prune for an existing mirror.
+func MirrorUsingGoGit(ctx context.Context, dir, url string) error {
+	r, err := gogit.PlainOpen(dir)
+	if err == gogit.ErrRepositoryNotExists {
+		r, err = gogit.PlainInit(dir, true)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "opening repository in %s", dir)
+	}
+	auth, err := Auth(url)
+	if err != nil {
+		return err
+	}
+	remote, err := setRemote(r, url)
+	if err != nil {
+		return err
+	}
+	refs, err := remote.List(&gogit.ListOptions{Auth: auth})
+	if err != nil {
+		return errors.Wrapf(err, "listing references of %s", url)
+	}
+	err = remote.FetchContext(ctx, &gogit.FetchOptions{
+		RemoteName: goGitRemote,
+		RefSpecs:   []config.RefSpec{"+refs/heads/*:refs/heads/*", "+refs/tags/*:refs/tags/*"},
+		Auth:       auth,
+		Tags:       gogit.NoTags,
+		Force:      true,
+	})
+	if err != nil && err != gogit.NoErrAlreadyUpToDate {
+		return errors.Wrapf(err, "fetching %s", url)
+	}
+	remoteNames := make(map[plumbing.ReferenceName]bool, len(refs))
+	for _, ref := range refs {
+		remoteNames[ref.Name()] = true
+	}
+	local, err := r.References()
+	if err != nil {
+		return err
+	}
+	var stale []plumbing.ReferenceName
+	err = local.ForEach(func(ref *plumbing.Reference) error {
+		if (ref.Name().IsBranch() || ref.Name().IsTag()) && !remoteNames[ref.Name()] {
+			stale = append(stale, ref.Name())
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, name := range stale {
+		if err := r.Storer.RemoveReference(name); err != nil {
+			return err
+		}
+	}
+	if head := remoteRefName(refs, ""); head != "" {
+		return r.Storer.SetReference(plumbing.NewSymbolicReference(plumbing.HEAD, head))
+	}
+	return nil
+}
+
+// HeadCommit returns the commit checked out in the repository in dir,
+// cloned by either git backend, without running git.
+func HeadCommit(dir string) (string, error) {
+	r, err := gogit.PlainOpen(dir)
+	if err != nil {
+		return "", errors.Wrapf(err, "opening repository in %s", dir)
+	}
+	head, err := r.Head()
+	if err != nil {
+		return "", errors.Wrapf(err, "resolving HEAD of %s", dir)
+	}
+	return head.Hash().String(), nil
+}
+
+// DefaultBranch returns the branch the HEAD of the origin remote of the
+// repository in dir points to, or "" when it has none, without running git.
+func DefaultBranch(dir string) string {
+	r, err := gogit.PlainOpen(dir)
+	if err != nil {
+		return ""
+	}
+	ref, err := r.Reference(plumbing.NewRemoteHEADReferenceName(goGitRemote), false)
+	if err != nil || ref.Type() != plumbing.SymbolicReference {
+		return ""
+	}
+	return strings.TrimPrefix(ref.Target().Short(), goGitRemote+"/")
+}
+
+// Fetch fetches ref, a branch, tag or commit, or the default branch when empty,
+// of the repository at url into r and returns the commit ref resolves to.
+// A positive depth makes a shallow fetch of branches and tags. Commits that
+// are not the tip of a branch or tag are fetched with their full history.
+func Fetch(ctx context.Context, r *gogit.Repository, url, ref string, depth int) (plumbing.Hash, error) {
+	if commitRegexp.MatchString(ref) {
+		if commit, err := r.ResolveRevision(plumbing.Revision(ref)); err == nil {
+			return *commit, nil
+		}
+	}
+	auth, err := Auth(url)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	endpoint, err := transport.NewEndpoint(url)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	if endpoint.Protocol == "file" {
+		// The in-process file transport does not support shallow fetches
+		depth = 0
+	}
+	remote, err := setRemote(r, url)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	refs, err := remote.List(&gogit.ListOptions{Auth: auth})
+	if err != nil {
+		return plumbing.ZeroHash, errors.Wrapf(err, "listing references of %s", url)
+	}
+	name := remoteRefName(refs, ref)
+	var refSpecs []config.RefSpec
+	if name != "" {
+		refSpecs = []config.RefSpec{config.RefSpec(fmt.Sprintf("+%s:%s", name, name))}
+	} else if ref == "" || ref == plumbing.HEAD.String() {
+		return plumbing.ZeroHash, fmt.Errorf("unable to find the default branch of %s", url)
+	} else if !commitRegexp.MatchString(ref) {
+		return plumbing.ZeroHash, fmt.Errorf("%s is not a branch, tag nor commit of %s", ref, url)
+	} else {
+		if shallow, err := r.Storer.Shallow(); err != nil {
+			return plumbing.ZeroHash, err
+		} else if len(shallow) > 0 {
+			return plumbing.ZeroHash, fmt.Errorf(
+				"commit %s of %s is not the tip of a branch or tag, it cannot be fetched into a shallow clone", ref, url)
+		}
+		depth = 0
+		refSpecs = []config.RefSpec{"+refs/heads/*:refs/heads/*", "+refs/tags/*:refs/tags/*"}
+	}
+	err = remote.FetchContext(ctx, &gogit.FetchOptions{
+		RemoteName: goGitRemote,
+		RefSpecs:   refSpecs,
+		Depth:      depth,
+		Auth:       auth,
+		Tags:       gogit.NoTags,
+		Force:      true,
+	})
+	if err != nil && err != gogit.NoErrAlreadyUpToDate {
+		return plumbing.ZeroHash, errors.Wrapf(err, "fetching %s of %s", ref, url)
+	}
+	revision := ref
+	if name != "" {
+		revision = name.String()
+	}
+	commit, err := r.ResolveRevision(plumbing.Revision(revision))
+	if err != nil {
+		return plumbing.ZeroHash, errors.Wrapf(err, "resolving %s of %s", ref, url)
+	}
+	return *commit, nil
+}
+
+// setRemote points the origin remote of r at url.
+func setRemote(r *gogit.Repository, url string) (*gogit.Remote, error) {
+	remote, err := r.Remote(goGitRemote)
+	if err == nil {
+		if urls := remote.Config().URLs; len(urls) == 1 && urls[0] == url {
+			return remote, nil
+		}
+		if err = r.DeleteRemote(goGitRemote); err != nil {
+			return nil, err
+		}
+	} else if err != gogit.ErrRemoteNotFound {
+		return nil, err
+	}
+	return r.CreateRemote(&config.RemoteConfig{Name: goGitRemote, URLs: []string{url}})
+}
+
+// remoteRefName returns the name of the branch or tag ref designates among refs,
+// the default branch for an empty ref, or an empty name if ref is none of them,
+// e.g. a commit that is not the tip of a branch or tag.
+func remoteRefName(refs []*plumbing.Reference, ref string) plumbing.ReferenceName {
+	byName := make(map[plumbing.ReferenceName]*plumbing.Reference, len(refs))
+	for _, r := range refs {
+		byName[r.Name()] = r
+	}
+	if ref == "" || ref == plumbing.HEAD.String() {
+		if head, ok := byName[plumbing.HEAD]; ok && head.Type() == plumbing.SymbolicReference {
+			return head.Target()
+		}
+		return ""
+	}
+	for _, name := range []plumbing.ReferenceName{
+		plumbing.NewBranchReferenceName(ref),
+		plumbing.NewTagReferenceName(ref),
+		plumbing.ReferenceName(ref),
+	} {
+		if r, ok := byName[name]; ok && (name.IsBranch() || name.IsTag()) && r.Type() == plumbing.HashReference {
+			return name
+		}
+	}
+	if commitRegexp.MatchString(ref) {
+		for _, r := range refs {
+			if r.Type() == plumbing.HashReference && (r.Name().IsBranch() || r.Name().IsTag()) &&
+				strings.HasPrefix(r.Hash().String(), ref) {
+				return r.Name()
+			}
+		}
+	}
+	return ""
+}
+
+// export writes the files of commit in r under dir, replacing everything but .git,
+// and detaches HEAD at commit. When subdir is neither empty nor ".", only the files
+// at the root of the repository and the ones under subdir are written.
+func export(r *gogit.Repository, commit plumbing.Hash, dir, subdir string) error {
+	c, err := r.CommitObject(commit)
+	if err != nil {
+		return err
+	}
+	tree, err := c.Tree()
+	if err != nil {
+		return err
+	}
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, info := range infos {
+		if info.Name() != gogit.GitDirName {
+			if err := os.RemoveAll(filepath.Join(dir, info.Name())); err != nil {
+				return err
+			}
+		}
+	}
+	subdir = path.Clean(filepath.ToSlash(subdir))
+	err = tree.Files().ForEach(func(f *object.File) error {
+		if subdir != "." && strings.Contains(f.Name, "/") && !strings.HasPrefix(f.Name, subdir+"/") {
+			return nil
+		}
+		return exportFile(f, filepath.Join(dir, filepath.FromSlash(f.Name)))
+	})
+	if err != nil {
+		return err
+	}
+	return r.Storer.SetReference(plumbing.NewHashReference(plumbing.HEAD, commit))
+}
+
+func exportFile(f *object.File, name string) error {
+	if err := os.MkdirAll(filepath.Dir(name), 0777); err != nil {
+		return err
+	}
+	if f.Mode == filemode.Symlink {
+		target, err := f.Contents()
+		if err != nil {
+			return err
+		}
+		return os.Symlink(target, name)
+	}
+	perm := os.FileMode(0666)
+	if f.Mode == filemode.Executable {
+		perm = 0777
+	}
+	reader, err := f.Reader()
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	out, err := os.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, reader); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
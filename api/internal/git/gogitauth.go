@@ -0,0 +1,147 @@
+// Copyright 2021 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package git
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	"github.com/pkg/errors"
+)
+
+const (
+	// TokenEnvVar holds a token used as the password of https remotes.
+	TokenEnvVar = "KUSTOMIZE_GIT_TOKEN"
+	// UsernameEnvVar holds the username going with TokenEnvVar, "git" by default.
+	UsernameEnvVar = "KUSTOMIZE_GIT_USERNAME"
+	// GitHubTokenEnvVar holds a token used for github.com when TokenEnvVar is not set.
+	GitHubTokenEnvVar = "GITHUB_TOKEN"
+	// NetrcEnvVar overrides the location of the netrc file, ~/.netrc by default.
+	NetrcEnvVar = "NETRC"
+)
+
+// Auth returns the credentials the go-git backend uses for the repository at url.
+// ssh remotes authenticate with the keys of the running SSH agent. https remotes
+// use the credentials in the url if any, else a token from the environment, else
+// the entry of the host in the netrc file. It returns nil for anonymous access.
+func Auth(url string) (transport.AuthMethod, error) {
+	endpoint, err := transport.NewEndpoint(url)
+	if err != nil {
+		return nil, err
+	}
+	switch endpoint.Protocol {
+	case "ssh":
+		user := endpoint.User
+		if user == "" {
+			user = "git"
+		}
+		auth, err := ssh.NewSSHAgentAuth(user)
+		if err != nil {
+			return nil, errors.Wrapf(err, "connecting to the SSH agent for %s", url)
+		}
+		return auth, nil
+	case "http", "https":
+		if endpoint.User != "" {
+			return &http.BasicAuth{Username: endpoint.User, Password: endpoint.Password}, nil
+		}
+		token := os.Getenv(TokenEnvVar)
+		if token == "" && endpoint.Host == "github.com" {
+			token = os.Getenv(GitHubTokenEnvVar)
+		}
+		if token != "" {
+			username := os.Getenv(UsernameEnvVar)
+			if username == "" {
+				username = "git"
+			}
+			return &http.BasicAuth{Username: username, Password: token}, nil
+		}
+		if login, password, ok := netrcCredentials(endpoint.Host); ok {
+			return &http.BasicAuth{Username: login, Password: password}, nil
+		}
+	}
+	return nil, nil
+}
+
+// netrcCredentials returns the login and password of host in the netrc file,
+// or of its default entry.
+func netrcCredentials(host string) (string, string, bool) {
+	name := os.Getenv(NetrcEnvVar)
+	if name == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", "", false
+		}
+		name = filepath.Join(home, ".netrc")
+		if runtime.GOOS == "windows" {
+			if _, err := os.Stat(name); err != nil {
+				name = filepath.Join(home, "_netrc")
+			}
+		}
+	}
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		return "", "", false
+	}
+	return parseNetrc(string(data), host)
+}
+
+// parseNetrc returns the login and password of the machine entry of host,
+// or of the default entry, in the netrc file data.
+func parseNetrc(data, host string) (string, string, bool) {
+	type entry struct {
+		login, password string
+	}
+	var (
+		current  *entry
+		found    *entry
+		fallback *entry
+	)
+	lines := strings.Split(data, "\n")
+	for i := 0; i < len(lines); i++ {
+		fields := strings.Fields(lines[i])
+		for j := 0; j < len(fields); j++ {
+			switch fields[j] {
+			case "machine", "default":
+				current = &entry{}
+				if fields[j] == "default" {
+					if fallback == nil {
+						fallback = current
+					}
+				} else if j+1 < len(fields) {
+					j++
+					if fields[j] == host && found == nil {
+						found = current
+					}
+				}
+			case "login", "password", "account":
+				if j+1 < len(fields) {
+					j++
+					if current != nil && fields[j-1] == "login" {
+						current.login = fields[j]
+					} else if current != nil && fields[j-1] == "password" {
+						current.password = fields[j]
+					}
+				}
+			case "macdef":
+				// A macro definition runs up to the next empty line
+				for i++; i < len(lines) && strings.TrimSpace(lines[i]) != ""; i++ {
+				}
+				j = len(fields)
+			}
+		}
+	}
+	if found == nil {
+		found = fallback
+	}
+	if found == nil || found.login == "" {
+		return "", "", false
+	}
+	return found.login, found.password, true
+}
@@ -42,6 +42,9 @@ type RepoSpec struct {
 	// Branch or tag reference.
 	Ref string
 
+	// Commit checked out by the cloner, if known.
+	Commit string
+
 	// e.g. .git or empty in case of _git is present
 	GitSuffix string
 }
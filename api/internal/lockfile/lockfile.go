@@ -0,0 +1,257 @@
+// Copyright 2021 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package lockfile reads and writes kustomization.lock, which pins
+// every remote input of a build: helm charts, GoGetter git refs and
+// remote bases.
+package lockfile
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"sigs.k8s.io/kustomize/api/filesys"
+	"sigs.k8s.io/kustomize/api/ifc"
+	"sigs.k8s.io/kustomize/api/types"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// FileName is the name of the lock file written next to the
+	// kustomization file of the build root.
+	FileName = "kustomization.lock"
+
+	apiVersion = "kustomize.qlik.com/v1"
+	kind       = "KustomizationLock"
+)
+
+// GitLock pins a git reference to a commit.
+type GitLock struct {
+	URL    string `json:"url"`
+	Ref    string `json:"ref,omitempty"`
+	Commit string `json:"commit"`
+}
+
+// Lock is the content of a kustomization.lock file.
+type Lock struct {
+	APIVersion string            `json:"apiVersion"`
+	Kind       string            `json:"kind"`
+	Charts     []types.ChartLock `json:"charts,omitempty"`
+	Git        []GitLock         `json:"git,omitempty"`
+}
+
+// ErrLockMismatch is returned in frozen mode when a resolved
+// input is missing from the lock file or differs from it.
+type ErrLockMismatch struct {
+	Input    string
+	Locked   string
+	Resolved string
+}
+
+func (e *ErrLockMismatch) Error() string {
+	if e.Locked == "" {
+		return fmt.Sprintf(
+			"%s resolved to %s but is not present in %s (frozen lockfile)",
+			e.Input, e.Resolved, FileName)
+	}
+	return fmt.Sprintf(
+		"%s resolved to %s but %s pins %s (frozen lockfile)",
+		e.Input, e.Resolved, FileName, e.Locked)
+}
+
+var _ ifc.Lockfile = &Lockfile{}
+
+// Lockfile tracks the pins read from disk and the inputs resolved
+// during a build. All methods are safe on a nil receiver, which
+// stands for a build without a lock file.
+type Lockfile struct {
+	mu       sync.Mutex
+	path     string
+	mode     types.LockfileMode
+	locked   Lock
+	resolved Lock
+}
+
+// Load loads the lock file for the build rooted at dir, in mode. It
+// returns nil, i.e. no lock file, when the mode is disabled, or when dir
+// is empty, e.g. for a build of a remote URL, or when it has no lock file
+// to read in read-only mode.
+func Load(fSys filesys.FileSystem, dir string, mode types.LockfileMode) (*Lockfile, error) {
+	if mode == types.LockfileDisabled || dir == "" {
+		return nil, nil
+	}
+	lf := &Lockfile{path: filepath.Join(dir, FileName), mode: mode}
+	if fSys.Exists(lf.path) {
+		b, err := fSys.ReadFile(lf.path)
+		if err != nil {
+			return nil, err
+		}
+		if err := yaml.Unmarshal(b, &lf.locked); err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", lf.path, err)
+		}
+	} else if mode == types.LockfileFrozen {
+		return nil, fmt.Errorf("frozen lockfile requested but %s does not exist", lf.path)
+	} else if mode == types.LockfileReadOnly {
+		return nil, nil
+	}
+	return lf, nil
+}
+
+// Path returns the location of the lock file.
+func (lf *Lockfile) Path() string {
+	if lf == nil {
+		return ""
+	}
+	return lf.path
+}
+
+// Mode returns the mode the lock file is used in.
+func (lf *Lockfile) Mode() types.LockfileMode {
+	if lf == nil {
+		return types.LockfileDisabled
+	}
+	return lf.mode
+}
+
+// LockedChart returns the pin for a chart, if there is one.
+func (lf *Lockfile) LockedChart(repo, name, requestedVersion string) (types.ChartLock, bool) {
+	if lf == nil {
+		return types.ChartLock{}, false
+	}
+	lf.mu.Lock()
+	defer lf.mu.Unlock()
+	for _, c := range lf.locked.Charts {
+		if c.Repo == repo && c.Name == name && c.RequestedVersion == requestedVersion {
+			return c, true
+		}
+	}
+	return types.ChartLock{}, false
+}
+
+// RecordChart records a resolved chart. In frozen mode it fails
+// if the chart differs from its pin.
+func (lf *Lockfile) RecordChart(c types.ChartLock) error {
+	if lf == nil {
+		return nil
+	}
+	locked, found := lf.LockedChart(c.Repo, c.Name, c.RequestedVersion)
+	if c.ManifestDigest == "" && found && locked.Version == c.Version && locked.Digest == c.Digest {
+		// the chart came from a cache or a mirror, not from its registry
+		c.ManifestDigest = locked.ManifestDigest
+	}
+	if lf.mode == types.LockfileFrozen {
+		input := fmt.Sprintf("chart %s from %s", c.Name, c.Repo)
+		resolved := fmt.Sprintf("%s@%s", c.Version, c.Digest)
+		if !found {
+			return &ErrLockMismatch{Input: input, Resolved: resolved}
+		}
+		if locked.Version != c.Version || locked.Digest != c.Digest {
+			return &ErrLockMismatch{Input: input, Locked: fmt.Sprintf("%s@%s", locked.Version, locked.Digest), Resolved: resolved}
+		}
+		if locked.ManifestDigest != c.ManifestDigest {
+			return &ErrLockMismatch{Input: input, Locked: fmt.Sprintf("%s@%s", locked.Version, locked.ManifestDigest),
+				Resolved: fmt.Sprintf("%s@%s", c.Version, c.ManifestDigest)}
+		}
+	}
+	lf.mu.Lock()
+	defer lf.mu.Unlock()
+	for i := range lf.resolved.Charts {
+		r := &lf.resolved.Charts[i]
+		if r.Repo == c.Repo && r.Name == c.Name && r.RequestedVersion == c.RequestedVersion {
+			*r = c
+			return nil
+		}
+	}
+	lf.resolved.Charts = append(lf.resolved.Charts, c)
+	return nil
+}
+
+// LockedGit returns the commit pinned for a git reference, if there is one.
+func (lf *Lockfile) LockedGit(url, ref string) (string, bool) {
+	if lf == nil {
+		return "", false
+	}
+	lf.mu.Lock()
+	defer lf.mu.Unlock()
+	for _, g := range lf.locked.Git {
+		if g.URL == url && g.Ref == ref {
+			return g.Commit, true
+		}
+	}
+	return "", false
+}
+
+// RecordGit records the commit a git reference resolved to. In frozen
+// mode it fails if the commit differs from its pin.
+func (lf *Lockfile) RecordGit(url, ref, commit string) error {
+	if lf == nil {
+		return nil
+	}
+	locked, found := lf.LockedGit(url, ref)
+	if lf.mode == types.LockfileFrozen && locked != commit {
+		input := fmt.Sprintf("git %s", url)
+		if ref != "" {
+			input = fmt.Sprintf("%s?ref=%s", input, ref)
+		}
+		if !found {
+			return &ErrLockMismatch{Input: input, Resolved: commit}
+		}
+		return &ErrLockMismatch{Input: input, Locked: locked, Resolved: commit}
+	}
+	lf.mu.Lock()
+	defer lf.mu.Unlock()
+	for i := range lf.resolved.Git {
+		r := &lf.resolved.Git[i]
+		if r.URL == url && r.Ref == ref {
+			r.Commit = commit
+			return nil
+		}
+	}
+	lf.resolved.Git = append(lf.resolved.Git, GitLock{URL: url, Ref: ref, Commit: commit})
+	return nil
+}
+
+// Save writes the inputs resolved during the build, and only those, so
+// that the pins of inputs the build no longer uses are dropped. Nothing is
+// written unless in update mode, nor when the build had no remote inputs
+// and there was no lock file.
+func (lf *Lockfile) Save(fSys filesys.FileSystem) error {
+	if lf == nil || lf.mode != types.LockfileUpdate {
+		return nil
+	}
+	lf.mu.Lock()
+	defer lf.mu.Unlock()
+	if len(lf.resolved.Charts) == 0 && len(lf.resolved.Git) == 0 && !fSys.Exists(lf.path) {
+		return nil
+	}
+	lock := Lock{
+		APIVersion: apiVersion,
+		Kind:       kind,
+		Charts:     append([]types.ChartLock(nil), lf.resolved.Charts...),
+		Git:        append([]GitLock(nil), lf.resolved.Git...),
+	}
+	sort.Slice(lock.Charts, func(i, j int) bool {
+		a, b := lock.Charts[i], lock.Charts[j]
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		if a.Repo != b.Repo {
+			return a.Repo < b.Repo
+		}
+		return a.RequestedVersion < b.RequestedVersion
+	})
+	sort.Slice(lock.Git, func(i, j int) bool {
+		a, b := lock.Git[i], lock.Git[j]
+		if a.URL != b.URL {
+			return a.URL < b.URL
+		}
+		return a.Ref < b.Ref
+	})
+	b, err := yaml.Marshal(lock)
+	if err != nil {
+		return err
+	}
+	return fSys.WriteFile(lf.path, b)
+}
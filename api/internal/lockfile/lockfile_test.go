@@ -0,0 +1,148 @@
+// Copyright 2021 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package lockfile_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/kustomize/api/filesys"
+	. "sigs.k8s.io/kustomize/api/internal/lockfile"
+	"sigs.k8s.io/kustomize/api/types"
+)
+
+func TestDisabled(t *testing.T) {
+	fSys := filesys.MakeFsInMemory()
+	lf, err := Load(fSys, "/app", types.LockfileDisabled)
+	assert.NoError(t, err)
+	assert.Nil(t, lf)
+
+	// a nil lock file pins nothing and records nothing
+	_, found := lf.LockedGit("https://github.com/example/repo", "main")
+	assert.False(t, found)
+	assert.NoError(t, lf.RecordGit("https://github.com/example/repo", "main", "abc"))
+	assert.NoError(t, lf.Save(fSys))
+	assert.False(t, fSys.Exists("/app/"+FileName))
+}
+
+func TestUpdateThenFrozen(t *testing.T) {
+	fSys := filesys.MakeFsInMemory()
+	assert.NoError(t, fSys.MkdirAll("/app"))
+
+	lf, err := Load(fSys, "/app", types.LockfileUpdate)
+	assert.NoError(t, err)
+	assert.NoError(t, lf.RecordChart(types.ChartLock{
+		Repo: "https://charts.example.com", Name: "foo", Version: "1.2.3", Digest: "sha256-aaa"}))
+	assert.NoError(t, lf.RecordChart(types.ChartLock{
+		Repo: "oci://registry.example.com/charts", Name: "bar", Version: "2.0.0", Digest: "sha256-ccc",
+		ManifestDigest: "sha256:ddd"}))
+	assert.NoError(t, lf.RecordGit("https://github.com/example/repo", "main", "abc"))
+	assert.NoError(t, lf.Save(fSys))
+
+	b, err := fSys.ReadFile("/app/" + FileName)
+	assert.NoError(t, err)
+	assert.Equal(t, `apiVersion: kustomize.qlik.com/v1
+charts:
+- digest: sha256-ccc
+  manifestDigest: sha256:ddd
+  name: bar
+  repo: oci://registry.example.com/charts
+  version: 2.0.0
+- digest: sha256-aaa
+  name: foo
+  repo: https://charts.example.com
+  version: 1.2.3
+git:
+- commit: abc
+  ref: main
+  url: https://github.com/example/repo
+kind: KustomizationLock
+`, string(b))
+
+	lf, err = Load(fSys, "/app", types.LockfileFrozen)
+	assert.NoError(t, err)
+
+	locked, found := lf.LockedChart("https://charts.example.com", "foo", "")
+	assert.True(t, found)
+	assert.Equal(t, "1.2.3", locked.Version)
+	commit, found := lf.LockedGit("https://github.com/example/repo", "main")
+	assert.True(t, found)
+	assert.Equal(t, "abc", commit)
+
+	assert.NoError(t, lf.RecordGit("https://github.com/example/repo", "main", "abc"))
+	assert.EqualError(t,
+		lf.RecordGit("https://github.com/example/repo", "main", "def"),
+		"git https://github.com/example/repo?ref=main resolved to def but kustomization.lock pins abc (frozen lockfile)")
+	assert.EqualError(t,
+		lf.RecordGit("https://github.com/example/other", "", "def"),
+		"git https://github.com/example/other resolved to def but is not present in kustomization.lock (frozen lockfile)")
+	assert.EqualError(t,
+		lf.RecordChart(types.ChartLock{Repo: "https://charts.example.com", Name: "foo", Version: "1.2.3", Digest: "sha256-bbb"}),
+		"chart foo from https://charts.example.com resolved to 1.2.3@sha256-bbb but kustomization.lock pins 1.2.3@sha256-aaa (frozen lockfile)")
+	assert.EqualError(t,
+		lf.RecordChart(types.ChartLock{Repo: "oci://registry.example.com/charts", Name: "bar", Version: "2.0.0", Digest: "sha256-ccc",
+			ManifestDigest: "sha256:eee"}),
+		"chart bar from oci://registry.example.com/charts resolved to 2.0.0@sha256:eee but kustomization.lock pins 2.0.0@sha256:ddd (frozen lockfile)")
+	// a chart read from a cache has no manifest digest, the pinned one is kept
+	assert.NoError(t, lf.RecordChart(types.ChartLock{
+		Repo: "oci://registry.example.com/charts", Name: "bar", Version: "2.0.0", Digest: "sha256-ccc"}))
+}
+
+func TestUpdateDropsUnusedPins(t *testing.T) {
+	fSys := filesys.MakeFsInMemory()
+	fSys.WriteFile("/app/"+FileName, []byte(`apiVersion: kustomize.qlik.com/v1
+git:
+- commit: abc
+  ref: main
+  url: https://github.com/example/removed
+- commit: def
+  url: https://github.com/example/repo
+kind: KustomizationLock
+`))
+	lf, err := Load(fSys, "/app", types.LockfileUpdate)
+	assert.NoError(t, err)
+	commit, found := lf.LockedGit("https://github.com/example/repo", "")
+	assert.True(t, found)
+	assert.NoError(t, lf.RecordGit("https://github.com/example/repo", "", commit))
+	assert.NoError(t, lf.Save(fSys))
+
+	b, err := fSys.ReadFile("/app/" + FileName)
+	assert.NoError(t, err)
+	assert.Equal(t, `apiVersion: kustomize.qlik.com/v1
+git:
+- commit: def
+  url: https://github.com/example/repo
+kind: KustomizationLock
+`, string(b))
+}
+
+func TestReadOnly(t *testing.T) {
+	fSys := filesys.MakeFsInMemory()
+	lf, err := Load(fSys, "/app", types.LockfileReadOnly)
+	assert.NoError(t, err)
+	assert.Nil(t, lf)
+
+	fSys.WriteFile("/app/"+FileName, []byte(`apiVersion: kustomize.qlik.com/v1
+git:
+- commit: def
+  url: https://github.com/example/repo
+kind: KustomizationLock
+`))
+	lf, err = Load(fSys, "/app", types.LockfileReadOnly)
+	assert.NoError(t, err)
+	commit, found := lf.LockedGit("https://github.com/example/repo", "")
+	assert.True(t, found)
+	assert.Equal(t, "def", commit)
+	assert.NoError(t, lf.RecordGit("https://github.com/example/other", "", "abc"))
+	assert.NoError(t, lf.Save(fSys))
+	b, err := fSys.ReadFile("/app/" + FileName)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(b), "other")
+}
+
+func TestFrozenWithoutLockfile(t *testing.T) {
+	fSys := filesys.MakeFsInMemory()
+	_, err := Load(fSys, "/app", types.LockfileFrozen)
+	assert.EqualError(t, err, "frozen lockfile requested but /app/kustomization.lock does not exist")
+}
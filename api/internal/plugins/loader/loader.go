@@ -13,15 +13,20 @@ import (
 	"strings"
 
 	"github.com/pkg/errors"
+	"sigs.k8s.io/kustomize/api/diagnostics"
 	"sigs.k8s.io/kustomize/api/filesys"
+	"sigs.k8s.io/kustomize/api/helmvalues"
 	"sigs.k8s.io/kustomize/api/ifc"
 	"sigs.k8s.io/kustomize/api/internal/plugins/builtinhelpers"
 	"sigs.k8s.io/kustomize/api/internal/plugins/execplugin"
 	"sigs.k8s.io/kustomize/api/internal/plugins/fnplugin"
 	"sigs.k8s.io/kustomize/api/internal/plugins/utils"
 	"sigs.k8s.io/kustomize/api/konfig"
+	fLdr "sigs.k8s.io/kustomize/api/loader"
+	"sigs.k8s.io/kustomize/api/provenance"
 	"sigs.k8s.io/kustomize/api/resmap"
 	"sigs.k8s.io/kustomize/api/resource"
+	"sigs.k8s.io/kustomize/api/trace"
 	"sigs.k8s.io/kustomize/api/types"
 	"sigs.k8s.io/kustomize/kyaml/resid"
 )
@@ -32,6 +37,25 @@ type Loader struct {
 	rf *resmap.Factory
 	fs filesys.FileSystem
 
+	// diagnostics collects the warnings of the plugins loaded, if set.
+	diagnostics *diagnostics.Collector
+
+	// inputs records what the plugins loaded read, if set.
+	inputs *provenance.Recorder
+
+	// lockfile pins and records the remote inputs of the plugins loaded, if set.
+	lockfile ifc.Lockfile
+
+	// tracer records the resources the plugins loaded change, if set.
+	tracer *trace.Recorder
+
+	// helmValues collects the values of the HelmCharts loaded, if set.
+	helmValues *helmvalues.Report
+
+	// nestedBuilder and env are handed to the plugins loaded, if set.
+	nestedBuilder resmap.NestedBuilder
+	env           map[string]string
+
 	// absolutePluginHome caches the location of a valid plugin root directory.
 	// It should only be set once the directory's existence has been confirmed.
 	absolutePluginHome string
@@ -42,6 +66,65 @@ func NewLoader(
 	return &Loader{pc: pc, rf: rf, fs: fs}
 }
 
+// SetDiagnostics makes the plugins loaded from now on emit their warnings into c.
+func (l *Loader) SetDiagnostics(c *diagnostics.Collector) {
+	l.diagnostics = c
+}
+
+// SetInputs makes the plugins loaded from now on record what they read into r.
+func (l *Loader) SetInputs(r *provenance.Recorder) {
+	l.inputs = r
+}
+
+// SetLockfile makes the plugins loaded from now on pin and record their remote inputs in lf.
+func (l *Loader) SetLockfile(lf ifc.Lockfile) {
+	l.lockfile = lf
+}
+
+// SetTracer names the plugins loaded from now on in the steps r records.
+func (l *Loader) SetTracer(r *trace.Recorder) {
+	l.tracer = r
+}
+
+// SetHelmValues makes the HelmCharts loaded from now on record their values into r
+// instead of being rendered.
+func (l *Loader) SetHelmValues(r *helmvalues.Report) {
+	l.helmValues = r
+}
+
+// SetNestedBuilder sets the builder the loaded plugins use to build
+// kustomizations in-process, and the env they see on top of the process env.
+func (l *Loader) SetNestedBuilder(nb resmap.NestedBuilder, env map[string]string) {
+	l.nestedBuilder = nb
+	l.env = env
+}
+
+// Diagnostics returns the collector set by SetDiagnostics, or nil.
+func (l *Loader) Diagnostics() *diagnostics.Collector {
+	return l.diagnostics
+}
+
+// Inputs returns the recorder set by SetInputs, or nil.
+// Each plugin loaded records into its own scope, see provenance.Recorder.Scope.
+func (l *Loader) Inputs() *provenance.Recorder {
+	return l.inputs
+}
+
+// Lockfile returns the lock file set by SetLockfile, or nil.
+func (l *Loader) Lockfile() ifc.Lockfile {
+	return l.lockfile
+}
+
+// Tracer returns the recorder set by SetTracer, or nil.
+func (l *Loader) Tracer() *trace.Recorder {
+	return l.tracer
+}
+
+// HelmValues returns the report set by SetHelmValues, or nil.
+func (l *Loader) HelmValues() *helmvalues.Report {
+	return l.helmValues
+}
+
 // Config provides the global (not plugin specific) PluginConfig data.
 func (l *Loader) Config() *types.PluginConfig {
 	return l.pc
@@ -193,15 +276,28 @@ func (l *Loader) loadAndConfigurePlugin(
 	if err != nil {
 		return nil, err
 	}
+	configPath := res.GetPluginConfigPath()
+	if configPath != "" {
+		res = res.DeepCopy()
+		res.RemovePluginConfigPath()
+	}
 	yaml, err := res.AsYAML()
 	if err != nil {
 		return nil, errors.Wrapf(err, "marshalling yaml from res %s", res.OrgId())
 	}
-	err = c.Config(resmap.NewPluginHelpers(ldr, v, l.rf, l.pc), yaml)
+	dr := diagnostics.NewReporter(l.diagnostics, res.OrgId().Kind, res.GetName(), configPath)
+	inputs := l.inputs.Scope(c)
+	if configPath != "" && configPath != ldr.Root() {
+		if content, errL := ldr.Load(configPath); errL == nil {
+			inputs.Record(provenance.InputFile, fLdr.InputURI(ldr, configPath), content)
+		}
+	}
+	err = c.Config(resmap.NewPluginHelpers(ldr, v, l.rf, l.pc).WithDiagnostics(dr).WithInputs(inputs).WithLockfile(l.lockfile).WithHelmValues(l.helmValues).WithConfigPath(configPath).WithNestedBuilder(l.nestedBuilder, l.env), yaml)
 	if err != nil {
 		return nil, errors.Wrapf(
 			err, "plugin %s fails configuration", res.OrgId())
 	}
+	l.tracer.SetPlugin(c, res.OrgId().Kind, res.GetName())
 	return c, nil
 }
 
@@ -0,0 +1,123 @@
+// Copyright 2021 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package target
+
+import (
+	"sync"
+
+	"sigs.k8s.io/kustomize/api/ifc"
+	"sigs.k8s.io/kustomize/api/internal/git"
+	"sigs.k8s.io/kustomize/api/internal/kusterr"
+	"sigs.k8s.io/kustomize/api/resmap"
+)
+
+// jobs returns the number of fetches run concurrently by the build.
+func (kt *KustTarget) jobs() int {
+	if pc := kt.pLdr.Config(); pc != nil && pc.Jobs > 1 {
+		return pc.Jobs
+	}
+	return 1
+}
+
+// runJobs calls f for every index below n on at most jobs goroutines
+// and returns the errors of the calls by index.
+func runJobs(jobs, n int, f func(i int) error) []error {
+	errs := make([]error, n)
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < jobs && w < n; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				errs[i] = f(i)
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+	return errs
+}
+
+// prefetchGenerators runs the fetches of the generators that are
+// Prefetchers concurrently, the first one of each key only, and
+// returns the error of the first generator that failed.
+func (kt *KustTarget) prefetchGenerators(generators []resmap.Generator) error {
+	if kt.jobs() < 2 {
+		return nil
+	}
+	var prefetchers []resmap.Prefetcher
+	keys := make(map[string]bool)
+	for _, g := range generators {
+		p, ok := g.(resmap.Prefetcher)
+		if !ok {
+			continue
+		}
+		key := p.PrefetchKey()
+		if key == "" || keys[key] {
+			continue
+		}
+		keys[key] = true
+		prefetchers = append(prefetchers, p)
+	}
+	errs := runJobs(kt.jobs(), len(prefetchers), func(i int) error {
+		return prefetchers[i].Prefetch()
+	})
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadedResource is a resources entry read as a file with content, or,
+// when that failed with errF, opened as a base with ldr, or failing with err.
+type loadedResource struct {
+	resources resmap.ResMap
+	content   []byte
+	errF      error
+	ldr       ifc.Loader
+	err       error
+}
+
+func (kt *KustTarget) loadResource(path string) *loadedResource {
+	content, errF := kt.ldr.Load(path)
+	if errF == nil {
+		var resources resmap.ResMap
+		if resources, errF = kt.rFactory.NewResMapFromBytes(content); errF == nil {
+			return &loadedResource{resources: resources, content: content}
+		}
+		errF = kusterr.Handler(errF, path)
+	}
+	ldr, err := kt.ldr.New(path)
+	return &loadedResource{errF: errF, ldr: ldr, err: err}
+}
+
+// loadRemoteResources loads the remote entries of paths, e.g. git
+// repositories, concurrently. The entries that are not loaded, all
+// of them when the build runs one job, are nil.
+func (kt *KustTarget) loadRemoteResources(paths []string) []*loadedResource {
+	loaded := make([]*loadedResource, len(paths))
+	if kt.jobs() < 2 {
+		return loaded
+	}
+	var remote []int
+	for i, path := range paths {
+		if _, err := git.NewRepoSpecFromUrl(path); err == nil {
+			remote = append(remote, i)
+		}
+	}
+	if len(remote) < 2 {
+		return loaded
+	}
+	runJobs(kt.jobs(), len(remote), func(i int) error {
+		loaded[remote[i]] = kt.loadResource(paths[remote[i]])
+		return nil
+	})
+	return loaded
+}
@@ -0,0 +1,45 @@
+// Copyright 2021 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package target
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunJobs(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		running int
+		most    int
+		ready   sync.WaitGroup
+	)
+	ready.Add(3)
+	errs := runJobs(3, 10, func(i int) error {
+		mu.Lock()
+		running++
+		if running > most {
+			most = running
+		}
+		mu.Unlock()
+		if i < 3 {
+			// the first jobs wait for each other, they must run concurrently
+			ready.Done()
+			ready.Wait()
+		}
+		mu.Lock()
+		running--
+		mu.Unlock()
+		if i%4 == 1 {
+			return fmt.Errorf("job %d", i)
+		}
+		return nil
+	})
+	assert.Equal(t, 3, most)
+	assert.Equal(t, []error{nil, fmt.Errorf("job 1"), nil, nil, nil, fmt.Errorf("job 5"),
+		nil, nil, nil, fmt.Errorf("job 9")}, errs)
+}
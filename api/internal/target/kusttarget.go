@@ -11,13 +11,17 @@ import (
 
 	"github.com/pkg/errors"
 	"sigs.k8s.io/kustomize/api/builtins"
+	"sigs.k8s.io/kustomize/api/diagnostics"
 	"sigs.k8s.io/kustomize/api/ifc"
 	"sigs.k8s.io/kustomize/api/internal/accumulator"
 	"sigs.k8s.io/kustomize/api/internal/plugins/builtinconfig"
 	"sigs.k8s.io/kustomize/api/internal/plugins/builtinhelpers"
 	"sigs.k8s.io/kustomize/api/internal/plugins/loader"
 	"sigs.k8s.io/kustomize/api/konfig"
+	fLdr "sigs.k8s.io/kustomize/api/loader"
+	"sigs.k8s.io/kustomize/api/provenance"
 	"sigs.k8s.io/kustomize/api/resmap"
+	"sigs.k8s.io/kustomize/api/trace"
 	"sigs.k8s.io/kustomize/api/types"
 	"sigs.k8s.io/kustomize/kyaml/openapi"
 	"sigs.k8s.io/yaml"
@@ -30,6 +34,8 @@ type KustTarget struct {
 	validator     ifc.Validator
 	rFactory      *resmap.Factory
 	pLdr          *loader.Loader
+	// inputKey is the key of the kustomization file in the recorder of inputs.
+	inputKey string
 }
 
 // NewKustTarget returns a new instance of KustTarget.
@@ -48,10 +54,11 @@ func NewKustTarget(
 
 // Load attempts to load the target's kustomization file.
 func (kt *KustTarget) Load() error {
-	content, err := loadKustFile(kt.ldr)
+	content, fileName, err := loadKustFile(kt.ldr)
 	if err != nil {
 		return err
 	}
+	original := content
 	content, err = types.FixKustomizationPreUnmarshalling(content)
 	if err != nil {
 		return err
@@ -69,6 +76,11 @@ func (kt *KustTarget) Load() error {
 				strings.Join(errs, "\n"), kt.ldr.Root())
 	}
 	kt.kustomization = &k
+	inputType := provenance.InputKustomization
+	if k.Kind == types.ComponentKind {
+		inputType = provenance.InputComponent
+	}
+	kt.inputKey = kt.inputs().Record(inputType, fLdr.InputURI(kt.ldr, fileName), original)
 	return nil
 }
 
@@ -80,23 +92,31 @@ func (kt *KustTarget) Kustomization() types.Kustomization {
 	return result
 }
 
-func loadKustFile(ldr ifc.Loader) ([]byte, error) {
+// InputKey returns the key of the kustomization file in the recorder
+// of the inputs of the build, once loaded.
+func (kt *KustTarget) InputKey() string {
+	return kt.inputKey
+}
+
+func loadKustFile(ldr ifc.Loader) ([]byte, string, error) {
 	var content []byte
+	var fileName string
 	match := 0
 	for _, kf := range konfig.RecognizedKustomizationFileNames() {
 		c, err := ldr.Load(kf)
 		if err == nil {
 			match += 1
 			content = c
+			fileName = kf
 		}
 	}
 	switch match {
 	case 0:
-		return nil, NewErrMissingKustomization(ldr.Root())
+		return nil, "", NewErrMissingKustomization(ldr.Root())
 	case 1:
-		return content, nil
+		return content, fileName, nil
 	default:
-		return nil, fmt.Errorf(
+		return nil, "", fmt.Errorf(
 			"Found multiple kustomization files under: %s\n", ldr.Root())
 	}
 }
@@ -123,13 +143,16 @@ func (kt *KustTarget) makeCustomizedResMap() (resmap.ResMap, error) {
 
 	// Given that names have changed (prefixs/suffixes added),
 	// fix all the back references to those names.
-	err = ra.FixBackReferences()
+	step := kt.step(trace.StepTransformer)
+	step.Plugin = "NameReferenceTransformer"
+	err = kt.tracer().Observe(step, nil, ra.ResMap(), ra.FixBackReferences)
 	if err != nil {
 		return nil, err
 	}
 
 	// With all the back references fixed, it's OK to resolve Vars.
-	err = ra.ResolveVars()
+	step.Plugin = "RefVarTransformer"
+	err = kt.tracer().Observe(step, nil, ra.ResMap(), ra.ResolveVars)
 	if err != nil {
 		return nil, err
 	}
@@ -144,7 +167,9 @@ func (kt *KustTarget) addHashesToNames(
 	if err != nil {
 		return err
 	}
-	return ra.Transform(p)
+	return kt.traceTransformed(p, ra.ResMap(), func() error {
+		return ra.Transform(p)
+	})
 }
 
 // AccumulateTarget returns a new ResAccumulator,
@@ -160,6 +185,12 @@ func (kt *KustTarget) AccumulateTarget() (
 // (or empty if the Component does not have a parent).
 func (kt *KustTarget) accumulateTarget(ra *accumulator.ResAccumulator) (
 	resRa *accumulator.ResAccumulator, err error) {
+	before := kt.snapshot(ra.ResMap())
+	defer func() {
+		if resRa != nil {
+			kt.linkChanged(before, resRa.ResMap(), []string{kt.inputKey})
+		}
+	}()
 	ra, err = kt.accumulateResources(ra, kt.kustomization.Resources)
 	if err != nil {
 		return nil, errors.Wrap(err, "accumulating resources")
@@ -221,12 +252,22 @@ func (kt *KustTarget) runGenerators(
 		return errors.Wrap(err, "loading generator plugins")
 	}
 	generators = append(generators, gs...)
+	if err = kt.prefetchGenerators(generators); err != nil {
+		return err
+	}
 	for _, g := range generators {
 		resMap, err := g.Generate()
 		if err != nil {
 			return err
 		}
-		err = ra.AbsorbAll(resMap)
+		kt.linkAll(resMap, kt.inputs().Scope(g).Keys())
+		// absorb through the accumulator, to trace the generator
+		// like a transformer of the resources accumulated so far.
+		err = ra.Transform(transformerFunc(func(m resmap.ResMap) error {
+			return kt.tracer().Observe(kt.step(trace.StepGenerator), g, m, func() error {
+				return m.AbsorbAll(resMap)
+			})
+		}))
 		if err != nil {
 			return errors.Wrapf(err, "merging from generator %v", g)
 		}
@@ -235,19 +276,7 @@ func (kt *KustTarget) runGenerators(
 }
 
 func (kt *KustTarget) configureExternalGenerators() ([]resmap.Generator, error) {
-	ra := accumulator.MakeEmptyAccumulator()
-	var generatorPaths []string
-	for _, p := range kt.kustomization.Generators {
-		// handle inline generators
-		rm, err := kt.rFactory.NewResMapFromBytes([]byte(p))
-		if err != nil {
-			// not an inline config
-			generatorPaths = append(generatorPaths, p)
-			continue
-		}
-		ra.AppendAll(rm)
-	}
-	ra, err := kt.accumulateResources(ra, generatorPaths)
+	ra, err := kt.accumulatePluginConfigs(kt.kustomization.Generators)
 	if err != nil {
 		return nil, err
 	}
@@ -267,28 +296,49 @@ func (kt *KustTarget) runTransformers(ra *accumulator.ResAccumulator) error {
 		return err
 	}
 	r = append(r, lts...)
-	return ra.Transform(newMultiTransformer(r))
+	t := newMultiTransformer(r)
+	t.observe = kt.observeTransformers()
+	return ra.Transform(t)
 }
 
 func (kt *KustTarget) configureExternalTransformers(transformers []string) ([]resmap.Transformer, error) {
+	ra, err := kt.accumulatePluginConfigs(transformers)
+	if err != nil {
+		return nil, err
+	}
+	return kt.pLdr.LoadTransformers(kt.ldr, kt.validator, ra.ResMap())
+}
+
+// accumulatePluginConfigs reads the inline plugin configs, then the ones
+// in files, recording where each config was read from for diagnostics.
+func (kt *KustTarget) accumulatePluginConfigs(configs []string) (*accumulator.ResAccumulator, error) {
 	ra := accumulator.MakeEmptyAccumulator()
-	var transformerPaths []string
-	for _, p := range transformers {
-		// handle inline transformers
+	var paths []string
+	for _, p := range configs {
+		// handle inline configs
 		rm, err := kt.rFactory.NewResMapFromBytes([]byte(p))
 		if err != nil {
 			// not an inline config
-			transformerPaths = append(transformerPaths, p)
+			paths = append(paths, p)
 			continue
 		}
+		for _, res := range rm.Resources() {
+			res.SetPluginConfigPath(kt.ldr.Root())
+		}
 		ra.AppendAll(rm)
 	}
-	ra, err := kt.accumulateResources(ra, transformerPaths)
-
-	if err != nil {
-		return nil, err
+	for _, p := range paths {
+		before := ra.ResMap().Size()
+		var err error
+		ra, err = kt.accumulateResources(ra, []string{p})
+		if err != nil {
+			return nil, err
+		}
+		for _, res := range ra.ResMap().Resources()[before:] {
+			res.SetPluginConfigPath(filepath.Join(kt.ldr.Root(), p))
+		}
 	}
-	return kt.pLdr.LoadTransformers(kt.ldr, kt.validator, ra.ResMap())
+	return ra, nil
 }
 
 func (kt *KustTarget) runValidators(ra *accumulator.ResAccumulator) error {
@@ -330,20 +380,60 @@ func (kt *KustTarget) removeValidatedByLabel(rm resmap.ResMap) {
 
 // accumulateResources fills the given resourceAccumulator
 // with resources read from the given list of paths.
+// Remote paths are loaded concurrently, then all are
+// accumulated in order.
 func (kt *KustTarget) accumulateResources(
 	ra *accumulator.ResAccumulator, paths []string) (*accumulator.ResAccumulator, error) {
-	for _, path := range paths {
+	loaded := kt.loadRemoteResources(paths)
+	defer func() {
+		// clean up the bases loaded ahead of an error
+		for _, l := range loaded {
+			if l != nil && l.ldr != nil {
+				l.ldr.Cleanup()
+			}
+		}
+	}()
+	for i, path := range paths {
 		// try loading resource as file then as base (directory or git repository)
-		if errF := kt.accumulateFile(ra, path); errF != nil {
-			ldr, err := kt.ldr.New(path)
-			if err != nil {
-				return nil, errors.Wrapf(
-					err, "accumulation err='%s'", errF.Error())
+		l := loaded[i]
+		loaded[i] = nil
+		if l == nil {
+			l = kt.loadResource(path)
+		}
+		errF := l.errF
+		if errF == nil {
+			key := kt.inputs().Record(provenance.InputResource, fLdr.InputURI(kt.ldr, path), l.content)
+			kt.linkAll(l.resources, []string{key})
+			step := kt.step(trace.StepResource)
+			step.Source = kt.inputURI(key)
+			kt.tracer().Created(l.resources, step)
+			if errF = ra.AppendAll(l.resources); errF == nil {
+				continue
+			}
+			errF = errors.Wrapf(errF, "merging resources from '%s'", path)
+			l.ldr, l.err = kt.ldr.New(path)
+		} else {
+			errF = errors.Wrapf(errF, "accumulating resources from '%s'", path)
+		}
+		if l.err != nil {
+			return nil, errors.Wrapf(
+				l.err, "accumulation err='%s'", errF.Error())
+		}
+		var err error
+		before := ra.ResMap().Size()
+		ra, err = kt.accumulateDirectory(ra, l.ldr, false)
+		if err != nil {
+			return nil, errors.Wrapf(
+				err, "accumulation err='%s'", errF.Error())
+		}
+		if url, commit, ok := fLdr.RemoteBase(l.ldr); ok {
+			var digest map[string]string
+			if commit != "" {
+				digest = map[string]string{"sha1": commit}
 			}
-			ra, err = kt.accumulateDirectory(ra, ldr, false)
-			if err != nil {
-				return nil, errors.Wrapf(
-					err, "accumulation err='%s'", errF.Error())
+			key := kt.inputs().RecordDigest(provenance.InputGitRepository, url, digest)
+			for _, res := range ra.ResMap().Resources()[before:] {
+				kt.linkResource(res, []string{key})
 			}
 		}
 	}
@@ -420,19 +510,6 @@ func (kt *KustTarget) accumulateDirectory(
 	return ra, nil
 }
 
-func (kt *KustTarget) accumulateFile(
-	ra *accumulator.ResAccumulator, path string) error {
-	resources, err := kt.rFactory.FromFile(kt.ldr, path)
-	if err != nil {
-		return errors.Wrapf(err, "accumulating resources from '%s'", path)
-	}
-	err = ra.AppendAll(resources)
-	if err != nil {
-		return errors.Wrapf(err, "merging resources from '%s'", path)
-	}
-	return nil
-}
-
 func (kt *KustTarget) configureBuiltinPlugin(
 	p resmap.Configurable, c interface{}, bpt builtinhelpers.BuiltinPluginType) (err error) {
 	var y []byte
@@ -445,11 +522,14 @@ func (kt *KustTarget) configureBuiltinPlugin(
 	}
 	err = p.Config(
 		resmap.NewPluginHelpers(
-			kt.ldr, kt.validator, kt.rFactory, kt.pLdr.Config()),
+			kt.ldr, kt.validator, kt.rFactory, kt.pLdr.Config()).WithDiagnostics(
+			diagnostics.NewReporter(kt.pLdr.Diagnostics(), bpt.String(), "", kt.ldr.Root())).WithInputs(
+			kt.inputs().Scope(p)).WithLockfile(kt.pLdr.Lockfile()),
 		y)
 	if err != nil {
 		return errors.Wrapf(
 			err, "trouble configuring builtin %s with config: `\n%s`", bpt, string(y))
 	}
+	kt.tracer().SetPlugin(p, bpt.String(), "")
 	return nil
 }
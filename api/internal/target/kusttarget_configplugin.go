@@ -8,6 +8,8 @@ import (
 
 	"sigs.k8s.io/kustomize/api/internal/plugins/builtinconfig"
 	"sigs.k8s.io/kustomize/api/internal/plugins/builtinhelpers"
+	fLdr "sigs.k8s.io/kustomize/api/loader"
+	"sigs.k8s.io/kustomize/api/provenance"
 	"sigs.k8s.io/kustomize/api/resmap"
 	"sigs.k8s.io/kustomize/api/types"
 )
@@ -85,10 +87,12 @@ var generatorConfigurators = map[builtinhelpers.BuiltinPluginType]func(
 			c.SecretArgs.Options = types.MergeGlobalOptionsIntoLocal(
 				c.SecretArgs.Options, kt.kustomization.GeneratorOptions)
 			p := f()
+			fLdr.RecordKvFiles(kt.inputs().Scope(p), kt.ldr, c.SecretArgs.KvPairSources)
 			err := kt.configureBuiltinPlugin(p, c, bpt)
 			if err != nil {
 				return nil, err
 			}
+			kt.tracer().SetPlugin(p, bpt.String(), c.SecretArgs.Name)
 			result = append(result, p)
 		}
 		return
@@ -104,10 +108,12 @@ var generatorConfigurators = map[builtinhelpers.BuiltinPluginType]func(
 			c.ConfigMapArgs.Options = types.MergeGlobalOptionsIntoLocal(
 				c.ConfigMapArgs.Options, kt.kustomization.GeneratorOptions)
 			p := f()
+			fLdr.RecordKvFiles(kt.inputs().Scope(p), kt.ldr, c.ConfigMapArgs.KvPairSources)
 			err := kt.configureBuiltinPlugin(p, c, bpt)
 			if err != nil {
 				return nil, err
 			}
+			kt.tracer().SetPlugin(p, bpt.String(), c.ConfigMapArgs.Name)
 			result = append(result, p)
 		}
 		return
@@ -175,10 +181,12 @@ var transformerConfigurators = map[builtinhelpers.BuiltinPluginType]func(
 			c.Path = args.Path
 			c.JsonOp = args.Patch
 			p := f()
+			kt.recordFile(p, provenance.InputPatch, args.Path)
 			err = kt.configureBuiltinPlugin(p, c, bpt)
 			if err != nil {
 				return nil, err
 			}
+			kt.tracer().SetPlugin(p, bpt.String(), args.Path)
 			result = append(result, p)
 		}
 		return
@@ -194,6 +202,9 @@ var transformerConfigurators = map[builtinhelpers.BuiltinPluginType]func(
 		}
 		c.Paths = kt.kustomization.PatchesStrategicMerge
 		p := f()
+		for _, path := range c.Paths {
+			kt.recordFile(p, provenance.InputPatch, string(path))
+		}
 		err = kt.configureBuiltinPlugin(p, c, bpt)
 		if err != nil {
 			return nil, err
@@ -219,10 +230,12 @@ var transformerConfigurators = map[builtinhelpers.BuiltinPluginType]func(
 			c.Path = pc.Path
 			c.Options = pc.Options
 			p := f()
+			kt.recordFile(p, provenance.InputPatch, pc.Path)
 			err = kt.configureBuiltinPlugin(p, c, bpt)
 			if err != nil {
 				return nil, err
 			}
+			kt.tracer().SetPlugin(p, bpt.String(), pc.Path)
 			result = append(result, p)
 		}
 		return
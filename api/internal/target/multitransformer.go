@@ -10,12 +10,15 @@ import (
 // multiTransformer contains a list of transformers.
 type multiTransformer struct {
 	transformers []resmap.Transformer
+	// observe, if set, runs each transformer through run, e.g. to
+	// record what it changed.
+	observe func(t resmap.Transformer, m resmap.ResMap, run func() error) error
 }
 
 var _ resmap.Transformer = &multiTransformer{}
 
 // newMultiTransformer constructs a multiTransformer.
-func newMultiTransformer(t []resmap.Transformer) resmap.Transformer {
+func newMultiTransformer(t []resmap.Transformer) *multiTransformer {
 	r := &multiTransformer{
 		transformers: make([]resmap.Transformer, len(t)),
 	}
@@ -27,7 +30,12 @@ func newMultiTransformer(t []resmap.Transformer) resmap.Transformer {
 // optionally detecting and erroring on commutation conflict.
 func (o *multiTransformer) Transform(m resmap.ResMap) error {
 	for _, t := range o.transformers {
-		if err := t.Transform(m); err != nil {
+		run := func() error { return t.Transform(m) }
+		if o.observe != nil {
+			if err := o.observe(t, m, run); err != nil {
+				return err
+			}
+		} else if err := run(); err != nil {
 			return err
 		}
 		m.DropEmpties()
@@ -0,0 +1,92 @@
+// Copyright 2021 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package target
+
+import (
+	fLdr "sigs.k8s.io/kustomize/api/loader"
+	"sigs.k8s.io/kustomize/api/provenance"
+	"sigs.k8s.io/kustomize/api/resmap"
+	"sigs.k8s.io/kustomize/api/resource"
+)
+
+// inputs returns the recorder of the inputs of the build, or nil.
+func (kt *KustTarget) inputs() *provenance.Recorder {
+	if kt.pLdr == nil {
+		return nil
+	}
+	return kt.pLdr.Inputs()
+}
+
+// recordFile records the file at path, if it is one, as an input of owner,
+// a plugin configured from the kustomization.
+func (kt *KustTarget) recordFile(owner interface{}, inputType provenance.InputType, path string) {
+	if path == "" || kt.inputs() == nil {
+		return
+	}
+	content, err := kt.ldr.Load(path)
+	if err != nil {
+		// e.g. an inline patch or a directory
+		return
+	}
+	kt.inputs().Scope(owner).Record(inputType, fLdr.InputURI(kt.ldr, path), content)
+}
+
+// snapshot returns the YAML of the resources of m, when the build links
+// its resources to their inputs, to tell which ones change, see linkChanged.
+func (kt *KustTarget) snapshot(m resmap.ResMap) map[*resource.Resource]string {
+	if !kt.inputs().LinkResources() {
+		return nil
+	}
+	result := make(map[*resource.Resource]string, m.Size())
+	for _, res := range m.Resources() {
+		result[res] = resourceYaml(res)
+	}
+	return result
+}
+
+func resourceYaml(res *resource.Resource) string {
+	b, err := res.AsYAML()
+	if err != nil {
+		return ""
+	}
+	return string(b)
+}
+
+// linkChanged links the resources of m that are not in the snapshot
+// before, or changed since, to the inputs with the given keys.
+func (kt *KustTarget) linkChanged(before map[*resource.Resource]string, m resmap.ResMap, keys []string) {
+	if !kt.inputs().LinkResources() {
+		return
+	}
+	for _, res := range m.Resources() {
+		if y, ok := before[res]; ok && y == resourceYaml(res) {
+			continue
+		}
+		res.AddInputs(keys...)
+	}
+}
+
+// linkAll links all the resources of m to the inputs with the given keys.
+func (kt *KustTarget) linkAll(m resmap.ResMap, keys []string) {
+	for _, res := range m.Resources() {
+		kt.linkResource(res, keys)
+	}
+}
+
+func (kt *KustTarget) linkResource(res *resource.Resource, keys []string) {
+	if kt.inputs().LinkResources() {
+		res.AddInputs(keys...)
+	}
+}
+
+// linkTransformed runs the transformer t, then links the resources it
+// created or modified to what t read.
+func (kt *KustTarget) linkTransformed(t resmap.Transformer, m resmap.ResMap, run func() error) error {
+	before := kt.snapshot(m)
+	if err := run(); err != nil {
+		return err
+	}
+	kt.linkChanged(before, m, kt.inputs().Scope(t).Keys())
+	return nil
+}
@@ -0,0 +1,62 @@
+// Copyright 2021 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package target
+
+import (
+	"sigs.k8s.io/kustomize/api/resmap"
+	"sigs.k8s.io/kustomize/api/trace"
+)
+
+// tracer returns the recorder of the steps of the resources of the build, or nil.
+func (kt *KustTarget) tracer() *trace.Recorder {
+	if kt.pLdr == nil {
+		return nil
+	}
+	return kt.pLdr.Tracer()
+}
+
+// inputURI returns the URI of the input with the given key, as the
+// provenance of the build has it.
+func (kt *KustTarget) inputURI(key string) string {
+	input, _ := kt.inputs().Input(key)
+	return input.URI
+}
+
+// step returns a step of the given type run by the kustomization of kt.
+func (kt *KustTarget) step(stepType trace.StepType) trace.Step {
+	return trace.Step{Type: stepType, Kustomization: kt.inputURI(kt.inputKey)}
+}
+
+// traceTransformed runs the transformer t, then records the
+// resources it changed in the trace of the build.
+func (kt *KustTarget) traceTransformed(t resmap.Transformer, m resmap.ResMap, run func() error) error {
+	return kt.tracer().Observe(kt.step(trace.StepTransformer), t, m, run)
+}
+
+// observeTransformers returns how the transformers of kt are run to
+// link or trace the resources they change, or nil.
+func (kt *KustTarget) observeTransformers() func(resmap.Transformer, resmap.ResMap, func() error) error {
+	link, traced := kt.inputs().LinkResources(), kt.tracer() != nil
+	switch {
+	case link && traced:
+		return func(t resmap.Transformer, m resmap.ResMap, run func() error) error {
+			return kt.linkTransformed(t, m, func() error {
+				return kt.traceTransformed(t, m, run)
+			})
+		}
+	case link:
+		return kt.linkTransformed
+	case traced:
+		return kt.traceTransformed
+	default:
+		return nil
+	}
+}
+
+// transformerFunc adapts a function to a resmap.Transformer.
+type transformerFunc func(m resmap.ResMap) error
+
+func (f transformerFunc) Transform(m resmap.ResMap) error {
+	return f(m)
+}
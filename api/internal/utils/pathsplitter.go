@@ -20,3 +20,32 @@ func PathSplitter(path string) []string {
 	}
 	return res
 }
+
+// FieldPathSplitter splits a dot delimited field path, permitting escaped
+// dots, e.g. in data.application\.yaml, and dots in list item matchers,
+// e.g. in spec.containers.[name=app.v1].image.
+func FieldPathSplitter(path string) []string {
+	var res []string
+	var field strings.Builder
+	brackets := 0
+	for i := 0; i < len(path); i++ {
+		c := path[i]
+		switch {
+		case c == '\\' && i+1 < len(path) && path[i+1] == '.':
+			field.WriteByte('.')
+			i++
+		case c == '[':
+			brackets++
+			field.WriteByte(c)
+		case c == ']' && brackets > 0:
+			brackets--
+			field.WriteByte(c)
+		case c == '.' && brackets == 0:
+			res = append(res, field.String())
+			field.Reset()
+		default:
+			field.WriteByte(c)
+		}
+	}
+	return append(res, field.String())
+}
@@ -47,3 +47,29 @@ func TestPathSplitter(t *testing.T) {
 		assert.Equal(t, tc.exp, PathSplitter(tc.path))
 	}
 }
+
+func TestFieldPathSplitter(t *testing.T) {
+	for _, tc := range []struct {
+		exp  []string
+		path string
+	}{
+		{
+			path: "",
+			exp:  []string{""},
+		},
+		{
+			path: "a.b.c",
+			exp:  []string{"a", "b", "c"},
+		},
+		{
+			path: `data.application\.yaml`,
+			exp:  []string{"data", "application.yaml"},
+		},
+		{
+			path: "spec.containers.[name=app.v1].image",
+			exp:  []string{"spec", "containers", "[name=app.v1]", "image"},
+		},
+	} {
+		assert.Equal(t, tc.exp, FieldPathSplitter(tc.path))
+	}
+}
@@ -0,0 +1,231 @@
+// Copyright 2021 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package inventory records the objects of a build, with a hash of their
+// content, in an inventory ConfigMap emitted along with them, so that an
+// apply can tell which objects of a previous build to prune.
+package inventory
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+
+	"sigs.k8s.io/kustomize/api/resmap"
+	"sigs.k8s.io/kustomize/api/resource"
+	"sigs.k8s.io/kustomize/api/types"
+)
+
+const (
+	// TypeConfigMap is the only type of inventory object, the default.
+	TypeConfigMap = "ConfigMap"
+
+	// HashAnnotation holds the hash of the content of an inventory
+	// ConfigMap, i.e. of the objects it lists and their hashes.
+	HashAnnotation = "kustomize.config.k8s.io/InventoryHash"
+
+	// fieldSeparator separates the fields of an object ID.
+	fieldSeparator = "_"
+	// colonTranscoded replaces the colons of names, e.g. of
+	// system:controller roles, which data keys cannot hold.
+	colonTranscoded = "__"
+)
+
+// ObjectID identifies an object of a build in an inventory.
+type ObjectID struct {
+	Group     string `json:"group,omitempty"`
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+}
+
+// String returns id as namespace_name_group_kind, the key of id
+// in the data of an inventory ConfigMap.
+func (id ObjectID) String() string {
+	return strings.Join([]string{
+		id.Namespace,
+		strings.ReplaceAll(id.Name, ":", colonTranscoded),
+		id.Group,
+		id.Kind,
+	}, fieldSeparator)
+}
+
+// ParseObjectID parses an id returned by ObjectID.String.
+func ParseObjectID(s string) (ObjectID, error) {
+	// only names hold separators, the transcoded colons
+	fields := strings.Split(s, fieldSeparator)
+	if len(fields) < 4 || fields[len(fields)-1] == "" {
+		return ObjectID{}, fmt.Errorf("invalid inventory object id %q", s)
+	}
+	name := strings.Join(fields[1:len(fields)-2], fieldSeparator)
+	if name == "" {
+		return ObjectID{}, fmt.Errorf("invalid inventory object id %q", s)
+	}
+	return ObjectID{
+		Namespace: fields[0],
+		Name:      strings.ReplaceAll(name, colonTranscoded, ":"),
+		Group:     fields[len(fields)-2],
+		Kind:      fields[len(fields)-1],
+	}, nil
+}
+
+func objectID(res *resource.Resource) ObjectID {
+	id := res.CurId()
+	return ObjectID{Group: id.Group, Kind: id.Kind, Namespace: id.Namespace, Name: id.Name}
+}
+
+// Inventory maps the objects of a build to the hashes of their content.
+type Inventory struct {
+	Objects map[ObjectID]string
+}
+
+// FromResMap returns the inventory of the resources of m, the
+// inventory ConfigMaps excepted.
+func FromResMap(m resmap.ResMap) (*Inventory, error) {
+	inv := &Inventory{Objects: make(map[ObjectID]string, m.Size())}
+	for _, res := range m.Resources() {
+		if isInventory(res) {
+			continue
+		}
+		c := res.DeepCopy()
+		c.RemoveBuildAnnotations()
+		y, err := c.AsYAML()
+		if err != nil {
+			return nil, err
+		}
+		inv.Objects[objectID(res)] = hash(y)
+	}
+	return inv, nil
+}
+
+// FromConfigMap returns the inventory listed by the inventory ConfigMap res.
+func FromConfigMap(res *resource.Resource) (*Inventory, error) {
+	if !isInventory(res) {
+		return nil, fmt.Errorf("%s is not an inventory", res.CurId())
+	}
+	data := res.GetDataMap()
+	inv := &Inventory{Objects: make(map[ObjectID]string, len(data))}
+	for key, value := range data {
+		id, err := ParseObjectID(key)
+		if err != nil {
+			return nil, err
+		}
+		inv.Objects[id] = value
+	}
+	if h := inv.Hash(); h != res.GetAnnotations()[HashAnnotation] {
+		return nil, fmt.Errorf(
+			"inventory %s does not match its %s annotation", res.CurId(), HashAnnotation)
+	}
+	return inv, nil
+}
+
+// Load returns the inventory of the output of a build: the one listed
+// by its inventory ConfigMap if it has one, or the one of its resources.
+func Load(m resmap.ResMap) (*Inventory, error) {
+	var found []*resource.Resource
+	for _, res := range m.Resources() {
+		if isInventory(res) {
+			found = append(found, res)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return FromResMap(m)
+	case 1:
+		return FromConfigMap(found[0])
+	default:
+		return nil, fmt.Errorf("found %d inventories, expected at most one", len(found))
+	}
+}
+
+// Hash returns the hash of the content of inv.
+func (inv *Inventory) Hash() string {
+	var lines []string
+	for id, h := range inv.Objects {
+		lines = append(lines, id.String()+"="+h+"\n")
+	}
+	sort.Strings(lines)
+	return hash([]byte(strings.Join(lines, "")))
+}
+
+// ConfigMap returns the inventory ConfigMap listing inv as configured by args.
+func (inv *Inventory) ConfigMap(rf *resource.Factory, args types.Inventory) (*resource.Resource, error) {
+	if args.Type != "" && args.Type != TypeConfigMap {
+		return nil, fmt.Errorf(
+			"unsupported inventory type %q, expected %s", args.Type, TypeConfigMap)
+	}
+	if args.ConfigMap.Name == "" {
+		return nil, fmt.Errorf("inventory has no configMap name")
+	}
+	metadata := map[string]interface{}{
+		"name":        args.ConfigMap.Name,
+		"annotations": map[string]interface{}{HashAnnotation: inv.Hash()},
+	}
+	if args.ConfigMap.Namespace != "" {
+		metadata["namespace"] = args.ConfigMap.Namespace
+	}
+	data := make(map[string]interface{}, len(inv.Objects))
+	for id, h := range inv.Objects {
+		data[id.String()] = h
+	}
+	return rf.FromMap(map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata":   metadata,
+		"data":       data,
+	}), nil
+}
+
+// Diff is what changes from an inventory to the next one.
+type Diff struct {
+	// Prune are the objects of the previous inventory not in the next one.
+	Prune []ObjectID `json:"prune"`
+	// Add are the objects of the next inventory not in the previous one.
+	Add []ObjectID `json:"add"`
+	// Change are the objects of both inventories with different content.
+	Change []ObjectID `json:"change"`
+	// Unchanged counts the objects of both inventories with the same content.
+	Unchanged int `json:"unchanged"`
+}
+
+// Compare returns what changes from the inventory previous to next.
+func Compare(previous, next *Inventory) Diff {
+	d := Diff{Prune: []ObjectID{}, Add: []ObjectID{}, Change: []ObjectID{}}
+	for id, h := range previous.Objects {
+		switch nextHash, ok := next.Objects[id]; {
+		case !ok:
+			d.Prune = append(d.Prune, id)
+		case nextHash != h:
+			d.Change = append(d.Change, id)
+		default:
+			d.Unchanged++
+		}
+	}
+	for id := range next.Objects {
+		if _, ok := previous.Objects[id]; !ok {
+			d.Add = append(d.Add, id)
+		}
+	}
+	for _, ids := range [][]ObjectID{d.Prune, d.Add, d.Change} {
+		sortIDs(ids)
+	}
+	return d
+}
+
+func sortIDs(ids []ObjectID) {
+	sort.Slice(ids, func(i, j int) bool {
+		return ids[i].String() < ids[j].String()
+	})
+}
+
+func isInventory(res *resource.Resource) bool {
+	_, ok := res.GetAnnotations()[HashAnnotation]
+	return ok && res.GetKind() == TypeConfigMap
+}
+
+func hash(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,63 @@
+// Copyright 2021 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package inventory
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/kustomize/api/provider"
+	"sigs.k8s.io/kustomize/api/types"
+)
+
+func TestObjectID(t *testing.T) {
+	for s, id := range map[string]ObjectID{
+		"prod_web_apps_Deployment": {Group: "apps", Kind: "Deployment", Namespace: "prod", Name: "web"},
+		"_prod__Namespace":         {Kind: "Namespace", Name: "prod"},
+		"_system__web____edit_rbac.authorization.k8s.io_ClusterRole": {Group: "rbac.authorization.k8s.io", Kind: "ClusterRole", Name: "system:web::edit"},
+	} {
+		assert.Equal(t, s, id.String())
+		parsed, err := ParseObjectID(s)
+		require.NoError(t, err)
+		assert.Equal(t, id, parsed)
+	}
+	for _, s := range []string{"", "prod_web", "prod__apps_Deployment", "prod_web_apps_"} {
+		_, err := ParseObjectID(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestConfigMap(t *testing.T) {
+	rf := provider.NewDefaultDepProvider().GetResourceFactory()
+	inv := &Inventory{Objects: map[ObjectID]string{
+		{Kind: "Namespace", Name: "prod"}: "1234",
+	}}
+	res, err := inv.ConfigMap(rf, types.Inventory{ConfigMap: types.NameArgs{Name: "inv"}})
+	require.NoError(t, err)
+	parsed, err := FromConfigMap(res)
+	require.NoError(t, err)
+	assert.Equal(t, inv, parsed)
+
+	res.SetDataMap(map[string]string{"_prod__Namespace": "5678"})
+	_, err = FromConfigMap(res)
+	assert.EqualError(t, err, "inventory ~G_v1_ConfigMap|~X|inv does not match its "+
+		"kustomize.config.k8s.io/InventoryHash annotation")
+}
+
+func TestCompare(t *testing.T) {
+	kept := ObjectID{Kind: "Namespace", Name: "prod"}
+	changed := ObjectID{Group: "apps", Kind: "Deployment", Namespace: "prod", Name: "web"}
+	pruned := ObjectID{Kind: "Service", Namespace: "prod", Name: "old"}
+	added := ObjectID{Kind: "Service", Namespace: "prod", Name: "new"}
+	d := Compare(
+		&Inventory{Objects: map[ObjectID]string{kept: "1", changed: "2", pruned: "3"}},
+		&Inventory{Objects: map[ObjectID]string{kept: "1", changed: "4", added: "5"}})
+	assert.Equal(t, Diff{
+		Prune:     []ObjectID{pruned},
+		Add:       []ObjectID{added},
+		Change:    []ObjectID{changed},
+		Unchanged: 1,
+	}, d)
+}
@@ -37,6 +37,13 @@ const (
 	// If a resource has this annotation, kustomize will drop it.
 	IgnoredByKustomizeAnnotation = ConfigAnnoDomain + "/local-config"
 
+	// Records, on a HelmChart, the config file that set each of its values.
+	HelmValuesOriginsAnnotation = ConfigAnnoDomain + "/helmValuesOrigins"
+
+	// Records, on a ConfigMap or Secret, the SuperConfigMap or SuperSecret
+	// configs that set each of its keys.
+	KeyOriginsAnnotation = ConfigAnnoDomain + "/keyOrigins"
+
 	// Label key that indicates the resources are built from Kustomize
 	ManagedbyLabelKey = "app.kubernetes.io/managed-by"
 
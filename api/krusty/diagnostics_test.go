@@ -0,0 +1,120 @@
+// Copyright 2021 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package krusty_test
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/kustomize/api/diagnostics"
+	"sigs.k8s.io/kustomize/api/filesys"
+	"sigs.k8s.io/kustomize/api/krusty"
+	"sigs.k8s.io/kustomize/api/types"
+)
+
+func writeDiagnosticsKustomization(t *testing.T) filesys.FileSystem {
+	fSys := filesys.MakeFsInMemory()
+	for path, content := range map[string]string{
+		"/app/kustomization.yaml": `
+resources:
+- deployment.yaml
+transformers:
+- searchReplace.yaml
+- |-
+  apiVersion: qlik.com/v1
+  kind: SearchReplace
+  metadata:
+    name: inline
+  target:
+    kind: Deployment
+  path: metadata/labels/app
+  search: foo
+  replace: bar
+`,
+		"/app/deployment.yaml": `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: foo
+  labels:
+    app: foo
+`,
+		"/app/searchReplace.yaml": `
+apiVersion: qlik.com/v1
+kind: SearchReplace
+metadata:
+  name: misspelled
+target:
+  kind: Deployment
+  name: fooo
+path: metadata/labels/app
+search: foo
+replace: bar
+`,
+	} {
+		assert.NoError(t, fSys.WriteFile(path, []byte(content)))
+	}
+	return fSys
+}
+
+func TestDiagnostics(t *testing.T) {
+	fSys := writeDiagnosticsKustomization(t)
+	result, err := krusty.MakeKustomizer(krusty.MakeDefaultOptions()).RunWithResult(fSys, "/app")
+	assert.NoError(t, err)
+	labels := result.Resources.Resources()[0].GetLabels()
+	assert.Equal(t, "bar", labels["app"])
+	assert.Equal(t, []diagnostics.Diagnostic{{
+		Plugin:     "SearchReplace",
+		Name:       "misspelled",
+		ConfigPath: "/app/searchReplace.yaml",
+		Target:     "~G_~V_Deployment|~X|fooo:a=:l=",
+		Reason:     diagnostics.ReasonTargetNotFound,
+		Message:    "target selector matched no resources",
+	}}, result.Diagnostics)
+}
+
+func TestDiagnosticsStrict(t *testing.T) {
+	fSys := writeDiagnosticsKustomization(t)
+	opts := krusty.MakeDefaultOptions()
+	opts.Strict = true
+	result, err := krusty.MakeKustomizer(opts).RunWithResult(fSys, "/app")
+	var errStrict *diagnostics.ErrStrict
+	assert.True(t, errors.As(err, &errStrict))
+	assert.Equal(t, 1, len(errStrict.Diagnostics))
+	assert.Equal(t, result.Diagnostics, errStrict.Diagnostics)
+}
+
+// Concurrent runs of a Kustomizer each report their own diagnostics.
+func TestDiagnosticsConcurrentRuns(t *testing.T) {
+	fSys := writeDiagnosticsKustomization(t)
+	assert.NoError(t, fSys.WriteFile("/clean/kustomization.yaml", []byte("resources:\n- ../app/deployment.yaml\n")))
+	opts := krusty.MakeDefaultOptions()
+	opts.LoadRestrictions = types.LoadRestrictionsNone
+	k := krusty.MakeKustomizer(opts)
+	var wg sync.WaitGroup
+	results := make([]*krusty.Result, 8)
+	errs := make([]error, len(results))
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			path := "/app"
+			if i%2 == 1 {
+				path = "/clean"
+			}
+			results[i], errs[i] = k.RunWithResult(fSys, path)
+		}(i)
+	}
+	wg.Wait()
+	for i, result := range results {
+		assert.NoError(t, errs[i])
+		if i%2 == 1 {
+			assert.Empty(t, result.Diagnostics)
+		} else {
+			assert.Equal(t, 1, len(result.Diagnostics))
+		}
+	}
+}
@@ -0,0 +1,72 @@
+// Copyright 2021 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package krusty_test
+
+import (
+	"testing"
+
+	kusttest_test "sigs.k8s.io/kustomize/api/testutils/kusttest"
+)
+
+// The envFrom refs upserted by EnvUpsert get the names of the generated ConfigMaps.
+func TestEnvUpsertEnvFromNameReference(t *testing.T) {
+	th := kusttest_test.MakeHarness(t)
+	th.WriteK(".", `
+resources:
+- deployment.yaml
+configMapGenerator:
+- name: app-config
+  literals:
+  - FOO=foo
+transformers:
+- |-
+  apiVersion: qlik.com/v1
+  kind: EnvUpsert
+  metadata:
+    name: app-config
+  enabled: true
+  target:
+    kind: Deployment
+  containers:
+  - app
+  envFrom:
+  - configMapRef:
+      name: app-config
+`)
+	th.WriteF("deployment.yaml", `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+spec:
+  template:
+    spec:
+      containers:
+      - name: app
+      - name: sidecar
+`)
+	m := th.Run(".", th.MakeDefaultOptions())
+	th.AssertActualEqualsExpected(m, `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+spec:
+  template:
+    spec:
+      containers:
+      - envFrom:
+        - configMapRef:
+            name: app-config-ffhfbd4982
+        name: app
+      - name: sidecar
+---
+apiVersion: v1
+data:
+  FOO: foo
+kind: ConfigMap
+metadata:
+  name: app-config-ffhfbd4982
+`)
+}
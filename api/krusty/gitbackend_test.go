@@ -0,0 +1,100 @@
+// Copyright 2021 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package krusty_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/kustomize/api/filesys"
+	"sigs.k8s.io/kustomize/api/konfig"
+	"sigs.k8s.io/kustomize/api/krusty"
+	"sigs.k8s.io/kustomize/api/types"
+)
+
+// GoGetter fetches its component with the go-git backend,
+// here from a git mirror, without a git program.
+func TestGitBackendGoGitGoGetter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kustomize-gitbackend-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	repoDir := filepath.Join(dir, "mirror", "example.com", "org", "component.git")
+	writeFiles(t, repoDir, map[string]string{
+		"manifests/kustomization.yaml": `
+configMapGenerator:
+- name: component
+  literals:
+  - foo=bar
+generatorOptions:
+  disableNameSuffixHash: true
+`,
+		"docs/README.md": "docs",
+	})
+	r, err := gogit.PlainInit(repoDir, false)
+	assert.NoError(t, err)
+	wt, err := r.Worktree()
+	assert.NoError(t, err)
+	assert.NoError(t, wt.AddGlob("."))
+	commit, err := wt.Commit("component", &gogit.CommitOptions{
+		Author: &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()},
+	})
+	assert.NoError(t, err)
+
+	writeFiles(t, dir, map[string]string{
+		"app/kustomization.yaml": `
+generators:
+- gogetter.yaml
+`,
+		"app/gogetter.yaml": `
+apiVersion: qlik.com/v1
+kind: GoGetter
+metadata:
+  name: component
+url: https://example.com/org/component?ref=master
+cwd: manifests
+`,
+	})
+	pluginHome := filepath.Join(dir, "plugins")
+	assert.NoError(t, os.Mkdir(pluginHome, 0777))
+	assert.NoError(t, os.Setenv(konfig.KustomizePluginHomeEnv, pluginHome))
+	defer os.Unsetenv(konfig.KustomizePluginHomeEnv)
+
+	// no git program on the PATH
+	path := os.Getenv("PATH")
+	assert.NoError(t, os.Setenv("PATH", pluginHome))
+	defer os.Setenv("PATH", path)
+
+	opts := krusty.MakeDefaultOptions()
+	opts.LockfileMode = types.LockfileUpdate
+	opts.PluginConfig.GitBackend = types.GitBackendGoGit
+	opts.PluginConfig.MirrorConfig = types.MirrorConfig{Offline: true, GitMirror: filepath.Join(dir, "mirror")}
+	m, err := krusty.MakeKustomizer(opts).Run(filesys.MakeFsOnDisk(), filepath.Join(dir, "app"))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	yml, err := m.AsYaml()
+	assert.NoError(t, err)
+	assert.Equal(t, `apiVersion: v1
+data:
+  foo: bar
+kind: ConfigMap
+metadata:
+  name: component
+`, string(yml))
+
+	cloneDir := filepath.Join(pluginHome, "qlik", "v1", "repos", "component")
+	_, err = os.Stat(filepath.Join(cloneDir, "docs"))
+	assert.True(t, os.IsNotExist(err))
+	lock, err := ioutil.ReadFile(filepath.Join(dir, "app", "kustomization.lock"))
+	assert.NoError(t, err)
+	assert.True(t, strings.Contains(string(lock), commit.String()), string(lock))
+}
@@ -0,0 +1,126 @@
+// Copyright 2021 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package krusty_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/kustomize/api/filesys"
+	"sigs.k8s.io/kustomize/api/krusty"
+	"sigs.k8s.io/kustomize/api/provenance"
+)
+
+func TestInputsGomsertDataSources(t *testing.T) {
+	fSys := filesys.MakeFsInMemory()
+	for path, content := range map[string]string{
+		"/app/kustomization.yaml": `
+resources:
+- configmap.yaml
+transformers:
+- |-
+  apiVersion: qlik.com/v1
+  kind: Gomsert
+  metadata:
+    name: greeting
+  dataSources:
+  - values=values.yaml
+  inputFile: template.yaml
+  target:
+    kind: ConfigMap
+  path: data
+`,
+		"/app/configmap.yaml": `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: greeting
+data:
+  greeting: hello
+`,
+		"/app/values.yaml":   "who: world\n",
+		"/app/template.yaml": "greeting: hello {{ (ds \"values\").who }}\n",
+	} {
+		assert.NoError(t, fSys.WriteFile(path, []byte(content)))
+	}
+	result, err := krusty.MakeKustomizer(krusty.MakeDefaultOptions()).RunWithResult(fSys, "/app")
+	assert.NoError(t, err)
+	yml, err := result.Resources.AsYaml()
+	assert.NoError(t, err)
+	assert.Equal(t, `apiVersion: v1
+data:
+  greeting: hello world
+kind: ConfigMap
+metadata:
+  name: greeting
+`, string(yml))
+	assert.Equal(t, []provenance.Input{{
+		Type:   provenance.InputFile,
+		URI:    "template.yaml",
+		Digest: map[string]string{"sha256": "3825d3818b0554602f2f2aa1b3dd31bb649482179beb862d55dc56ce0d1c670a"},
+	}, {
+		Type:   provenance.InputGomplateDataSource,
+		URI:    "values.yaml",
+		Digest: map[string]string{"sha256": "373379ad28506ed0f1ea5e691d11e72dc251c56129b5336f471b7fd5c311b302"},
+	}, {
+		Type:   provenance.InputKustomization,
+		URI:    "kustomization.yaml",
+		Digest: map[string]string{"sha256": "d8ab43a103995c5e85cad56ff6ad3f3f16815077dcbbcf298e41f4333388743c"},
+	}, {
+		Type:   provenance.InputResource,
+		URI:    "configmap.yaml",
+		Digest: map[string]string{"sha256": "1a0caeacf8316cf301c7f0f025186af846c294d77e9f39b7f47c57c31fcc9dcd"},
+	}}, result.Inputs)
+}
+
+// Plugins record the absolute path of their local inputs, which the
+// inputs of the build have relative to its root, not to the plugin.
+func TestInputsGomsertDataSourcesOfBase(t *testing.T) {
+	fSys := filesys.MakeFsInMemory()
+	for path, content := range map[string]string{
+		"/app/kustomization.yaml": `
+resources:
+- base
+`,
+		"/app/base/kustomization.yaml": `
+resources:
+- configmap.yaml
+transformers:
+- |-
+  apiVersion: qlik.com/v1
+  kind: Gomsert
+  metadata:
+    name: greeting
+  dataSources:
+  - values=data/values.yaml
+  inputFile: template.yaml
+  target:
+    kind: ConfigMap
+  path: data
+`,
+		"/app/base/configmap.yaml": `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: greeting
+`,
+		"/app/base/data/values.yaml": "who: world\n",
+		"/app/base/template.yaml":    "greeting: hello {{ (ds \"values\").who }}\n",
+	} {
+		assert.NoError(t, fSys.WriteFile(path, []byte(content)))
+	}
+	result, err := krusty.MakeKustomizer(krusty.MakeDefaultOptions()).RunWithResult(fSys, "/app")
+	assert.NoError(t, err)
+	var uris []string
+	for _, input := range result.Inputs {
+		uris = append(uris, string(input.Type)+" "+input.URI)
+	}
+	assert.Equal(t, []string{
+		string(provenance.InputFile) + " base/template.yaml",
+		string(provenance.InputGomplateDataSource) + " base/data/values.yaml",
+		string(provenance.InputKustomization) + " base/kustomization.yaml",
+		string(provenance.InputKustomization) + " kustomization.yaml",
+		string(provenance.InputResource) + " base/configmap.yaml",
+	}, uris)
+}
@@ -0,0 +1,43 @@
+// Copyright 2021 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package krusty
+
+import (
+	"fmt"
+
+	"sigs.k8s.io/kustomize/api/internal/target"
+	"sigs.k8s.io/kustomize/api/inventory"
+	"sigs.k8s.io/kustomize/api/resmap"
+	"sigs.k8s.io/kustomize/api/resource"
+	"sigs.k8s.io/kustomize/api/trace"
+)
+
+// addInventory appends to m the inventory ConfigMap the kustomization
+// of kt declares, listing the other resources of m.
+func (b *runState) addInventory(kt *target.KustTarget, m resmap.ResMap, rf *resource.Factory) error {
+	k := kt.Kustomization()
+	if k.Inventory == nil {
+		if b.options.DoPrune {
+			return fmt.Errorf("pruning requires an inventory in the kustomization")
+		}
+		return nil
+	}
+	args := *k.Inventory
+	if args.ConfigMap.Namespace == "" {
+		args.ConfigMap.Namespace = k.Namespace
+	}
+	input, _ := b.inputs.Input(kt.InputKey())
+	step := trace.Step{Type: trace.StepGenerator, Plugin: "Inventory", Kustomization: input.URI}
+	return b.tracer.Observe(step, nil, m, func() error {
+		inv, err := inventory.FromResMap(m)
+		if err != nil {
+			return err
+		}
+		res, err := inv.ConfigMap(rf, args)
+		if err != nil {
+			return err
+		}
+		return m.Append(res)
+	})
+}
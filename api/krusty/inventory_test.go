@@ -0,0 +1,115 @@
+// Copyright 2021 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package krusty_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/kustomize/api/filesys"
+	"sigs.k8s.io/kustomize/api/inventory"
+	"sigs.k8s.io/kustomize/api/krusty"
+)
+
+func writeInventoryApp(t *testing.T, fSys filesys.FileSystem, inv string) {
+	t.Helper()
+	require.NoError(t, fSys.WriteFile("/app/kustomization.yaml", []byte(`
+namespace: prod
+resources:
+- resources.yaml
+`+inv)))
+	require.NoError(t, fSys.WriteFile("/app/resources.yaml", []byte(`
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata:
+  name: system:web
+`)))
+}
+
+func TestInventory(t *testing.T) {
+	fSys := filesys.MakeFsInMemory()
+	writeInventoryApp(t, fSys, `
+inventory:
+  type: ConfigMap
+  configMap:
+    name: inventory
+`)
+	k := krusty.MakeKustomizer(krusty.MakeDefaultOptions())
+	m, err := k.Run(fSys, "/app")
+	require.NoError(t, err)
+	yml, err := m.AsYaml()
+	require.NoError(t, err)
+	assert.Equal(t, `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: prod
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata:
+  name: system:web
+---
+apiVersion: v1
+data:
+  _system__web_rbac.authorization.k8s.io_ClusterRole: e6018c16e30c8af499db53a37125dc89e4d7a077cd53ce8426ea6c237e232447
+  prod_web_apps_Deployment: eba0b2a715ea87210f9bd2734dd4eea8b60f88dd643550db6489f9c1e88dc277
+kind: ConfigMap
+metadata:
+  annotations:
+    kustomize.config.k8s.io/InventoryHash: cb459b1606f13d54d1dc1125cd1fbbaeb8f3445d6fa9ece21dbbbc79f04fcfde
+  name: inventory
+  namespace: prod
+`, string(yml))
+
+	inv, err := inventory.Load(m)
+	require.NoError(t, err)
+	assert.Len(t, inv.Objects, 2)
+	assert.Contains(t, inv.Objects, inventory.ObjectID{
+		Group: "rbac.authorization.k8s.io", Kind: "ClusterRole", Name: "system:web"})
+}
+
+func TestInventoryErrors(t *testing.T) {
+	for name, tc := range map[string]struct {
+		inv     string
+		doPrune bool
+		err     string
+	}{
+		"noInventoryToPrune": {
+			doPrune: true,
+			err:     "pruning requires an inventory in the kustomization",
+		},
+		"unsupportedType": {
+			inv: `
+inventory:
+  type: Secret
+  configMap:
+    name: inventory
+`,
+			err: `unsupported inventory type "Secret", expected ConfigMap`,
+		},
+		"noName": {
+			inv: `
+inventory:
+  type: ConfigMap
+`,
+			err: "inventory has no configMap name",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			fSys := filesys.MakeFsInMemory()
+			writeInventoryApp(t, fSys, tc.inv)
+			opts := krusty.MakeDefaultOptions()
+			opts.DoPrune = tc.doPrune
+			_, err := krusty.MakeKustomizer(opts).Run(fSys, "/app")
+			assert.EqualError(t, err, tc.err)
+		})
+	}
+}
@@ -0,0 +1,100 @@
+// Copyright 2021 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package krusty_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/kustomize/api/filesys"
+	"sigs.k8s.io/kustomize/api/konfig"
+	"sigs.k8s.io/kustomize/api/krusty"
+	"sigs.k8s.io/kustomize/api/types"
+)
+
+// The GoGetters of a kustomization fetch their components concurrently
+// with more than one job, the output is the same as with one.
+func TestJobsGoGetter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kustomize-jobs-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	var generators []string
+	for i := 0; i < 6; i++ {
+		name := fmt.Sprintf("component%d", i)
+		repoDir := filepath.Join(dir, "mirror", "example.com", "org", name+".git")
+		writeFiles(t, repoDir, map[string]string{
+			"manifests/kustomization.yaml": fmt.Sprintf(`
+configMapGenerator:
+- name: %s
+  literals:
+  - index=%d
+generatorOptions:
+  disableNameSuffixHash: true
+`, name, i),
+		})
+		r, err := gogit.PlainInit(repoDir, false)
+		assert.NoError(t, err)
+		wt, err := r.Worktree()
+		assert.NoError(t, err)
+		assert.NoError(t, wt.AddGlob("."))
+		_, err = wt.Commit(name, &gogit.CommitOptions{
+			Author: &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()},
+		})
+		assert.NoError(t, err)
+		generators = append(generators, fmt.Sprintf(`apiVersion: qlik.com/v1
+kind: GoGetter
+metadata:
+  name: %s
+url: https://example.com/org/%s?ref=master
+cwd: manifests
+`, name, name))
+	}
+	writeFiles(t, dir, map[string]string{
+		"app/kustomization.yaml": `
+generators:
+- gogetters.yaml
+`,
+		"app/gogetters.yaml": strings.Join(generators, "---\n"),
+	})
+	pluginHome := filepath.Join(dir, "plugins")
+	assert.NoError(t, os.Mkdir(pluginHome, 0777))
+	assert.NoError(t, os.Setenv(konfig.KustomizePluginHomeEnv, pluginHome))
+	defer os.Unsetenv(konfig.KustomizePluginHomeEnv)
+
+	build := func(jobs int) string {
+		opts := krusty.MakeDefaultOptions()
+		opts.PluginConfig.GitBackend = types.GitBackendGoGit
+		opts.PluginConfig.MirrorConfig = types.MirrorConfig{Offline: true, GitMirror: filepath.Join(dir, "mirror")}
+		opts.PluginConfig.Jobs = jobs
+		m, err := krusty.MakeKustomizer(opts).Run(filesys.MakeFsOnDisk(), filepath.Join(dir, "app"))
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		yml, err := m.AsYaml()
+		assert.NoError(t, err)
+		return string(yml)
+	}
+	parallel := build(4)
+	assert.Equal(t, build(1), parallel)
+	var expected []string
+	for i := 0; i < 6; i++ {
+		expected = append(expected, fmt.Sprintf(`apiVersion: v1
+data:
+  index: "%d"
+kind: ConfigMap
+metadata:
+  name: component%d
+`, i, i))
+	}
+	assert.Equal(t, strings.Join(expected, "---\n"), parallel)
+}
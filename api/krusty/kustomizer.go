@@ -8,7 +8,11 @@ import (
 	"path/filepath"
 
 	"sigs.k8s.io/kustomize/api/builtins"
+	"sigs.k8s.io/kustomize/api/diagnostics"
 	"sigs.k8s.io/kustomize/api/filesys"
+	"sigs.k8s.io/kustomize/api/helmvalues"
+	"sigs.k8s.io/kustomize/api/ifc"
+	"sigs.k8s.io/kustomize/api/internal/lockfile"
 	pLdr "sigs.k8s.io/kustomize/api/internal/plugins/loader"
 	"sigs.k8s.io/kustomize/api/internal/target"
 	"sigs.k8s.io/kustomize/api/konfig"
@@ -16,6 +20,7 @@ import (
 	"sigs.k8s.io/kustomize/api/provenance"
 	"sigs.k8s.io/kustomize/api/provider"
 	"sigs.k8s.io/kustomize/api/resmap"
+	"sigs.k8s.io/kustomize/api/trace"
 	"sigs.k8s.io/kustomize/api/types"
 	"sigs.k8s.io/kustomize/kyaml/openapi"
 )
@@ -27,11 +32,52 @@ import (
 // To use, load a filesystem with kustomization files (any
 // number of overlays and bases), then make a Kustomizer
 // injected with the given fileystem, then call Run.
+//
+// A Kustomizer holds no state of its runs, so Run and RunWithResult
+// may be called concurrently.
 type Kustomizer struct {
 	options     *Options
 	depProvider *provider.DepProvider
 }
 
+// Result is what a Run recorded besides the resources it built.
+type Result struct {
+	// Resources are the resources built, nil when the Run failed.
+	Resources resmap.ResMap
+	// Diagnostics are the warnings plugins emitted, including during a Run that failed.
+	Diagnostics []diagnostics.Diagnostic
+	// Inputs are what the Run read, e.g. kustomization files, patches
+	// or template datasources, including during a Run that failed.
+	Inputs []provenance.Input
+	// Provenance is the provenance of Resources with the Provenance option, or nil.
+	Provenance *provenance.Statement
+	// HelmValues are the values the HelmCharts recorded with the HelmValues option, or nil.
+	HelmValues *helmvalues.Report
+	// Trace are the traces of Resources, the steps that created or
+	// modified each one, with the Trace option.
+	Trace []trace.Resource
+}
+
+// runState is the state of a Run.
+type runState struct {
+	*Kustomizer
+	diagnostics *diagnostics.Collector
+	inputs      *provenance.Recorder
+	lockfile    *lockfile.Lockfile
+	statement   *provenance.Statement
+	tracer      *trace.Recorder
+	trace       []trace.Resource
+	helmValues  *helmvalues.Report
+	memo        *memo
+
+	// nested is set for the builds plugins run through a nestedBuilder,
+	// which share the lock file, diagnostics, inputs, helm values and memo of their parent.
+	nested bool
+	// memoKeys are the memo keys whose computation runs this nested build.
+	memoKeys []string
+	env      map[string]string
+}
+
 // MakeKustomizer returns an instance of Kustomizer.
 func MakeKustomizer(o *Options) *Kustomizer {
 	return &Kustomizer{
@@ -51,23 +97,90 @@ func MakeKustomizer(o *Options) *Kustomizer {
 // of internal paths (e.g. the filesystem may contain multiple overlays,
 // and Run can be called on each of them).
 func (b *Kustomizer) Run(
+	fSys filesys.FileSystem, path string) (resmap.ResMap, error) {
+	result, err := b.RunWithResult(fSys, path)
+	return result.Resources, err
+}
+
+// RunWithResult is Run returning, besides the resources, what the Run
+// recorded. The Result is returned when the Run fails too.
+func (b *Kustomizer) RunWithResult(
+	fSys filesys.FileSystem, path string) (*Result, error) {
+	s := &runState{
+		Kustomizer:  b,
+		diagnostics: diagnostics.NewCollector(),
+		inputs:      provenance.NewRecorder(),
+		memo:        newMemo(),
+	}
+	if b.options.Trace {
+		s.tracer = trace.NewRecorder()
+	}
+	if b.options.HelmValues {
+		s.helmValues = helmvalues.NewReport()
+	}
+	m, err := s.run(fSys, path)
+	result := &Result{
+		Diagnostics: s.diagnostics.Diagnostics(),
+		Inputs:      s.inputs.Inputs(),
+		HelmValues:  s.helmValues,
+	}
+	if err != nil {
+		return result, err
+	}
+	result.Resources = m
+	result.Provenance = s.statement
+	result.Trace = s.trace
+	return result, nil
+}
+
+func (b *runState) run(
 	fSys filesys.FileSystem, path string) (resmap.ResMap, error) {
 	resmapFactory := resmap.NewFactory(b.depProvider.GetResourceFactory())
+	if !b.nested {
+		lockDir := ""
+		if fSys.IsDir(path) {
+			lockDir = path
+		}
+		var err error
+		if b.lockfile, err = lockfile.Load(fSys, lockDir, b.options.LockfileMode); err != nil {
+			return nil, err
+		}
+	}
 	lr := fLdr.RestrictionNone
 	if b.options.LoadRestrictions == types.LoadRestrictionsRootOnly {
 		lr = fLdr.RestrictionRootOnly
 	}
-	ldr, err := fLdr.NewLoader(lr, path, fSys)
+	gitBackend := types.GitBackendExec
+	if b.options.PluginConfig != nil {
+		gitBackend = b.options.PluginConfig.GitBackend
+	}
+	// a nil *lockfile.Lockfile would make a non-nil ifc.Lockfile
+	var lf ifc.Lockfile
+	if b.lockfile != nil {
+		lf = b.lockfile
+	}
+	ldr, err := fLdr.NewLoaderWithGitBackend(lr, path, fSys, gitBackend, lf)
 	if err != nil {
 		return nil, err
 	}
 	defer ldr.Cleanup()
+	if !b.nested {
+		b.inputs.SetRoot(ldr.Root())
+		b.inputs.SetLinkResources(b.options.Provenance)
+	}
+	// The plugin configs are always located on disk, regardless of the fSys passed in
+	pl := pLdr.NewLoader(b.options.PluginConfig, resmapFactory, filesys.MakeFsOnDisk())
+	pl.SetDiagnostics(b.diagnostics)
+	pl.SetInputs(b.inputs)
+	pl.SetLockfile(lf)
+	pl.SetTracer(b.tracer)
+	pl.SetHelmValues(b.helmValues)
+	pl.SetNestedBuilder(&nestedBuilder{parent: b, keys: b.memoKeys}, b.env)
 	kt := target.NewKustTarget(
 		ldr,
 		b.depProvider.GetFieldValidator(),
 		resmapFactory,
-		// The plugin configs are always located on disk, regardless of the fSys passed in
-		pLdr.NewLoader(b.options.PluginConfig, resmapFactory, filesys.MakeFsOnDisk()),
+		pl,
 	)
 	err = kt.Load()
 	if err != nil {
@@ -102,8 +215,38 @@ func (b *Kustomizer) Run(
 				CreateIfNotPresent: true,
 			}},
 		}
-		t.Transform(m)
+		step := trace.Step{Type: trace.StepTransformer, Plugin: "LabelTransformer"}
+		if err = b.tracer.Observe(step, &t, m, func() error { return t.Transform(m) }); err != nil {
+			return nil, err
+		}
+	}
+	if !b.nested {
+		if err = b.addInventory(kt, m, resmapFactory.RF()); err != nil {
+			return nil, err
+		}
 	}
+	b.trace = b.tracer.Resources(m)
+	links := resourceInputs(m)
 	m.RemoveBuildAnnotations()
+	if b.nested {
+		// the plugin that runs the nested build links what it returns
+		// to what it read, the inputs of the nested build included.
+		for res, keys := range links {
+			res.AddInputs(keys...)
+		}
+	}
+	if ds := b.diagnostics.Diagnostics(); b.options.Strict && !b.nested && len(ds) > 0 {
+		return nil, &diagnostics.ErrStrict{Diagnostics: ds}
+	}
+	if !b.nested {
+		if err = b.lockfile.Save(fSys); err != nil {
+			return nil, err
+		}
+	}
+	if b.options.Provenance && !b.nested {
+		if b.statement, err = makeStatement(b.inputs, path, kt, m, links); err != nil {
+			return nil, err
+		}
+	}
 	return m, nil
 }
@@ -0,0 +1,182 @@
+// Copyright 2021 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package krusty_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/kustomize/api/filesys"
+	"sigs.k8s.io/kustomize/api/krusty"
+)
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	for path, content := range files {
+		path = filepath.Join(dir, filepath.FromSlash(path))
+		assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0777))
+		assert.NoError(t, ioutil.WriteFile(path, []byte(content), 0666))
+	}
+}
+
+// GoGetter components that are already on disk, as named by KUZ_COMMON_<name>,
+// are built in-process, once per run, and hand their common components
+// down to the GoGetters of their own build.
+func TestNestedBuildGoGetter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kustomize-nested-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	commonDir := filepath.Join(dir, "common")
+	writeFiles(t, dir, map[string]string{
+		"app/kustomization.yaml": `
+resources:
+- a
+- b
+`,
+		"app/a/kustomization.yaml": `
+namePrefix: a-
+generators:
+- gogetter.yaml
+`,
+		"app/b/kustomization.yaml": `
+namePrefix: b-
+generators:
+- gogetter.yaml
+`,
+		"app/a/gogetter.yaml": `
+apiVersion: qlik.com/v1
+kind: GoGetter
+metadata:
+  name: component
+url: https://example.com/component
+cwd: manifests
+commonComponents:
+- name: common
+  path: ` + commonDir + `
+`,
+		"app/b/gogetter.yaml": `
+apiVersion: qlik.com/v1
+kind: GoGetter
+metadata:
+  name: component
+url: https://example.com/component
+cwd: manifests
+commonComponents:
+- name: common
+  path: ` + commonDir + `
+`,
+		"component/manifests/kustomization.yaml": `
+configMapGenerator:
+- name: component
+  literals:
+  - foo=bar
+generatorOptions:
+  disableNameSuffixHash: true
+generators:
+- gogetter.yaml
+`,
+		"component/manifests/gogetter.yaml": `
+apiVersion: qlik.com/v1
+kind: GoGetter
+metadata:
+  name: common
+url: https://example.com/common
+`,
+		"common/kustomization.yaml": `
+resources:
+- service.yaml
+`,
+		"common/service.yaml": `
+apiVersion: v1
+kind: Service
+metadata:
+  name: common
+`,
+	})
+	assert.NoError(t, os.Setenv("KUZ_COMMON_component", filepath.Join(dir, "component")))
+	defer os.Unsetenv("KUZ_COMMON_component")
+	wd, err := os.Getwd()
+	assert.NoError(t, err)
+
+	m, err := krusty.MakeKustomizer(krusty.MakeDefaultOptions()).Run(filesys.MakeFsOnDisk(), filepath.Join(dir, "app"))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	yml, err := m.AsYaml()
+	assert.NoError(t, err)
+	assert.Equal(t, `apiVersion: v1
+data:
+  foo: bar
+kind: ConfigMap
+metadata:
+  name: a-component
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: a-common
+---
+apiVersion: v1
+data:
+  foo: bar
+kind: ConfigMap
+metadata:
+  name: b-component
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: b-common
+`, string(yml))
+	cwd, err := os.Getwd()
+	assert.NoError(t, err)
+	assert.Equal(t, wd, cwd)
+}
+
+// A component that fetches itself fails the build instead of
+// waiting forever for its own memoized build.
+func TestNestedBuildGoGetterFetchingItself(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kustomize-nested-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	gogetter := `
+apiVersion: qlik.com/v1
+kind: GoGetter
+metadata:
+  name: component
+url: https://example.com/component
+cwd: manifests
+`
+	writeFiles(t, dir, map[string]string{
+		"app/kustomization.yaml": `
+generators:
+- gogetter.yaml
+`,
+		"app/gogetter.yaml": gogetter,
+		"component/manifests/kustomization.yaml": `
+generators:
+- gogetter.yaml
+`,
+		"component/manifests/gogetter.yaml": gogetter,
+	})
+	assert.NoError(t, os.Setenv("KUZ_COMMON_component", filepath.Join(dir, "component")))
+	defer os.Unsetenv("KUZ_COMMON_component")
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := krusty.MakeKustomizer(krusty.MakeDefaultOptions()).Run(filesys.MakeFsOnDisk(), filepath.Join(dir, "app"))
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "is needed by a nested build of its own computation")
+		}
+	case <-time.After(time.Minute):
+		t.Fatal("the build of a component fetching itself deadlocked")
+	}
+}
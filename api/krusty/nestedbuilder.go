@@ -0,0 +1,95 @@
+// Copyright 2021 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package krusty
+
+import (
+	"fmt"
+	"sync"
+
+	"sigs.k8s.io/kustomize/api/filesys"
+	"sigs.k8s.io/kustomize/api/resmap"
+)
+
+// nestedBuilder builds kustomizations on behalf of the plugins of a
+// Run, e.g. the components fetched by the GoGetter generator,
+// with the same options, lock file, diagnostics, inputs and helm values.
+type nestedBuilder struct {
+	parent *runState
+	// keys are the memo keys whose computation runs the builds of nb.
+	keys []string
+}
+
+var _ resmap.NestedBuilder = &nestedBuilder{}
+
+// Build builds the kustomization in dir, on disk, in-process.
+func (nb *nestedBuilder) Build(dir string, env map[string]string) (resmap.ResMap, error) {
+	nestedEnv := make(map[string]string, len(nb.parent.env)+len(env))
+	for k, v := range nb.parent.env {
+		nestedEnv[k] = v
+	}
+	for k, v := range env {
+		nestedEnv[k] = v
+	}
+	s := &runState{
+		Kustomizer:  nb.parent.Kustomizer,
+		diagnostics: nb.parent.diagnostics,
+		inputs:      nb.parent.inputs,
+		lockfile:    nb.parent.lockfile,
+		helmValues:  nb.parent.helmValues,
+		memo:        nb.parent.memo,
+		nested:      true,
+		memoKeys:    nb.keys,
+		env:         nestedEnv,
+	}
+	return s.run(filesys.MakeFsOnDisk(), dir)
+}
+
+// Memo returns the value computed by f the first time key is used in the build.
+func (nb *nestedBuilder) Memo(key string, f func(resmap.NestedBuilder) (interface{}, error)) (interface{}, error) {
+	keys := append(nb.keys[:len(nb.keys):len(nb.keys)], key)
+	return nb.parent.memo.get(key, nb.keys, func() (interface{}, error) {
+		return f(&nestedBuilder{parent: nb.parent, keys: keys})
+	})
+}
+
+// memo holds the values memoized by the plugins of a build and its nested builds.
+type memo struct {
+	mu      sync.Mutex
+	entries map[string]*memoEntry
+}
+
+type memoEntry struct {
+	done  chan struct{}
+	value interface{}
+	err   error
+}
+
+func newMemo() *memo {
+	return &memo{entries: make(map[string]*memoEntry)}
+}
+
+// get returns the value computed by f for key, computing it unless another caller
+// did or does. computing are the keys whose computation the caller runs in, which
+// waiting for key would deadlock if key is one of them.
+func (m *memo) get(key string, computing []string, f func() (interface{}, error)) (interface{}, error) {
+	for _, k := range computing {
+		if k == key {
+			return nil, fmt.Errorf("%q is needed by a nested build of its own computation, "+
+				"e.g. a component that fetches itself", key)
+		}
+	}
+	m.mu.Lock()
+	e, ok := m.entries[key]
+	if ok {
+		m.mu.Unlock()
+		<-e.done
+		return e.value, e.err
+	}
+	e = &memoEntry{done: make(chan struct{})}
+	m.entries[key] = e
+	m.mu.Unlock()
+	defer close(e.done)
+	e.value, e.err = f()
+	return e.value, e.err
+}
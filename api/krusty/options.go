@@ -27,9 +27,31 @@ type Options struct {
 	// See type definition.
 	LoadRestrictions types.LoadRestrictions
 
-	// Create an inventory object for pruning.
+	// When true, the build fails unless the kustomization has an
+	// inventory, the object listing the others that an apply prunes
+	// with. The inventory is emitted whenever there is one.
 	DoPrune bool
 
+	// How the kustomization.lock file pinning remote
+	// inputs is used. See type definition.
+	LockfileMode types.LockfileMode
+
+	// When true, a build in which plugins emitted
+	// diagnostics fails instead of returning resources.
+	Strict bool
+
+	// When true, the build links its resources to the inputs
+	// that created or modified them, see Kustomizer.Provenance.
+	Provenance bool
+
+	// When true, the build records the generators and transformers
+	// that created or modified each resource, see Kustomizer.Trace.
+	Trace bool
+
+	// When true, HelmCharts record their values instead of being
+	// rendered, see Kustomizer.HelmValues.
+	HelmValues bool
+
 	// Options related to kustomize plugins.
 	PluginConfig *types.PluginConfig
 }
@@ -41,6 +63,8 @@ func MakeDefaultOptions() *Options {
 		AddManagedbyLabel:    false,
 		LoadRestrictions:     types.LoadRestrictionsRootOnly,
 		DoPrune:              false,
+		LockfileMode:         types.LockfileDisabled,
+		Strict:               false,
 		PluginConfig:         types.DisabledPluginConfig(),
 	}
 }
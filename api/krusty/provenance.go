@@ -0,0 +1,60 @@
+// Copyright 2021 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package krusty
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+
+	"sigs.k8s.io/kustomize/api/internal/target"
+	"sigs.k8s.io/kustomize/api/provenance"
+	"sigs.k8s.io/kustomize/api/resmap"
+	"sigs.k8s.io/kustomize/api/resource"
+)
+
+// resourceInputs returns the keys of the inputs linked to the resources of m.
+func resourceInputs(m resmap.ResMap) map[*resource.Resource][]string {
+	result := make(map[*resource.Resource][]string)
+	for _, res := range m.Resources() {
+		if keys := res.GetInputs(); len(keys) > 0 {
+			result[res] = keys
+		}
+	}
+	return result
+}
+
+// makeStatement returns the provenance of the resources of m, built
+// from the kustomization of kt at path.
+func makeStatement(
+	r *provenance.Recorder, path string, kt *target.KustTarget, m resmap.ResMap,
+	links map[*resource.Resource][]string) (*provenance.Statement, error) {
+	var subjects []provenance.Subject
+	subjectLinks := make(map[string][]string)
+	for _, res := range m.Resources() {
+		y, err := res.AsYAML()
+		if err != nil {
+			return nil, err
+		}
+		sum := sha256.Sum256(y)
+		name := subjectName(res)
+		subjects = append(subjects, provenance.Subject{
+			Name:   name,
+			Digest: map[string]string{"sha256": hex.EncodeToString(sum[:])},
+		})
+		subjectLinks[name] = links[res]
+	}
+	entryPoint, _ := r.Input(kt.InputKey())
+	return provenance.NewStatement(r, path, entryPoint.URI, subjects, subjectLinks), nil
+}
+
+// subjectName returns the name of res in the provenance of a build,
+// apiVersion/kind/namespace/name, without the namespace when it has none.
+func subjectName(res *resource.Resource) string {
+	id := res.CurId()
+	name := id.ApiVersion() + "/" + id.Kind + "/"
+	if id.Namespace != "" {
+		name += id.Namespace + "/"
+	}
+	return name + id.Name
+}
@@ -0,0 +1,148 @@
+// Copyright 2021 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package krusty_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/kustomize/api/filesys"
+	"sigs.k8s.io/kustomize/api/krusty"
+	"sigs.k8s.io/kustomize/api/provenance"
+)
+
+func TestProvenance(t *testing.T) {
+	fSys := filesys.MakeFsInMemory()
+	for path, content := range map[string]string{
+		"/app/base/kustomization.yaml": `
+resources:
+- deployment.yaml
+- service.yaml
+`,
+		"/app/base/deployment.yaml": `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  replicas: 1
+`,
+		"/app/base/service.yaml": `
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+`,
+		"/app/overlay/kustomization.yaml": `
+namespace: prod
+resources:
+- ../base
+patchesStrategicMerge:
+- replicas.yaml
+components:
+- ../component
+`,
+		"/app/overlay/replicas.yaml": `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  replicas: 3
+`,
+		"/app/component/kustomization.yaml": `
+apiVersion: kustomize.config.k8s.io/v1alpha1
+kind: Component
+resources:
+- configmap.yaml
+`,
+		"/app/component/configmap.yaml": `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: extra
+`,
+	} {
+		require.NoError(t, fSys.WriteFile(path, []byte(content)))
+	}
+	opts := krusty.MakeDefaultOptions()
+	opts.Provenance = true
+	result, err := krusty.MakeKustomizer(opts).RunWithResult(fSys, "/app/overlay")
+	require.NoError(t, err)
+	yml, err := result.Resources.AsYaml()
+	require.NoError(t, err)
+	assert.NotContains(t, string(yml), "inputs")
+
+	s := result.Provenance
+	require.NotNil(t, s)
+	assert.Equal(t, provenance.StatementType, s.Type)
+	assert.Equal(t, provenance.PredicateType, s.PredicateType)
+	assert.Equal(t, "/app/overlay", s.Predicate.Invocation.ConfigSource.URI)
+	assert.Equal(t, "kustomization.yaml", s.Predicate.Invocation.ConfigSource.EntryPoint)
+	assert.NotEmpty(t, s.Predicate.Invocation.ConfigSource.Digest["sha256"])
+
+	var materials []string
+	for _, input := range s.Predicate.Materials {
+		assert.Len(t, input.Digest["sha256"], 64, input.URI)
+		materials = append(materials, string(input.Type)+" "+input.URI)
+	}
+	assert.ElementsMatch(t, []string{
+		"kustomization kustomization.yaml",
+		"kustomization ../base/kustomization.yaml",
+		"component ../component/kustomization.yaml",
+		"resource ../base/deployment.yaml",
+		"resource ../base/service.yaml",
+		"resource ../component/configmap.yaml",
+		"patch replicas.yaml",
+	}, materials)
+
+	var subjects []string
+	for _, subject := range s.Subject {
+		assert.Len(t, subject.Digest["sha256"], 64, subject.Name)
+		subjects = append(subjects, subject.Name)
+	}
+	assert.Equal(t, []string{
+		"apps/v1/Deployment/prod/web",
+		"v1/Service/prod/web",
+		"v1/ConfigMap/prod/extra",
+	}, subjects)
+	assert.Equal(t, []provenance.Link{{
+		Subject: "apps/v1/Deployment/prod/web",
+		Materials: []string{
+			"../base/deployment.yaml",
+			"../base/kustomization.yaml",
+			"kustomization.yaml",
+			"replicas.yaml",
+		},
+	}, {
+		Subject: "v1/Service/prod/web",
+		Materials: []string{
+			"../base/kustomization.yaml",
+			"../base/service.yaml",
+			"kustomization.yaml",
+		},
+	}, {
+		Subject: "v1/ConfigMap/prod/extra",
+		Materials: []string{
+			"../component/configmap.yaml",
+			"../component/kustomization.yaml",
+			"kustomization.yaml",
+		},
+	}}, s.Predicate.Links)
+}
+
+func TestProvenanceNotRequested(t *testing.T) {
+	fSys := filesys.MakeFsInMemory()
+	require.NoError(t, fSys.WriteFile("/app/kustomization.yaml", []byte("resources:\n- cm.yaml\n")))
+	require.NoError(t, fSys.WriteFile("/app/cm.yaml", []byte(`
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: cm
+`)))
+	result, err := krusty.MakeKustomizer(krusty.MakeDefaultOptions()).RunWithResult(fSys, "/app")
+	require.NoError(t, err)
+	assert.Nil(t, result.Provenance)
+}
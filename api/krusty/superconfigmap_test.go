@@ -0,0 +1,231 @@
+// Copyright 2021 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package krusty_test
+
+import (
+	"strings"
+	"testing"
+
+	kusttest_test "sigs.k8s.io/kustomize/api/testutils/kusttest"
+)
+
+// Every overlay that sets a key of the ConfigMap is recorded as its origin.
+func TestSuperConfigMapKeyOriginsAcrossOverlays(t *testing.T) {
+	th := kusttest_test.MakeHarness(t)
+	th.WriteK("base", `
+resources:
+- configmap.yaml
+- defaults.yaml
+`)
+	th.WriteF("base/configmap.yaml", `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: app-config
+data:
+  LOG_LEVEL: info
+`)
+	th.WriteF("base/defaults.yaml", `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: defaults
+data:
+  REGION: us-east-1
+  TIMEOUT: 30s
+`)
+	th.WriteK("staging", `
+resources:
+- ../base
+transformers:
+- |-
+  apiVersion: qlik.com/v1
+  kind: SuperConfigMap
+  metadata:
+    name: app-config
+  options:
+    disableNameSuffixHash: true
+  files:
+  - settings.json
+  envs:
+  - app.env
+  fromResources:
+  - kind: ConfigMap
+    name: defaults
+    keys:
+    - REGION
+`)
+	th.WriteF("staging/settings.json", `{"features":{"a":true},"replicas":1}`)
+	th.WriteF("staging/app.env", `LOG_LEVEL=debug
+`)
+	th.WriteK("prod", `
+resources:
+- ../staging
+transformers:
+- |-
+  apiVersion: qlik.com/v1
+  kind: SuperConfigMap
+  metadata:
+    name: app-config
+  options:
+    disableNameSuffixHash: true
+  keyBehaviors:
+  - keys:
+    - LOG_*
+    behavior: create
+  - keys:
+    - "*.json"
+    behavior: merge
+  data:
+    LOG_LEVEL: warn
+    settings.json: '{"features":{"b":true}}'
+`)
+	opts := th.MakeDefaultOptions()
+	opts.PluginConfig.KeyOrigins = true
+	m := th.Run("prod", opts)
+	th.AssertActualEqualsExpected(m, `
+apiVersion: v1
+data:
+  LOG_LEVEL: debug
+  REGION: us-east-1
+  settings.json: '{"features":{"a":true,"b":true},"replicas":1}'
+kind: ConfigMap
+metadata:
+  annotations:
+    config.kubernetes.io/keyOrigins: '{"LOG_LEVEL":["../staging"],"REGION":["../staging"],"settings.json":["../staging","."]}'
+  name: app-config
+---
+apiVersion: v1
+data:
+  REGION: us-east-1
+  TIMEOUT: 30s
+kind: ConfigMap
+metadata:
+  name: defaults
+`)
+
+	// Key origins are only recorded when the build asks for them.
+	m = th.Run("prod", th.MakeDefaultOptions())
+	th.AssertActualEqualsExpected(m, `
+apiVersion: v1
+data:
+  LOG_LEVEL: debug
+  REGION: us-east-1
+  settings.json: '{"features":{"a":true,"b":true},"replicas":1}'
+kind: ConfigMap
+metadata:
+  name: app-config
+---
+apiVersion: v1
+data:
+  REGION: us-east-1
+  TIMEOUT: 30s
+kind: ConfigMap
+metadata:
+  name: defaults
+`)
+}
+
+// A ConfigMap can be deleted by an overlay once nothing refers to it, and
+// keys inherited from a base can be removed.
+func TestSuperConfigMapDeleteAndRemoveKeys(t *testing.T) {
+	th := kusttest_test.MakeHarness(t)
+	th.WriteK("base", `
+resources:
+- resources.yaml
+`)
+	th.WriteF("base/resources.yaml", `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: app-config
+data:
+  LOG_LEVEL: info
+  DEBUG_PORT: "5005"
+  DEBUG_SUSPEND: "n"
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: legacy-config
+data:
+  MODE: legacy
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+spec:
+  template:
+    spec:
+      containers:
+      - name: app
+        envFrom:
+        - configMapRef:
+            name: legacy-config
+`)
+	th.WriteK("prod", `
+resources:
+- ../base
+patches:
+- patch: |-
+    - op: remove
+      path: /spec/template/spec/containers/0/envFrom
+  target:
+    kind: Deployment
+    name: app
+transformers:
+- |-
+  apiVersion: qlik.com/v1
+  kind: SuperConfigMap
+  metadata:
+    name: app-config
+  options:
+    disableNameSuffixHash: true
+  removeKeys:
+  - DEBUG_*
+- |-
+  apiVersion: qlik.com/v1
+  kind: SuperConfigMap
+  metadata:
+    name: legacy-config
+  delete: true
+`)
+	m := th.Run("prod", th.MakeDefaultOptions())
+	th.AssertActualEqualsExpected(m, `
+apiVersion: v1
+data:
+  LOG_LEVEL: info
+kind: ConfigMap
+metadata:
+  name: app-config
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+spec:
+  template:
+    spec:
+      containers:
+      - name: app
+`)
+
+	th.WriteK("staging", `
+resources:
+- ../base
+transformers:
+- |-
+  apiVersion: qlik.com/v1
+  kind: SuperConfigMap
+  metadata:
+    name: legacy-config
+  delete: true
+`)
+	err := th.RunWithErr("staging", th.MakeDefaultOptions())
+	if err == nil || !strings.Contains(err.Error(),
+		"ConfigMap legacy-config cannot be deleted, it is still referenced by: Deployment app at spec/template/spec/containers/envFrom/configMapRef/name") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
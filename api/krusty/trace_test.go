@@ -0,0 +1,156 @@
+// Copyright 2021 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package krusty_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/kustomize/api/filesys"
+	"sigs.k8s.io/kustomize/api/krusty"
+	"sigs.k8s.io/kustomize/api/trace"
+)
+
+func TestTrace(t *testing.T) {
+	fSys := filesys.MakeFsInMemory()
+	for path, content := range map[string]string{
+		"/app/base/kustomization.yaml": `
+resources:
+- deployment.yaml
+configMapGenerator:
+- name: cfg
+  literals:
+  - a=b
+`,
+		"/app/base/deployment.yaml": `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  replicas: 1
+  template:
+    spec:
+      containers:
+      - name: app
+        image: nginx
+        envFrom:
+        - configMapRef:
+            name: cfg
+`,
+		"/app/overlay/kustomization.yaml": `
+namePrefix: prod-
+resources:
+- ../base
+configMapGenerator:
+- name: cfg
+  behavior: merge
+  literals:
+  - c=d
+images:
+- name: nginx
+  newTag: "1.21"
+patches:
+- path: replicas.yaml
+`,
+		"/app/overlay/replicas.yaml": `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  replicas: 3
+`,
+	} {
+		require.NoError(t, fSys.WriteFile(path, []byte(content)))
+	}
+	opts := krusty.MakeDefaultOptions()
+	opts.Trace = true
+	result, err := krusty.MakeKustomizer(opts).RunWithResult(fSys, "/app/overlay")
+	require.NoError(t, err)
+	assert.Equal(t, []trace.Resource{{
+		Kind: "Deployment",
+		Name: "prod-web",
+		Steps: []trace.Step{{
+			Type:          trace.StepResource,
+			Source:        "../base/deployment.yaml",
+			Kustomization: "../base/kustomization.yaml",
+			Created:       true,
+		}, {
+			Type:          trace.StepTransformer,
+			Plugin:        "PatchTransformer",
+			Name:          "replicas.yaml",
+			Kustomization: "kustomization.yaml",
+			Changes:       []trace.Change{{Path: "spec.replicas", Before: 1, After: 3}},
+		}, {
+			Type:          trace.StepTransformer,
+			Plugin:        "PrefixSuffixTransformer",
+			Kustomization: "kustomization.yaml",
+			Changes:       []trace.Change{{Path: "metadata.name", Before: "web", After: "prod-web"}},
+		}, {
+			Type:          trace.StepTransformer,
+			Plugin:        "ImageTagTransformer",
+			Kustomization: "kustomization.yaml",
+			Changes: []trace.Change{{
+				Path:   "spec.template.spec.containers[name=app].image",
+				Before: "nginx",
+				After:  "nginx:1.21",
+			}},
+		}, {
+			Type:          trace.StepTransformer,
+			Plugin:        "NameReferenceTransformer",
+			Kustomization: "kustomization.yaml",
+			Changes: []trace.Change{{
+				Path:   "spec.template.spec.containers[name=app].envFrom[0].configMapRef.name",
+				Before: "cfg",
+				After:  "prod-cfg-fh478f99mk",
+			}},
+		}},
+	}, {
+		Kind: "ConfigMap",
+		Name: "prod-cfg-fh478f99mk",
+		Steps: []trace.Step{{
+			Type:          trace.StepGenerator,
+			Plugin:        "ConfigMapGenerator",
+			Name:          "cfg",
+			Kustomization: "../base/kustomization.yaml",
+			Created:       true,
+		}, {
+			Type:          trace.StepGenerator,
+			Plugin:        "ConfigMapGenerator",
+			Name:          "cfg",
+			Kustomization: "kustomization.yaml",
+			Changes:       []trace.Change{{Path: "data.c", After: "d"}},
+		}, {
+			Type:          trace.StepTransformer,
+			Plugin:        "PrefixSuffixTransformer",
+			Kustomization: "kustomization.yaml",
+			Changes:       []trace.Change{{Path: "metadata.name", Before: "cfg", After: "prod-cfg"}},
+		}, {
+			Type:          trace.StepTransformer,
+			Plugin:        "HashTransformer",
+			Kustomization: "kustomization.yaml",
+			Changes: []trace.Change{{
+				Path:   "metadata.name",
+				Before: "prod-cfg",
+				After:  "prod-cfg-fh478f99mk",
+			}},
+		}},
+	}}, result.Trace)
+}
+
+func TestTraceNotRequested(t *testing.T) {
+	fSys := filesys.MakeFsInMemory()
+	require.NoError(t, fSys.WriteFile("/app/kustomization.yaml", []byte("namePrefix: p-\nresources:\n- cm.yaml\n")))
+	require.NoError(t, fSys.WriteFile("/app/cm.yaml", []byte(`
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: cm
+`)))
+	result, err := krusty.MakeKustomizer(krusty.MakeDefaultOptions()).RunWithResult(fSys, "/app")
+	require.NoError(t, err)
+	assert.Nil(t, result.Trace)
+}
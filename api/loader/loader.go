@@ -8,6 +8,7 @@ import (
 	"sigs.k8s.io/kustomize/api/filesys"
 	"sigs.k8s.io/kustomize/api/ifc"
 	"sigs.k8s.io/kustomize/api/internal/git"
+	"sigs.k8s.io/kustomize/api/types"
 )
 
 // NewLoader returns a Loader pointed at the given target.
@@ -19,16 +20,27 @@ import (
 func NewLoader(
 	lr LoadRestrictorFunc,
 	target string, fSys filesys.FileSystem) (ifc.Loader, error) {
+	return NewLoaderWithGitBackend(lr, target, fSys, types.GitBackendExec, nil)
+}
+
+// NewLoaderWithGitBackend is NewLoader cloning remote
+// targets and bases with the given git backend, at the
+// commits pinned in the lock file lf, which may be nil.
+func NewLoaderWithGitBackend(
+	lr LoadRestrictorFunc,
+	target string, fSys filesys.FileSystem,
+	backend types.GitBackend, lf ifc.Lockfile) (ifc.Loader, error) {
+	cloner := git.ClonerFor(backend, lf)
 	repoSpec, err := git.NewRepoSpecFromUrl(target)
 	if err == nil {
 		// The target qualifies as a remote git target.
 		return newLoaderAtGitClone(
-			repoSpec, fSys, nil, git.ClonerUsingGitExec)
+			repoSpec, fSys, nil, cloner)
 	}
 	root, err := demandDirectoryRoot(fSys, target)
 	if err != nil {
 		return nil, err
 	}
 	return newLoaderAtConfirmedDir(
-		lr, root, fSys, nil, git.ClonerUsingGitExec), nil
+		lr, root, fSys, nil, cloner), nil
 }
@@ -0,0 +1,85 @@
+// Copyright 2021 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package loader
+
+import (
+	"path/filepath"
+	"strings"
+
+	"sigs.k8s.io/kustomize/api/ifc"
+	"sigs.k8s.io/kustomize/api/provenance"
+	"sigs.k8s.io/kustomize/api/types"
+)
+
+// InputURI returns the URI of the file at path, relative to the root
+// of ldr, in the provenance of a build: its absolute path, or its URL
+// with its path in the repository when ldr is in a remote base.
+func InputURI(ldr ifc.Loader, path string) string {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(ldr.Root(), path)
+	}
+	fl, ok := ldr.(*fileLoader)
+	if !ok {
+		return path
+	}
+	repo := fl.containingRepo()
+	if repo == nil {
+		return path
+	}
+	rel, err := filepath.Rel(repo.CloneDir().String(), path)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return path
+	}
+	ref := repo.Commit
+	if ref == "" {
+		ref = repo.Ref
+	}
+	uri := repo.CloneSpec() + "//" + filepath.ToSlash(rel)
+	if ref != "" {
+		uri += "?ref=" + ref
+	}
+	return uri
+}
+
+// RecordKvFiles records the files and env files of sources, loaded
+// through ldr, as inputs of r. Sources ldr cannot load, e.g. directories,
+// are skipped and left to the generator to report.
+func RecordKvFiles(r *provenance.Recorder, ldr ifc.Loader, sources types.KvPairSources) {
+	if r == nil {
+		return
+	}
+	var paths []string
+	for _, source := range sources.FileSources {
+		if i := strings.Index(source, "="); i >= 0 {
+			source = source[i+1:]
+		}
+		paths = append(paths, source)
+	}
+	paths = append(paths, sources.EnvSources...)
+	if sources.EnvSource != "" {
+		paths = append(paths, sources.EnvSource)
+	}
+	for _, path := range paths {
+		if content, err := ldr.Load(path); err == nil {
+			r.Record(provenance.InputFile, InputURI(ldr, path), content)
+		}
+	}
+}
+
+// RemoteBase returns the URL of the repository and the commit checked
+// out when ldr was opened at a remote base.
+func RemoteBase(ldr ifc.Loader) (url string, commit string, ok bool) {
+	fl, isFileLoader := ldr.(*fileLoader)
+	if !isFileLoader || fl.repoSpec == nil {
+		return "", "", false
+	}
+	url = fl.repoSpec.CloneSpec()
+	if fl.repoSpec.Path != "" {
+		url += "//" + fl.repoSpec.Path
+	}
+	if fl.repoSpec.Ref != "" {
+		url += "?ref=" + fl.repoSpec.Ref
+	}
+	return url, fl.repoSpec.Commit, true
+}
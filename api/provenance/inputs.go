@@ -0,0 +1,238 @@
+// Copyright 2021 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package provenance
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// InputType is the kind of an input of a build.
+type InputType string
+
+const (
+	// InputKustomization is a kustomization file.
+	InputKustomization InputType = "kustomization"
+	// InputComponent is the kustomization file of a component.
+	InputComponent InputType = "component"
+	// InputResource is a file of resources.
+	InputResource InputType = "resource"
+	// InputPatch is a patch file.
+	InputPatch InputType = "patch"
+	// InputFile is a file read by a generator or a plugin config file.
+	InputFile InputType = "file"
+	// InputGitRepository is a remote base, at a commit.
+	InputGitRepository InputType = "gitRepository"
+	// InputHelmChart is a helm chart, with the digest of its content.
+	InputHelmChart InputType = "helmChart"
+	// InputGoGetter is a git reference fetched by GoGetter, at a commit.
+	InputGoGetter InputType = "goGetter"
+	// InputGomplateDataSource is a datasource of a gomplate template.
+	InputGomplateDataSource InputType = "gomplateDataSource"
+)
+
+// Input is something a build read, e.g. a template datasource.
+type Input struct {
+	Type InputType `json:"type"`
+	// URI locates the input: the absolute path plugins record local inputs
+	// with, relative to the root of the build when it is recorded with one,
+	// or the URL of a remote input.
+	URI string `json:"uri"`
+	// Digest maps algorithms to the hex encoded digest of the content
+	// of the input, e.g. sha256, or of the commit of a git input, sha1.
+	// It is empty for the remote inputs the build does not read itself.
+	Digest map[string]string `json:"digest,omitempty"`
+}
+
+// Key identifies the input in a Recorder.
+func (i Input) Key() string {
+	key := string(i.Type) + " " + i.URI
+	algorithms := make([]string, 0, len(i.Digest))
+	for algorithm := range i.Digest {
+		algorithms = append(algorithms, algorithm)
+	}
+	sort.Strings(algorithms)
+	for _, algorithm := range algorithms {
+		key += " " + algorithm + ":" + i.Digest[algorithm]
+	}
+	return key
+}
+
+// recording is the state shared by a Recorder and its scopes.
+type recording struct {
+	mu     sync.Mutex
+	root   string
+	links  bool
+	inputs map[string]Input
+	scopes map[interface{}]*Recorder
+}
+
+// Recorder accumulates the inputs of a build.
+// All methods are safe on a nil receiver, which discards inputs.
+type Recorder struct {
+	*recording
+	// keys of the inputs recorded through the scope, nil for the root.
+	keys   map[string]bool
+	parent *Recorder
+}
+
+// NewRecorder returns an empty Recorder.
+func NewRecorder() *Recorder {
+	return &Recorder{recording: &recording{
+		inputs: make(map[string]Input),
+		scopes: make(map[interface{}]*Recorder),
+	}}
+}
+
+// SetRoot makes the local inputs recorded from now on have a URI
+// relative to dir, the root of the build, when they are absolute paths.
+func (r *Recorder) SetRoot(dir string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.root = dir
+}
+
+// Relative returns uri as the inputs recorded from now on have it,
+// relative to the root of the build when it is an absolute path.
+func (r *Recorder) Relative(uri string) string {
+	if r == nil {
+		return uri
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.relative(uri)
+}
+
+// SetLinkResources tells the build whether to link its resources to
+// the inputs that created or modified them, see LinkResources.
+func (r *Recorder) SetLinkResources(links bool) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.links = links
+}
+
+// LinkResources tells if the build links its resources to their inputs.
+func (r *Recorder) LinkResources() bool {
+	if r == nil {
+		return false
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.links
+}
+
+// Record records the input of the given type at uri, with the digest
+// of content unless it is nil, and returns its key.
+func (r *Recorder) Record(inputType InputType, uri string, content []byte) string {
+	var digest map[string]string
+	if content != nil {
+		sum := sha256.Sum256(content)
+		digest = map[string]string{"sha256": hex.EncodeToString(sum[:])}
+	}
+	return r.RecordDigest(inputType, uri, digest)
+}
+
+// RecordDigest records the input of the given type at uri with digest,
+// e.g. the commit of a git input, and returns its key.
+func (r *Recorder) RecordDigest(inputType InputType, uri string, digest map[string]string) string {
+	if r == nil {
+		return ""
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	input := Input{Type: inputType, URI: r.relative(uri), Digest: digest}
+	key := input.Key()
+	r.inputs[key] = input
+	for s := r; s != nil; s = s.parent {
+		if s.keys != nil {
+			s.keys[key] = true
+		}
+	}
+	return key
+}
+
+func (r *Recorder) relative(uri string) string {
+	if r.root == "" || !filepath.IsAbs(uri) {
+		return uri
+	}
+	rel, err := filepath.Rel(r.root, uri)
+	if err != nil {
+		return uri
+	}
+	return filepath.ToSlash(rel)
+}
+
+// Scope returns the recorder of what owner, e.g. a plugin, reads: it
+// records into r and keeps the keys of what was recorded through it.
+// The same scope is returned for an owner every time.
+func (r *Recorder) Scope(owner interface{}) *Recorder {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if s, ok := r.scopes[owner]; ok {
+		return s
+	}
+	s := &Recorder{recording: r.recording, keys: make(map[string]bool), parent: r}
+	r.scopes[owner] = s
+	return s
+}
+
+// Keys returns the sorted keys of the inputs recorded through r,
+// all of them for the root recorder.
+func (r *Recorder) Keys() []string {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var keys []string
+	if r.keys == nil {
+		for key := range r.inputs {
+			keys = append(keys, key)
+		}
+	} else {
+		for key := range r.keys {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Input returns the input recorded with key.
+func (r *Recorder) Input(key string) (Input, bool) {
+	if r == nil {
+		return Input{}, false
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	input, ok := r.inputs[key]
+	return input, ok
+}
+
+// Inputs returns the recorded inputs ordered by type, URI and digest,
+// without duplicates.
+func (r *Recorder) Inputs() []Input {
+	keys := r.Keys()
+	if keys == nil {
+		return nil
+	}
+	result := make([]Input, 0, len(keys))
+	for _, key := range keys {
+		input, _ := r.Input(key)
+		result = append(result, input)
+	}
+	return result
+}
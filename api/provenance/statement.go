@@ -0,0 +1,110 @@
+// Copyright 2021 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package provenance
+
+import "sort"
+
+const (
+	// StatementType is the type of an in-toto statement.
+	StatementType = "https://in-toto.io/Statement/v0.1"
+	// PredicateType is the type of a SLSA provenance predicate.
+	PredicateType = "https://slsa.dev/provenance/v0.2"
+	// BuildType is the type of the builds of kustomize.
+	BuildType = "https://kustomize.qlik.com/build/v1"
+)
+
+// Statement is an in-toto statement of the provenance of the
+// resources of a build, its subjects.
+type Statement struct {
+	Type          string    `json:"_type"`
+	PredicateType string    `json:"predicateType"`
+	Subject       []Subject `json:"subject"`
+	Predicate     Predicate `json:"predicate"`
+}
+
+// Subject is a resource of the output of a build.
+type Subject struct {
+	// Name identifies the resource as apiVersion/kind/namespace/name,
+	// without the namespace for resources that have none.
+	Name string `json:"name"`
+	// Digest maps sha256 to the digest of the YAML of the resource.
+	Digest map[string]string `json:"digest"`
+}
+
+// Predicate is a SLSA provenance predicate, with the links
+// of the subjects to the materials they come from.
+type Predicate struct {
+	Builder    Builder    `json:"builder"`
+	BuildType  string     `json:"buildType"`
+	Invocation Invocation `json:"invocation"`
+	Materials  []Input    `json:"materials"`
+	// Links has an entry for each subject, in the same order.
+	Links []Link `json:"links"`
+}
+
+// Builder identifies the kustomize executable that ran the build.
+type Builder struct {
+	ID string `json:"id"`
+}
+
+// Invocation locates the kustomization the build ran.
+type Invocation struct {
+	ConfigSource ConfigSource `json:"configSource"`
+}
+
+// ConfigSource is the kustomization a build ran.
+type ConfigSource struct {
+	URI        string            `json:"uri"`
+	Digest     map[string]string `json:"digest,omitempty"`
+	EntryPoint string            `json:"entryPoint"`
+}
+
+// Link lists the materials that created or modified a subject.
+type Link struct {
+	Subject string `json:"subject"`
+	// Materials are the URIs of the materials.
+	Materials []string `json:"materials"`
+}
+
+// NewStatement returns the statement of the provenance of subjects,
+// built from the kustomization at entryPoint in the build root uri.
+// links maps the subjects to the keys of the inputs of r they come from.
+func NewStatement(r *Recorder, uri, entryPoint string, subjects []Subject, links map[string][]string) *Statement {
+	s := &Statement{
+		Type:          StatementType,
+		PredicateType: PredicateType,
+		Subject:       subjects,
+		Predicate: Predicate{
+			Builder:    Builder{ID: GetProvenance().Version},
+			BuildType:  BuildType,
+			Invocation: Invocation{ConfigSource: ConfigSource{URI: uri, EntryPoint: entryPoint}},
+			Materials:  r.Inputs(),
+			Links:      make([]Link, 0, len(subjects)),
+		},
+	}
+	if s.Subject == nil {
+		s.Subject = []Subject{}
+	}
+	if s.Predicate.Materials == nil {
+		s.Predicate.Materials = []Input{}
+	}
+	for _, input := range s.Predicate.Materials {
+		if input.Type == InputKustomization && input.URI == entryPoint {
+			s.Predicate.Invocation.ConfigSource.Digest = input.Digest
+		}
+	}
+	for _, subject := range s.Subject {
+		materials := []string{}
+		seen := map[string]bool{}
+		for _, key := range links[subject.Name] {
+			if input, ok := r.Input(key); ok && !seen[input.URI] {
+				seen[input.URI] = true
+				materials = append(materials, input.URI)
+			}
+		}
+		sort.Strings(materials)
+		s.Predicate.Links = append(s.Predicate.Links, Link{Subject: subject.Name, Materials: materials})
+	}
+	return s
+}
@@ -6,7 +6,12 @@
 package resmap
 
 import (
+	"os"
+
+	"sigs.k8s.io/kustomize/api/diagnostics"
+	"sigs.k8s.io/kustomize/api/helmvalues"
 	"sigs.k8s.io/kustomize/api/ifc"
+	"sigs.k8s.io/kustomize/api/provenance"
 	"sigs.k8s.io/kustomize/api/resource"
 	"sigs.k8s.io/kustomize/api/types"
 	"sigs.k8s.io/kustomize/kyaml/resid"
@@ -25,6 +30,38 @@ type Generator interface {
 	Generate() (ResMap, error)
 }
 
+// A Prefetcher is a Generator that can fetch what it generates from,
+// e.g. a helm chart or a git repository, ahead of Generate. The fetches
+// of the generators of a kustomization run concurrently, before their
+// Generate runs in order.
+type Prefetcher interface {
+	// PrefetchKey names what Prefetch fetches into, e.g. a directory.
+	// Only the first of the generators of a kustomization with the same
+	// key is prefetched, the others fetch in Generate, as when the key
+	// is empty.
+	PrefetchKey() string
+	// Prefetch fetches what Generate needs. Generate must still work,
+	// fetching again if needed, when something else changed what was
+	// fetched in the meantime.
+	Prefetch() error
+}
+
+// A NestedBuilder builds kustomizations on behalf of plugins, e.g. the
+// ones fetched by a generator, in-process and with the options of the
+// running build.
+type NestedBuilder interface {
+	// Build builds the kustomization in the directory dir on disk.
+	// env is added to the env of the running build for the plugins
+	// of the nested build, see PluginHelpers.LookupEnv.
+	Build(dir string, env map[string]string) (ResMap, error)
+	// Memo returns the value computed by f for key the first time Memo
+	// is called with key during the running build, including its nested
+	// builds. Concurrent callers with the same key wait for the first one.
+	// f runs its nested builds with nb, so that a key its own nested
+	// builds ask for is an error instead of a deadlock.
+	Memo(key string, f func(nb NestedBuilder) (interface{}, error)) (interface{}, error)
+}
+
 // Something that's configurable accepts an
 // instance of PluginHelpers and a raw config
 // object (YAML in []byte form).
@@ -47,6 +84,104 @@ type PluginHelpers struct {
 	v   ifc.Validator
 	rf  *Factory
 	pc  *types.PluginConfig
+	dr  *diagnostics.Reporter
+	in  *provenance.Recorder
+	lf  ifc.Lockfile
+	hv  *helmvalues.Report
+	cp  string
+	nb  NestedBuilder
+	env map[string]string
+}
+
+// WithDiagnostics returns a copy of c whose Diagnostics is dr.
+func (c *PluginHelpers) WithDiagnostics(dr *diagnostics.Reporter) *PluginHelpers {
+	result := *c
+	result.dr = dr
+	return &result
+}
+
+// WithInputs returns a copy of c whose Inputs is r.
+func (c *PluginHelpers) WithInputs(r *provenance.Recorder) *PluginHelpers {
+	result := *c
+	result.in = r
+	return &result
+}
+
+// Inputs returns the recorder of what the plugin reads, e.g. template
+// datasources, for the provenance of the build. It may be nil, in which
+// case nothing is recorded.
+func (c *PluginHelpers) Inputs() *provenance.Recorder {
+	return c.in
+}
+
+// WithLockfile returns a copy of c whose Lockfile is lf.
+func (c *PluginHelpers) WithLockfile(lf ifc.Lockfile) *PluginHelpers {
+	result := *c
+	result.lf = lf
+	return &result
+}
+
+// Lockfile returns the lock file of the build, which pins the remote
+// inputs the plugin resolves and records them. It may be nil, in which
+// case nothing is pinned nor recorded.
+func (c *PluginHelpers) Lockfile() ifc.Lockfile {
+	return c.lf
+}
+
+// WithHelmValues returns a copy of c whose HelmValues is r.
+func (c *PluginHelpers) WithHelmValues(r *helmvalues.Report) *PluginHelpers {
+	result := *c
+	result.hv = r
+	return &result
+}
+
+// HelmValues returns the report HelmCharts record their values into
+// instead of being rendered. It may be nil, in which case they are rendered.
+func (c *PluginHelpers) HelmValues() *helmvalues.Report {
+	return c.hv
+}
+
+// WithConfigPath returns a copy of c whose ConfigPath is path.
+func (c *PluginHelpers) WithConfigPath(path string) *PluginHelpers {
+	result := *c
+	result.cp = path
+	return &result
+}
+
+// ConfigPath returns the file the plugin config was read from,
+// or the kustomization directory for inline configs. It may be empty.
+func (c *PluginHelpers) ConfigPath() string {
+	return c.cp
+}
+
+// WithNestedBuilder returns a copy of c whose NestedBuilder is nb
+// and whose LookupEnv looks up env before the process env.
+func (c *PluginHelpers) WithNestedBuilder(nb NestedBuilder, env map[string]string) *PluginHelpers {
+	result := *c
+	result.nb = nb
+	result.env = env
+	return &result
+}
+
+// NestedBuilder returns the builder for kustomizations the plugin needs to build.
+// It may be nil, e.g. for plugins configured outside of a krusty build.
+func (c *PluginHelpers) NestedBuilder() NestedBuilder {
+	return c.nb
+}
+
+// LookupEnv looks up key in the env handed down to a nested build,
+// then in the process env.
+func (c *PluginHelpers) LookupEnv(key string) (string, bool) {
+	if value, ok := c.env[key]; ok {
+		return value, true
+	}
+	return os.LookupEnv(key)
+}
+
+// Diagnostics returns the reporter the plugin emits its warnings into.
+// It may be nil, in which case warnings are discarded.
+func (c *PluginHelpers) Diagnostics() *diagnostics.Reporter {
+	return c.dr
 }
 
 func (c *PluginHelpers) GeneralConfig() *types.PluginConfig {
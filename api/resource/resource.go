@@ -4,10 +4,12 @@
 package resource
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
 	"reflect"
+	"sort"
 	"strings"
 
 	"sigs.k8s.io/kustomize/api/filters/patchstrategicmerge"
@@ -41,6 +43,12 @@ const (
 	// and kinds of their targets
 	buildAnnotationAllowNameChange = konfig.ConfigAnnoDomain + "/allowNameChange"
 	buildAnnotationAllowKindChange = konfig.ConfigAnnoDomain + "/allowKindChange"
+
+	// the file a plugin config was read from, for diagnostics
+	buildAnnotationPluginConfigPath = konfig.ConfigAnnoDomain + "/pluginConfigPath"
+
+	// the keys of the inputs that created or modified the resource, for provenance
+	buildAnnotationInputs = konfig.ConfigAnnoDomain + "/inputs"
 )
 
 var buildAnnotations = []string{
@@ -51,6 +59,9 @@ var buildAnnotations = []string{
 	buildAnnotationPreviousNamespaces,
 	buildAnnotationAllowNameChange,
 	buildAnnotationAllowKindChange,
+	buildAnnotationPluginConfigPath,
+	buildAnnotationInputs,
+	konfig.HelmValuesOriginsAnnotation,
 }
 
 func (r *Resource) AsRNode() *kyaml.RNode {
@@ -418,6 +429,61 @@ func (r *Resource) KindChangeAllowed() bool {
 	return false
 }
 
+// SetPluginConfigPath records the file this plugin config was read from.
+func (r *Resource) SetPluginConfigPath(path string) {
+	annotations := r.GetAnnotations()
+	annotations[buildAnnotationPluginConfigPath] = path
+	r.SetAnnotations(annotations)
+}
+
+// GetPluginConfigPath returns the file this plugin config was read from, if recorded.
+func (r *Resource) GetPluginConfigPath() string {
+	return r.GetAnnotations()[buildAnnotationPluginConfigPath]
+}
+
+// RemovePluginConfigPath removes the record of the file this plugin config was read from.
+func (r *Resource) RemovePluginConfigPath() {
+	annotations := r.GetAnnotations()
+	if _, set := annotations[buildAnnotationPluginConfigPath]; !set {
+		return
+	}
+	delete(annotations, buildAnnotationPluginConfigPath)
+	r.SetAnnotations(annotations)
+}
+
+// AddInputs records that the inputs with the given keys created or
+// modified the resource, see provenance.Recorder.
+func (r *Resource) AddInputs(keys ...string) {
+	inputs := r.GetInputs()
+	seen := make(map[string]bool, len(inputs))
+	for _, key := range inputs {
+		seen[key] = true
+	}
+	for _, key := range keys {
+		if key != "" && !seen[key] {
+			seen[key] = true
+			inputs = append(inputs, key)
+		}
+	}
+	if len(inputs) == 0 {
+		return
+	}
+	sort.Strings(inputs)
+	b, _ := json.Marshal(inputs)
+	annotations := r.GetAnnotations()
+	annotations[buildAnnotationInputs] = string(b)
+	r.SetAnnotations(annotations)
+}
+
+// GetInputs returns the keys of the inputs recorded by AddInputs.
+func (r *Resource) GetInputs() []string {
+	var inputs []string
+	if value, ok := r.GetAnnotations()[buildAnnotationInputs]; ok {
+		_ = json.Unmarshal([]byte(value), &inputs)
+	}
+	return inputs
+}
+
 // String returns resource as JSON.
 func (r *Resource) String() string {
 	bs, err := r.MarshalJSON()
@@ -0,0 +1,108 @@
+// Copyright 2021 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package trace
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"sigs.k8s.io/kustomize/api/resource"
+)
+
+// fields returns the leaf fields of res, scalars and empty maps or
+// lists, by path, leaving out the annotations internal to the build.
+func fields(res *resource.Resource) (map[string]interface{}, error) {
+	c := res.DeepCopy()
+	c.RemoveBuildAnnotations()
+	m, err := c.Map()
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string]interface{})
+	flatten("", m, result)
+	return result, nil
+}
+
+func flatten(path string, value interface{}, result map[string]interface{}) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if len(v) == 0 {
+			result[path] = map[string]interface{}{}
+			return
+		}
+		for key, child := range v {
+			flatten(fieldPath(path, key), child, result)
+		}
+	case []interface{}:
+		if len(v) == 0 {
+			result[path] = []interface{}{}
+			return
+		}
+		names := elementNames(v)
+		for i, child := range v {
+			if names != nil {
+				flatten(fmt.Sprintf("%s[name=%s]", path, names[i]), child, result)
+			} else {
+				flatten(fmt.Sprintf("%s[%d]", path, i), child, result)
+			}
+		}
+	default:
+		result[path] = v
+	}
+}
+
+func fieldPath(path, key string) string {
+	if strings.ContainsAny(key, ".[]") {
+		return path + "[" + key + "]"
+	}
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// elementNames returns the names of the elements of a list of maps with
+// distinct name fields, e.g. containers, to locate them by name rather
+// than by index, or nil.
+func elementNames(list []interface{}) []string {
+	names := make([]string, len(list))
+	seen := make(map[string]bool, len(list))
+	for i, element := range list {
+		m, ok := element.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		name, ok := m["name"].(string)
+		if !ok || name == "" || seen[name] {
+			return nil
+		}
+		seen[name] = true
+		names[i] = name
+	}
+	return names
+}
+
+// diff returns the changes from the fields before to the fields after,
+// ordered by path.
+func diff(before, after map[string]interface{}) []Change {
+	var changes []Change
+	for path, value := range before {
+		if other, ok := after[path]; !ok {
+			changes = append(changes, Change{Path: path, Before: value})
+		} else if !reflect.DeepEqual(value, other) {
+			changes = append(changes, Change{Path: path, Before: value, After: other})
+		}
+	}
+	for path, value := range after {
+		if _, ok := before[path]; !ok {
+			changes = append(changes, Change{Path: path, After: value})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+	return changes
+}
@@ -0,0 +1,269 @@
+// Copyright 2021 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package trace records, for each resource of a build, the ordered list
+// of the resource files, generators and transformers that created or
+// modified it, with the fields each one changed, e.g. to tell which
+// transformer of an overlay set a wrong value.
+package trace
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"sigs.k8s.io/kustomize/api/resmap"
+	"sigs.k8s.io/kustomize/api/resource"
+)
+
+// StepType is what a step of the trace of a resource ran.
+type StepType string
+
+const (
+	// StepResource means that the resource was read from a file.
+	StepResource StepType = "resource"
+	// StepGenerator means that a generator created or merged the resource.
+	StepGenerator StepType = "generator"
+	// StepTransformer means that a transformer modified the resource.
+	StepTransformer StepType = "transformer"
+)
+
+// Change is the change of a field of a resource by a step.
+type Change struct {
+	// Path locates the field, e.g. spec.template.spec.containers[name=app].image.
+	Path string `json:"path"`
+	// Before is the value of the field before the step, nil if it had none.
+	Before interface{} `json:"before,omitempty"`
+	// After is the value of the field after the step, nil if it was removed.
+	After interface{} `json:"after,omitempty"`
+}
+
+// Step is a resource file, generator or transformer that created
+// or modified a resource.
+type Step struct {
+	Type StepType `json:"type"`
+	// Plugin is the kind of the generator or transformer, e.g. PatchTransformer.
+	Plugin string `json:"plugin,omitempty"`
+	// Name is the metadata.name of the plugin config, if any.
+	Name string `json:"name,omitempty"`
+	// Source is the file the resource was read from.
+	Source string `json:"source,omitempty"`
+	// Kustomization is the kustomization file that ran the step.
+	Kustomization string `json:"kustomization,omitempty"`
+	// Created is set when the step created the resource.
+	Created bool `json:"created,omitempty"`
+	// Changes are the fields the step changed, when it did not create the resource.
+	Changes []Change `json:"changes,omitempty"`
+}
+
+func (s Step) String() string {
+	var b strings.Builder
+	b.WriteString(string(s.Type))
+	for _, field := range []string{s.Plugin, s.Name, s.Source} {
+		if field != "" {
+			fmt.Fprintf(&b, " %s", field)
+		}
+	}
+	if s.Kustomization != "" {
+		fmt.Fprintf(&b, " (%s)", s.Kustomization)
+	}
+	return b.String()
+}
+
+// Resource is the trace of a resource of the output of a build.
+type Resource struct {
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+	Steps     []Step `json:"steps"`
+}
+
+// ID returns kind/namespace/name, without the namespace when there is none.
+func (r Resource) ID() string {
+	if r.Namespace == "" {
+		return r.Kind + "/" + r.Name
+	}
+	return r.Kind + "/" + r.Namespace + "/" + r.Name
+}
+
+// Find returns the resources selected by query, kind/name with a
+// case insensitive kind, in any namespace, or * for all of them.
+func Find(resources []Resource, query string) ([]Resource, error) {
+	if query == "*" {
+		return resources, nil
+	}
+	parts := strings.Split(query, "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return nil, fmt.Errorf("invalid resource %q, expected kind/name", query)
+	}
+	var result []Resource
+	for _, r := range resources {
+		if strings.EqualFold(r.Kind, parts[0]) && r.Name == parts[1] {
+			result = append(result, r)
+		}
+	}
+	if len(result) == 0 {
+		return nil, fmt.Errorf("no resource %s in the output of the build", query)
+	}
+	return result, nil
+}
+
+type plugin struct {
+	kind string
+	name string
+}
+
+// Recorder records the steps of the resources of a build.
+// All methods are safe on a nil receiver, which records nothing.
+type Recorder struct {
+	mu      sync.Mutex
+	plugins map[interface{}]plugin
+	steps   map[*resource.Resource][]Step
+}
+
+// NewRecorder returns an empty Recorder.
+func NewRecorder() *Recorder {
+	return &Recorder{
+		plugins: make(map[interface{}]plugin),
+		steps:   make(map[*resource.Resource][]Step),
+	}
+}
+
+// SetPlugin names the generator or transformer p in the steps it runs,
+// after the kind and name of its config.
+func (r *Recorder) SetPlugin(p interface{}, kind, name string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.plugins[p] = plugin{kind: kind, name: name}
+}
+
+// Created records step as the creation of the resources of m.
+func (r *Recorder) Created(m resmap.ResMap, step Step) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	step.Created = true
+	for _, res := range m.Resources() {
+		r.steps[res] = append(r.steps[res], step)
+	}
+}
+
+// Observe runs run, a step of the generator or transformer p over m,
+// then records the step for the resources of m it created or changed.
+// Plugin and Name of the step default to what SetPlugin recorded for p.
+func (r *Recorder) Observe(step Step, p interface{}, m resmap.ResMap, run func() error) error {
+	if r == nil {
+		return run()
+	}
+	if step.Plugin == "" {
+		r.mu.Lock()
+		pl, ok := r.plugins[p]
+		r.mu.Unlock()
+		if ok {
+			step.Plugin, step.Name = pl.kind, pl.name
+		} else {
+			step.Plugin = pluginType(p)
+		}
+	}
+	before, err := snapshot(m.Resources())
+	if err != nil {
+		return err
+	}
+	if err = run(); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, res := range m.Resources() {
+		old, ok := before[res]
+		if !ok {
+			// a resource replaced by a new one, e.g. by a generator
+			// with the replace behavior, carries the steps of the old one.
+			if prior := findReplaced(before, m, res); prior != nil {
+				old = before[prior]
+				r.steps[res] = append(r.steps[prior], r.steps[res]...)
+				delete(r.steps, prior)
+				ok = true
+			}
+		}
+		if !ok {
+			created := step
+			created.Created = true
+			r.steps[res] = append(r.steps[res], created)
+			continue
+		}
+		current, err := fields(res)
+		if err != nil {
+			return err
+		}
+		if changes := diff(old, current); len(changes) > 0 {
+			changed := step
+			changed.Changes = changes
+			r.steps[res] = append(r.steps[res], changed)
+		}
+	}
+	return nil
+}
+
+// Resources returns the traces of the resources of m, in the same order.
+func (r *Recorder) Resources(m resmap.ResMap) []Resource {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	result := make([]Resource, 0, m.Size())
+	for _, res := range m.Resources() {
+		id := res.CurId()
+		steps := r.steps[res]
+		if steps == nil {
+			steps = []Step{}
+		}
+		result = append(result, Resource{
+			Kind:      id.Kind,
+			Namespace: id.Namespace,
+			Name:      id.Name,
+			Steps:     steps,
+		})
+	}
+	return result
+}
+
+// pluginType names a plugin SetPlugin did not, after its Go type.
+func pluginType(p interface{}) string {
+	name := fmt.Sprintf("%T", p)
+	name = name[strings.LastIndex(name, ".")+1:]
+	return strings.TrimSuffix(name, "Plugin")
+}
+
+func snapshot(resources []*resource.Resource) (map[*resource.Resource]map[string]interface{}, error) {
+	result := make(map[*resource.Resource]map[string]interface{}, len(resources))
+	for _, res := range resources {
+		f, err := fields(res)
+		if err != nil {
+			return nil, err
+		}
+		result[res] = f
+	}
+	return result, nil
+}
+
+// findReplaced returns the resource of before, no longer in m,
+// that res replaced, i.e. with the same id, or nil.
+func findReplaced(before map[*resource.Resource]map[string]interface{}, m resmap.ResMap, res *resource.Resource) *resource.Resource {
+	current := make(map[*resource.Resource]bool, m.Size())
+	for _, r := range m.Resources() {
+		current[r] = true
+	}
+	for prior := range before {
+		if !current[prior] && (prior.CurId().Equals(res.CurId()) || prior.OrgId().Equals(res.OrgId())) {
+			return prior
+		}
+	}
+	return nil
+}
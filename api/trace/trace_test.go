@@ -0,0 +1,78 @@
+// Copyright 2021 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package trace
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiff(t *testing.T) {
+	before := make(map[string]interface{})
+	flatten("", map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"name":   "web",
+			"labels": map[string]interface{}{"app.kubernetes.io/name": "web"},
+		},
+		"spec": map[string]interface{}{
+			"containers": []interface{}{
+				map[string]interface{}{"name": "app", "image": "nginx"},
+				map[string]interface{}{"name": "sidecar", "image": "envoy"},
+			},
+			"args": []interface{}{"a", "b"},
+		},
+	}, before)
+	after := make(map[string]interface{})
+	flatten("", map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"name":   "prod-web",
+			"labels": map[string]interface{}{},
+		},
+		"spec": map[string]interface{}{
+			"containers": []interface{}{
+				map[string]interface{}{"name": "sidecar", "image": "envoy"},
+				map[string]interface{}{"name": "app", "image": "nginx:1.21"},
+			},
+			"args": []interface{}{"a", "c"},
+		},
+	}, after)
+	assert.Equal(t, []Change{
+		{Path: "metadata.labels", After: map[string]interface{}{}},
+		{Path: "metadata.labels[app.kubernetes.io/name]", Before: "web"},
+		{Path: "metadata.name", Before: "web", After: "prod-web"},
+		{Path: "spec.args[1]", Before: "b", After: "c"},
+		{Path: "spec.containers[name=app].image", Before: "nginx", After: "nginx:1.21"},
+	}, diff(before, after))
+}
+
+func TestFind(t *testing.T) {
+	resources := []Resource{
+		{Kind: "Deployment", Namespace: "a", Name: "web"},
+		{Kind: "Deployment", Namespace: "b", Name: "web"},
+		{Kind: "Service", Name: "web"},
+	}
+	found, err := Find(resources, "deployment/web")
+	assert.NoError(t, err)
+	assert.Equal(t, resources[:2], found)
+	found, err = Find(resources, "*")
+	assert.NoError(t, err)
+	assert.Equal(t, resources, found)
+	_, err = Find(resources, "ConfigMap/web")
+	assert.EqualError(t, err, "no resource ConfigMap/web in the output of the build")
+	_, err = Find(resources, "web")
+	assert.EqualError(t, err, `invalid resource "web", expected kind/name`)
+}
+
+func TestNilRecorder(t *testing.T) {
+	var r *Recorder
+	r.SetPlugin(r, "Kind", "name")
+	ran := false
+	assert.NoError(t, r.Observe(Step{Type: StepTransformer}, nil, nil, func() error {
+		ran = true
+		return nil
+	}))
+	assert.True(t, ran)
+	assert.Nil(t, r.Resources(nil))
+}
@@ -0,0 +1,22 @@
+// Copyright 2021 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package types
+
+// ChartLock pins a helm chart.
+type ChartLock struct {
+	Repo string `json:"repo"`
+	Name string `json:"name"`
+	// RequestedVersion is the chartVersion from the generator config,
+	// empty if the latest version was requested.
+	RequestedVersion string `json:"requestedVersion,omitempty"`
+	// Version is the chart version that was resolved.
+	Version string `json:"version"`
+	// Digest is the sha256 of the files of the untarred chart. No chart
+	// repository publishes it, it only tells whether the chart fetched
+	// has the content pinned.
+	Digest string `json:"digest"`
+	// ManifestDigest is the digest of the manifest of a chart fetched
+	// from an OCI registry, i.e. what the registry identifies it with.
+	ManifestDigest string `json:"manifestDigest,omitempty"`
+}
@@ -0,0 +1,26 @@
+// Copyright 2021 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package types
+
+// GitBackend determines how git repositories (remote bases,
+// components fetched by plugins) are cloned and updated.
+type GitBackend int
+
+const (
+	// The git program on the PATH is run.
+	GitBackendExec GitBackend = iota
+
+	// A git implementation in pure Go is used,
+	// no git program needs to be installed.
+	GitBackendGoGit
+)
+
+func (b GitBackend) String() string {
+	switch b {
+	case GitBackendGoGit:
+		return "go-git"
+	default:
+		return "exec"
+	}
+}
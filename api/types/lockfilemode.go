@@ -0,0 +1,38 @@
+// Copyright 2021 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package types
+
+// LockfileMode determines how a build uses the kustomization.lock
+// file that pins remote inputs (helm charts, git refs, remote bases).
+type LockfileMode int
+
+const (
+	// The lock file is neither read nor written.
+	LockfileDisabled LockfileMode = iota
+
+	// Pins from an existing lock file are honored, inputs
+	// missing from it are resolved and the file is rewritten.
+	LockfileUpdate
+
+	// Pins from the lock file are honored and the build fails
+	// if any resolved input is missing from it or differs from it.
+	LockfileFrozen
+
+	// Pins from an existing lock file are honored, the
+	// file is not written.
+	LockfileReadOnly
+)
+
+func (m LockfileMode) String() string {
+	switch m {
+	case LockfileUpdate:
+		return "update"
+	case LockfileFrozen:
+		return "frozen"
+	case LockfileReadOnly:
+		return "read-only"
+	default:
+		return "disabled"
+	}
+}
@@ -8,6 +8,25 @@ type HelmConfig struct {
 	Command string
 }
 
+// MirrorConfig controls how plugins that fetch remote artifacts
+// (helm charts, git repositories) interact with local mirrors.
+type MirrorConfig struct {
+	// Offline, when true, forbids network access; artifacts are
+	// resolved only from ChartMirror and GitMirror.
+	Offline bool
+
+	// Vendor, when true, copies every artifact fetched during
+	// the build into ChartMirror and GitMirror.
+	Vendor bool
+
+	// ChartMirror is a directory holding untarred helm charts
+	// laid out as <chartName>/<chartVersion>/.
+	ChartMirror string
+
+	// GitMirror is a directory holding bare git repositories.
+	GitMirror string
+}
+
 // PluginConfig holds plugin configuration.
 type PluginConfig struct {
 	// PluginRestrictions distinguishes plugin restrictions.
@@ -21,6 +40,22 @@ type PluginConfig struct {
 
 	// HelmConfig contains metadata needed for allowing and running helm.
 	HelmConfig HelmConfig
+
+	// MirrorConfig contains settings for offline builds and vendoring.
+	MirrorConfig MirrorConfig
+
+	// GitBackend selects how remote bases and the repositories
+	// fetched by plugins are cloned.
+	GitBackend GitBackend
+
+	// Jobs is the number of generator fetches and remote resources
+	// loaded concurrently, 1 when not positive.
+	Jobs int
+
+	// KeyOrigins makes SuperConfigMaps and SuperSecrets annotate the
+	// ConfigMaps and Secrets they set keys of with the configs that set
+	// each key, relative to the root of the build.
+	KeyOrigins bool
 }
 
 func EnabledPluginConfig(b BuiltinPluginLoadingOptions) (pc *PluginConfig) {
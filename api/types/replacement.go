@@ -71,12 +71,41 @@ type FieldOptions struct {
 	// Which position in the split to consider.
 	Index int `json:"index" yaml:"index"`
 
-	// TODO (#3492): Implement use of this option
-	// None, Base64, URL, Hex, etc
+	// The field is decoded when read and encoded when written with this:
+	// none, the default, base64, url, hex or json, a quoted JSON string.
 	Encoding string `json:"encoding" yaml:"encoding"`
 
+	// The field, once decoded, is a json or yaml document, the value is
+	// read from or written to the field at EmbeddedPath of the document.
+	Format string `json:"format,omitempty" yaml:"format,omitempty"`
+
+	// Structured field path in the document of the field, its root when empty.
+	EmbeddedPath string `json:"embeddedPath,omitempty" yaml:"embeddedPath,omitempty"`
+
 	// If field missing, add it.
 	Create bool `json:"create" yaml:"create"`
+
+	// Applied in order to the value, once read from the source field
+	// or before it is written to the target fields.
+	Transforms []ValueTransform `json:"transforms,omitempty" yaml:"transforms,omitempty"`
+}
+
+// ValueTransform is a step of the transformation of a scalar value.
+// Only one of its prefix and suffix, regex or format is set.
+type ValueTransform struct {
+	// Added before the value.
+	Prefix string `json:"prefix,omitempty" yaml:"prefix,omitempty"`
+
+	// Added after the value.
+	Suffix string `json:"suffix,omitempty" yaml:"suffix,omitempty"`
+
+	// The matches of the regular expression in the value are replaced
+	// with Replace, which may refer to its submatches, e.g. ${1}.
+	Regex   string `json:"regex,omitempty" yaml:"regex,omitempty"`
+	Replace string `json:"replace,omitempty" yaml:"replace,omitempty"`
+
+	// The value replaces the %s of the format string.
+	Format string `json:"format,omitempty" yaml:"format,omitempty"`
 }
 
 func (fo *FieldOptions) String() string {
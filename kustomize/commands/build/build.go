@@ -8,6 +8,7 @@ import (
 	"io"
 
 	"github.com/spf13/cobra"
+	"sigs.k8s.io/kustomize/api/builtins_qlik/utils"
 	"sigs.k8s.io/kustomize/api/filesys"
 	"sigs.k8s.io/kustomize/api/konfig"
 	"sigs.k8s.io/kustomize/api/krusty"
@@ -29,6 +30,19 @@ var theFlags struct {
 	loadRestrictor string
 	reorderOutput  string
 	fnOptions      types.FnPluginLoadingOptions
+	offline        bool
+	chartMirror    string
+	gitMirror      string
+	gitBackend     string
+	jobs           int
+	lockfile       bool
+	frozenLockfile bool
+	strict         bool
+	diagnostics    string
+	provenance     string
+	explain        string
+	explainFormat  string
+	keyOrigins     bool
 }
 
 type Help struct {
@@ -77,10 +91,20 @@ func NewCmdBuild(
 			k := krusty.MakeKustomizer(
 				HonorKustomizeFlags(krusty.MakeDefaultOptions()),
 			)
-			m, err := k.Run(fSys, theArgs.kustomizationPath)
+			result, err := k.RunWithResult(fSys, theArgs.kustomizationPath)
+			if errD := writeDiagnostics(cmd.ErrOrStderr(), result.Diagnostics); errD != nil && err == nil {
+				err = errD
+			}
 			if err != nil {
 				return err
 			}
+			m := result.Resources
+			if err = writeProvenance(fSys, result.Provenance); err != nil {
+				return err
+			}
+			if theFlags.explain != "" {
+				return writeExplain(writer, result.Trace)
+			}
 			if theFlags.outputPath != "" && fSys.IsDir(theFlags.outputPath) {
 				// Ignore writer; write to o.outputPath directly.
 				return MakeWriter(fSys).WriteIndividualFiles(
@@ -105,6 +129,14 @@ func NewCmdBuild(
 	AddFlagReorderOutput(cmd.Flags())
 	AddFlagEnableManagedbyLabel(cmd.Flags())
 	AddFlagEnableHelm(cmd.Flags())
+	AddFlagOffline(cmd.Flags())
+	AddFlagGitBackend(cmd.Flags())
+	AddFlagJobs(cmd.Flags())
+	AddFlagLockfile(cmd.Flags())
+	AddFlagDiagnostics(cmd.Flags())
+	AddFlagProvenance(cmd.Flags())
+	AddFlagExplain(cmd.Flags())
+	AddFlagKeyOrigins(cmd.Flags())
 	return cmd
 }
 
@@ -123,6 +155,18 @@ func Validate(args []string) error {
 	if err := validateFlagLoadRestrictor(); err != nil {
 		return err
 	}
+	if err := validateFlagDiagnostics(); err != nil {
+		return err
+	}
+	if err := validateFlagGitBackend(); err != nil {
+		return err
+	}
+	if err := validateFlagJobs(); err != nil {
+		return err
+	}
+	if err := validateFlagExplain(); err != nil {
+		return err
+	}
 	return validateFlagReorderOutput()
 }
 
@@ -131,6 +175,10 @@ func Validate(args []string) error {
 func HonorKustomizeFlags(kOpts *krusty.Options) *krusty.Options {
 	kOpts.DoLegacyResourceSort = getFlagReorderOutput() == legacy
 	kOpts.LoadRestrictions = getFlagLoadRestrictorValue()
+	kOpts.LockfileMode = getFlagLockfileMode()
+	kOpts.Strict = theFlags.strict
+	kOpts.Provenance = theFlags.provenance != ""
+	kOpts.Trace = theFlags.explain != ""
 	if theFlags.enable.plugins {
 		c := types.EnabledPluginConfig(types.BploUseStaticallyLinked)
 		c.FnpLoadingOptions = theFlags.fnOptions
@@ -139,6 +187,13 @@ func HonorKustomizeFlags(kOpts *krusty.Options) *krusty.Options {
 		kOpts.PluginConfig.HelmConfig.Enabled = theFlags.enable.helm
 	}
 	kOpts.PluginConfig.HelmConfig.Command = theFlags.helmCommand
+	kOpts.PluginConfig.MirrorConfig.Offline = theFlags.offline
+	kOpts.PluginConfig.MirrorConfig.Vendor = utils.MirrorConfigFromEnv().Vendor
+	kOpts.PluginConfig.MirrorConfig.ChartMirror = theFlags.chartMirror
+	kOpts.PluginConfig.MirrorConfig.GitMirror = theFlags.gitMirror
+	kOpts.PluginConfig.GitBackend = getFlagGitBackend()
+	kOpts.PluginConfig.Jobs = theFlags.jobs
+	kOpts.PluginConfig.KeyOrigins = theFlags.keyOrigins
 	kOpts.AddManagedbyLabel = isManagedByLabelEnabled()
 	return kOpts
 }
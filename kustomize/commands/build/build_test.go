@@ -6,11 +6,14 @@ package build_test
 import (
 	"bytes"
 	"fmt"
+	"os"
 	"strings"
 	"testing"
 
+	"sigs.k8s.io/kustomize/api/builtins_qlik/utils"
 	"sigs.k8s.io/kustomize/api/filesys"
 	"sigs.k8s.io/kustomize/api/konfig"
+	"sigs.k8s.io/kustomize/api/krusty"
 	"sigs.k8s.io/kustomize/api/provenance"
 	. "sigs.k8s.io/kustomize/kustomize/v4/commands/build"
 )
@@ -251,3 +254,74 @@ func TestValidation(t *testing.T) {
 		})
 	}
 }
+
+func TestValidationJobs(t *testing.T) {
+	fSys := filesys.MakeFsInMemory()
+	cmd := NewCmdBuild(fSys, MakeHelp("foo", "bar"), new(bytes.Buffer))
+	if err := cmd.Flags().Set("jobs", "0"); err != nil {
+		t.Fatal(err)
+	}
+	defer cmd.Flags().Set("jobs", "1")
+	err := cmd.RunE(cmd, []string{"."})
+	if err == nil || !strings.Contains(err.Error(), "illegal flag value --jobs 0") {
+		t.Fatalf("Expected an illegal --jobs error, but got %v", err)
+	}
+}
+
+func TestOfflineFlagsOverrideEnv(t *testing.T) {
+	os.Setenv(utils.OfflineEnvVar, "true")
+	os.Setenv(utils.GitMirrorEnvVar, "/mirror/env")
+	defer os.Unsetenv(utils.OfflineEnvVar)
+	defer os.Unsetenv(utils.GitMirrorEnvVar)
+
+	cmd := NewCmdBuild(filesys.MakeFsInMemory(), MakeHelp("foo", "bar"), new(bytes.Buffer))
+	mc := HonorKustomizeFlags(krusty.MakeDefaultOptions()).PluginConfig.MirrorConfig
+	if !mc.Offline || mc.GitMirror != "/mirror/env" {
+		t.Fatalf("Expected the env to set the defaults, but got %+v", mc)
+	}
+	if err := cmd.Flags().Set("offline", "false"); err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Flags().Set("git-mirror", "/mirror/flag"); err != nil {
+		t.Fatal(err)
+	}
+	mc = HonorKustomizeFlags(krusty.MakeDefaultOptions()).PluginConfig.MirrorConfig
+	if mc.Offline || mc.GitMirror != "/mirror/flag" {
+		t.Fatalf("Expected the flags to override the env, but got %+v", mc)
+	}
+	cmd.Flags().Set("offline", "false")
+	cmd.Flags().Set("git-mirror", "")
+}
+
+func TestBuildExplain(t *testing.T) {
+	fSys := filesys.MakeFsInMemory()
+	loadFileSystem(fSys)
+	buffy := new(bytes.Buffer)
+	cmd := NewCmdBuild(fSys, MakeHelp("foo", "bar"), buffy)
+	if err := cmd.Flags().Set("explain", "deployment/foo-dply1-bar"); err != nil {
+		t.Fatal(err)
+	}
+	defer cmd.Flags().Set("explain", "")
+	if err := cmd.RunE(cmd, []string{}); err != nil {
+		t.Fatal(err)
+	}
+	expected := `Deployment/ns1/foo-dply1-bar
+  1. resource deployment.yaml (kustomization.yaml): created
+  2. transformer NamespaceTransformer (kustomization.yaml)
+       metadata.namespace: <none> -> "ns1"
+  3. transformer PrefixSuffixTransformer (kustomization.yaml)
+       metadata.name: "dply1" -> "foo-dply1-bar"
+  4. transformer LabelTransformer (kustomization.yaml)
+       metadata.labels.app: <none> -> "nginx"
+       spec.selector.matchLabels.app: <none> -> "nginx"
+       spec.template.metadata.labels.app: <none> -> "nginx"
+  5. transformer AnnotationsTransformer (kustomization.yaml)
+       metadata.annotations.note: <none> -> "This is a test annotation"
+       spec.template.metadata.annotations.note: <none> -> "This is a test annotation"
+  6. transformer PatchJson6902Transformer jsonpatch.json (kustomization.yaml)
+       spec.replica: <none> -> "3"
+`
+	if buffy.String() != expected {
+		t.Fatalf("Expected output:\n%s\n But got output:\n%s", expected, buffy)
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright 2021 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package build
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/spf13/pflag"
+	"sigs.k8s.io/kustomize/api/diagnostics"
+)
+
+const (
+	flagDiagnosticsName = "diagnostics"
+
+	diagnosticsText = "text"
+	diagnosticsJSON = "json"
+	diagnosticsNone = "none"
+)
+
+// AddFlagDiagnostics adds the --strict and --diagnostics flags.
+func AddFlagDiagnostics(set *pflag.FlagSet) {
+	set.BoolVar(
+		&theFlags.strict,
+		"strict",
+		false,
+		"fail the build if plugins emit warnings, e.g. for a target that matches nothing")
+	set.StringVar(
+		&theFlags.diagnostics,
+		flagDiagnosticsName,
+		diagnosticsText,
+		"format of the plugin warnings summary written to stderr: "+
+			"'"+diagnosticsText+"', '"+diagnosticsJSON+"' or '"+diagnosticsNone+"'")
+}
+
+func validateFlagDiagnostics() error {
+	switch theFlags.diagnostics {
+	case diagnosticsText, diagnosticsJSON, diagnosticsNone:
+		return nil
+	default:
+		return fmt.Errorf(
+			"illegal flag value --%s %s; legal values: %v",
+			flagDiagnosticsName, theFlags.diagnostics,
+			[]string{diagnosticsText, diagnosticsJSON, diagnosticsNone})
+	}
+}
+
+type diagnosticsSummary struct {
+	Warnings    int                      `json:"warnings"`
+	Diagnostics []diagnostics.Diagnostic `json:"diagnostics"`
+}
+
+// writeDiagnostics writes the summary of ds in the format of the --diagnostics flag.
+// Nothing is written in text format when there are no diagnostics.
+func writeDiagnostics(w io.Writer, ds []diagnostics.Diagnostic) error {
+	switch theFlags.diagnostics {
+	case diagnosticsJSON:
+		if ds == nil {
+			ds = []diagnostics.Diagnostic{}
+		}
+		b, err := json.MarshalIndent(diagnosticsSummary{Warnings: len(ds), Diagnostics: ds}, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, string(b))
+		return err
+	case diagnosticsText:
+		if len(ds) == 0 {
+			return nil
+		}
+		if _, err := fmt.Fprintf(w, "%d plugin warning(s):\n", len(ds)); err != nil {
+			return err
+		}
+		for _, d := range ds {
+			if _, err := fmt.Fprintf(w, "  - %s\n", d); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+// Copyright 2021 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package build
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/spf13/pflag"
+	"sigs.k8s.io/kustomize/api/trace"
+)
+
+const (
+	flagExplainName       = "explain"
+	flagExplainFormatName = "explain-format"
+
+	explainText = "text"
+	explainJSON = "json"
+)
+
+// AddFlagExplain adds the --explain and --explain-format flags.
+func AddFlagExplain(set *pflag.FlagSet) {
+	set.StringVar(
+		&theFlags.explain,
+		flagExplainName,
+		"",
+		"instead of the output, write the generators and transformers that created or "+
+			"modified the resource kind/name, with the fields each one changed, or of all resources for '*'")
+	set.StringVar(
+		&theFlags.explainFormat,
+		flagExplainFormatName,
+		explainText,
+		"format of the --"+flagExplainName+" output: '"+explainText+"' or '"+explainJSON+"'")
+}
+
+func validateFlagExplain() error {
+	switch theFlags.explainFormat {
+	case explainText, explainJSON:
+		return nil
+	default:
+		return fmt.Errorf(
+			"illegal flag value --%s %s; legal values: %v",
+			flagExplainFormatName, theFlags.explainFormat,
+			[]string{explainText, explainJSON})
+	}
+}
+
+// writeExplain writes the traces of the resources of the --explain flag,
+// in the format of the --explain-format flag.
+func writeExplain(w io.Writer, resources []trace.Resource) error {
+	selected, err := trace.Find(resources, theFlags.explain)
+	if err != nil {
+		return err
+	}
+	if theFlags.explainFormat == explainJSON {
+		b, err := json.MarshalIndent(selected, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, string(b))
+		return err
+	}
+	for _, r := range selected {
+		if _, err = fmt.Fprintln(w, r.ID()); err != nil {
+			return err
+		}
+		for i, step := range r.Steps {
+			line := fmt.Sprintf("  %d. %s", i+1, step)
+			if step.Created {
+				line += ": created"
+			}
+			if _, err = fmt.Fprintln(w, line); err != nil {
+				return err
+			}
+			for _, c := range step.Changes {
+				if _, err = fmt.Fprintf(w, "       %s: %s -> %s\n",
+					c.Path, explainValue(c.Before), explainValue(c.After)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func explainValue(v interface{}) string {
+	if v == nil {
+		return "<none>"
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
@@ -0,0 +1,43 @@
+// Copyright 2021 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package build
+
+import (
+	"fmt"
+
+	"github.com/spf13/pflag"
+	"sigs.k8s.io/kustomize/api/types"
+)
+
+const flagGitBackendName = "git-backend"
+
+// AddFlagGitBackend adds the --git-backend flag.
+func AddFlagGitBackend(set *pflag.FlagSet) {
+	set.StringVar(
+		&theFlags.gitBackend,
+		flagGitBackendName,
+		types.GitBackendExec.String(),
+		"how remote bases and the repositories of GoGetter are cloned: '"+
+			types.GitBackendExec.String()+"' runs the git program, '"+
+			types.GitBackendGoGit.String()+"' needs no git install")
+}
+
+func validateFlagGitBackend() error {
+	switch theFlags.gitBackend {
+	case types.GitBackendExec.String(), types.GitBackendGoGit.String(), "":
+		return nil
+	default:
+		return fmt.Errorf(
+			"illegal flag value --%s %s; legal values: %v",
+			flagGitBackendName, theFlags.gitBackend,
+			[]string{types.GitBackendExec.String(), types.GitBackendGoGit.String()})
+	}
+}
+
+func getFlagGitBackend() types.GitBackend {
+	if theFlags.gitBackend == types.GitBackendGoGit.String() {
+		return types.GitBackendGoGit
+	}
+	return types.GitBackendExec
+}
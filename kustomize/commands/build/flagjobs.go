@@ -0,0 +1,31 @@
+// Copyright 2021 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package build
+
+import (
+	"fmt"
+
+	"github.com/spf13/pflag"
+)
+
+const flagJobsName = "jobs"
+
+// AddFlagJobs adds the --jobs flag.
+func AddFlagJobs(set *pflag.FlagSet) {
+	set.IntVar(
+		&theFlags.jobs,
+		flagJobsName,
+		1,
+		"number of generator fetches, e.g. of HelmChart and GoGetter, "+
+			"and of remote resources run concurrently; output order does not depend on it")
+}
+
+func validateFlagJobs() error {
+	if theFlags.jobs < 1 {
+		return fmt.Errorf(
+			"illegal flag value --%s %d; must be at least 1",
+			flagJobsName, theFlags.jobs)
+	}
+	return nil
+}
@@ -0,0 +1,18 @@
+// Copyright 2021 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package build
+
+import (
+	"github.com/spf13/pflag"
+)
+
+// AddFlagKeyOrigins adds the --key-origins flag.
+func AddFlagKeyOrigins(set *pflag.FlagSet) {
+	set.BoolVar(
+		&theFlags.keyOrigins,
+		"key-origins",
+		false,
+		"annotate the ConfigMaps and Secrets set by SuperConfigMaps and SuperSecrets "+
+			"with the configs that set each of their keys")
+}
@@ -0,0 +1,37 @@
+// Copyright 2021 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package build
+
+import (
+	"github.com/spf13/pflag"
+	"sigs.k8s.io/kustomize/api/types"
+)
+
+// AddFlagLockfile adds the --lockfile and --frozen-lockfile flags.
+// Without them the pins of an existing kustomization.lock are
+// honored, but the file is not written.
+func AddFlagLockfile(set *pflag.FlagSet) {
+	set.BoolVar(
+		&theFlags.lockfile,
+		"lockfile",
+		false,
+		"record the resolved remote inputs (helm charts, git refs, remote bases) "+
+			"in kustomization.lock, creating or rewriting it")
+	set.BoolVar(
+		&theFlags.frozenLockfile,
+		"frozen-lockfile",
+		false,
+		"fail if a remote input is missing from kustomization.lock or resolves differently")
+}
+
+func getFlagLockfileMode() types.LockfileMode {
+	switch {
+	case theFlags.frozenLockfile:
+		return types.LockfileFrozen
+	case theFlags.lockfile:
+		return types.LockfileUpdate
+	default:
+		return types.LockfileReadOnly
+	}
+}
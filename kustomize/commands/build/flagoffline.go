@@ -0,0 +1,31 @@
+// Copyright 2021 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package build
+
+import (
+	"github.com/spf13/pflag"
+	"sigs.k8s.io/kustomize/api/builtins_qlik/utils"
+)
+
+// AddFlagOffline adds the --offline, --chart-mirror and --git-mirror flags.
+// The KUST_OFFLINE, KUST_CHART_MIRROR and KUST_GIT_MIRROR environment
+// variables set their defaults, so the flags take precedence over them.
+func AddFlagOffline(set *pflag.FlagSet) {
+	env := utils.MirrorConfigFromEnv()
+	set.BoolVar(
+		&theFlags.offline,
+		"offline",
+		env.Offline,
+		"resolve helm charts and git repositories only from the local mirrors (env "+utils.OfflineEnvVar+")")
+	set.StringVar(
+		&theFlags.chartMirror,
+		"chart-mirror",
+		env.ChartMirror,
+		"directory holding mirrored helm charts (env "+utils.ChartMirrorEnvVar+")")
+	set.StringVar(
+		&theFlags.gitMirror,
+		"git-mirror",
+		env.GitMirror,
+		"directory holding mirrored bare git repositories (env "+utils.GitMirrorEnvVar+")")
+}
@@ -0,0 +1,34 @@
+// Copyright 2021 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package build
+
+import (
+	"encoding/json"
+
+	"github.com/spf13/pflag"
+	"sigs.k8s.io/kustomize/api/filesys"
+	"sigs.k8s.io/kustomize/api/provenance"
+)
+
+// AddFlagProvenance adds the --provenance flag.
+func AddFlagProvenance(set *pflag.FlagSet) {
+	set.StringVar(
+		&theFlags.provenance,
+		"provenance",
+		"",
+		"write the provenance of the output, an in-toto statement listing every input "+
+			"of the build with its digest and the inputs of each resource, to this file")
+}
+
+// writeProvenance writes s to the file of the --provenance flag, if set.
+func writeProvenance(fSys filesys.FileSystem, s *provenance.Statement) error {
+	if theFlags.provenance == "" || s == nil {
+		return nil
+	}
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return fSys.WriteFile(theFlags.provenance, append(b, '\n'))
+}
@@ -0,0 +1,138 @@
+// Copyright 2021 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package cache
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"sigs.k8s.io/kustomize/api/builtins_qlik/utils"
+)
+
+// NewCmdCache makes a new cache command.
+func NewCmdCache(w io.Writer) *cobra.Command {
+	var cacheDir string
+
+	cacheCmd := &cobra.Command{
+		Use:   "cache",
+		Short: "Commands for inspecting and pruning the helm chart cache",
+		Long: fmt.Sprintf(`Commands for inspecting and pruning the helm chart cache.
+The cache is used by the HelmChart generator when %s=true.
+Its location is taken from %s and defaults to %s.
+`, "KUST_HELM_CACHE", utils.ChartCacheDirEnvVar, utils.DefaultChartCacheRoot()),
+		Example: `kustomize cache ls`,
+	}
+	cacheCmd.PersistentFlags().StringVar(
+		&cacheDir, "cache-dir", "",
+		"chart cache root directory (overrides "+utils.ChartCacheDirEnvVar+")")
+
+	getCache := func() (*utils.ChartCache, error) {
+		if cacheDir != "" {
+			return utils.NewChartCache(cacheDir, nil), nil
+		}
+		return utils.NewChartCacheFromEnv(nil)
+	}
+
+	cacheCmd.AddCommand(newCmdList(w, getCache))
+	cacheCmd.AddCommand(newCmdPrune(w, getCache))
+	return cacheCmd
+}
+
+func newCmdList(w io.Writer, getCache func() (*utils.ChartCache, error)) *cobra.Command {
+	return &cobra.Command{
+		Use:     "ls",
+		Short:   "Lists the entries of the helm chart cache, least recently used first",
+		Example: `kustomize cache ls`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cache, err := getCache()
+			if err != nil {
+				return err
+			}
+			entries, err := cache.List()
+			if err != nil {
+				return err
+			}
+			tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(tw, "KIND\tNAME\tVERSION\tDIGEST\tSIZE\tLAST USED\tKEY")
+			var totalSize int64
+			for _, entry := range entries {
+				fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%d\t%s\t%s\n",
+					entry.Kind, entry.Name, entry.Version, shortDigest(entry.Digest),
+					entry.Size, entry.LastUsed.Format(time.RFC3339), shortDigest(entry.Key))
+				totalSize += entry.Size
+			}
+			if err := tw.Flush(); err != nil {
+				return err
+			}
+			fmt.Fprintf(w, "%d entries, %d bytes in %s\n", len(entries), totalSize, cache.Root)
+			return nil
+		},
+	}
+}
+
+func newCmdPrune(w io.Writer, getCache func() (*utils.ChartCache, error)) *cobra.Command {
+	var (
+		maxSizeMB int64
+		maxAge    time.Duration
+		all       bool
+	)
+	pruneCmd := &cobra.Command{
+		Use:   "prune",
+		Short: "Evicts entries from the helm chart cache",
+		Long: `Evicts entries from the helm chart cache.
+Entries not used within --max-age are removed first, then the least recently
+used entries until the cache fits within --max-size-mb. Without flags the
+limits configured through the environment are applied.
+`,
+		Example: `kustomize cache prune --max-age 168h --max-size-mb 2048`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cache, err := getCache()
+			if err != nil {
+				return err
+			}
+			var removed []*utils.ChartCacheEntry
+			if all {
+				removed, err = cache.Clear()
+				printRemoved(w, removed)
+				return err
+			}
+			maxSizeBytes := cache.MaxSizeBytes
+			if cmd.Flags().Changed("max-size-mb") {
+				maxSizeBytes = maxSizeMB * 1024 * 1024
+			}
+			if cmd.Flags().Changed("max-age") {
+				cache.MaxAge = maxAge
+			}
+			if maxSizeBytes == 0 && cache.MaxAge == 0 {
+				return fmt.Errorf("no eviction limit given; use --max-size-mb, --max-age or --all")
+			}
+			removed, err = cache.Prune(maxSizeBytes, cache.MaxAge)
+			printRemoved(w, removed)
+			return err
+		},
+	}
+	pruneCmd.Flags().Int64Var(&maxSizeMB, "max-size-mb", 0,
+		"evict least recently used entries until the cache is at most this size")
+	pruneCmd.Flags().DurationVar(&maxAge, "max-age", 0,
+		"evict entries not used within this duration, e.g. 72h")
+	pruneCmd.Flags().BoolVar(&all, "all", false, "remove every entry of the cache")
+	return pruneCmd
+}
+
+func printRemoved(w io.Writer, removed []*utils.ChartCacheEntry) {
+	for _, entry := range removed {
+		fmt.Fprintf(w, "removed %s %s %s (%s)\n",
+			entry.Kind, entry.Name, entry.Version, shortDigest(entry.Key))
+	}
+}
+
+func shortDigest(digest string) string {
+	if len(digest) > 19 {
+		return digest[:19]
+	}
+	return digest
+}
@@ -0,0 +1,126 @@
+// Copyright 2021 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package cache
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"sigs.k8s.io/kustomize/api/builtins_qlik/utils"
+)
+
+func run(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+	var out bytes.Buffer
+	cmd := NewCmdCache(&out)
+	cmd.SetArgs(args)
+	cmd.SetOut(&out)
+	cmd.SetErr(&out)
+	err := cmd.Execute()
+	return out.String(), err
+}
+
+func fillCache(t *testing.T, root string) {
+	t.Helper()
+	chartDir := filepath.Join(t.TempDir(), "foo")
+	if err := os.MkdirAll(filepath.Join(chartDir, "templates"), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(chartDir, "Chart.yaml"), []byte("name: foo\nversion: 1.0.0\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cache := utils.NewChartCache(root, nil)
+	digest, err := cache.StoreChart("https://charts.example.com", "foo", "1.0.0", chartDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry := utils.ChartCacheEntry{Name: "foo", Version: "1.0.0", Digest: digest}
+	if err := cache.StoreRendered("key", entry, []byte("kind: ConfigMap\n")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestList(t *testing.T) {
+	root := t.TempDir()
+	fillCache(t, root)
+
+	out, err := run(t, "ls", "--cache-dir", root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, "chart     foo") || !strings.Contains(out, "rendered  foo") {
+		t.Errorf("unexpected output:\n%s", out)
+	}
+	if !strings.Contains(out, "2 entries") {
+		t.Errorf("unexpected output:\n%s", out)
+	}
+}
+
+func TestPruneWithoutLimit(t *testing.T) {
+	root := t.TempDir()
+	fillCache(t, root)
+
+	if _, err := run(t, "prune", "--cache-dir", root); err == nil {
+		t.Fatal("expected an error without eviction limit")
+	}
+	entries, err := utils.NewChartCache(root, nil).List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Errorf("expected 2 entries, got %d", len(entries))
+	}
+}
+
+func TestPruneMaxSize(t *testing.T) {
+	root := t.TempDir()
+	fillCache(t, root)
+
+	out, err := run(t, "prune", "--cache-dir", root, "--max-size-mb", "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != "" {
+		t.Errorf("expected nothing evicted, got:\n%s", out)
+	}
+}
+
+func TestPruneAll(t *testing.T) {
+	root := t.TempDir()
+	fillCache(t, root)
+	// files the cache did not write survive, e.g. when --cache-dir
+	// is mistakenly set to a directory holding other data.
+	unrelated := filepath.Join(root, "notes.txt")
+	if err := ioutil.WriteFile(unrelated, []byte("keep"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	unrelatedDir := filepath.Join(root, utils.ChartCacheKindChart, "not-an-entry")
+	if err := os.MkdirAll(unrelatedDir, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := run(t, "prune", "--cache-dir", root, "--all")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Count(out, "removed ") != 2 {
+		t.Errorf("expected 2 entries removed, got:\n%s", out)
+	}
+	entries, err := utils.NewChartCache(root, nil).List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("expected an empty cache, got %d entries", len(entries))
+	}
+	for _, path := range []string{unrelated, unrelatedDir} {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("%s should not have been removed: %v", path, err)
+		}
+	}
+}
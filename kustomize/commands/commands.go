@@ -16,9 +16,13 @@ import (
 	"sigs.k8s.io/kustomize/cmd/config/completion"
 	"sigs.k8s.io/kustomize/cmd/config/configcobra"
 	"sigs.k8s.io/kustomize/kustomize/v4/commands/build"
+	"sigs.k8s.io/kustomize/kustomize/v4/commands/cache"
 	"sigs.k8s.io/kustomize/kustomize/v4/commands/create"
 	"sigs.k8s.io/kustomize/kustomize/v4/commands/edit"
+	"sigs.k8s.io/kustomize/kustomize/v4/commands/helm"
+	"sigs.k8s.io/kustomize/kustomize/v4/commands/inventory"
 	"sigs.k8s.io/kustomize/kustomize/v4/commands/openapi"
+	"sigs.k8s.io/kustomize/kustomize/v4/commands/vendor"
 	"sigs.k8s.io/kustomize/kustomize/v4/commands/version"
 )
 
@@ -53,6 +57,10 @@ See https://sigs.k8s.io/kustomize
 		create.NewCmdCreate(fSys, pvd.GetResourceFactory()),
 		version.NewCmdVersion(stdOut),
 		openapi.NewCmdOpenAPI(stdOut),
+		cache.NewCmdCache(stdOut),
+		vendor.NewCmdVendor(fSys, stdOut),
+		helm.NewCmdHelm(fSys, stdOut),
+		inventory.NewCmdInventory(fSys, pvd.GetResourceFactory(), stdOut),
 	)
 	configcobra.AddCommands(c, konfig.ProgramName)
 
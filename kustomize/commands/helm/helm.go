@@ -0,0 +1,157 @@
+// Copyright 2021 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package helm
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/spf13/cobra"
+	"sigs.k8s.io/kustomize/api/builtins_qlik/utils"
+	"sigs.k8s.io/kustomize/api/filesys"
+	"sigs.k8s.io/kustomize/api/helmvalues"
+	"sigs.k8s.io/kustomize/api/krusty"
+	"sigs.k8s.io/kustomize/api/resmap"
+	"sigs.k8s.io/kustomize/api/types"
+	kyaml "sigs.k8s.io/kustomize/kyaml/yaml"
+	"sigs.k8s.io/yaml"
+)
+
+// NewCmdHelm makes a new helm command.
+func NewCmdHelm(fSys filesys.FileSystem, w io.Writer) *cobra.Command {
+	helmCmd := &cobra.Command{
+		Use:     "helm",
+		Short:   "Commands for inspecting the HelmCharts of a kustomization",
+		Example: `kustomize helm values ./overlays/prod`,
+	}
+	helmCmd.AddCommand(newCmdValues(fSys, w))
+	return helmCmd
+}
+
+func newCmdValues(fSys filesys.FileSystem, w io.Writer) *cobra.Command {
+	var output string
+
+	valuesCmd := &cobra.Command{
+		Use:   "values DIR",
+		Short: "Prints the effective values of every HelmChart of a kustomization",
+		Long: `Builds the kustomization in DIR without rendering the helm charts, and prints
+the values of every HelmChart once all HelmValues transformers have been
+merged in, each one commented with the config file that set it.
+`,
+		Example: `kustomize helm values ./overlays/prod`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			kustomizationPath := filesys.SelfDir
+			if len(args) > 1 {
+				return fmt.Errorf("specify one path to a kustomization directory")
+			} else if len(args) == 1 {
+				kustomizationPath = args[0]
+			}
+			if output != "yaml" && output != "json" {
+				return fmt.Errorf("unsupported output: %q, must be one of: yaml, json", output)
+			}
+			kOpts := krusty.MakeDefaultOptions()
+			kOpts.LoadRestrictions = types.LoadRestrictionsNone
+			kOpts.HelmValues = true
+			result, err := krusty.MakeKustomizer(kOpts).RunWithResult(fSys, kustomizationPath)
+			if err != nil {
+				return err
+			}
+			report := result.HelmValues
+			if err := recordHelmChartResources(report, result.Resources); err != nil {
+				return err
+			}
+			if output == "json" {
+				return writeJson(w, report.Charts())
+			}
+			return writeYaml(w, report.Charts())
+		},
+	}
+	valuesCmd.Flags().StringVarP(&output, "output", "o", "yaml", "output format: yaml or json")
+	return valuesCmd
+}
+
+// recordHelmChartResources records the HelmCharts output by the build, e.g. when DIR
+// is meant to be used as the generators of another kustomization.
+func recordHelmChartResources(report *helmvalues.Report, m resmap.ResMap) error {
+	for _, r := range m.Resources() {
+		if r.GetKind() != "HelmChart" {
+			continue
+		}
+		obj, err := r.Map()
+		if err != nil {
+			return err
+		}
+		chart := helmvalues.ChartValues{
+			ConfigPath: fmt.Sprintf("HelmChart/%v", r.GetName()),
+			Origins:    make(map[string]string),
+		}
+		chart.ChartName, _ = obj["chartName"].(string)
+		chart.ReleaseName, _ = obj["releaseName"].(string)
+		chart.Values, _ = obj["values"].(map[string]interface{})
+		for path := range utils.FlattenValues(chart.Values) {
+			chart.Origins[path] = chart.ConfigPath
+			if origin, ok := report.Origins(r.GetName())[path]; ok {
+				chart.Origins[path] = origin
+			}
+		}
+		report.Record(chart)
+	}
+	return nil
+}
+
+func writeJson(w io.Writer, charts []helmvalues.ChartValues) error {
+	if charts == nil {
+		charts = []helmvalues.ChartValues{}
+	}
+	b, err := json.MarshalIndent(charts, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(b))
+	return err
+}
+
+func writeYaml(w io.Writer, charts []helmvalues.ChartValues) error {
+	for i, chart := range charts {
+		if i > 0 {
+			fmt.Fprintln(w, "---")
+		}
+		fmt.Fprintf(w, "# chart: %v, release: %v, configured in: %v\n", chart.ChartName, chart.ReleaseName, chart.ConfigPath)
+		values, err := yaml.Marshal(chart.Values)
+		if err != nil {
+			return err
+		}
+		node, err := kyaml.Parse(string(values))
+		if err != nil {
+			return err
+		}
+		commentOrigins(node.YNode(), "", chart.Origins)
+		s, err := node.String()
+		if err != nil {
+			return err
+		}
+		fmt.Fprint(w, s)
+	}
+	return nil
+}
+
+// commentOrigins comments every leaf of the values with the config that set it.
+func commentOrigins(node *kyaml.Node, prefix string, origins map[string]string) {
+	if node.Kind != kyaml.MappingNode {
+		return
+	}
+	for i := 0; i < len(node.Content)-1; i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		path := key.Value
+		if prefix != "" {
+			path = prefix + "." + key.Value
+		}
+		if value.Kind == kyaml.MappingNode && len(value.Content) > 0 {
+			commentOrigins(value, path, origins)
+		} else if origin, ok := origins[path]; ok {
+			key.LineComment = origin
+		}
+	}
+}
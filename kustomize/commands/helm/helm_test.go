@@ -0,0 +1,133 @@
+// Copyright 2021 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package helm
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/kustomize/api/filesys"
+	"sigs.k8s.io/kustomize/api/helmvalues"
+)
+
+func writeKustomization(t *testing.T, fSys filesys.FileSystem) {
+	t.Helper()
+	files := map[string]string{
+		"app/kustomization.yaml": `
+generators:
+- charts
+`,
+		"app/charts/kustomization.yaml": `
+resources:
+- chart.yaml
+transformers:
+- values.yaml
+`,
+		"app/charts/chart.yaml": `
+apiVersion: qlik.com/v1
+kind: HelmChart
+metadata:
+  name: app
+chartName: app
+releaseName: app-release
+values:
+  image:
+    repository: app
+    tag: "1.0"
+  replicas: 1
+`,
+		"app/charts/values.yaml": `
+apiVersion: qlik.com/v1
+kind: HelmValues
+metadata:
+  name: prod
+chartName: app
+overwrite: true
+values:
+  image:
+    tag: "2.0"
+`,
+	}
+	for path, content := range files {
+		if err := fSys.WriteFile(path, []byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func run(t *testing.T, fSys filesys.FileSystem, args ...string) (string, error) {
+	t.Helper()
+	var out bytes.Buffer
+	cmd := NewCmdHelm(fSys, &out)
+	cmd.SetArgs(args)
+	cmd.SetOut(&out)
+	cmd.SetErr(&out)
+	err := cmd.Execute()
+	return out.String(), err
+}
+
+func TestValuesYaml(t *testing.T) {
+	fSys := filesys.MakeFsInMemory()
+	writeKustomization(t, fSys)
+
+	out, err := run(t, fSys, "values", "app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, `# chart: app, release: app-release, configured in: /app/charts
+image:
+  repository: app # /app/charts
+  tag: "2.0" # /app/charts/values.yaml
+replicas: 1 # /app/charts
+`, out)
+}
+
+func TestValuesJson(t *testing.T) {
+	fSys := filesys.MakeFsInMemory()
+	writeKustomization(t, fSys)
+
+	out, err := run(t, fSys, "values", "app", "-o", "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var charts []helmvalues.ChartValues
+	if err := json.Unmarshal([]byte(out), &charts); err != nil {
+		t.Fatalf("%v\n%s", err, out)
+	}
+	if !assert.Len(t, charts, 1) {
+		return
+	}
+	assert.Equal(t, "app", charts[0].ChartName)
+	assert.Equal(t, "app-release", charts[0].ReleaseName)
+	assert.Equal(t, map[string]interface{}{
+		"image":    map[string]interface{}{"repository": "app", "tag": "2.0"},
+		"replicas": float64(1),
+	}, charts[0].Values)
+}
+
+func TestValuesUnsupportedOutput(t *testing.T) {
+	fSys := filesys.MakeFsInMemory()
+	writeKustomization(t, fSys)
+
+	if _, err := run(t, fSys, "values", "app", "-o", "toml"); err == nil {
+		t.Fatal("expected an error for an unsupported output")
+	}
+}
+
+func TestValuesDoesNotLeakIntoOtherBuilds(t *testing.T) {
+	fSys := filesys.MakeFsInMemory()
+	writeKustomization(t, fSys)
+
+	first, err := run(t, fSys, "values", "app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := run(t, fSys, "values", "app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, first, second)
+}
@@ -0,0 +1,121 @@
+// Copyright 2021 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package inventory
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/spf13/cobra"
+	"sigs.k8s.io/kustomize/api/filesys"
+	"sigs.k8s.io/kustomize/api/inventory"
+	"sigs.k8s.io/kustomize/api/resmap"
+	"sigs.k8s.io/kustomize/api/resource"
+)
+
+const (
+	formatText = "text"
+	formatJSON = "json"
+)
+
+// NewCmdInventory makes a new inventory command.
+func NewCmdInventory(fSys filesys.FileSystem, rf *resource.Factory, w io.Writer) *cobra.Command {
+	inventoryCmd := &cobra.Command{
+		Use:   "inventory",
+		Short: "Commands for the inventories of builds",
+		Long: `Commands for the inventories of builds.
+A build emits an inventory ConfigMap when its kustomization has an
+inventory, listing every object of the build with a hash of its content.
+`,
+		Example: `kustomize inventory diff old.yaml new.yaml`,
+	}
+	inventoryCmd.AddCommand(newCmdDiff(fSys, resmap.NewFactory(rf), w))
+	return inventoryCmd
+}
+
+func newCmdDiff(fSys filesys.FileSystem, rmF *resmap.Factory, w io.Writer) *cobra.Command {
+	var format string
+	diffCmd := &cobra.Command{
+		Use:   "diff OLD NEW",
+		Short: "Lists the objects an apply of a build prunes, adds and changes after another",
+		Long: `Lists the objects an apply of the build output NEW prunes, adds and changes
+after the build output OLD. Each output is compared through its inventory
+ConfigMap, or through its objects when it has none.
+`,
+		Example: `kustomize build . > new.yaml && kustomize inventory diff old.yaml new.yaml`,
+		Args:    cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if format != formatText && format != formatJSON {
+				return fmt.Errorf(
+					"illegal flag value --format %s; legal values: %v",
+					format, []string{formatText, formatJSON})
+			}
+			previous, err := load(fSys, rmF, args[0])
+			if err != nil {
+				return err
+			}
+			next, err := load(fSys, rmF, args[1])
+			if err != nil {
+				return err
+			}
+			d := inventory.Compare(previous, next)
+			if format == formatJSON {
+				b, err := json.MarshalIndent(d, "", "  ")
+				if err != nil {
+					return err
+				}
+				_, err = fmt.Fprintln(w, string(b))
+				return err
+			}
+			for _, change := range []struct {
+				action string
+				ids    []inventory.ObjectID
+			}{{"prune", d.Prune}, {"add", d.Add}, {"change", d.Change}} {
+				for _, id := range change.ids {
+					fmt.Fprintf(w, "%-6s %s\n", change.action, describe(id))
+				}
+			}
+			fmt.Fprintf(w, "%d to prune, %d to add, %d to change, %d unchanged\n",
+				len(d.Prune), len(d.Add), len(d.Change), d.Unchanged)
+			return nil
+		},
+	}
+	diffCmd.Flags().StringVar(
+		&format, "format", formatText,
+		"output format: '"+formatText+"' or '"+formatJSON+"'")
+	return diffCmd
+}
+
+// load returns the inventory of the build output in the file at path.
+func load(fSys filesys.FileSystem, rmF *resmap.Factory, path string) (*inventory.Inventory, error) {
+	b, err := fSys.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	m, err := rmF.NewResMapFromBytes(b)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %v", path, err)
+	}
+	inv, err := inventory.Load(m)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %v", path, err)
+	}
+	return inv, nil
+}
+
+// describe returns id as kind.group namespace/name, the way kubectl
+// names objects, without the group of the core ones or the namespace
+// of cluster scoped ones.
+func describe(id inventory.ObjectID) string {
+	s := id.Kind
+	if id.Group != "" {
+		s += "." + id.Group
+	}
+	s += " "
+	if id.Namespace != "" {
+		s += id.Namespace + "/"
+	}
+	return s + id.Name
+}
@@ -0,0 +1,112 @@
+// Copyright 2021 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package inventory
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"sigs.k8s.io/kustomize/api/filesys"
+	"sigs.k8s.io/kustomize/api/krusty"
+	"sigs.k8s.io/kustomize/api/provider"
+)
+
+var factory = provider.NewDefaultDepProvider().GetResourceFactory()
+
+func build(t *testing.T, fSys filesys.FileSystem, resources, output string) {
+	t.Helper()
+	fSys.WriteFile("/app/kustomization.yaml", []byte(`
+namespace: prod
+resources:
+- resources.yaml
+inventory:
+  configMap:
+    name: inventory
+`))
+	fSys.WriteFile("/app/resources.yaml", []byte(resources))
+	m, err := krusty.MakeKustomizer(krusty.MakeDefaultOptions()).Run(fSys, "/app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	yml, err := m.AsYaml()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fSys.WriteFile(output, yml)
+}
+
+func TestDiff(t *testing.T) {
+	fSys := filesys.MakeFsInMemory()
+	build(t, fSys, `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  replicas: 1
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: old
+---
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: prod
+`, "/old.yaml")
+	build(t, fSys, `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  replicas: 3
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: new
+---
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: prod
+`, "/new.yaml")
+	buffy := new(bytes.Buffer)
+	cmd := NewCmdInventory(fSys, factory, buffy)
+	cmd.SetArgs([]string{"diff", "/old.yaml", "/new.yaml"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	expected := `prune  Service prod/old
+add    Service prod/new
+change Deployment.apps prod/web
+1 to prune, 1 to add, 1 to change, 1 unchanged
+`
+	if buffy.String() != expected {
+		t.Fatalf("Expected output:\n%s\nBut got output:\n%s", expected, buffy)
+	}
+}
+
+func TestDiffTamperedInventory(t *testing.T) {
+	fSys := filesys.MakeFsInMemory()
+	build(t, fSys, `
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+`, "/old.yaml")
+	b, _ := fSys.ReadFile("/old.yaml")
+	fSys.WriteFile("/new.yaml", bytes.Replace(b, []byte("prod_web__Service"), []byte("prod_api__Service"), 1))
+	cmd := NewCmdInventory(fSys, factory, new(bytes.Buffer))
+	cmd.SetArgs([]string{"diff", "/old.yaml", "/new.yaml"})
+	cmd.SilenceUsage = true
+	cmd.SilenceErrors = true
+	err := cmd.Execute()
+	if err == nil || !strings.Contains(err.Error(), "reading /new.yaml: inventory") {
+		t.Fatalf("Expected a tampered inventory error, but got %v", err)
+	}
+}